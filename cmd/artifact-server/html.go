@@ -0,0 +1,282 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/apigee/registry/pkg/application/apihub"
+	"github.com/apigee/registry/pkg/application/scoring"
+	"github.com/apigee/registry/pkg/application/style"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	metrics "github.com/google/gnostic/metrics"
+)
+
+// htmlTemplates renders artifacts as HTML pages.
+// Common artifact types have their own templates, and
+// other types are rendered as preformatted YAML.
+var htmlTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"scoreValue": scoreValue,
+	"yaml":       yamlFormat,
+}).Parse(`
+{{define "page"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+pre { background: #f6f6f6; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{template "body" .}}
+</body>
+</html>
+{{end}}
+
+{{define "lint"}}
+{{if .Message.Name}}<p>{{.Message.Name}}</p>{{end}}
+{{range .Message.Files}}
+<h2>{{.FilePath}}</h2>
+{{if .Problems}}
+<table>
+<tr><th>Rule</th><th>Location</th><th>Message</th><th>Suggestion</th></tr>
+{{range .Problems}}
+<tr>
+<td>{{if .RuleDocUri}}<a href="{{.RuleDocUri}}">{{.RuleId}}</a>{{else}}{{.RuleId}}{{end}}</td>
+<td>{{with .Location}}{{with .StartPosition}}{{.LineNumber}}:{{.ColumnNumber}}{{end}}{{end}}</td>
+<td>{{.Message}}</td>
+<td>{{.Suggestion}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}<p>No problems found.</p>{{end}}
+{{end}}
+{{end}}
+
+{{define "lintstats"}}
+<p>{{.Message.OperationCount}} operations, {{.Message.SchemaCount}} schemas</p>
+<table>
+<tr><th>Rule</th><th>Count</th></tr>
+{{range .Message.ProblemCounts}}
+<tr>
+<td>{{if .RuleDocUri}}<a href="{{.RuleDocUri}}">{{.RuleId}}</a>{{else}}{{.RuleId}}{{end}}</td>
+<td>{{.Count}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+
+{{define "scorerow"}}
+<tr>
+<td>{{if .Uri}}<a href="{{.Uri}}">{{or .DisplayName .Id}}</a>{{else}}{{or .DisplayName .Id}}{{end}}</td>
+<td>{{scoreValue .}}</td>
+<td>{{.Severity}}</td>
+<td>{{.Description}}</td>
+</tr>
+{{end}}
+
+{{define "score"}}
+<table>
+<tr><th>Score</th><th>Value</th><th>Severity</th><th>Description</th></tr>
+{{template "scorerow" .Message}}
+</table>
+{{end}}
+
+{{define "scorecard"}}
+{{if .Message.Description}}<p>{{.Message.Description}}</p>{{end}}
+<table>
+<tr><th>Score</th><th>Value</th><th>Severity</th><th>Description</th></tr>
+{{range .Message.Scores}}{{template "scorerow" .}}{{end}}
+</table>
+{{end}}
+
+{{define "references"}}
+{{if .Message.Description}}<p>{{.Message.Description}}</p>{{end}}
+<table>
+<tr><th>Reference</th><th>Category</th><th>Resource</th></tr>
+{{range .Message.References}}
+<tr>
+<td>{{if .Uri}}<a href="{{.Uri}}">{{or .DisplayName .Id}}</a>{{else}}{{or .DisplayName .Id}}{{end}}</td>
+<td>{{.Category}}</td>
+<td>{{.Resource}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+
+{{define "wordcounts"}}
+<table>
+<tr><th>Word</th><th>Count</th></tr>
+{{range .}}<tr><td>{{.Word}}</td><td>{{.Count}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+{{define "vocabulary"}}
+{{with .Message.Schemas}}<h2>Schemas</h2>{{template "wordcounts" .}}{{end}}
+{{with .Message.Properties}}<h2>Properties</h2>{{template "wordcounts" .}}{{end}}
+{{with .Message.Operations}}<h2>Operations</h2>{{template "wordcounts" .}}{{end}}
+{{with .Message.Parameters}}<h2>Parameters</h2>{{template "wordcounts" .}}{{end}}
+{{end}}
+
+{{define "list"}}
+<ul>
+{{range .Message.Items}}<li><a href="/{{.Name}}">{{.Name}}</a>{{with .Description}} ({{.}}){{end}}</li>
+{{else}}<li>None found.</li>
+{{end}}
+</ul>
+{{with .Message.Next}}<p><a href="{{.}}">Next page</a></p>{{end}}
+{{end}}
+
+{{define "files"}}
+<ul>
+{{range .Message}}<li><a href="?file={{.}}">{{.}}</a></li>
+{{end}}
+</ul>
+{{end}}
+
+{{define "message"}}<pre>{{yaml .Message}}</pre>{{end}}
+
+{{define "text"}}<pre>{{.Message}}</pre>{{end}}
+`))
+
+// htmlPage holds the values used to render a page.
+type htmlPage struct {
+	Title   string
+	Message interface{}
+}
+
+// htmlTemplateForMessage returns the name of the template that renders a message.
+func htmlTemplateForMessage(message proto.Message) string {
+	switch message.(type) {
+	case *style.Lint:
+		return "lint"
+	case *style.LintStats:
+		return "lintstats"
+	case *scoring.Score:
+		return "score"
+	case *scoring.ScoreCard:
+		return "scorecard"
+	case *apihub.ReferenceList:
+		return "references"
+	case *metrics.Vocabulary:
+		return "vocabulary"
+	default:
+		return "message"
+	}
+}
+
+// writeHTML renders a page using the named template for its body.
+func writeHTML(w io.Writer, body string, page *htmlPage) error {
+	t, err := htmlTemplates.Clone()
+	if err != nil {
+		return err
+	}
+	if _, err := t.New("body").Parse(`{{template "` + body + `" .}}`); err != nil {
+		return err
+	}
+	// Render the page completely before writing it so that errors can be reported.
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "page", page); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// listItem is a resource shown in a list.
+type listItem struct {
+	Name        string
+	Description string
+}
+
+// listing holds the values used to render a page of a collection.
+type listing struct {
+	Items []listItem
+	Next  string
+}
+
+// listPage returns a page that displays the resources in a List response.
+// Resources are found in the response's repeated message field, and each
+// is described by its display name, MIME type or revision ID.
+func listPage(req *http.Request, name string, response proto.Message) *htmlPage {
+	l := &listing{}
+	m := response.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.Get(i)
+		if f.IsList() && f.Kind() == protoreflect.MessageKind {
+			list := m.Get(f).List()
+			for j := 0; j < list.Len(); j++ {
+				l.Items = append(l.Items, itemForMessage(list.Get(j).Message()))
+			}
+		}
+	}
+	if f := fields.ByName("next_page_token"); f != nil {
+		if token := m.Get(f).String(); token != "" {
+			q := req.URL.Query()
+			q.Set("page_token", token)
+			l.Next = "?" + q.Encode()
+		}
+	}
+	return &htmlPage{Title: name, Message: l}
+}
+
+func itemForMessage(m protoreflect.Message) listItem {
+	value := func(name protoreflect.Name) string {
+		if f := m.Descriptor().Fields().ByName(name); f != nil && f.Kind() == protoreflect.StringKind {
+			return m.Get(f).String()
+		}
+		return ""
+	}
+	item := listItem{Name: value("name")}
+	for _, field := range []protoreflect.Name{"display_name", "mime_type", "revision_id"} {
+		if v := value(field); v != "" {
+			item.Description = v
+			break
+		}
+	}
+	return item
+}
+
+// scoreValue returns a displayable form of a score's value.
+func scoreValue(score *scoring.Score) string {
+	switch v := score.Value.(type) {
+	case *scoring.Score_PercentValue:
+		return fmt.Sprintf("%.0f%%", v.PercentValue.GetValue())
+	case *scoring.Score_IntegerValue:
+		return strconv.Itoa(int(v.IntegerValue.GetValue()))
+	case *scoring.Score_BooleanValue:
+		if v.BooleanValue.GetDisplayValue() != "" {
+			return v.BooleanValue.GetDisplayValue()
+		}
+		return strconv.FormatBool(v.BooleanValue.GetValue())
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// format identifies a rendering of a message.
+type format int
+
+const (
+	formatJSON format = iota
+	formatYAML
+	formatText
+	formatHTML
+)
+
+// mediaTypes lists the media types that can be returned, in order of preference.
+// The text/json and text/yaml types are accepted for compatibility with earlier versions.
+var mediaTypes = []struct {
+	mediaType string
+	format    format
+}{
+	{"application/json", formatJSON},
+	{"application/yaml", formatYAML},
+	{"text/html", formatHTML},
+	{"text/plain", formatText},
+	{"application/x-yaml", formatYAML},
+	{"text/json", formatJSON},
+	{"text/yaml", formatYAML},
+}
+
+// mediaRange is a single entry in an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// specificity returns how closely a range matches a media type, or -1 if it doesn't.
+func (r mediaRange) specificity(mediaType string) int {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	switch {
+	case r.typ == typ && r.subtype == subtype:
+		return 2
+	case r.typ == typ && r.subtype == "*":
+		return 1
+	case r.typ == "*" && r.subtype == "*":
+		return 0
+	default:
+		return -1
+	}
+}
+
+func parseAccept(accept []string) []mediaRange {
+	var ranges []mediaRange
+	for _, header := range accept {
+		for _, entry := range strings.Split(header, ",") {
+			params := strings.Split(entry, ";")
+			typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+			if !ok {
+				continue
+			}
+			r := mediaRange{typ: typ, subtype: subtype, q: 1}
+			for _, param := range params[1:] {
+				k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(k, "q") {
+					if q, err := strconv.ParseFloat(v, 64); err == nil {
+						r.q = q
+					}
+				}
+			}
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// negotiateFormat selects the media type and format of a response from
+// the values of a request's Accept headers. JSON is returned by default.
+func negotiateFormat(accept []string) (string, format, error) {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return mediaTypes[0].mediaType, mediaTypes[0].format, nil
+	}
+	best, bestQ := -1, 0.0
+	for i, t := range mediaTypes {
+		// The quality of a media type is set by the most specific range that matches it.
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			if s := r.specificity(t.mediaType); s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	if best < 0 {
+		return "", 0, fmt.Errorf("none of the accepted types (%s) are supported", strings.Join(accept, ", "))
+	}
+	return mediaTypes[best].mediaType, mediaTypes[best].format, nil
+}
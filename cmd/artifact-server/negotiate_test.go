@@ -0,0 +1,112 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/apigee/registry/pkg/application/scoring"
+	"github.com/apigee/registry/pkg/application/style"
+	"github.com/apigee/registry/pkg/mime"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept    []string
+		mediaType string
+		format    format
+	}{
+		{nil, "application/json", formatJSON},
+		{[]string{"*/*"}, "application/json", formatJSON},
+		{[]string{"application/json"}, "application/json", formatJSON},
+		{[]string{"application/yaml"}, "application/yaml", formatYAML},
+		{[]string{"text/yaml"}, "text/yaml", formatYAML},
+		{[]string{"text/json"}, "text/json", formatJSON},
+		{[]string{"text/plain"}, "text/plain", formatText},
+		{[]string{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}, "text/html", formatHTML},
+		{[]string{"application/json;q=0.5, application/yaml"}, "application/yaml", formatYAML},
+		{[]string{"application/*;q=0.5", "text/plain;q=0.1"}, "application/json", formatJSON},
+		{[]string{"*/*", "application/json;q=0"}, "application/yaml", formatYAML},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.accept, "|"), func(t *testing.T) {
+			mediaType, format, err := negotiateFormat(test.accept)
+			if err != nil {
+				t.Fatalf("negotiateFormat(%v) returned error: %s", test.accept, err)
+			}
+			if mediaType != test.mediaType || format != test.format {
+				t.Errorf("negotiateFormat(%v) returned %s (%d), want %s (%d)",
+					test.accept, mediaType, format, test.mediaType, test.format)
+			}
+		})
+	}
+}
+
+func TestNegotiateUnsupportedFormat(t *testing.T) {
+	if _, _, err := negotiateFormat([]string{"image/png"}); err == nil {
+		t.Errorf("negotiateFormat() succeeded for an unsupported type")
+	}
+}
+
+func TestUnmarshalArtifact(t *testing.T) {
+	score := &scoring.Score{
+		Id:    "lint-errors",
+		Value: &scoring.Score_IntegerValue{IntegerValue: &scoring.IntegerValue{Value: 3}},
+	}
+	b, err := proto.Marshal(score)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := unmarshalArtifact(b, mime.MimeTypeForMessageType("google.cloud.apigeeregistry.v1.scoring.Score"))
+	if err != nil {
+		t.Fatalf("unmarshalArtifact() returned error: %s", err)
+	}
+	if !proto.Equal(message, score) {
+		t.Errorf("unmarshalArtifact() returned %v, want %v", message, score)
+	}
+	if _, err := unmarshalArtifact(b, mime.MimeTypeForMessageType("unknown.Type")); err == nil {
+		t.Errorf("unmarshalArtifact() succeeded for an unknown type")
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	lint := &style.Lint{
+		Name: "spectral",
+		Files: []*style.LintFile{{
+			FilePath: "openapi.yaml",
+			Problems: []*style.LintProblem{{
+				RuleId:     "info-contact",
+				RuleDocUri: "https://example.com/info-contact",
+				Message:    "Info object must have <contact> object.",
+			}},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := writeHTML(&buf, htmlTemplateForMessage(lint), &htmlPage{Title: "lint-spectral", Message: lint}); err != nil {
+		t.Fatalf("writeHTML() returned error: %s", err)
+	}
+	for _, want := range []string{
+		"<title>lint-spectral</title>",
+		`<a href="https://example.com/info-contact">info-contact</a>`,
+		"Info object must have &lt;contact&gt; object.",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("writeHTML() output is missing %q", want)
+		}
+	}
+}
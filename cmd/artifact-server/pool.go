@@ -0,0 +1,58 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/apigee/registry/pkg/connection"
+)
+
+// clientPool shares a fixed set of registry clients among all requests.
+// Each client holds its own connection, and clients are used in turn.
+type clientPool struct {
+	clients []connection.RegistryClient
+	next    uint32
+}
+
+func newClientPool(ctx context.Context, size int) (*clientPool, error) {
+	if size < 1 {
+		size = 1
+	}
+	pool := &clientPool{}
+	for i := 0; i < size; i++ {
+		client, err := connection.NewRegistryClient(ctx)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.clients = append(pool.clients, client)
+	}
+	return pool, nil
+}
+
+// get returns the next client in the pool.
+func (p *clientPool) get() connection.RegistryClient {
+	n := atomic.AddUint32(&p.next, 1)
+	return p.clients[int(n)%len(p.clients)]
+}
+
+// Close closes all of the clients in the pool.
+func (p *clientPool) Close() {
+	for _, client := range p.clients {
+		client.Close()
+	}
+}
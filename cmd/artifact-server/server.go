@@ -0,0 +1,381 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apigee/registry/cmd/registry/compress"
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/pkg/names"
+	"github.com/apigee/registry/pkg/visitor"
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// server is a read-only HTTP gateway to a registry.
+// Resource paths are registry resource names.
+type server struct {
+	clients *clientPool
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, fmt.Errorf("method %s is not supported", req.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.Trim(req.URL.Path, "/")
+	client := s.clients.get()
+	if collection, err := names.ParseResourceCollection(name); err == nil {
+		handleList(w, req, client, name, collection)
+		return
+	}
+	entity, err := names.ParseResourceEntity(name)
+	if err != nil {
+		writeError(w, err, http.StatusNotFound)
+		return
+	}
+	switch entity.(type) {
+	case names.Artifact:
+		handleArtifact(w, req, client, name)
+	case names.Spec, names.SpecRevision:
+		handleSpec(w, req, client, name)
+	case names.Api, names.Version, names.Deployment, names.DeploymentRevision:
+		handleResource(w, req, client, name, entity)
+	default:
+		writeError(w, fmt.Errorf("unsupported resource: %s", name), http.StatusNotFound)
+	}
+}
+
+// negotiate selects the format of a response, writing an error if none is acceptable.
+func negotiate(w http.ResponseWriter, req *http.Request) (string, format, bool) {
+	w.Header().Add("Vary", "Accept")
+	mediaType, format, err := negotiateFormat(req.Header.Values("Accept"))
+	if err != nil {
+		writeError(w, err, http.StatusNotAcceptable)
+		return "", 0, false
+	}
+	return mediaType, format, true
+}
+
+// handleList writes a page of a collection.
+// Pages are selected with the page_size and page_token query parameters,
+// and collections can be filtered and ordered with filter and order_by.
+func handleList(w http.ResponseWriter, req *http.Request, client connection.RegistryClient, name string, collection names.Name) {
+	mediaType, format, ok := negotiate(w, req)
+	if !ok {
+		return
+	}
+	q := req.URL.Query()
+	pageSize, err := strconv.ParseInt(q.Get("page_size"), 10, 32)
+	if err != nil && q.Get("page_size") != "" {
+		writeError(w, fmt.Errorf("invalid page_size: %s", q.Get("page_size")), http.StatusBadRequest)
+		return
+	}
+	pageToken, filter, orderBy := q.Get("page_token"), q.Get("filter"), q.Get("order_by")
+	parent := path.Dir(name)
+	ctx := req.Context()
+	g := client.GrpcClient()
+	var response proto.Message
+	switch collection.(type) {
+	case names.Api:
+		response, err = g.ListApis(ctx, &rpc.ListApisRequest{
+			Parent: parent, PageSize: int32(pageSize), PageToken: pageToken, Filter: filter, OrderBy: orderBy,
+		})
+	case names.Version:
+		response, err = g.ListApiVersions(ctx, &rpc.ListApiVersionsRequest{
+			Parent: parent, PageSize: int32(pageSize), PageToken: pageToken, Filter: filter, OrderBy: orderBy,
+		})
+	case names.Spec:
+		response, err = g.ListApiSpecs(ctx, &rpc.ListApiSpecsRequest{
+			Parent: parent, PageSize: int32(pageSize), PageToken: pageToken, Filter: filter, OrderBy: orderBy,
+		})
+	case names.SpecRevision:
+		response, err = g.ListApiSpecRevisions(ctx, &rpc.ListApiSpecRevisionsRequest{
+			Name: name, PageSize: int32(pageSize), PageToken: pageToken, Filter: filter,
+		})
+	case names.Deployment:
+		response, err = g.ListApiDeployments(ctx, &rpc.ListApiDeploymentsRequest{
+			Parent: parent, PageSize: int32(pageSize), PageToken: pageToken, Filter: filter, OrderBy: orderBy,
+		})
+	case names.DeploymentRevision:
+		response, err = g.ListApiDeploymentRevisions(ctx, &rpc.ListApiDeploymentRevisionsRequest{
+			Name: name, PageSize: int32(pageSize), PageToken: pageToken, Filter: filter,
+		})
+	case names.Artifact:
+		response, err = g.ListArtifacts(ctx, &rpc.ListArtifactsRequest{
+			Parent: parent, PageSize: int32(pageSize), PageToken: pageToken, Filter: filter, OrderBy: orderBy,
+		})
+	default:
+		writeError(w, fmt.Errorf("unsupported collection: %s", name), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	if format == formatHTML {
+		w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+		if err := writeHTML(w, "list", listPage(req, name, response)); err != nil {
+			writeError(w, err, http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := writeMessage(w, name, mediaType, format, response); err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// handleResource writes the metadata of a resource.
+func handleResource(w http.ResponseWriter, req *http.Request, client connection.RegistryClient, name string, entity names.Name) {
+	mediaType, format, ok := negotiate(w, req)
+	if !ok {
+		return
+	}
+	ctx := req.Context()
+	var message proto.Message
+	var version string
+	var err error
+	switch entity.(type) {
+	case names.Api:
+		var api *rpc.Api
+		api, err = client.GetApi(ctx, &rpc.GetApiRequest{Name: name})
+		message, version = api, timestampVersion(api.GetUpdateTime())
+	case names.Version:
+		var v *rpc.ApiVersion
+		v, err = client.GetApiVersion(ctx, &rpc.GetApiVersionRequest{Name: name})
+		message, version = v, timestampVersion(v.GetUpdateTime())
+	case names.Deployment, names.DeploymentRevision:
+		var deployment *rpc.ApiDeployment
+		deployment, err = client.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: name})
+		message, version = deployment, deployment.GetRevisionId()
+	}
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	if notModified(w, req, entityTag(version, mediaType)) {
+		return
+	}
+	if err := writeMessage(w, name, mediaType, format, message); err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// handleArtifact writes the contents of an artifact.
+func handleArtifact(w http.ResponseWriter, req *http.Request, client connection.RegistryClient, name string) {
+	mediaType, format, ok := negotiate(w, req)
+	if !ok {
+		return
+	}
+	ctx := req.Context()
+	artifact, err := client.GetArtifact(ctx, &rpc.GetArtifactRequest{Name: name})
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	if notModified(w, req, entityTag(timestampVersion(artifact.GetUpdateTime()), mediaType)) {
+		return
+	}
+	if err := visitor.FetchArtifactContents(ctx, client, artifact); err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	if mime.IsPrintableType(artifact.GetMimeType()) {
+		if format == formatHTML {
+			w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+			page := &htmlPage{Title: name, Message: string(artifact.GetContents())}
+			if err := writeHTML(w, "text", page); err != nil {
+				writeError(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+		// Printable contents are returned unchanged.
+		w.Header().Set("Content-Type", artifact.GetMimeType())
+		fmt.Fprintf(w, "%s\n", artifact.GetContents())
+		return
+	}
+	message, err := unmarshalArtifact(artifact.GetContents(), artifact.GetMimeType())
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := writeMessage(w, name, mediaType, format, message); err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// handleSpec writes the contents of a spec.
+// Compressed specs are decompressed. Files in zip archives are selected
+// with the file query parameter, and archives that contain more than one
+// file are otherwise described by a list of their files.
+func handleSpec(w http.ResponseWriter, req *http.Request, client connection.RegistryClient, name string) {
+	ctx := req.Context()
+	spec, err := client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: name})
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	if !mime.IsZipArchive(spec.GetMimeType()) || req.URL.Query().Get("file") != "" {
+		// These responses don't depend on the Accept header.
+		if notModified(w, req, entityTag(spec.GetRevisionId(), "")) {
+			return
+		}
+	}
+	if err := visitor.FetchSpecContents(ctx, client, spec); err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	if !mime.IsZipArchive(spec.GetMimeType()) {
+		writeContents(w, spec.GetContents())
+		return
+	}
+	files, err := compress.UnzipArchiveToMap(spec.GetContents())
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if filename := req.URL.Query().Get("file"); filename != "" {
+		contents, ok := files[filename]
+		if !ok {
+			writeError(w, fmt.Errorf("%s not found in %s", filename, name), http.StatusNotFound)
+			return
+		}
+		writeContents(w, contents)
+		return
+	}
+	if len(files) == 1 {
+		if notModified(w, req, entityTag(spec.GetRevisionId(), "")) {
+			return
+		}
+		for _, contents := range files {
+			writeContents(w, contents)
+		}
+		return
+	}
+	mediaType, format, ok := negotiate(w, req)
+	if !ok {
+		return
+	}
+	if notModified(w, req, entityTag(spec.GetRevisionId(), mediaType)) {
+		return
+	}
+	filenames := make([]string, 0, len(files))
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	if format == formatHTML {
+		w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+		if err := writeHTML(w, "files", &htmlPage{Title: name, Message: filenames}); err != nil {
+			writeError(w, err, http.StatusInternalServerError)
+		}
+		return
+	}
+	values := make([]interface{}, len(filenames))
+	for i, filename := range filenames {
+		values[i] = filename
+	}
+	listing, err := structpb.NewStruct(map[string]interface{}{
+		"name":  spec.GetName(),
+		"files": values,
+	})
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := writeMessage(w, name, mediaType, format, listing); err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// writeContents writes spec contents, which are displayed as text when possible.
+func writeContents(w http.ResponseWriter, contents []byte) {
+	if utf8.Valid(contents) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	_, _ = w.Write(contents)
+}
+
+// timestampVersion identifies a version of a resource that has no revisions.
+func timestampVersion(t *timestamppb.Timestamp) string {
+	if t == nil {
+		return ""
+	}
+	return strconv.FormatInt(t.AsTime().UnixNano(), 36)
+}
+
+// entityTag returns an ETag for a version of a resource in the specified media type.
+func entityTag(version, mediaType string) string {
+	if version == "" {
+		return ""
+	}
+	if mediaType == "" {
+		return `"` + version + `"`
+	}
+	return `"` + version + "-" + strings.ReplaceAll(mediaType, "/", "-") + `"`
+}
+
+// notModified sets the ETag of a response and returns true
+// if it matches the request, in which case nothing more should be written.
+func notModified(w http.ResponseWriter, req *http.Request, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, header := range req.Header.Values("If-None-Match") {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeStatusError writes an error returned by the registry with a corresponding HTTP status.
+func writeStatusError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch status.Code(err) {
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	}
+	writeError(w, err, code)
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"testing"
+
+	"github.com/apigee/registry/cmd/registry/compress"
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry"
+	"github.com/apigee/registry/server/registry/test/seeder"
+)
+
+func TestMain(m *testing.M) {
+	grpctest.TestMain(m, registry.Config{})
+}
+
+const openapi = `openapi: 3.0.0
+info:
+  title: Test
+  version: 1.0.0
+paths: {}
+`
+
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ctx := context.Background()
+	gzipped, err := compress.GZippedBytes([]byte(openapi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpctest.SetupRegistry(ctx, t, "artifact-server-test", []seeder.RegistryResource{
+		&rpc.ApiSpec{
+			Name:     "projects/artifact-server-test/locations/global/apis/a/versions/v/specs/openapi.yaml",
+			MimeType: "application/x.openapi+gzip;version=3",
+			Contents: gzipped,
+		},
+		&rpc.ApiVersion{
+			Name: "projects/artifact-server-test/locations/global/apis/b/versions/v",
+		},
+		&rpc.ApiVersion{
+			Name: "projects/artifact-server-test/locations/global/apis/c/versions/v",
+		},
+		&rpc.Artifact{
+			Name:     "projects/artifact-server-test/locations/global/artifacts/notes",
+			MimeType: "text/plain",
+			Contents: []byte("hello"),
+		},
+	})
+	clients, err := newClientPool(ctx, 2)
+	if err != nil {
+		t.Fatalf("Setup: failed to create clients: %s", err)
+	}
+	t.Cleanup(clients.Close)
+	s := httptest.NewServer(&server{clients: clients})
+	t.Cleanup(s.Close)
+	return s
+}
+
+func get(t *testing.T, url string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func TestSpecContents(t *testing.T) {
+	s := testServer(t)
+	url := s.URL + "/projects/artifact-server-test/locations/global/apis/a/versions/v/specs/openapi.yaml"
+	resp, body := get(t, url, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s returned %d: %s", url, resp.StatusCode, body)
+	}
+	if body != openapi {
+		t.Errorf("GET %s returned %q, want %q", url, body, openapi)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("GET %s returned no ETag", url)
+	}
+	resp, _ = get(t, url, map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("GET %s with If-None-Match returned %d, want %d", url, resp.StatusCode, http.StatusNotModified)
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	s := testServer(t)
+	url := s.URL + "/projects/artifact-server-test/locations/global/apis?page_size=2&order_by=name"
+	resp, body := get(t, url, map[string]string{"Accept": "application/json"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s returned %d: %s", url, resp.StatusCode, body)
+	}
+	var page struct {
+		Apis []struct {
+			Name string `json:"name"`
+		} `json:"apis"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+	if err := json.Unmarshal([]byte(body), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Apis) != 2 || page.NextPageToken == "" {
+		t.Fatalf("GET %s returned %d APIs and token %q", url, len(page.Apis), page.NextPageToken)
+	}
+	url = url + "&page_token=" + neturl.QueryEscape(page.NextPageToken)
+	_, body = get(t, url, map[string]string{"Accept": "application/json"})
+	page.Apis, page.NextPageToken = nil, ""
+	if err := json.Unmarshal([]byte(body), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Apis) != 1 || page.Apis[0].Name != "projects/artifact-server-test/locations/global/apis/c" {
+		t.Errorf("GET %s returned %+v", url, page.Apis)
+	}
+}
+
+func TestResources(t *testing.T) {
+	s := testServer(t)
+	tests := []struct {
+		path   string
+		accept string
+		status int
+	}{
+		{"/projects/artifact-server-test/locations/global/apis/a", "application/yaml", http.StatusOK},
+		{"/projects/artifact-server-test/locations/global/apis/a/versions/v", "application/json", http.StatusOK},
+		{"/projects/artifact-server-test/locations/global/apis/a/versions/v/specs", "text/html", http.StatusOK},
+		{"/projects/artifact-server-test/locations/global/artifacts/notes", "*/*", http.StatusOK},
+		{"/projects/artifact-server-test/locations/global/artifacts/notes", "image/png", http.StatusNotAcceptable},
+		{"/projects/artifact-server-test/locations/global/apis/missing", "application/json", http.StatusNotFound},
+		{"/not/a/resource", "application/json", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			resp, body := get(t, s.URL+test.path, map[string]string{"Accept": test.accept})
+			if resp.StatusCode != test.status {
+				t.Errorf("GET %s returned %d, want %d: %s", test.path, resp.StatusCode, test.status, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/apigee/registry-experimental/pkg/artifacttypes"
+	"github.com/apigee/registry/cmd/registry/compress"
+	"github.com/apigee/registry/cmd/registry/patch"
+	"github.com/apigee/registry/pkg/mime"
+	"google.golang.org/protobuf/proto"
+)
+
+// unmarshalArtifact decodes artifact contents with a message type
+// specified in their MIME type, decompressing them if necessary.
+func unmarshalArtifact(data []byte, mimeType string) (proto.Message, error) {
+	messageType, err := mime.MessageTypeForMimeType(mimeType)
+	if err != nil {
+		return nil, err
+	}
+	message, err := artifacttypes.NewMessage(messageType)
+	if err != nil {
+		return nil, err
+	}
+	if mime.IsGZipCompressed(mimeType) {
+		data, err = compress.GUnzippedBytes(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := patch.UnmarshalContents(data, mimeType, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
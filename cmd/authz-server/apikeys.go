@@ -0,0 +1,73 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// defaultAPIKeyHeader is the header that carries API keys if none is configured.
+const defaultAPIKeyHeader = "x-api-key"
+
+// apiKeys maps hex-encoded SHA-256 hashes of API keys to principals.
+var apiKeys map[string]string
+
+// loadAPIKeys reads a YAML map of API key hashes and corresponding principals.
+// Hashes are hex-encoded SHA-256 digests, optionally prefixed with "sha256:".
+func loadAPIKeys(filename string) (map[string]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]string
+	if err := yaml.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string, len(entries))
+	for hash, principal := range entries {
+		hash = strings.ToLower(strings.TrimPrefix(hash, "sha256:"))
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+			return nil, fmt.Errorf("invalid API key hash %q for %s", hash, principal)
+		}
+		keys[hash] = principal
+	}
+	return keys, nil
+}
+
+// hashAPIKey returns the hex-encoded SHA-256 hash of an API key.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyHeader returns the (lowercased) name of the header that carries API keys.
+func apiKeyHeader() string {
+	if config.APIKeyHeader == "" {
+		return defaultAPIKeyHeader
+	}
+	return strings.ToLower(config.APIKeyHeader)
+}
+
+// getAPIKeyPrincipal returns the principal associated with an API key.
+func getAPIKeyPrincipal(key string) (string, bool) {
+	principal, ok := apiKeys[hashAPIKey(key)]
+	return principal, ok
+}
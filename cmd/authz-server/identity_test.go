@@ -0,0 +1,212 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+)
+
+func checkRequest(headers map[string]string, certificate string) *auth.CheckRequest {
+	return &auth.CheckRequest{
+		Attributes: &auth.AttributeContext{
+			Source: &auth.AttributeContext_Peer{
+				Certificate: certificate,
+			},
+			Request: &auth.AttributeContext_Request{
+				Http: &auth.AttributeContext_HttpRequest{
+					Headers: headers,
+				},
+			},
+		},
+	}
+}
+
+// testCertificate returns a certificate in the URL-encoded PEM format used by Envoy.
+func testCertificate(t *testing.T, spiffeID string) string {
+	t.Helper()
+	return url.PathEscape(string(testCertificatePEM(t, spiffeID)))
+}
+
+func testCertificatePEM(t *testing.T, spiffeID string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := url.Parse(spiffeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		DNSNames:     []string{"test.example.com"},
+		URIs:         []*url.URL{uri},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestPeerCertificateWithPlus(t *testing.T) {
+	// Keys are random, so make certificates until one has a "+" in its base64 body.
+	var b []byte
+	for i := 0; i < 100 && !strings.Contains(string(b), "+"); i++ {
+		b = testCertificatePEM(t, "spiffe://cluster.local/ns/default/sa/reader")
+	}
+	if !strings.Contains(string(b), "+") {
+		t.Fatal("no certificate with a \"+\" was generated")
+	}
+	encoded := url.PathEscape(string(b))
+	if !strings.Contains(encoded, "+") {
+		t.Fatalf("encoded certificate doesn't contain a \"+\": %s", encoded)
+	}
+	cert, err := parsePeerCertificate(encoded)
+	if err != nil {
+		t.Fatalf("parsePeerCertificate() returned error: %s", err)
+	}
+	if got := certificatePrincipal(cert); got != "spiffe://cluster.local/ns/default/sa/reader" {
+		t.Errorf("certificatePrincipal() returned %q", got)
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "keys.yaml")
+	contents := "sha256:" + hashAPIKey("ci-key") + ": ci@example.com\n"
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadAPIKeys(filename)
+	if err != nil {
+		t.Fatalf("loadAPIKeys() returned error: %s", err)
+	}
+	if keys[hashAPIKey("ci-key")] != "ci@example.com" {
+		t.Errorf("loadAPIKeys() returned %v", keys)
+	}
+
+	if err := os.WriteFile(filename, []byte("not-a-hash: ci@example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadAPIKeys(filename); err == nil {
+		t.Errorf("loadAPIKeys() succeeded with an invalid hash")
+	}
+}
+
+func TestAlternateIdentities(t *testing.T) {
+	config = AuthzConfig{
+		Readers:               []string{"ci@example.com", "spiffe://cluster.local/ns/*/sa/reader"},
+		Writers:               []string{"ci@example.com"},
+		TrustPeerCertificates: true,
+	}
+	apiKeys = map[string]string{hashAPIKey("ci-key"): "ci@example.com"}
+	defer func() {
+		config = AuthzConfig{}
+		apiKeys = nil
+	}()
+
+	tests := []struct {
+		desc        string
+		headers     map[string]string
+		certificate string
+		want        rpc.Code
+	}{
+		{
+			desc:    "valid api key",
+			headers: map[string]string{":path": "/CreateApi", "x-api-key": "ci-key"},
+			want:    rpc.OK,
+		},
+		{
+			desc:    "unknown api key",
+			headers: map[string]string{":path": "/GetApi", "x-api-key": "other-key"},
+			want:    rpc.UNAUTHENTICATED,
+		},
+		{
+			desc:        "certificate reader",
+			headers:     map[string]string{":path": "/GetApi"},
+			certificate: testCertificate(t, "spiffe://cluster.local/ns/default/sa/reader"),
+			want:        rpc.OK,
+		},
+		{
+			desc:        "certificate reader writing",
+			headers:     map[string]string{":path": "/CreateApi"},
+			certificate: testCertificate(t, "spiffe://cluster.local/ns/default/sa/reader"),
+			want:        rpc.PERMISSION_DENIED,
+		},
+		{
+			desc:        "unknown certificate",
+			headers:     map[string]string{":path": "/GetApi"},
+			certificate: testCertificate(t, "spiffe://cluster.local/ns/default/sa/other"),
+			want:        rpc.PERMISSION_DENIED,
+		},
+		{
+			desc:    "no credentials",
+			headers: map[string]string{":path": "/GetApi"},
+			want:    rpc.UNAUTHENTICATED,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			s := &authorizationServer{}
+			resp, err := s.check(context.Background(), checkRequest(test.headers, test.certificate))
+			if err != nil {
+				t.Fatalf("check() returned error: %s", err)
+			}
+			if got := rpc.Code(resp.Status.Code); got != test.want {
+				t.Errorf("check() returned %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRedactedHeaders(t *testing.T) {
+	headers := map[string]string{
+		":path":         "/GetApi",
+		"authorization": "Bearer secret",
+		"x-api-key":     "ci-key",
+	}
+	redacted := redactedHeaders(headers)
+	if redacted[":path"] != "/GetApi" {
+		t.Errorf("redactedHeaders() changed :path to %q", redacted[":path"])
+	}
+	for _, k := range []string{"authorization", "x-api-key"} {
+		if redacted[k] != "REDACTED" {
+			t.Errorf("redactedHeaders() didn't redact %s", k)
+		}
+	}
+	if headers["x-api-key"] != "ci-key" {
+		t.Errorf("redactedHeaders() modified its argument")
+	}
+}
@@ -26,10 +26,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gogo/googleapis/google/rpc"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -44,8 +47,9 @@ import (
 )
 
 var (
-	portFlag   = flag.String("p", ":50051", "port")
-	configFlag = flag.String("c", "", "configuration file")
+	portFlag    = flag.String("p", ":50051", "port")
+	configFlag  = flag.String("c", "", "configuration file")
+	metricsFlag = flag.String("m", "", "address for serving Prometheus metrics (disabled if empty)")
 )
 
 // AuthzConfig configures the authz filter.
@@ -56,6 +60,14 @@ type AuthzConfig struct {
 	Writers   []string `json:"writers" yaml:"writers"`
 	// hard-coded tokens and corresponding user ids (for testing only)
 	Tokens map[string]string `json:"tokens" yaml:"tokens"`
+	// file containing hashed API keys and corresponding principals
+	APIKeys string `json:"apiKeys" yaml:"apiKeys"`
+	// header that carries API keys (defaults to "x-api-key")
+	APIKeyHeader string `json:"apiKeyHeader" yaml:"apiKeyHeader"`
+	// identify callers with the client certificates they present to Envoy
+	TrustPeerCertificates bool `json:"trustPeerCertificates" yaml:"trustPeerCertificates"`
+	// optional limits on request rates
+	RateLimits *RateLimitConfig `json:"rateLimits" yaml:"rateLimits"`
 }
 
 var config AuthzConfig
@@ -78,23 +90,39 @@ type authorizationServer struct{}
 func (a *authorizationServer) Check(ctx context.Context, req *auth.CheckRequest) (*auth.CheckResponse, error) {
 	log.Println(">>> Authorization called check()")
 	response, err := a.check(ctx, req)
+	method := methodName(req.GetAttributes().GetRequest().GetHttp().GetHeaders()[":path"])
 	if err == nil {
 		log.Printf(">>> Authorization response %+v", response)
+		checkedRequests.WithLabelValues(method, rpc.Code(response.Status.Code).String()).Inc()
 	} else {
 		log.Printf(">>> Authorization error %s", err.Error())
+		checkedRequests.WithLabelValues(method, "ERROR").Inc()
 	}
 	return response, err
 }
 
 func (a *authorizationServer) check(ctx context.Context, req *auth.CheckRequest) (*auth.CheckResponse, error) {
-	b, err := json.MarshalIndent(req.Attributes.Request.Http.Headers, "", "  ")
+	b, err := json.MarshalIndent(redactedHeaders(req.Attributes.Request.Http.Headers), "", "  ")
 	if err == nil {
 		log.Println("Inbound Headers: " + string(b))
 	}
 
 	authHeader, ok := req.Attributes.Request.Http.Headers["authorization"]
 	if !ok {
-		// there's no auth header, so the request is uncredentialed.
+		// API keys are passed in a separate header.
+		if key, ok := req.Attributes.Request.Http.Headers[apiKeyHeader()]; ok {
+			if principal, ok := getAPIKeyPrincipal(key); ok {
+				return allowOrDenyUser(principal, req)
+			}
+			return denyUnauthenticatedUser(), nil
+		}
+		// mTLS clients are identified by their certificates.
+		if config.TrustPeerCertificates {
+			if principal := getPeerPrincipal(req); principal != "" {
+				return allowOrDenyUser(principal, req)
+			}
+		}
+		// there's no credential, so the request is uncredentialed.
 		if config.Anonymous {
 			return allowOrDenyUser("anonymous", req)
 		}
@@ -148,23 +176,47 @@ func (a *authorizationServer) check(ctx context.Context, req *auth.CheckRequest)
 	return denyUnauthenticatedUser(), nil
 }
 
+// redactedHeaders returns a copy of request headers without credential values,
+// which must not be written to logs.
+func redactedHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if k == "authorization" || k == apiKeyHeader() {
+			v = "REDACTED"
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
 func allowOrDenyUser(email string, req *auth.CheckRequest) (*auth.CheckResponse, error) {
 	if isReadOnlyMethod(
 		req.Attributes.Request.Http.Headers[":path"],
 		req.Attributes.Request.Http.Headers[":method"],
 	) {
 		if isReader(email) {
-			return allowAuthorizedUser(email), nil
+			return allowOrLimitUser(email, req), nil
 		}
 	} else {
 		if isWriter(email) {
-			return allowAuthorizedUser(email), nil
+			return allowOrLimitUser(email, req), nil
 		}
 	}
 	// we have a user, but they aren't authorized to do this.
 	return denyUnauthorizedUser(), nil
 }
 
+// allowOrLimitUser allows an authorized user unless they have exceeded a rate limit.
+func allowOrLimitUser(email string, req *auth.CheckRequest) *auth.CheckResponse {
+	if limiter != nil {
+		method := methodName(req.Attributes.Request.Http.Headers[":path"])
+		if ok, retryAfter := limiter.allow(email, method, time.Now()); !ok {
+			return denyRateLimitedUser(retryAfter)
+		}
+	}
+	return allowAuthorizedUser(email)
+}
+
 // isReadOnlyMethod recognizes Get and List operations as immutable.
 func isReadOnlyMethod(path, method string) bool {
 	// assume that "GET" is for http requests to read-only methods.
@@ -392,6 +444,33 @@ func denyUnauthorizedUser() *auth.CheckResponse {
 	}
 }
 
+func denyRateLimitedUser(retryAfter time.Duration) *auth.CheckResponse {
+	var headers []*core.HeaderValueOption
+	if retryAfter > 0 {
+		seconds := int64((retryAfter + time.Second - 1) / time.Second)
+		headers = append(headers, &core.HeaderValueOption{
+			Header: &core.HeaderValue{
+				Key:   "retry-after",
+				Value: strconv.FormatInt(seconds, 10),
+			},
+		})
+	}
+	return &auth.CheckResponse{
+		Status: &rpcstatus.Status{
+			Code: int32(rpc.RESOURCE_EXHAUSTED),
+		},
+		HttpResponse: &auth.CheckResponse_DeniedResponse{
+			DeniedResponse: &auth.DeniedHttpResponse{
+				Status: &envoy_type.HttpStatus{
+					Code: envoy_type.StatusCode_TooManyRequests,
+				},
+				Headers: headers,
+				Body:    "Rate limit exceeded",
+			},
+		},
+	}
+}
+
 func denyUnauthenticatedUser() *auth.CheckResponse {
 	return &auth.CheckResponse{
 		Status: &rpcstatus.Status{
@@ -458,6 +537,19 @@ func main() {
 		config.Writers = []string{"*"}
 	}
 
+	if config.APIKeys != "" {
+		var err error
+		apiKeys, err = loadAPIKeys(config.APIKeys)
+		if err != nil {
+			log.Fatalf("Failed to load API keys: %s", err)
+		}
+		log.Printf("loaded %d API keys", len(apiKeys))
+	}
+
+	if config.RateLimits != nil {
+		limiter = newRateLimiter(config.RateLimits)
+	}
+
 	// marshal and print current configuration for logging
 	configJSON, _ := json.Marshal(config)
 	log.Printf("authz-server %s", configJSON)
@@ -472,6 +564,17 @@ func main() {
 	auth.RegisterAuthorizationServer(s, &authorizationServer{})
 	healthpb.RegisterHealthServer(s, &healthServer{})
 
+	if *metricsFlag != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			log.Printf("authz-server serving metrics on %s", *metricsFlag)
+			if err := http.ListenAndServe(*metricsFlag, mux); err != nil {
+				log.Fatalf("failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
 	log.Printf("authz-server listening on %s", *portFlag)
 	_ = s.Serve(lis)
 }
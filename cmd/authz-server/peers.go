@@ -0,0 +1,76 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"log"
+	"net/url"
+
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+)
+
+var errNoCertificate = errors.New("no PEM certificate found")
+
+// getPeerPrincipal returns the identity of a client that authenticated
+// to Envoy with mTLS. When Envoy forwards the client certificate, its
+// SPIFFE ID is preferred, followed by other URI SANs, DNS SANs and the
+// subject common name. Otherwise the principal computed by Envoy is used.
+func getPeerPrincipal(req *auth.CheckRequest) string {
+	source := req.GetAttributes().GetSource()
+	if source == nil {
+		return ""
+	}
+	if source.Certificate != "" {
+		cert, err := parsePeerCertificate(source.Certificate)
+		if err != nil {
+			log.Printf("Failed to parse peer certificate: %s", err)
+		} else if principal := certificatePrincipal(cert); principal != "" {
+			return principal
+		}
+	}
+	return source.Principal
+}
+
+// parsePeerCertificate parses a certificate in the URL-encoded PEM format used by Envoy.
+// Envoy doesn't escape "+", which is a base64 character, so it isn't decoded as a space.
+func parsePeerCertificate(encoded string) (*x509.Certificate, error) {
+	s, err := url.PathUnescape(encoded)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(s))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errNoCertificate
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func certificatePrincipal(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return cert.Subject.CommonName
+}
@@ -0,0 +1,190 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	registry "github.com/apigee/registry/rpc"
+)
+
+// RateLimitConfig configures token-bucket rate limits.
+type RateLimitConfig struct {
+	// limit applied to each principal across all methods
+	Principal *RateLimit `json:"principal" yaml:"principal"`
+	// limits applied to each principal for specific methods (keyed by method name)
+	Methods map[string]*RateLimit `json:"methods" yaml:"methods"`
+}
+
+// RateLimit configures a single token bucket.
+type RateLimit struct {
+	// rate at which tokens are added to the bucket
+	RequestsPerSecond float64 `json:"requestsPerSecond" yaml:"requestsPerSecond"`
+	// size of the bucket, if unspecified this is the per-second rate (rounded up)
+	Burst int `json:"burst" yaml:"burst"`
+}
+
+func (l *RateLimit) newLimiter() *rate.Limiter {
+	burst := l.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(l.RequestsPerSecond))
+	}
+	return rate.NewLimiter(rate.Limit(l.RequestsPerSecond), burst)
+}
+
+var (
+	checkedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "authz_checked_requests_total",
+		Help: "Number of requests checked by the authz server, by method and result.",
+	}, []string{"method", "result"})
+	rateLimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "authz_rate_limited_requests_total",
+		Help: "Number of requests denied because a rate limit was exceeded, by method and limit.",
+	}, []string{"method", "limit"})
+)
+
+func init() {
+	prometheus.MustRegister(checkedRequests, rateLimitedRequests)
+}
+
+// sweepInterval is the minimum time between removals of idle buckets.
+const sweepInterval = time.Minute
+
+// rateLimiter tracks token buckets for principals and methods.
+type rateLimiter struct {
+	config    *RateLimitConfig
+	mutex     sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+func newRateLimiter(config *RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config:  config,
+		buckets: make(map[string]*rate.Limiter),
+	}
+}
+
+// limiter is the rate limiter configured at startup (nil if no limits are configured).
+var limiter *rateLimiter
+
+// bucket returns the token bucket for a key, creating it if necessary.
+// The caller must hold the mutex.
+func (r *rateLimiter) bucket(key string, limit *RateLimit) *rate.Limiter {
+	b, ok := r.buckets[key]
+	if !ok {
+		b = limit.newLimiter()
+		r.buckets[key] = b
+	}
+	return b
+}
+
+// sweep removes buckets that have refilled completely. A full bucket behaves
+// like a new one, so this bounds memory use without changing any limits.
+// The caller must hold the mutex.
+func (r *rateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < sweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for key, b := range r.buckets {
+		if b.TokensAt(now) >= float64(b.Burst()) {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// allow takes a token from each bucket that applies to a request.
+// If any bucket is empty, no tokens are taken and allow returns false
+// along with the time until the request could be retried (zero if unknown).
+func (r *rateLimiter) allow(principal, method string, now time.Time) (bool, time.Duration) {
+	type check struct {
+		name   string
+		bucket *rate.Limiter
+	}
+	r.mutex.Lock()
+	r.sweep(now)
+	var checks []check
+	if r.config.Principal != nil {
+		checks = append(checks, check{
+			name:   "principal",
+			bucket: r.bucket(principal, r.config.Principal),
+		})
+	}
+	if l, ok := r.config.Methods[method]; ok && l != nil {
+		checks = append(checks, check{
+			name:   "method",
+			bucket: r.bucket(principal+" "+method, l),
+		})
+	}
+	r.mutex.Unlock()
+
+	var reservations []*rate.Reservation
+	var retryAfter time.Duration
+	var exceeded []string
+	for _, c := range checks {
+		reservation := c.bucket.ReserveN(now, 1)
+		reservations = append(reservations, reservation)
+		if !reservation.OK() {
+			// the bucket can never hold a token, so retrying won't help.
+			exceeded = append(exceeded, c.name)
+			continue
+		}
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			exceeded = append(exceeded, c.name)
+			if delay > retryAfter {
+				retryAfter = delay
+			}
+		}
+	}
+	if len(exceeded) == 0 {
+		return true, 0
+	}
+	for _, reservation := range reservations {
+		reservation.CancelAt(now)
+	}
+	for _, name := range exceeded {
+		rateLimitedRequests.WithLabelValues(method, name).Inc()
+	}
+	return false, retryAfter
+}
+
+// otherMethod identifies requests that aren't calls of Registry RPCs.
+const otherMethod = "other"
+
+// registryService describes the Registry service, whose RPCs are called with
+// paths like "/google.cloud.apigeeregistry.v1.Registry/ListApis".
+var registryService = registry.File_google_cloud_apigeeregistry_v1_registry_service_proto.Services().ByName("Registry")
+
+// methodName returns the name used to identify a method in rate limits and
+// metrics. Paths are supplied by clients, so only the names of Registry RPCs
+// are used and all other requests share one name.
+func methodName(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	name := strings.TrimPrefix(path, "/"+string(registryService.FullName())+"/")
+	if name == path || registryService.Methods().ByName(protoreflect.Name(name)) == nil {
+		return otherMethod
+	}
+	return name
+}
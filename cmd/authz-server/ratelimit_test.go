@@ -0,0 +1,123 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+)
+
+func TestRateLimiter(t *testing.T) {
+	r := newRateLimiter(&RateLimitConfig{
+		Principal: &RateLimit{RequestsPerSecond: 1, Burst: 3},
+		Methods: map[string]*RateLimit{
+			"CreateApi": {RequestsPerSecond: 0.5, Burst: 1},
+		},
+	})
+	now := time.Now()
+
+	// The method limit applies before the principal limit is exhausted.
+	if ok, _ := r.allow("alice", "CreateApi", now); !ok {
+		t.Fatalf("first CreateApi was limited")
+	}
+	ok, retryAfter := r.allow("alice", "CreateApi", now)
+	if ok {
+		t.Fatalf("second CreateApi was allowed")
+	}
+	if retryAfter != 2*time.Second {
+		t.Errorf("retryAfter is %s, want 2s", retryAfter)
+	}
+
+	// The denied request didn't consume a principal token.
+	for i := 0; i < 2; i++ {
+		if ok, _ := r.allow("alice", "GetApi", now); !ok {
+			t.Fatalf("GetApi %d was limited", i)
+		}
+	}
+	if ok, _ := r.allow("alice", "GetApi", now); ok {
+		t.Fatalf("GetApi was allowed after the principal limit was exhausted")
+	}
+
+	// Other principals have their own buckets.
+	if ok, _ := r.allow("bob", "CreateApi", now); !ok {
+		t.Fatalf("CreateApi by another principal was limited")
+	}
+
+	// Tokens are replenished over time.
+	if ok, _ := r.allow("alice", "CreateApi", now.Add(2*time.Second)); !ok {
+		t.Fatalf("CreateApi was limited after the bucket was refilled")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	r := newRateLimiter(&RateLimitConfig{
+		Principal: &RateLimit{RequestsPerSecond: 1, Burst: 2},
+	})
+	now := time.Now()
+	r.allow("alice", "GetApi", now)
+	r.allow("bob", "GetApi", now)
+	r.allow("bob", "GetApi", now)
+	if len(r.buckets) != 2 {
+		t.Fatalf("rate limiter has %d buckets, want 2", len(r.buckets))
+	}
+
+	// Buckets that have refilled are removed.
+	later := now.Add(sweepInterval)
+	r.allow("carol", "GetApi", later)
+	if len(r.buckets) != 1 {
+		t.Errorf("rate limiter has %d buckets after sweeping, want 1", len(r.buckets))
+	}
+	if _, ok := r.buckets["carol"]; !ok {
+		t.Errorf("active bucket was removed")
+	}
+}
+
+func TestMethodName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/google.cloud.apigeeregistry.v1.Registry/ListApis", "ListApis"},
+		{"/google.cloud.apigeeregistry.v1.Registry/UpdateApiSpec?x=1", "UpdateApiSpec"},
+		{"/google.cloud.apigeeregistry.v1.Registry/NoSuchMethod", "other"},
+		{"/google.cloud.apigeeregistry.v1.Admin/GetStatus", "other"},
+		{"/v1/projects/p/locations/global/apis/3f9c1a7e-5b2d-4c8e-9a61-0d2e7b4f8c35", "other"},
+		{"/ListApis", "other"},
+		{"", "other"},
+	}
+	for _, test := range tests {
+		if got := methodName(test.path); got != test.want {
+			t.Errorf("methodName(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestRateLimitedResponse(t *testing.T) {
+	resp := denyRateLimitedUser(1500 * time.Millisecond)
+	if rpc.Code(resp.Status.Code) != rpc.RESOURCE_EXHAUSTED {
+		t.Errorf("status is %s, want RESOURCE_EXHAUSTED", rpc.Code(resp.Status.Code))
+	}
+	denied := resp.GetDeniedResponse()
+	if denied.Status.Code != 429 {
+		t.Errorf("http status is %d, want 429", denied.Status.Code)
+	}
+	if len(denied.Headers) != 1 ||
+		denied.Headers[0].Header.Key != "retry-after" ||
+		denied.Headers[0].Header.Value != "2" {
+		t.Errorf("unexpected headers %+v", denied.Headers)
+	}
+}
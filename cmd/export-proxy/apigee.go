@@ -0,0 +1,204 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const defaultApigeeURL = "https://apigee.googleapis.com"
+
+// apigeeClient calls the Apigee management API.
+type apigeeClient struct {
+	baseURL      string
+	token        string
+	client       *http.Client
+	pollInterval time.Duration
+}
+
+func newApigeeClient(baseURL, token string) *apigeeClient {
+	if baseURL == "" {
+		baseURL = defaultApigeeURL
+	}
+	return &apigeeClient{
+		baseURL:      baseURL,
+		token:        token,
+		client:       http.DefaultClient,
+		pollInterval: 5 * time.Second,
+	}
+}
+
+// apigeeError is an unsuccessful response from the Apigee management API.
+type apigeeError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apigeeError) Error() string {
+	return fmt.Sprintf("apigee: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// apiProxy is an Apigee API proxy.
+type apiProxy struct {
+	Name             string   `json:"name"`
+	Revision         []string `json:"revision"`
+	LatestRevisionID string   `json:"latestRevisionId"`
+}
+
+// hasRevision returns true if the proxy has the specified revision.
+func (p *apiProxy) hasRevision(revision string) bool {
+	for _, r := range p.Revision {
+		if r == revision {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyRevision is a revision of an Apigee API proxy.
+type proxyRevision struct {
+	Name     string `json:"name"`
+	Revision string `json:"revision"`
+}
+
+// proxyDeployment is the deployment of a proxy revision to an environment.
+type proxyDeployment struct {
+	Environment string `json:"environment"`
+	APIProxy    string `json:"apiProxy"`
+	Revision    string `json:"revision"`
+	State       string `json:"state"`
+}
+
+// do sends a request and decodes its JSON response into v (if v is non-nil).
+func (c *apigeeClient) do(ctx context.Context, method, path string, body []byte, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Add("Content-Type", "application/octet-stream")
+	}
+	req.Header.Add("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newApigeeError(resp.StatusCode, b)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(b, v)
+}
+
+// newApigeeError returns an error with the message from a Google API error response.
+func newApigeeError(code int, body []byte) error {
+	var response struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	message := string(body)
+	if err := json.Unmarshal(body, &response); err == nil && response.Error.Message != "" {
+		message = response.Error.Message
+	}
+	return &apigeeError{StatusCode: code, Message: message}
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*apigeeError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// getProxy returns the named proxy, or nil if it doesn't exist.
+func (c *apigeeClient) getProxy(ctx context.Context, org, name string) (*apiProxy, error) {
+	proxy := &apiProxy{}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/%s/apis/%s", org, url.PathEscape(name)), nil, proxy)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// importProxy creates a proxy (or a new revision of an existing proxy) from a bundle.
+func (c *apigeeClient) importProxy(ctx context.Context, org, name string, bundle []byte) (*proxyRevision, error) {
+	revision := &proxyRevision{}
+	path := fmt.Sprintf("/v1/%s/apis?action=import&name=%s", org, url.QueryEscape(name))
+	if err := c.do(ctx, http.MethodPost, path, bundle, revision); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// getDeployment returns the deployment of a proxy revision, or nil if it isn't deployed.
+func (c *apigeeClient) getDeployment(ctx context.Context, org, env, name, revision string) (*proxyDeployment, error) {
+	deployment := &proxyDeployment{}
+	path := fmt.Sprintf("/v1/%s/environments/%s/apis/%s/revisions/%s/deployments", org, url.PathEscape(env), url.PathEscape(name), revision)
+	err := c.do(ctx, http.MethodGet, path, nil, deployment)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// deployRevision deploys a proxy revision to an environment, replacing any deployed revision.
+func (c *apigeeClient) deployRevision(ctx context.Context, org, env, name, revision string) error {
+	path := fmt.Sprintf("/v1/%s/environments/%s/apis/%s/revisions/%s/deployments?override=true", org, url.PathEscape(env), url.PathEscape(name), revision)
+	return c.do(ctx, http.MethodPost, path, nil, nil)
+}
+
+// waitForDeployment polls a deployment until it is ready, fails, or the context is done.
+func (c *apigeeClient) waitForDeployment(ctx context.Context, org, env, name, revision string) error {
+	for {
+		deployment, err := c.getDeployment(ctx, org, env, name, revision)
+		if err != nil {
+			return err
+		}
+		if deployment != nil {
+			switch deployment.State {
+			case "READY":
+				return nil
+			case "ERROR":
+				return fmt.Errorf("deployment of %s revision %s to %s failed", name, revision, env)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("deployment of %s revision %s to %s isn't ready: %w", name, revision, env, ctx.Err())
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type APIProxy struct {
+	Name            string `xml:"name,attr"`
+	DisplayName     string
+	Description     string
+	BasePaths       string
+	Policies        []string `xml:"Policies>Policy"`
+	ProxyEndpoints  []string `xml:"ProxyEndpoints>ProxyEndpoint"`
+	Resources       []string `xml:"Resources>Resource"`
+	TargetEndpoints []string `xml:"TargetEndpoints>TargetEndpoint"`
+}
+
+type RouteRule struct {
+	Name           string `xml:"name,attr"`
+	TargetEndpoint string
+}
+
+type Step struct {
+	Name      string
+	Condition string `xml:",omitempty"`
+}
+
+type Flow struct {
+	Name        string `xml:"name,attr,omitempty"`
+	Description string `xml:",omitempty"`
+	Request     []Step `xml:"Request>Step"`
+	Response    []Step `xml:"Response>Step"`
+	Condition   string `xml:",omitempty"`
+}
+
+type ProxyEndpoint struct {
+	Name      string `xml:"name,attr"`
+	PreFlow   Flow
+	Flows     []Flow `xml:"Flows>Flow"`
+	BasePath  string `xml:"HTTPProxyConnection>BasePath"`
+	RouteRule RouteRule
+}
+
+type TargetEndpoint struct {
+	Name string `xml:"name,attr"`
+	URL  string `xml:"HTTPTargetConnection>URL"`
+}
+
+type OASValidation struct {
+	Name        string `xml:"name,attr"`
+	Source      string
+	OASResource string
+}
+
+type CORS struct {
+	Name                      string `xml:"name,attr"`
+	AllowOrigins              string
+	AllowMethods              string
+	AllowHeaders              string
+	ExposeHeaders             string
+	MaxAge                    int
+	AllowCredentials          bool
+	GeneratePreflightResponse bool
+	IgnoreUnresolvedVariables bool
+}
+
+type APIKey struct {
+	Ref string `xml:"ref,attr"`
+}
+
+type VerifyAPIKey struct {
+	Name   string `xml:"name,attr"`
+	APIKey APIKey
+}
+
+type OAuthV2 struct {
+	Name      string `xml:"name,attr"`
+	Operation string
+}
+
+// Supported values of the --auth flag.
+const (
+	authNone   = "none"
+	authAPIKey = "apikey"
+	authOAuth  = "oauth"
+)
+
+// bundleOptions control the contents of generated proxy bundles.
+type bundleOptions struct {
+	// auth selects the policy that verifies callers (none, apikey or oauth).
+	auth string
+	// corsOrigins are allowed to make cross-origin requests. If empty, no CORS policy is added.
+	corsOrigins []string
+	// validate adds a policy that validates requests against an OpenAPI v3 spec.
+	validate bool
+	// basePath overrides the base path derived from the spec.
+	basePath string
+}
+
+// proxyBundle holds the files of an Apigee API proxy bundle.
+type proxyBundle struct {
+	root      APIProxy
+	proxy     ProxyEndpoint
+	target    TargetEndpoint
+	policies  map[string]any
+	resources map[string][]byte
+}
+
+// newProxyBundle builds a proxy that forwards requests to a target URL.
+// If a spec is provided, the proxy has a conditional flow for each
+// operation and its base path is taken from the spec.
+func newProxyBundle(name, displayName, description, targetURL string, spec *openAPISpec, opts bundleOptions) (*proxyBundle, error) {
+	b := &proxyBundle{
+		root: APIProxy{
+			Name:            name,
+			DisplayName:     displayName,
+			Description:     description,
+			ProxyEndpoints:  []string{"default"},
+			TargetEndpoints: []string{"default"},
+		},
+		proxy: ProxyEndpoint{
+			Name:    "default",
+			PreFlow: Flow{Name: "PreFlow"},
+			RouteRule: RouteRule{
+				Name:           "default",
+				TargetEndpoint: "default",
+			},
+		},
+		target: TargetEndpoint{
+			Name: "default",
+			URL:  targetURL,
+		},
+		policies:  make(map[string]any),
+		resources: make(map[string][]byte),
+	}
+
+	basePath := opts.basePath
+	if basePath == "" && spec != nil {
+		basePath = spec.basePath()
+	}
+	if basePath == "" {
+		basePath = "/"
+	}
+	b.root.BasePaths = basePath
+	b.proxy.BasePath = basePath
+
+	if len(opts.corsOrigins) > 0 {
+		b.addPolicy("CORS", CORS{
+			Name:                      "CORS",
+			AllowOrigins:              strings.Join(opts.corsOrigins, ", "),
+			AllowMethods:              "GET, PUT, POST, PATCH, DELETE",
+			AllowHeaders:              "origin, x-requested-with, accept, content-type, authorization, x-api-key",
+			ExposeHeaders:             "*",
+			MaxAge:                    3628800,
+			GeneratePreflightResponse: true,
+			IgnoreUnresolvedVariables: true,
+		})
+	}
+
+	switch opts.auth {
+	case "", authNone:
+	case authAPIKey:
+		b.addPolicy("Verify-API-Key", VerifyAPIKey{
+			Name:   "Verify-API-Key",
+			APIKey: APIKey{Ref: "request.header.x-api-key"},
+		})
+	case authOAuth:
+		b.addPolicy("Verify-OAuth-Token", OAuthV2{
+			Name:      "Verify-OAuth-Token",
+			Operation: "VerifyAccessToken",
+		})
+	default:
+		return nil, fmt.Errorf("unsupported auth %q: must be %s, %s or %s", opts.auth, authNone, authAPIKey, authOAuth)
+	}
+
+	if spec == nil {
+		return b, nil
+	}
+
+	if opts.validate && spec.isV3() {
+		b.resources["oas/"+spec.filename] = spec.contents
+		b.root.Resources = append(b.root.Resources, "oas://"+spec.filename)
+		b.addPolicy("OAS-Validation", OASValidation{
+			Name:        "OAS-Validation",
+			Source:      "request",
+			OASResource: "oas://" + spec.filename,
+		})
+	}
+
+	flowNames := make(map[string]bool)
+	for _, op := range spec.operations() {
+		name := flowName(op)
+		for i := 2; flowNames[name]; i++ {
+			name = fmt.Sprintf("%s-%d", flowName(op), i)
+		}
+		flowNames[name] = true
+		b.proxy.Flows = append(b.proxy.Flows, Flow{
+			Name:        name,
+			Description: op.summary,
+			Condition:   fmt.Sprintf("(proxy.pathsuffix MatchesPath %q) and (request.verb = %q)", pathPattern(op.path), op.method),
+		})
+	}
+	return b, nil
+}
+
+// addPolicy adds a policy to the bundle and a step that runs it in the proxy's PreFlow.
+func (b *proxyBundle) addPolicy(name string, policy any) {
+	b.policies[name] = policy
+	b.root.Policies = append(b.root.Policies, name)
+	b.proxy.PreFlow.Request = append(b.proxy.PreFlow.Request, Step{Name: name})
+}
+
+var templateParameter = regexp.MustCompile(`{[^}]*}`)
+
+// pathPattern converts an OpenAPI path template into an Apigee MatchesPath pattern.
+func pathPattern(path string) string {
+	return templateParameter.ReplaceAllString(path, "*")
+}
+
+var invalidFlowNameCharacters = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// flowName returns the name of the flow for an operation.
+func flowName(op operation) string {
+	if op.id != "" {
+		return invalidFlowNameCharacters.ReplaceAllString(op.id, "-")
+	}
+	name := op.method + "-" + strings.Trim(op.path, "/")
+	return strings.Trim(invalidFlowNameCharacters.ReplaceAllString(name, "-"), "-")
+}
+
+// zip returns the bundle as a zip archive.
+// Files are written in a fixed order so that identical bundles have identical archives.
+func (b *proxyBundle) zip() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	if err := write(zw, "apiproxy/"+b.root.Name+".xml", b.root); err != nil {
+		return buf, err
+	}
+
+	if err := write(zw, "apiproxy/proxies/default.xml", b.proxy); err != nil {
+		return buf, err
+	}
+
+	if err := write(zw, "apiproxy/targets/default.xml", b.target); err != nil {
+		return buf, err
+	}
+
+	for _, name := range sortedKeys(b.policies) {
+		if err := write(zw, "apiproxy/policies/"+name+".xml", b.policies[name]); err != nil {
+			return buf, err
+		}
+	}
+
+	for _, name := range sortedKeys(b.resources) {
+		w, err := zw.Create("apiproxy/resources/" + name)
+		if err != nil {
+			return buf, err
+		}
+		if _, err := w.Write(b.resources[name]); err != nil {
+			return buf, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return buf, err
+	}
+
+	return buf, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func write(zw *zip.Writer, name string, v any) error {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(out); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const petstore = `openapi: 3.0.0
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: https://petstore.example.com/v1/
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List all pets
+    post:
+      operationId: createPets
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+    get:
+      summary: Info for a specific pet
+`
+
+func unzip(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(b)
+	}
+	return files
+}
+
+func TestBundleFromSpec(t *testing.T) {
+	spec, err := parseOpenAPISpec("petstore.yaml", []byte(petstore))
+	if err != nil {
+		t.Fatalf("parseOpenAPISpec() returned error: %s", err)
+	}
+	bundle, err := newProxyBundle("petstore", "Petstore", "", "https://backend.example.com", spec, bundleOptions{
+		auth:        authAPIKey,
+		corsOrigins: []string{"https://example.com"},
+		validate:    true,
+	})
+	if err != nil {
+		t.Fatalf("newProxyBundle() returned error: %s", err)
+	}
+
+	if bundle.proxy.BasePath != "/v1" {
+		t.Errorf("base path is %q, want %q", bundle.proxy.BasePath, "/v1")
+	}
+	wantFlows := []Flow{
+		{
+			Name:        "listPets",
+			Description: "List all pets",
+			Condition:   `(proxy.pathsuffix MatchesPath "/pets") and (request.verb = "GET")`,
+		},
+		{
+			Name:      "createPets",
+			Condition: `(proxy.pathsuffix MatchesPath "/pets") and (request.verb = "POST")`,
+		},
+		{
+			Name:        "GET-pets-petId",
+			Description: "Info for a specific pet",
+			Condition:   `(proxy.pathsuffix MatchesPath "/pets/*") and (request.verb = "GET")`,
+		},
+	}
+	if diff := cmp.Diff(wantFlows, bundle.proxy.Flows); diff != "" {
+		t.Errorf("unexpected flows (-want +got):\n%s", diff)
+	}
+	wantSteps := []Step{{Name: "CORS"}, {Name: "Verify-API-Key"}, {Name: "OAS-Validation"}}
+	if diff := cmp.Diff(wantSteps, bundle.proxy.PreFlow.Request); diff != "" {
+		t.Errorf("unexpected PreFlow steps (-want +got):\n%s", diff)
+	}
+
+	b, err := bundle.zip()
+	if err != nil {
+		t.Fatalf("zip() returned error: %s", err)
+	}
+	files := unzip(t, b.Bytes())
+	for _, name := range []string{
+		"apiproxy/petstore.xml",
+		"apiproxy/proxies/default.xml",
+		"apiproxy/targets/default.xml",
+		"apiproxy/policies/CORS.xml",
+		"apiproxy/policies/OAS-Validation.xml",
+		"apiproxy/policies/Verify-API-Key.xml",
+		"apiproxy/resources/oas/petstore.yaml",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle is missing %s", name)
+		}
+	}
+	if !strings.Contains(files["apiproxy/policies/OAS-Validation.xml"], "<OASResource>oas://petstore.yaml</OASResource>") {
+		t.Errorf("unexpected OAS validation policy:\n%s", files["apiproxy/policies/OAS-Validation.xml"])
+	}
+	if !strings.Contains(files["apiproxy/policies/Verify-API-Key.xml"], `<APIKey ref="request.header.x-api-key"></APIKey>`) {
+		t.Errorf("unexpected API key policy:\n%s", files["apiproxy/policies/Verify-API-Key.xml"])
+	}
+
+	// Bundles are deterministic.
+	b2, err := bundle.zip()
+	if err != nil {
+		t.Fatalf("zip() returned error: %s", err)
+	}
+	if !bytes.Equal(b.Bytes(), b2.Bytes()) {
+		t.Errorf("zip() returned different archives for the same bundle")
+	}
+}
+
+func TestBundleFromSwagger(t *testing.T) {
+	spec, err := parseOpenAPISpec("swagger.json", []byte(`{"swagger": "2.0", "basePath": "/v2", "paths": {"/items": {"get": {}}}}`))
+	if err != nil {
+		t.Fatalf("parseOpenAPISpec() returned error: %s", err)
+	}
+	bundle, err := newProxyBundle("items", "", "", "https://backend.example.com", spec, bundleOptions{
+		auth:     authOAuth,
+		validate: true,
+	})
+	if err != nil {
+		t.Fatalf("newProxyBundle() returned error: %s", err)
+	}
+	if bundle.proxy.BasePath != "/v2" {
+		t.Errorf("base path is %q, want %q", bundle.proxy.BasePath, "/v2")
+	}
+	// OAS validation only supports OpenAPI v3.
+	if diff := cmp.Diff([]string{"Verify-OAuth-Token"}, bundle.root.Policies); diff != "" {
+		t.Errorf("unexpected policies (-want +got):\n%s", diff)
+	}
+	if len(bundle.proxy.Flows) != 1 || bundle.proxy.Flows[0].Name != "GET-items" {
+		t.Errorf("unexpected flows %+v", bundle.proxy.Flows)
+	}
+}
+
+func TestPassThroughBundle(t *testing.T) {
+	bundle, err := newProxyBundle("passthrough", "", "", "https://backend.example.com", nil, bundleOptions{})
+	if err != nil {
+		t.Fatalf("newProxyBundle() returned error: %s", err)
+	}
+	if bundle.proxy.BasePath != "/" || len(bundle.proxy.Flows) != 0 || len(bundle.policies) != 0 {
+		t.Errorf("unexpected pass-through bundle %+v", bundle)
+	}
+	if _, err := newProxyBundle("invalid", "", "", "", nil, bundleOptions{auth: "basic"}); err == nil {
+		t.Errorf("newProxyBundle() succeeded with unsupported auth")
+	}
+}
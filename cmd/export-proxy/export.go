@@ -0,0 +1,151 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Annotations that record the proxy that a deployment was exported to.
+const (
+	proxyAnnotation       = "apigee-proxy"
+	revisionAnnotation    = "apigee-proxy-revision"
+	hashAnnotation        = "apigee-proxy-hash"
+	environmentAnnotation = "apigee-environment"
+)
+
+// Actions taken to export a deployment.
+const (
+	actionCreate    = "create"
+	actionUpdate    = "update"
+	actionUnchanged = "unchanged"
+)
+
+// exportOptions control where and how proxies are deployed.
+type exportOptions struct {
+	// org is the Apigee organization, in the form organizations/{org}.
+	org string
+	// environment is the environment that proxies are deployed to (if non-empty).
+	environment string
+	// wait is how long to wait for deployments to be ready (if nonzero).
+	wait time.Duration
+}
+
+// export describes how a deployment will be exported to a proxy.
+type export struct {
+	deployment *rpc.ApiDeployment
+	proxy      string
+	bundle     []byte
+	hash       string
+	action     string
+	// revision is the current proxy revision of unchanged exports.
+	revision string
+}
+
+// planExport compares a bundle with the proxy that the deployment was last
+// exported to and decides whether a proxy must be created or updated.
+// A proxy is unchanged if the deployment's annotations record that the same
+// bundle was imported as a revision that still exists.
+func planExport(ctx context.Context, apigee *apigeeClient, org string, deployment *rpc.ApiDeployment, bundle []byte, proxyName string) (*export, error) {
+	sum := sha256.Sum256(bundle)
+	e := &export{
+		deployment: deployment,
+		proxy:      proxyName,
+		bundle:     bundle,
+		hash:       hex.EncodeToString(sum[:]),
+	}
+	proxy, err := apigee.getProxy(ctx, org, proxyName)
+	if err != nil {
+		return nil, err
+	}
+	annotations := deployment.GetAnnotations()
+	switch {
+	case proxy == nil:
+		e.action = actionCreate
+	case annotations[proxyAnnotation] == org+"/apis/"+proxyName &&
+		annotations[hashAnnotation] == e.hash &&
+		proxy.hasRevision(annotations[revisionAnnotation]):
+		e.action = actionUnchanged
+		e.revision = annotations[revisionAnnotation]
+	default:
+		e.action = actionUpdate
+	}
+	return e, nil
+}
+
+// applyExport imports a new proxy revision if the bundle changed, optionally
+// deploys it, and records the proxy and revision on the registry deployment.
+func applyExport(ctx context.Context, client connection.RegistryClient, apigee *apigeeClient, opts exportOptions, e *export) error {
+	if e.action == actionUnchanged && opts.environment == "" {
+		// The deployment's annotations are already up to date.
+		return nil
+	}
+	if e.action != actionUnchanged {
+		revision, err := apigee.importProxy(ctx, opts.org, e.proxy, e.bundle)
+		if err != nil {
+			return err
+		}
+		e.revision = revision.Revision
+		log.FromContext(ctx).Infof("Imported %s/apis/%s revision %s", opts.org, e.proxy, e.revision)
+	}
+
+	if opts.environment != "" {
+		deployment, err := apigee.getDeployment(ctx, opts.org, opts.environment, e.proxy, e.revision)
+		if err != nil {
+			return err
+		}
+		if deployment == nil {
+			if err := apigee.deployRevision(ctx, opts.org, opts.environment, e.proxy, e.revision); err != nil {
+				return err
+			}
+			log.FromContext(ctx).Infof("Deploying %s revision %s to %s", e.proxy, e.revision, opts.environment)
+		}
+		if opts.wait > 0 {
+			ctx, cancel := context.WithTimeout(ctx, opts.wait)
+			defer cancel()
+			if err := apigee.waitForDeployment(ctx, opts.org, opts.environment, e.proxy, e.revision); err != nil {
+				return err
+			}
+			log.FromContext(ctx).Infof("Deployed %s revision %s to %s", e.proxy, e.revision, opts.environment)
+		}
+	}
+
+	annotations := make(map[string]string)
+	for k, v := range e.deployment.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[proxyAnnotation] = opts.org + "/apis/" + e.proxy
+	annotations[revisionAnnotation] = e.revision
+	annotations[hashAnnotation] = e.hash
+	if opts.environment != "" {
+		annotations[environmentAnnotation] = opts.environment
+	}
+	_, err := client.UpdateApiDeployment(ctx, &rpc.UpdateApiDeploymentRequest{
+		ApiDeployment: &rpc.ApiDeployment{
+			Name:        e.deployment.GetName(),
+			Annotations: annotations,
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"annotations"}},
+	})
+	return err
+}
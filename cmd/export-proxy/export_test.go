@@ -0,0 +1,202 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry"
+	"github.com/apigee/registry/server/registry/test/seeder"
+)
+
+func TestMain(m *testing.M) {
+	grpctest.TestMain(m, registry.Config{})
+}
+
+// fakeApigee is a minimal stand-in for the Apigee management API.
+type fakeApigee struct {
+	mu          sync.Mutex
+	revisions   map[string]int    // proxy name -> latest revision
+	deployments map[string]string // env/proxy/revision -> state
+	imports     int
+}
+
+func newFakeApigee() *fakeApigee {
+	return &fakeApigee{
+		revisions:   make(map[string]int),
+		deployments: make(map[string]string),
+	}
+}
+
+var (
+	proxyPath      = regexp.MustCompile(`^/v1/organizations/[^/]+/apis/([^/]+)$`)
+	importPath     = regexp.MustCompile(`^/v1/organizations/[^/]+/apis$`)
+	deploymentPath = regexp.MustCompile(`^/v1/organizations/[^/]+/environments/([^/]+)/apis/([^/]+)/revisions/([^/]+)/deployments$`)
+)
+
+func (f *fakeApigee) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		writeFakeError(w, http.StatusUnauthorized, "missing credentials")
+		return
+	}
+	if m := proxyPath.FindStringSubmatch(r.URL.Path); m != nil && r.Method == http.MethodGet {
+		latest, ok := f.revisions[m[1]]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "proxy not found")
+			return
+		}
+		proxy := &apiProxy{Name: m[1], LatestRevisionID: strconv.Itoa(latest)}
+		for i := 1; i <= latest; i++ {
+			proxy.Revision = append(proxy.Revision, strconv.Itoa(i))
+		}
+		_ = json.NewEncoder(w).Encode(proxy)
+		return
+	}
+	if importPath.MatchString(r.URL.Path) && r.Method == http.MethodPost {
+		name := r.URL.Query().Get("name")
+		if r.URL.Query().Get("action") != "import" || name == "" {
+			writeFakeError(w, http.StatusBadRequest, "invalid import")
+			return
+		}
+		f.imports++
+		f.revisions[name]++
+		_ = json.NewEncoder(w).Encode(&proxyRevision{Name: name, Revision: strconv.Itoa(f.revisions[name])})
+		return
+	}
+	if m := deploymentPath.FindStringSubmatch(r.URL.Path); m != nil {
+		key := m[1] + "/" + m[2] + "/" + m[3]
+		switch r.Method {
+		case http.MethodPost:
+			f.deployments[key] = "PROGRESSING"
+			_ = json.NewEncoder(w).Encode(&proxyDeployment{Environment: m[1], APIProxy: m[2], Revision: m[3], State: "PROGRESSING"})
+		case http.MethodGet:
+			state, ok := f.deployments[key]
+			if !ok {
+				writeFakeError(w, http.StatusNotFound, "deployment not found")
+				return
+			}
+			// Deployments become ready after they are first checked.
+			f.deployments[key] = "READY"
+			_ = json.NewEncoder(w).Encode(&proxyDeployment{Environment: m[1], APIProxy: m[2], Revision: m[3], State: state})
+		}
+		return
+	}
+	writeFakeError(w, http.StatusNotFound, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL))
+}
+
+func writeFakeError(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error": {"code": %d, "message": %q}}`, code, message)
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	client, _ := grpctest.SetupRegistry(ctx, t, "export-proxy-test", []seeder.RegistryResource{
+		&rpc.ApiDeployment{
+			Name:        "projects/export-proxy-test/locations/global/apis/petstore/deployments/prod",
+			EndpointUri: "https://backend.example.com",
+		},
+	})
+	fake := newFakeApigee()
+	s := httptest.NewServer(fake)
+	defer s.Close()
+	apigee := newApigeeClient(s.URL, "token")
+	apigee.pollInterval = time.Millisecond
+	opts := exportOptions{org: "organizations/o", environment: "test", wait: time.Second}
+	name := "projects/export-proxy-test/locations/global/apis/petstore/deployments/prod"
+
+	export := func(bundleOpts bundleOptions) *export {
+		t.Helper()
+		deployment, err := client.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle, err := newProxyBundle("prod", "", "", deployment.EndpointUri, nil, bundleOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := bundle.zip()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := planExport(ctx, apigee, opts.org, deployment, b.Bytes(), "prod")
+		if err != nil {
+			t.Fatalf("planExport() returned error: %s", err)
+		}
+		if err := applyExport(ctx, client, apigee, opts, e); err != nil {
+			t.Fatalf("applyExport() returned error: %s", err)
+		}
+		return e
+	}
+
+	checkAnnotations := func(revision string) {
+		t.Helper()
+		deployment, err := client.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := deployment.GetAnnotations()
+		if a[proxyAnnotation] != "organizations/o/apis/prod" || a[revisionAnnotation] != revision || a[environmentAnnotation] != "test" {
+			t.Errorf("unexpected annotations %v", a)
+		}
+	}
+
+	if e := export(bundleOptions{}); e.action != actionCreate || e.revision != "1" {
+		t.Errorf("first export was %s of revision %s, want create of revision 1", e.action, e.revision)
+	}
+	checkAnnotations("1")
+	if fake.deployments["test/prod/1"] != "READY" {
+		t.Errorf("revision 1 wasn't deployed")
+	}
+
+	if e := export(bundleOptions{}); e.action != actionUnchanged || e.revision != "1" {
+		t.Errorf("second export was %s of revision %s, want unchanged revision 1", e.action, e.revision)
+	}
+	if fake.imports != 1 {
+		t.Errorf("unchanged proxy was imported")
+	}
+
+	if e := export(bundleOptions{auth: authAPIKey}); e.action != actionUpdate || e.revision != "2" {
+		t.Errorf("third export was %s of revision %s, want update of revision 2", e.action, e.revision)
+	}
+	checkAnnotations("2")
+}
+
+func TestApigeeErrors(t *testing.T) {
+	s := httptest.NewServer(newFakeApigee())
+	defer s.Close()
+	apigee := newApigeeClient(s.URL, "wrong")
+	_, err := apigee.importProxy(context.Background(), "organizations/o", "p", []byte("bundle"))
+	e, ok := err.(*apigeeError)
+	if !ok {
+		t.Fatalf("importProxy() returned %v, want an apigeeError", err)
+	}
+	if e.StatusCode != http.StatusUnauthorized || e.Message != "missing credentials" {
+		t.Errorf("importProxy() returned %+v", e)
+	}
+}
@@ -15,60 +15,47 @@
 package main
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
-	"encoding/xml"
 	"fmt"
-	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/apex/log"
 	"github.com/apigee/registry/pkg/config"
 	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/mime"
 	"github.com/apigee/registry/pkg/names"
+	"github.com/apigee/registry/pkg/visitor"
 	"github.com/apigee/registry/rpc"
 	"github.com/spf13/cobra"
 )
 
-type APIProxy struct {
-	Name           string `xml:"name,attr"`
-	DisplayName    string
-	Description    string
-	BasePath       string `xml:"BasePaths"`
-	ProxyEndpoint  string `xml:"ProxyEndpoints>ProxyEndpoint"`
-	TargetEndpoint string `xml:"TargetEndpoints>TargetEndpoint"`
-}
-
-type RouteRule struct {
-	Name           string `xml:"name,attr"`
-	TargetEndpoint string
-}
-
-type ProxyEndpoint struct {
-	Name      string `xml:"name,attr"`
-	BasePath  string `xml:"HTTPProxyConnection>BasePath"`
-	RouteRule RouteRule
-}
-
-type TargetEndpoint struct {
-	Name string `xml:"name,attr"`
-	URL  string `xml:"HTTPTargetConnection>URL"`
-}
-
 func main() {
+	var opts bundleOptions
+	var exportOpts exportOptions
+	var dryRun bool
+	var output string
+	var apigeeURL string
+	var filter string
+	var jobs int
 	cmd := &cobra.Command{
-		Use:   "export-proxy DEPLOYMENT ORGANIZATION",
+		Use:   "export-proxy DEPLOYMENT_PATTERN ORGANIZATION",
 		Short: "Exports Apigee resources to YAML files compatible with API Registry",
 		Args:  cobra.ExactArgs(2),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if jobs < 1 {
+				return fmt.Errorf("--jobs must be at least 1, got %d", jobs)
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := cmd.Context()
 
 			config, err := config.Active()
 			if err != nil {
 				log.FromContext(ctx).WithError(err).Fatal("Failed to load active configuration")
-			} else if config.Registry.Token == "" {
+			} else if config.Registry.Token == "" && !dryRun {
 				log.FromContext(ctx).Fatal("Active configuration doesn't have a GCP access token")
 			}
 
@@ -79,53 +66,58 @@ func main() {
 
 			name, err := names.ParseDeployment(args[0])
 			if err != nil {
-				log.FromContext(ctx).WithError(err).Fatal("Failed to parse deployment")
+				log.FromContext(ctx).WithError(err).Fatal("Failed to parse deployment name or pattern")
 			}
 
 			if !strings.HasPrefix(args[1], "organizations/") {
 				log.FromContext(ctx).Fatal("Invalid organization: must have format organizations/{org}")
 			}
 
-			deployment, err := client.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{
-				Name: name.String(),
-			})
-			if err != nil {
-				log.FromContext(ctx).WithError(err).Fatal("Failed to get deployment")
+			exportOpts.org = args[1]
+			var apigee *apigeeClient
+			if config.Registry.Token != "" {
+				apigee = newApigeeClient(apigeeURL, config.Registry.Token)
 			}
 
-			root := APIProxy{
-				Name:           name.DeploymentID,
-				DisplayName:    deployment.DisplayName,
-				Description:    deployment.Description,
-				BasePath:       "/",
-				ProxyEndpoint:  "default",
-				TargetEndpoint: "default",
+			plan, err := buildPlan(ctx, client, apigee, exportOpts.org, name, filter, opts, jobs)
+			if err != nil {
+				log.FromContext(ctx).WithError(err).Fatal("Failed to plan export")
 			}
-
-			proxy := ProxyEndpoint{
-				Name:     "default",
-				BasePath: "/",
-				RouteRule: RouteRule{
-					Name:           "default",
-					TargetEndpoint: "default",
-				},
+			if len(plan) == 0 {
+				log.FromContext(ctx).Fatalf("No deployments match %s", name)
 			}
-
-			target := TargetEndpoint{
-				Name: "default",
-				URL:  deployment.EndpointUri,
+			if err := printPlan(cmd.OutOrStdout(), plan); err != nil {
+				log.FromContext(ctx).WithError(err).Fatal("Failed to print plan")
 			}
 
-			proxyZip, err := bundle(root, proxy, target)
-			if err != nil {
-				log.FromContext(ctx).WithError(err).Fatal("Failed to create bundle")
+			if dryRun {
+				for _, e := range plan {
+					filename := filepath.Join(output, e.proxy+".zip")
+					if err := os.WriteFile(filename, e.bundle, 0644); err != nil {
+						log.FromContext(ctx).WithError(err).Fatal("Failed to write bundle")
+					}
+					log.FromContext(ctx).Infof("Wrote %s", filename)
+				}
+				return
 			}
 
-			if err := createProxy(ctx, args[1], root.Name, proxyZip.Bytes(), config.Registry.Token); err != nil {
-				log.FromContext(ctx).WithError(err).Fatal("Failed to create proxy")
+			if err := applyPlan(ctx, client, apigee, exportOpts, plan, jobs); err != nil {
+				log.FromContext(ctx).WithError(err).Fatal("Failed to export proxies")
 			}
 		},
 	}
+	cmd.Flags().StringVar(&opts.auth, "auth", authNone, "verify callers with API keys (apikey) or OAuth access tokens (oauth)")
+	cmd.Flags().StringSliceVar(&opts.corsOrigins, "cors-origins", nil, "origins allowed to make cross-origin requests (enables CORS)")
+	cmd.Flags().BoolVar(&opts.validate, "validate", true, "validate requests against the deployment's OpenAPI v3 spec")
+	cmd.Flags().StringVar(&opts.basePath, "base-path", "", "proxy base path (derived from the spec if unspecified)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "write the proxy bundle to a local zip file instead of creating a proxy")
+	cmd.Flags().StringVar(&output, "output", ".", "directory for bundles written with --dry-run")
+	cmd.Flags().StringVar(&exportOpts.environment, "environment", "", "environment to deploy the proxy to")
+	cmd.Flags().DurationVar(&exportOpts.wait, "wait", 0, "how long to wait for the deployment to be ready (requires --environment)")
+	cmd.Flags().StringVar(&apigeeURL, "apigee-url", defaultApigeeURL, "Apigee management API endpoint")
+	_ = cmd.Flags().MarkHidden("apigee-url")
+	cmd.Flags().StringVar(&filter, "filter", "", "filter selected deployments")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 10, "number of actions to perform concurrently")
 
 	ctx := context.Background()
 	if err := cmd.ExecuteContext(ctx); err != nil {
@@ -134,59 +126,36 @@ func main() {
 	}
 }
 
-func bundle(root APIProxy, proxy ProxyEndpoint, target TargetEndpoint) (bytes.Buffer, error) {
-	var buf bytes.Buffer
-	zw := zip.NewWriter(&buf)
-
-	if err := write(zw, "apiproxy/"+root.Name+".xml", root); err != nil {
-		return buf, err
-	}
-
-	if err := write(zw, "apiproxy/proxies/default.xml", proxy); err != nil {
-		return buf, err
-	}
-
-	if err := write(zw, "apiproxy/targets/default.xml", target); err != nil {
-		return buf, err
-	}
-
-	if err := zw.Close(); err != nil {
-		return buf, err
+// bundleForDeployment builds a proxy bundle for a deployment.
+// If the deployment references an OpenAPI spec, the proxy is generated from it.
+func bundleForDeployment(ctx context.Context, client connection.RegistryClient, proxyName string, deployment *rpc.ApiDeployment, opts bundleOptions) (*proxyBundle, error) {
+	spec, err := specForDeployment(ctx, client, deployment)
+	if err != nil {
+		return nil, err
 	}
-
-	return buf, nil
+	return newProxyBundle(proxyName, deployment.DisplayName, deployment.Description, deployment.EndpointUri, spec, opts)
 }
 
-func write(zw *zip.Writer, name string, v any) error {
-	out, err := xml.MarshalIndent(v, "", "  ")
-	if err != nil {
-		return err
+// specForDeployment returns the OpenAPI spec referenced by a deployment, or nil if there is none.
+func specForDeployment(ctx context.Context, client connection.RegistryClient, deployment *rpc.ApiDeployment) (*openAPISpec, error) {
+	if deployment.ApiSpecRevision == "" {
+		log.FromContext(ctx).Infof("%s doesn't reference a spec, creating a pass-through proxy", deployment.Name)
+		return nil, nil
 	}
-
-	w, err := zw.Create(name)
+	spec, err := client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: deployment.ApiSpecRevision})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if _, err := w.Write([]byte(xml.Header)); err != nil {
-		return err
+	if !mime.IsOpenAPIv2(spec.MimeType) && !mime.IsOpenAPIv3(spec.MimeType) {
+		log.FromContext(ctx).Warnf("%s isn't an OpenAPI spec (%s), creating a pass-through proxy", spec.Name, spec.MimeType)
+		return nil, nil
 	}
-
-	if _, err := w.Write(out); err != nil {
-		return err
+	if err := visitor.FetchSpecContents(ctx, client, spec); err != nil {
+		return nil, err
 	}
-
-	return nil
-}
-
-func createProxy(ctx context.Context, org, name string, bundle []byte, token string) error {
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://apigee.googleapis.com/v1/%s/apis?action=import&name=%s", org, name), bytes.NewReader(bundle))
-	if err != nil {
-		return err
+	filename := spec.Filename
+	if filename == "" {
+		filename = "openapi.yaml"
 	}
-	req.Header.Add("Content-Type", "application/octet-stream")
-	req.Header.Add("Authorization", "Bearer "+token)
-
-	_, err = http.DefaultClient.Do(req)
-	return err
+	return parseOpenAPISpec(filepath.Base(filename), spec.Contents)
 }
@@ -0,0 +1,114 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpec holds the parts of an OpenAPI (v2 or v3) document used to generate proxies.
+type openAPISpec struct {
+	filename string
+	contents []byte
+	document openAPIDocument
+}
+
+type openAPIDocument struct {
+	Swagger  string `yaml:"swagger"`
+	OpenAPI  string `yaml:"openapi"`
+	BasePath string `yaml:"basePath"`
+	Servers  []struct {
+		URL string `yaml:"url"`
+	} `yaml:"servers"`
+	Paths map[string]map[string]yaml.Node `yaml:"paths"`
+}
+
+// operation is a single operation in an OpenAPI document.
+type operation struct {
+	id      string
+	summary string
+	method  string
+	path    string
+}
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// parseOpenAPISpec reads an OpenAPI document in YAML or JSON format.
+func parseOpenAPISpec(filename string, contents []byte) (*openAPISpec, error) {
+	spec := &openAPISpec{filename: filename, contents: contents}
+	if err := yaml.Unmarshal(contents, &spec.document); err != nil {
+		return nil, err
+	}
+	if spec.document.Swagger == "" && spec.document.OpenAPI == "" {
+		return nil, fmt.Errorf("%s is not an OpenAPI document", filename)
+	}
+	return spec, nil
+}
+
+func (s *openAPISpec) isV3() bool {
+	return strings.HasPrefix(s.document.OpenAPI, "3.")
+}
+
+// basePath returns the path of the first server (v3) or the basePath (v2).
+func (s *openAPISpec) basePath() string {
+	if !s.isV3() {
+		return s.document.BasePath
+	}
+	if len(s.document.Servers) == 0 {
+		return ""
+	}
+	u, err := url.Parse(s.document.Servers[0].URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// operations returns the operations in the document, ordered by path and method.
+func (s *openAPISpec) operations() []operation {
+	paths := make([]string, 0, len(s.document.Paths))
+	for path := range s.document.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ops []operation
+	for _, path := range paths {
+		item := s.document.Paths[path]
+		for _, method := range httpMethods {
+			node, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op struct {
+				OperationID string `yaml:"operationId"`
+				Summary     string `yaml:"summary"`
+			}
+			_ = node.Decode(&op)
+			ops = append(ops, operation{
+				id:      op.OperationID,
+				summary: op.Summary,
+				method:  strings.ToUpper(method),
+				path:    path,
+			})
+		}
+	}
+	return ops
+}
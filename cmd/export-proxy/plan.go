@@ -0,0 +1,199 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/apigee/registry/cmd/registry/tasks"
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/names"
+	"github.com/apigee/registry/pkg/visitor"
+	"github.com/apigee/registry/rpc"
+)
+
+// actionUnknown is planned when Apigee can't be checked (in dry runs without credentials).
+const actionUnknown = "unknown"
+
+// isPattern returns true if a deployment name matches more than one deployment.
+func isPattern(name names.Deployment) bool {
+	return name.ApiID == "-" || name.DeploymentID == "-"
+}
+
+// proxyName returns the name of the proxy for a deployment. Deployments that
+// were exported to the organization before keep their proxies. Otherwise,
+// proxies are named with deployment IDs, which are prefixed with API IDs
+// when several APIs are exported together.
+func proxyName(org string, deployment *rpc.ApiDeployment, qualified bool) (string, error) {
+	if proxy, ok := deployment.GetAnnotations()[proxyAnnotation]; ok && strings.HasPrefix(proxy, org+"/apis/") {
+		return strings.TrimPrefix(proxy, org+"/apis/"), nil
+	}
+	name, err := names.ParseDeployment(deployment.GetName())
+	if err != nil {
+		return "", err
+	}
+	if qualified {
+		return name.ApiID + "-" + name.DeploymentID, nil
+	}
+	return name.DeploymentID, nil
+}
+
+// planTask plans the export of a single deployment.
+type planTask struct {
+	client     connection.RegistryClient
+	apigee     *apigeeClient
+	org        string
+	deployment *rpc.ApiDeployment
+	qualified  bool
+	opts       bundleOptions
+
+	mutex *sync.Mutex
+	plan  *[]*export
+	// cancel stops planning when a task fails.
+	cancel context.CancelFunc
+}
+
+func (task *planTask) String() string {
+	return "plan " + task.deployment.GetName()
+}
+
+func (task *planTask) Run(ctx context.Context) error {
+	err := task.run(ctx)
+	if err != nil {
+		task.cancel()
+	}
+	return err
+}
+
+func (task *planTask) run(ctx context.Context) error {
+	proxy, err := proxyName(task.org, task.deployment, task.qualified)
+	if err != nil {
+		return err
+	}
+	bundle, err := bundleForDeployment(ctx, task.client, proxy, task.deployment, task.opts)
+	if err != nil {
+		return err
+	}
+	b, err := bundle.zip()
+	if err != nil {
+		return err
+	}
+	var e *export
+	if task.apigee == nil {
+		e = &export{deployment: task.deployment, proxy: proxy, bundle: b.Bytes(), action: actionUnknown}
+	} else if e, err = planExport(ctx, task.apigee, task.org, task.deployment, b.Bytes(), proxy); err != nil {
+		return err
+	}
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	*task.plan = append(*task.plan, e)
+	return nil
+}
+
+// buildPlan plans the export of every deployment that matches a name or pattern.
+// If apigee is nil, proxies aren't checked and all actions are unknown.
+// Plans are ordered by deployment name.
+func buildPlan(ctx context.Context, client connection.RegistryClient, apigee *apigeeClient, org string,
+	pattern names.Deployment, filter string, opts bundleOptions, jobs int) ([]*export, error) {
+	var plan []*export
+	var mutex sync.Mutex
+	// When a task fails, the pool stops taking tasks, so listing must stop too.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	taskQueue, wait := tasks.WorkerPool(ctx, jobs, false)
+	err := visitor.ListDeployments(ctx, client, pattern, 0, filter, func(ctx context.Context, deployment *rpc.ApiDeployment) error {
+		task := &planTask{
+			client:     client,
+			apigee:     apigee,
+			org:        org,
+			deployment: deployment,
+			qualified:  isPattern(pattern),
+			opts:       opts,
+			mutex:      &mutex,
+			plan:       &plan,
+			cancel:     cancel,
+		}
+		select {
+		case taskQueue <- task:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	// The error of a failed task explains the cancellation of the listing.
+	if waitErr := wait(); waitErr != nil {
+		err = waitErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].deployment.GetName() < plan[j].deployment.GetName()
+	})
+	return plan, nil
+}
+
+// printPlan writes the action, proxy and deployment of each planned export.
+func printPlan(w io.Writer, plan []*export) error {
+	counts := make(map[string]int)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, e := range plan {
+		counts[e.action]++
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.action, e.proxy, e.deployment.GetName())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Plan: %d to create, %d to update, %d unchanged, %d unknown.\n",
+		counts[actionCreate], counts[actionUpdate], counts[actionUnchanged], counts[actionUnknown])
+	return err
+}
+
+// applyTask applies a single planned export.
+type applyTask struct {
+	client connection.RegistryClient
+	apigee *apigeeClient
+	opts   exportOptions
+	export *export
+}
+
+func (task *applyTask) String() string {
+	return task.export.action + " " + task.export.proxy
+}
+
+func (task *applyTask) Run(ctx context.Context) error {
+	return applyExport(ctx, task.client, task.apigee, task.opts, task.export)
+}
+
+// applyPlan applies planned exports with at most jobs running concurrently.
+// All exports are attempted, and the first error is returned.
+func applyPlan(ctx context.Context, client connection.RegistryClient, apigee *apigeeClient, opts exportOptions, plan []*export, jobs int) error {
+	taskQueue, wait := tasks.WorkerPool(ctx, jobs, true)
+	for _, e := range plan {
+		taskQueue <- &applyTask{
+			client: client,
+			apigee: apigee,
+			opts:   opts,
+			export: e,
+		}
+	}
+	return wait()
+}
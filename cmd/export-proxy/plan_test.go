@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/pkg/names"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPlan(t *testing.T) {
+	ctx := context.Background()
+	client, _ := grpctest.SetupRegistry(ctx, t, "export-proxy-plan-test", []seeder.RegistryResource{
+		&rpc.ApiDeployment{
+			Name:        "projects/export-proxy-plan-test/locations/global/apis/petstore/deployments/prod",
+			EndpointUri: "https://petstore.example.com",
+		},
+		&rpc.ApiDeployment{
+			Name:        "projects/export-proxy-plan-test/locations/global/apis/bookstore/deployments/prod",
+			EndpointUri: "https://bookstore.example.com",
+		},
+	})
+	fake := newFakeApigee()
+	s := httptest.NewServer(fake)
+	defer s.Close()
+	apigee := newApigeeClient(s.URL, "token")
+	apigee.pollInterval = time.Millisecond
+	opts := exportOptions{org: "organizations/o", environment: "test", wait: time.Second}
+	pattern, err := names.ParseDeployment("projects/export-proxy-plan-test/locations/global/apis/-/deployments/-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without Apigee, actions can't be planned.
+	plan, err := buildPlan(ctx, client, nil, opts.org, pattern, "", bundleOptions{}, 2)
+	if err != nil {
+		t.Fatalf("buildPlan() returned error: %s", err)
+	}
+	if len(plan) != 2 || plan[0].action != actionUnknown {
+		t.Fatalf("unexpected plan without Apigee: %v", plan)
+	}
+	var out bytes.Buffer
+	if err := printPlan(&out, plan); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Plan: 0 to create, 0 to update, 0 unchanged, 2 unknown.") {
+		t.Errorf("unexpected plan output:\n%s", out.String())
+	}
+
+	plan, err = buildPlan(ctx, client, apigee, opts.org, pattern, "", bundleOptions{}, 2)
+	if err != nil {
+		t.Fatalf("buildPlan() returned error: %s", err)
+	}
+	want := []string{"bookstore-prod", "petstore-prod"}
+	if len(plan) != len(want) {
+		t.Fatalf("plan has %d exports, want %d", len(plan), len(want))
+	}
+	for i, e := range plan {
+		if e.proxy != want[i] || e.action != actionCreate {
+			t.Errorf("plan[%d] is %s of %s, want create of %s", i, e.action, e.proxy, want[i])
+		}
+	}
+
+	out.Reset()
+	if err := printPlan(&out, plan); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Plan: 2 to create, 0 to update, 0 unchanged, 0 unknown.") {
+		t.Errorf("unexpected plan output:\n%s", out.String())
+	}
+
+	if err := applyPlan(ctx, client, apigee, opts, plan, 2); err != nil {
+		t.Fatalf("applyPlan() returned error: %s", err)
+	}
+	if fake.imports != 2 {
+		t.Errorf("applyPlan() imported %d proxies, want 2", fake.imports)
+	}
+
+	// After applying, a new plan finds nothing to do.
+	plan, err = buildPlan(ctx, client, apigee, opts.org, pattern, "", bundleOptions{}, 2)
+	if err != nil {
+		t.Fatalf("buildPlan() returned error: %s", err)
+	}
+	for _, e := range plan {
+		if e.action != actionUnchanged {
+			t.Errorf("replanned %s of %s, want unchanged", e.action, e.proxy)
+		}
+	}
+}
+
+func TestProxyName(t *testing.T) {
+	deployment := &rpc.ApiDeployment{Name: "projects/p/locations/global/apis/a/deployments/d"}
+	tests := []struct {
+		annotations map[string]string
+		qualified   bool
+		want        string
+	}{
+		{nil, false, "d"},
+		{nil, true, "a-d"},
+		{map[string]string{proxyAnnotation: "organizations/o/apis/existing"}, true, "existing"},
+		{map[string]string{proxyAnnotation: "organizations/other/apis/existing"}, false, "d"},
+	}
+	for _, test := range tests {
+		deployment.Annotations = test.annotations
+		got, err := proxyName("organizations/o", deployment, test.qualified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("proxyName(%v, %t) = %s, want %s", test.annotations, test.qualified, got, test.want)
+		}
+	}
+}
+
+func TestPlanError(t *testing.T) {
+	ctx := context.Background()
+	// More deployments than the task queue holds, which all fail to plan.
+	var seeds []seeder.RegistryResource
+	for i := 0; i < 1100; i++ {
+		seeds = append(seeds, &rpc.ApiDeployment{
+			Name:            fmt.Sprintf("projects/export-proxy-plan-error-test/locations/global/apis/petstore/deployments/d%d", i),
+			ApiSpecRevision: "projects/export-proxy-plan-error-test/locations/global/apis/petstore/versions/v1/specs/missing",
+		})
+	}
+	client, _ := grpctest.SetupRegistry(ctx, t, "export-proxy-plan-error-test", seeds)
+	pattern, err := names.ParseDeployment("projects/export-proxy-plan-error-test/locations/global/apis/-/deployments/-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := buildPlan(ctx, client, nil, "organizations/o", pattern, "", bundleOptions{}, 1)
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("buildPlan() returned %v, want NotFound", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("buildPlan() didn't return after a task failed")
+	}
+}
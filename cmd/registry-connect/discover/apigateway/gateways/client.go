@@ -0,0 +1,95 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateways
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/apigateway/v1"
+	"google.golang.org/api/option"
+)
+
+// Client reads the APIs, API configs and gateways of a project.
+type Client interface {
+	Project() string
+	Apis(ctx context.Context) ([]*apigateway.ApigatewayApi, error)
+	// ApiConfigs returns the configs of an API, including their source files.
+	ApiConfigs(ctx context.Context, api string) ([]*apigateway.ApigatewayApiConfig, error)
+	// Gateways returns the gateways in all locations.
+	Gateways(ctx context.Context) ([]*apigateway.ApigatewayGateway, error)
+}
+
+// NewRESTClient returns a client that calls the API Gateway REST API.
+func NewRESTClient(ctx context.Context, project string, opts ...option.ClientOption) (Client, error) {
+	service, err := apigateway.NewService(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &restClient{project: project, service: service}, nil
+}
+
+type restClient struct {
+	project string
+	service *apigateway.Service
+}
+
+func (c *restClient) Project() string {
+	return c.project
+}
+
+func (c *restClient) Apis(ctx context.Context) ([]*apigateway.ApigatewayApi, error) {
+	var apis []*apigateway.ApigatewayApi
+	parent := fmt.Sprintf("projects/%s/locations/global", c.project)
+	err := c.service.Projects.Locations.Apis.List(parent).Pages(ctx, func(resp *apigateway.ApigatewayListApisResponse) error {
+		apis = append(apis, resp.Apis...)
+		return nil
+	})
+	return apis, err
+}
+
+func (c *restClient) ApiConfigs(ctx context.Context, api string) ([]*apigateway.ApigatewayApiConfig, error) {
+	var names []string
+	err := c.service.Projects.Locations.Apis.Configs.List(api).Pages(ctx, func(resp *apigateway.ApigatewayListApiConfigsResponse) error {
+		for _, config := range resp.ApiConfigs {
+			names = append(names, config.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Listed configs don't include their files.
+	var configs []*apigateway.ApigatewayApiConfig
+	for _, name := range names {
+		config, err := c.service.Projects.Locations.Apis.Configs.Get(name).View("FULL").Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+func (c *restClient) Gateways(ctx context.Context) ([]*apigateway.ApigatewayGateway, error) {
+	var gateways []*apigateway.ApigatewayGateway
+	parent := fmt.Sprintf("projects/%s/locations/-", c.project)
+	err := c.service.Projects.Locations.Gateways.List(parent).Pages(ctx, func(resp *apigateway.ApigatewayListGatewaysResponse) error {
+		gateways = append(gateways, resp.Gateways...)
+		return nil
+	})
+	return gateways, err
+}
@@ -15,272 +15,318 @@
 package gateways
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/common"
 	"github.com/apigee/registry/pkg/encoding"
+	"github.com/apigee/registry/pkg/log"
+	"github.com/apigee/registry/pkg/mime"
 	"github.com/spf13/cobra"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/apigateway/v1"
+	"gopkg.in/yaml.v3"
 )
 
-const weNeedToReadAPIs = false
-
 func Command() *cobra.Command {
 	var output string
+	var project string
 	var cmd = &cobra.Command{
 		Use:   "gateways",
-		Short: "Export API Gateway Gateways",
+		Short: "Export API Gateway APIs, configs and gateways",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
-			project, err := getProject()
-			if err != nil {
-				return err
-			}
-			if weNeedToReadAPIs {
-				apis, err := fetchApis()
+			ctx := cmd.Context()
+			if project == "" {
+				creds, err := google.FindDefaultCredentials(ctx, apigateway.CloudPlatformScope)
 				if err != nil {
 					return err
 				}
-				for i, api := range apis {
-					fmt.Printf("%d %+v\n", i, api)
+				if creds.ProjectID == "" {
+					return fmt.Errorf("--project must be set when it can't be found from default credentials")
 				}
+				project = creds.ProjectID
 			}
-			gateways, err := fetchGateways()
+			client, err := NewRESTClient(ctx, project)
 			if err != nil {
 				return err
 			}
-			for _, gateway := range gateways {
-				fmt.Printf("exporting %s\n", gateway.Name)
-				config, err := fetchConfig(gateway.ApiConfig)
-				if err != nil {
-					return err
-				}
-				err = writeRegistryYAML(output, project, &gateway, config)
-				if err != nil {
-					return err
-				}
-			}
-			return nil
+			return export(ctx, client, output)
 		},
 	}
 	cmd.Flags().StringVar(&output, "output", "gateway-export", "output directory")
+	cmd.Flags().StringVar(&project, "project", "", "Google Cloud project (defaults to the project of the default credentials)")
 
 	return cmd
 }
 
-func getProject() (string, error) {
-	buf := &bytes.Buffer{}
-	command := "gcloud config get project"
-	cmdargs := strings.Split(command, " ")
-	c := exec.Command(cmdargs[0], cmdargs[1:]...)
-	c.Stdout = buf
-	if err := c.Run(); err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(buf.String()), nil
-}
-
-func fetchApis() ([]GatewayApi, error) {
-	buf := &bytes.Buffer{}
-	command := "gcloud api-gateway apis list --format json"
-	cmdargs := strings.Split(command, " ")
-	c := exec.Command(cmdargs[0], cmdargs[1:]...)
-	c.Stdout = buf
-	if err := c.Run(); err != nil {
-		return nil, err
-	}
-	var apis []GatewayApi
-	if err := json.Unmarshal(buf.Bytes(), &apis); err != nil {
-		return nil, err
+// export writes a directory for each API, containing an info.yaml file with the
+// API, a version for each of its configs and a deployment for each gateway
+// that serves one of its configs. Spec files are written to a subdirectory for
+// each config, and info.yaml refers to them with relative file names.
+func export(ctx context.Context, client Client, output string) error {
+	apis, err := client.Apis(ctx)
+	if err != nil {
+		return err
 	}
-	return apis, nil
-}
-
-func fetchGateways() ([]Gateway, error) {
-	buf := &bytes.Buffer{}
-	command := "gcloud api-gateway gateways list --format json"
-	cmdargs := strings.Split(command, " ")
-	c := exec.Command(cmdargs[0], cmdargs[1:]...)
-	c.Stdout = buf
-	if err := c.Run(); err != nil {
-		return nil, err
+	gateways, err := client.Gateways(ctx)
+	if err != nil {
+		return err
 	}
-	var gateways []Gateway
-	if err := json.Unmarshal(buf.Bytes(), &gateways); err != nil {
-		return nil, err
+	gatewaysByConfig := map[string][]*apigateway.ApigatewayGateway{}
+	for _, g := range gateways {
+		gatewaysByConfig[g.ApiConfig] = append(gatewaysByConfig[g.ApiConfig], g)
 	}
-	return gateways, nil
-}
 
-func fetchConfig(name string) (*GatewayConfig, error) {
-	buf := &bytes.Buffer{}
-	command := "gcloud api-gateway api-configs describe --view FULL --format json " + name
-	cmdargs := strings.Split(command, " ")
-	c := exec.Command(cmdargs[0], cmdargs[1:]...)
-	c.Stdout = buf
-	if err := c.Run(); err != nil {
-		return nil, err
-	}
-	var config GatewayConfig
-	if err := json.Unmarshal(buf.Bytes(), &config); err != nil {
-		return nil, err
+	for _, api := range apis {
+		log.FromContext(ctx).Infof("exporting %s", api.Name)
+		configs, err := client.ApiConfigs(ctx, api.Name)
+		if err != nil {
+			return err
+		}
+		if err := writeAPI(output, client.Project(), api, configs, gatewaysByConfig); err != nil {
+			return err
+		}
 	}
-	return &config, nil
-}
-
-type GatewayApi struct {
-	CreateTime     string `json:"createTime"`
-	DisplayName    string `json:"displayName"`
-	ManagedService string `json:"managedService"`
-	Name           string `json:"name"`
-	State          string `json:"state"`
-	UpdateTime     string `json:"updateTime"`
-}
-
-type Gateway struct {
-	ApiConfig       string `json:"apiConfig"`
-	CreateTime      string `json:"createTime"`
-	DefaultHostname string `json:"defaultHostname"`
-	DisplayName     string `json:"displayName"`
-	Name            string `json:"name"`
-	State           string `json:"state"`
-	UpdateTime      string `json:"updateTime"`
-}
-
-type GatewayConfig struct {
-	CreateTime            string                     `json:"createTime"`
-	DisplayName           string                     `json:"displayName"`
-	GatewayServiceAccount string                     `json:"gatewayServiceAccount"`
-	GrpcServices          []map[string][]GatewayFile `json:"grpcServices"`
-	ManagedServiceConfigs []*GatewayFile             `json:"managedServiceConfigs"`
-	OpenAPIDocuments      []map[string]GatewayFile   `json:"openapiDocuments"`
-	Name                  string                     `json:"name"`
-	ServiceConfigId       string                     `json:"serviceConfigId"`
-	State                 string                     `json:"state"`
-	UpdateTime            string                     `json:"updateTime"`
-}
-
-type GatewayFile struct {
-	Contents string `json:"contents"`
-	Path     string `json:"path"`
+	return nil
 }
 
-func writeRegistryYAML(output, project string, gateway *Gateway, config *GatewayConfig) error {
-	apiId := filepath.Base(gateway.Name)
-	err := os.MkdirAll(filepath.Join(output, project+"-"+apiId), 0777)
-	if err != nil {
+func writeAPI(output, project string, api *apigateway.ApigatewayApi, configs []*apigateway.ApigatewayApiConfig,
+	gatewaysByConfig map[string][]*apigateway.ApigatewayGateway) error {
+	apiID := project + "-" + path.Base(api.Name)
+	dir := filepath.Join(output, apiID)
+	if err := os.MkdirAll(dir, 0777); err != nil {
 		return err
 	}
-	description := "Exported from API Gateway"
-
-	specname := "openapi"
-	filename := "openapi.yaml"
-	mimetype := "application/x.openapi+gzip;version=2.0"
-	if config.GrpcServices != nil {
-		specname = "protos"
-		filename = "protos.zip"
-		mimetype = "application/x.proto+zip"
+	displayName := api.DisplayName
+	if displayName == "" {
+		displayName = project + " gateway API " + path.Base(api.Name)
 	}
 	a := &encoding.Api{
 		Header: encoding.Header{
-			ApiVersion: "apigeeregistry/v1",
+			ApiVersion: encoding.RegistryV1,
 			Kind:       "API",
 			Metadata: encoding.Metadata{
-				Name: project + "-" + apiId,
+				Name: apiID,
 				Labels: map[string]string{
 					"provider": strings.ReplaceAll(project, ".", "-"),
 				},
+				Annotations: map[string]string{
+					"apigateway-api":             api.Name,
+					"apigateway-managed-service": api.ManagedService,
+				},
 			},
 		},
 		Data: encoding.ApiData{
-			DisplayName: project + " gateway " + apiId,
-			Description: description,
-			ApiVersions: []*encoding.ApiVersion{
-				{
-					Header: encoding.Header{
-						Metadata: encoding.Metadata{
-							Name: "v1",
+			DisplayName: displayName,
+			Description: "Exported from API Gateway",
+		},
+	}
+
+	for _, config := range configs {
+		version, err := writeVersion(dir, config)
+		if err != nil {
+			return err
+		}
+		a.Data.ApiVersions = append(a.Data.ApiVersions, version)
+
+		for _, gateway := range gatewaysByConfig[config.Name] {
+			deployment := &encoding.ApiDeployment{
+				Header: encoding.Header{
+					ApiVersion: encoding.RegistryV1,
+					Kind:       "Deployment",
+					Metadata: encoding.Metadata{
+						Name: path.Base(gateway.Name),
+						Labels: map[string]string{
+							"apihub-gateway": "apihub-google-cloud-api-gateway",
 						},
-					},
-					Data: encoding.ApiVersionData{
-						DisplayName: "v1",
-						ApiSpecs: []*encoding.ApiSpec{
-							{
-								Header: encoding.Header{
-									Metadata: encoding.Metadata{
-										Name: specname,
-									},
-								},
-								Data: encoding.ApiSpecData{
-									FileName: filename,
-									MimeType: mimetype,
-								},
-							},
+						Annotations: map[string]string{
+							"apigateway-gateway": gateway.Name,
+							"apigateway-state":   gateway.State,
 						},
 					},
 				},
-			},
-			ApiDeployments: []*encoding.ApiDeployment{
-				{
-					Header: encoding.Header{
-						Metadata: encoding.Metadata{
-							Name: "gateway",
-							Labels: map[string]string{
-								"apihub-gateway": "apihub-google-cloud-api-gateway",
-							},
-						},
-					},
-					Data: encoding.ApiDeploymentData{
-						DisplayName:     "gateway",
-						ApiSpecRevision: "v1/specs/" + specname,
-						EndpointURI:     "https://" + gateway.DefaultHostname,
-					},
+				Data: encoding.ApiDeploymentData{
+					DisplayName: gateway.DisplayName,
+					EndpointURI: "https://" + gateway.DefaultHostname,
 				},
-			},
-		},
+			}
+			if len(version.Data.ApiSpecs) > 0 {
+				deployment.Data.ApiSpecRevision = common.SpecRevision(version.Metadata.Name, version.Data.ApiSpecs[0].Metadata.Name)
+			}
+			a.Data.ApiDeployments = append(a.Data.ApiDeployments, deployment)
+		}
 	}
+
 	b, err := encoding.EncodeYAML(a)
 	if err != nil {
-		panic(err)
-	}
-	if err = os.WriteFile(filepath.Join(output, project+"-"+apiId, "info.yaml"), b, 0666); err != nil {
 		return err
 	}
-	for _, openapi := range config.OpenAPIDocuments {
-		f := openapi["document"]
-		contents, err := base64.StdEncoding.DecodeString(f.Contents)
+	return os.WriteFile(filepath.Join(dir, "info.yaml"), b, 0666)
+}
+
+// writeVersion writes the spec files of an API config and returns a version
+// that describes it. OpenAPI documents are exported as individual specs, and
+// gRPC sources are zipped together with the config's service configurations.
+func writeVersion(dir string, config *apigateway.ApigatewayApiConfig) (*encoding.ApiVersion, error) {
+	versionID := path.Base(config.Name)
+	if err := os.MkdirAll(filepath.Join(dir, versionID), 0777); err != nil {
+		return nil, err
+	}
+	version := &encoding.ApiVersion{
+		Header: encoding.Header{
+			ApiVersion: encoding.RegistryV1,
+			Kind:       "Version",
+			Metadata: encoding.Metadata{
+				Name: versionID,
+				Annotations: map[string]string{
+					"apigateway-api-config":        config.Name,
+					"apigateway-service-config-id": config.ServiceConfigId,
+				},
+			},
+		},
+		Data: encoding.ApiVersionData{
+			DisplayName: config.DisplayName,
+			State:       strings.ToLower(config.State),
+		},
+	}
+
+	specIDs := map[string]bool{}
+	for _, doc := range config.OpenapiDocuments {
+		if doc.Document == nil {
+			continue
+		}
+		contents, err := base64.StdEncoding.DecodeString(doc.Document.Contents)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if err = os.WriteFile(filepath.Join(output, project+"-"+apiId, f.Path), contents, 0666); err != nil {
-			return err
+		// Documents in different directories can have the same name,
+		// so files are named with the unique IDs of their specs.
+		specID := uniqueID(specIDs, doc.Document.Path)
+		filename := path.Join(versionID, specID+path.Ext(doc.Document.Path))
+		if err := os.WriteFile(filepath.Join(dir, filename), contents, 0666); err != nil {
+			return nil, err
 		}
+		version.Data.ApiSpecs = append(version.Data.ApiSpecs, &encoding.ApiSpec{
+			Header: encoding.Header{
+				ApiVersion: encoding.RegistryV1,
+				Kind:       "Spec",
+				Metadata: encoding.Metadata{
+					Name: specID,
+				},
+			},
+			Data: encoding.ApiSpecData{
+				FileName: filename,
+				MimeType: mime.OpenAPIMimeType("", openAPIVersion(contents)),
+			},
+		})
 	}
-	for _, grpcService := range config.GrpcServices {
-		files := grpcService["source"]
-		for _, f := range files {
+
+	if len(config.GrpcServices) > 0 {
+		files := map[string][]byte{}
+		for _, service := range config.GrpcServices {
+			for _, f := range service.Source {
+				contents, err := base64.StdEncoding.DecodeString(f.Contents)
+				if err != nil {
+					return nil, err
+				}
+				files[f.Path] = contents
+			}
+		}
+		for _, f := range config.ManagedServiceConfigs {
 			contents, err := base64.StdEncoding.DecodeString(f.Contents)
 			if err != nil {
-				return err
-			}
-			if err = os.WriteFile(filepath.Join(output, project+"-"+apiId, f.Path), contents, 0666); err != nil {
-				return err
+				return nil, err
 			}
+			files[f.Path] = contents
+		}
+		b, err := zipFiles(files)
+		if err != nil {
+			return nil, err
+		}
+		specID := uniqueID(specIDs, "protos")
+		filename := path.Join(versionID, specID+".zip")
+		if err := os.WriteFile(filepath.Join(dir, filename), b, 0666); err != nil {
+			return nil, err
+		}
+		version.Data.ApiSpecs = append(version.Data.ApiSpecs, &encoding.ApiSpec{
+			Header: encoding.Header{
+				ApiVersion: encoding.RegistryV1,
+				Kind:       "Spec",
+				Metadata: encoding.Metadata{
+					Name: specID,
+				},
+			},
+			Data: encoding.ApiSpecData{
+				FileName: filename,
+				MimeType: mime.ProtobufMimeType("+zip"),
+			},
+		})
+	}
+	return version, nil
+}
+
+var invalidIDCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// uniqueID returns an ID derived from a file name that isn't in ids, and adds it to ids.
+func uniqueID(ids map[string]bool, filename string) string {
+	base := strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	base = strings.Trim(invalidIDCharacters.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	if base == "" {
+		base = "spec"
+	}
+	id := base
+	for i := 2; ids[id]; i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	ids[id] = true
+	return id
+}
+
+// openAPIVersion returns the major and minor version of an OpenAPI document.
+// API Gateway requires OpenAPI 2.0, which is assumed if the document can't be read.
+func openAPIVersion(contents []byte) string {
+	var doc struct {
+		OpenAPI string `yaml:"openapi"`
+	}
+	if err := yaml.Unmarshal(contents, &doc); err == nil && doc.OpenAPI != "" {
+		parts := strings.Split(doc.OpenAPI, ".")
+		if len(parts) >= 2 {
+			return parts[0] + "." + parts[1]
 		}
+		return doc.OpenAPI
+	}
+	return "2.0"
+}
+
+func zipFiles(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
 	}
-	for _, f := range config.ManagedServiceConfigs {
-		contents, err := base64.StdEncoding.DecodeString(f.Contents)
+	sort.Strings(names)
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if err = os.WriteFile(filepath.Join(output, project+"-"+apiId, f.Path), contents, 0666); err != nil {
-			return err
+		if _, err := f.Write(files[name]); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
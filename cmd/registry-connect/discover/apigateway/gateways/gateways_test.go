@@ -0,0 +1,223 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateways
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apigee/registry/pkg/encoding"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/apigateway/v1"
+	"google.golang.org/api/option"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	petstore = "projects/p/locations/global/apis/petstore"
+	library  = "projects/p/locations/global/apis/library"
+)
+
+func encoded(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// fakeAPIGateway serves a project with an OpenAPI API that has two configs,
+// one of which is deployed, and a gRPC API with one deployed config.
+func fakeAPIGateway(t *testing.T) *httptest.Server {
+	configs := map[string]*apigateway.ApigatewayApiConfig{
+		petstore + "/configs/v1": {
+			Name:        petstore + "/configs/v1",
+			DisplayName: "Petstore v1",
+			State:       "ACTIVE",
+			OpenapiDocuments: []*apigateway.ApigatewayApiConfigOpenApiDocument{{
+				Document: &apigateway.ApigatewayApiConfigFile{Path: "openapi.yaml", Contents: encoded("swagger: \"2.0\"\n")},
+			}},
+		},
+		petstore + "/configs/v2": {
+			Name:  petstore + "/configs/v2",
+			State: "ACTIVE",
+			OpenapiDocuments: []*apigateway.ApigatewayApiConfigOpenApiDocument{
+				{Document: &apigateway.ApigatewayApiConfigFile{Path: "specs/pets.yaml", Contents: encoded("swagger: \"2.0\"\n")}},
+				{Document: &apigateway.ApigatewayApiConfigFile{Path: "other/pets.yaml", Contents: encoded("swagger: \"2.0\"\nhost: other\n")}},
+			},
+		},
+		library + "/configs/c1": {
+			Name:  library + "/configs/c1",
+			State: "ACTIVE",
+			GrpcServices: []*apigateway.ApigatewayApiConfigGrpcServiceDefinition{{
+				Source: []*apigateway.ApigatewayApiConfigFile{{Path: "library.proto", Contents: encoded("syntax = \"proto3\";\n")}},
+			}},
+			ManagedServiceConfigs: []*apigateway.ApigatewayApiConfigFile{{Path: "api_config.yaml", Contents: encoded("type: google.api.Service\n")}},
+		},
+	}
+	write := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := http.NewServeMux()
+	m.HandleFunc("/v1/projects/p/locations/global/apis", func(w http.ResponseWriter, r *http.Request) {
+		// APIs are returned in two pages.
+		if r.URL.Query().Get("pageToken") == "" {
+			write(w, &apigateway.ApigatewayListApisResponse{
+				Apis:          []*apigateway.ApigatewayApi{{Name: petstore, DisplayName: "Petstore", ManagedService: "petstore.apigateway.p.cloud.goog"}},
+				NextPageToken: "next",
+			})
+			return
+		}
+		write(w, &apigateway.ApigatewayListApisResponse{Apis: []*apigateway.ApigatewayApi{{Name: library}}})
+	})
+	m.HandleFunc("/v1/projects/p/locations/-/gateways", func(w http.ResponseWriter, r *http.Request) {
+		write(w, &apigateway.ApigatewayListGatewaysResponse{Gateways: []*apigateway.ApigatewayGateway{
+			{Name: "projects/p/locations/us-central1/gateways/pets", ApiConfig: petstore + "/configs/v1", DefaultHostname: "pets.gateway.dev", State: "ACTIVE"},
+			{Name: "projects/p/locations/us-central1/gateways/books", ApiConfig: library + "/configs/c1", DefaultHostname: "books.gateway.dev", State: "ACTIVE"},
+		}})
+	})
+	m.HandleFunc("/v1/projects/p/locations/global/apis/", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/v1/"):]
+		if filepath.Base(name) == "configs" {
+			resp := &apigateway.ApigatewayListApiConfigsResponse{}
+			for _, c := range []string{petstore + "/configs/v1", petstore + "/configs/v2", library + "/configs/c1"} {
+				if filepath.Dir(c) == name {
+					// Listed configs don't include files.
+					resp.ApiConfigs = append(resp.ApiConfigs, &apigateway.ApigatewayApiConfig{Name: c})
+				}
+			}
+			write(w, resp)
+			return
+		}
+		config, ok := configs[name]
+		if !ok || r.URL.Query().Get("view") != "FULL" {
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+			return
+		}
+		write(w, config)
+	})
+	return httptest.NewServer(m)
+}
+
+func TestExport(t *testing.T) {
+	s := fakeAPIGateway(t)
+	defer s.Close()
+	ctx := context.Background()
+	client, err := NewRESTClient(ctx, "p", option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := t.TempDir()
+	if err := export(ctx, client, output); err != nil {
+		t.Fatalf("export() returned error: %s", err)
+	}
+
+	read := func(dir string) *encoding.Api {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(output, dir, "info.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		api := &encoding.Api{}
+		if err := yaml.Unmarshal(b, api); err != nil {
+			t.Fatal(err)
+		}
+		return api
+	}
+
+	api := read("p-petstore")
+	if api.Data.DisplayName != "Petstore" || api.Metadata.Annotations["apigateway-managed-service"] != "petstore.apigateway.p.cloud.goog" {
+		t.Errorf("unexpected API %+v", api)
+	}
+	var specs []string
+	for _, v := range api.Data.ApiVersions {
+		for _, s := range v.Data.ApiSpecs {
+			specs = append(specs, v.Metadata.Name+"/specs/"+s.Metadata.Name+" "+s.Data.FileName+" "+s.Data.MimeType)
+			if _, err := os.Stat(filepath.Join(output, "p-petstore", s.Data.FileName)); err != nil {
+				t.Errorf("spec file wasn't written: %s", err)
+			}
+		}
+	}
+	want := []string{
+		"v1/specs/openapi v1/openapi.yaml application/x.openapi;version=2.0",
+		"v2/specs/pets v2/pets.yaml application/x.openapi;version=2.0",
+		"v2/specs/pets-2 v2/pets-2.yaml application/x.openapi;version=2.0",
+	}
+	if diff := cmp.Diff(want, specs); diff != "" {
+		t.Errorf("unexpected specs (-want +got):\n%s", diff)
+	}
+	// Documents with the same name in different directories don't overwrite each other.
+	if b, err := os.ReadFile(filepath.Join(output, "p-petstore", "v2", "pets-2.yaml")); err != nil || string(b) != "swagger: \"2.0\"\nhost: other\n" {
+		t.Errorf("unexpected contents of other/pets.yaml: %q, %v", b, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(output, "p-petstore", "v2", "pets.yaml")); err != nil || string(b) != "swagger: \"2.0\"\n" {
+		t.Errorf("unexpected contents of specs/pets.yaml: %q, %v", b, err)
+	}
+	if len(api.Data.ApiDeployments) != 1 {
+		t.Fatalf("want 1 deployment got %d", len(api.Data.ApiDeployments))
+	}
+	d := api.Data.ApiDeployments[0]
+	if d.Metadata.Name != "pets" || d.Data.EndpointURI != "https://pets.gateway.dev" || d.Data.ApiSpecRevision != "v1/specs/openapi" {
+		t.Errorf("unexpected deployment %+v", d)
+	}
+
+	api = read("p-library")
+	if len(api.Data.ApiVersions) != 1 || len(api.Data.ApiVersions[0].Data.ApiSpecs) != 1 {
+		t.Fatalf("unexpected versions %+v", api.Data.ApiVersions)
+	}
+	spec := api.Data.ApiVersions[0].Data.ApiSpecs[0]
+	if spec.Metadata.Name != "protos" || spec.Data.MimeType != "application/x.protobuf+zip" {
+		t.Errorf("unexpected gRPC spec %+v", spec)
+	}
+	b, err := os.ReadFile(filepath.Join(output, "p-library", spec.Data.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, f := range r.File {
+		files = append(files, f.Name)
+	}
+	if diff := cmp.Diff([]string{"api_config.yaml", "library.proto"}, files); diff != "" {
+		t.Errorf("unexpected zipped files (-want +got):\n%s", diff)
+	}
+	if d := api.Data.ApiDeployments[0]; d.Data.ApiSpecRevision != "c1/specs/protos" {
+		t.Errorf("unexpected deployment %+v", d)
+	}
+}
+
+func TestOpenAPIVersion(t *testing.T) {
+	tests := map[string]string{
+		"swagger: \"2.0\"":   "2.0",
+		"openapi: 3.0.1":     "3.0",
+		"openapi: \"3.1.0\"": "3.1",
+		"not: [valid":        "2.0",
+	}
+	for doc, want := range tests {
+		if got := openAPIVersion([]byte(doc)); got != want {
+			t.Errorf("openAPIVersion(%q) = %s, want %s", doc, got, want)
+		}
+	}
+}
@@ -26,6 +26,11 @@ import (
 
 var project string // TODO: remove when a relative ReferenceList_Reference.Resource works in Hub
 
+var stateFile string
+var deletionsFile string
+var specsDir string
+var metricsConfigFile string
+
 func Command() *cobra.Command {
 	var cmd = &cobra.Command{
 		Use:   "apigee",
@@ -38,6 +43,22 @@ func Command() *cobra.Command {
 				return fmt.Errorf("--mgmt can only be set with --opdk")
 			}
 
+			if metricsConfigFile != "" {
+				flags := metricsOpts
+				if err := loadMetricsConfig(metricsConfigFile, &metricsOpts); err != nil {
+					return err
+				}
+				if cmd.Flags().Changed("metrics") {
+					metricsOpts.Metrics = flags.Metrics
+				}
+				if cmd.Flags().Changed("metrics-window") {
+					metricsOpts.Window = flags.Window
+				}
+			}
+			if metricsOpts.Window <= 0 {
+				return fmt.Errorf("--metrics-window must be positive")
+			}
+
 			ctx := cmd.Context()
 			apigee.Config.Org = args[0]
 			client, err := apigee.NewClient()
@@ -49,6 +70,12 @@ func Command() *cobra.Command {
 	}
 	cmd.Flags().StringVarP(&project, "project", "", "", "hub project id (temporary)")
 	_ = cmd.MarkFlagRequired("project")
+	cmd.Flags().StringVar(&stateFile, "state", "", "sync state file; if set, only resources that changed since the last sync are exported")
+	cmd.Flags().StringVar(&specsDir, "specs", "", "directory to write proxy specs to; if set, a version with an OpenAPI spec is exported for each proxy revision")
+	cmd.Flags().StringArrayVar(&metricsOpts.Metrics, "metrics", metricsOpts.Metrics, "Apigee analytics metric to collect for deployments (may be repeated)")
+	cmd.Flags().DurationVar(&metricsOpts.Window, "metrics-window", metricsOpts.Window, "time range of collected metrics, ending now")
+	cmd.Flags().StringVar(&metricsConfigFile, "metrics-config", "", "YAML file with metrics and window settings (overridden by flags)")
+	cmd.Flags().StringVar(&deletionsFile, "deletions", "", "file to write the names of deleted resources to (required with --state)")
 
 	cmd.Flags().BoolVar(&client.Config.Debug, "debug", false, "debug mode")
 	cmd.Flags().BoolVar(&client.Config.SkipVerify, "skipverify", false, "skip server certificate verify")
@@ -62,14 +89,14 @@ func Command() *cobra.Command {
 
 	cmd.MarkFlagsMutuallyExclusive("opdk", "edge")
 	cmd.MarkFlagsRequiredTogether("opdk", "mgmt")
+	// Deletions are only reported once, so they must be written somewhere.
+	cmd.MarkFlagsRequiredTogether("state", "deletions")
 
 	return cmd
 }
 
-func label(s string) string {
-	return strings.ToLower(regexp.MustCompile(`([^A-Za-z0-9-_]+)`).ReplaceAllString(s, "-"))
-}
+var nameReplacements = regexp.MustCompile(`([^A-Za-z0-9-]+)`)
 
 func name(s string) string {
-	return strings.ToLower(regexp.MustCompile(`([^A-Za-z0-9-]+)`).ReplaceAllString(s, "-"))
+	return strings.ToLower(nameReplacements.ReplaceAllString(s, "-"))
 }
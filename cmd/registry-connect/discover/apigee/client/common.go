@@ -49,6 +49,7 @@ func NewClient() (Client, error) {
 type Client interface {
 	Org() string
 	Proxies(ctx context.Context) ([]*apigee.GoogleCloudApigeeV1ApiProxy, error)
+	ProxyBundle(ctx context.Context, proxy string, revision string) ([]byte, error)
 	ProxyConsoleURL(ctx context.Context, proxy *apigee.GoogleCloudApigeeV1ApiProxy) string
 	ProductConsoleURL(ctx context.Context, product *apigee.GoogleCloudApigeeV1ApiProduct) string
 	Deployments(ctx context.Context) ([]*apigee.GoogleCloudApigeeV1Deployment, error)
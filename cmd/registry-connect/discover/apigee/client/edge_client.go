@@ -17,6 +17,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/apigee/edge"
@@ -87,6 +88,15 @@ func (c *EdgeClient) Proxies(ctx context.Context) ([]*apigee.GoogleCloudApigeeV1
 	return proxies, nil
 }
 
+func (c *EdgeClient) ProxyBundle(ctx context.Context, proxy string, revision string) ([]byte, error) {
+	rev, err := strconv.Atoi(revision)
+	if err != nil {
+		return nil, fmt.Errorf("invalid revision %q of proxy %q", revision, proxy)
+	}
+	bundle, _, err := c.service.Proxies.Export(proxy, edge.Revision(rev))
+	return bundle, err
+}
+
 func (c *EdgeClient) Deployments(ctx context.Context) ([]*apigee.GoogleCloudApigeeV1Deployment, error) {
 	client := c.service
 
@@ -181,8 +191,14 @@ func (c *EdgeClient) Products(ctx context.Context) ([]*apigee.GoogleCloudApigeeV
 			attrs = append(attrs, attr)
 		}
 
+		var lastModifiedAt int64
+		if !p.LastModifiedAt.IsZero() {
+			lastModifiedAt = p.LastModifiedAt.UnixMilli()
+		}
+
 		products = append(products, &apigee.GoogleCloudApigeeV1ApiProduct{
 			Name:           p.Name,
+			LastModifiedAt: lastModifiedAt,
 			Proxies:        p.Proxies,
 			OperationGroup: &apigee.GoogleCloudApigeeV1OperationGroup{},
 			Attributes:     attrs,
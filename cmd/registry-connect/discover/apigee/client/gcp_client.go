@@ -17,12 +17,18 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
 	"time"
 
+	"golang.org/x/oauth2/google"
 	"google.golang.org/api/apigee/v1"
+	"google.golang.org/api/googleapi"
 )
 
+const bundleURL = "https://apigee.googleapis.com"
+
 func NewGCPClient() (*GCPClient, error) {
 	return &GCPClient{Config.Org}, nil
 }
@@ -60,6 +66,30 @@ func (c *GCPClient) Proxies(ctx context.Context) ([]*apigee.GoogleCloudApigeeV1A
 	return proxies, nil
 }
 
+// ProxyBundle downloads a proxy revision's bundle. The generated client can't
+// return the zip archive, so the request is made directly.
+func (c *GCPClient) ProxyBundle(ctx context.Context, proxy string, revision string) ([]byte, error) {
+	hc, err := google.DefaultClient(ctx, apigee.CloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/v1/organizations/%s/apis/%s/revisions/%s?format=bundle", bundleURL, c.Org(), proxy, revision)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
 func (c *GCPClient) Deployments(ctx context.Context) ([]*apigee.GoogleCloudApigeeV1Deployment, error) {
 	apg, err := apigee.NewService(ctx)
 	if err != nil {
@@ -190,8 +220,8 @@ func (c *GCPClient) Metrics(ctx context.Context, env string, dimensions []string
 	ds := strings.Join(dimensions, ",")
 	ms := strings.Join(metrics, ",")
 	timeFormat := "01/02/2006 15:04"
-	tr := start.Format(timeFormat) + "~" + end.Format(timeFormat)
+	tr := start.UTC().Format(timeFormat) + "~" + end.UTC().Format(timeFormat)
 
 	name := fmt.Sprintf("organizations/%s/environments/%s/stats/%s", c.Org(), env, ds)
-	return apg.Organizations.Environments.Stats.Get(name).Select(ms).TimeRange(tr).Do()
+	return apg.Organizations.Environments.Stats.Get(name).Select(ms).TimeRange(tr).Context(ctx).Do()
 }
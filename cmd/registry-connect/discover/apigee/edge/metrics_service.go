@@ -49,7 +49,7 @@ func (s *MetricsServiceOp) Metrics(ctx context.Context, env string, dimensions [
 	ds := strings.Join(dimensions, ",")
 	ms := strings.Join(metrics, ",")
 	timeFormat := "01/02/2006 15:04"
-	tr := start.Format(timeFormat) + "~" + end.Format(timeFormat)
+	tr := start.UTC().Format(timeFormat) + "~" + end.UTC().Format(timeFormat)
 
 	q := url.Values{}
 	q.Set("select", ms)
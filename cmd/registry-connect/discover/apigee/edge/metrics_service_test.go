@@ -0,0 +1,68 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/environments/test/stats/apiproxy" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("select") != "sum(message_count),avg(total_response_time)" {
+			t.Errorf("want selected metrics got %q", q.Get("select"))
+		}
+		if q.Get("timeRange") != "01/01/2023 00:00~01/08/2023 00:00" {
+			t.Errorf("want UTC time range got %q", q.Get("timeRange"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"environments": [{"name": "test", "dimensions": [{"name": "proxy-1",
+			"metrics": [{"name": "sum(message_count)", "values": ["42.0"]}]}]}]}`))
+	}))
+	defer ts.Close()
+
+	baseUrl, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := &MetricsServiceOp{
+		client: &EdgeClient{
+			client:     http.DefaultClient,
+			BaseURLEnv: baseUrl,
+			BaseURL:    baseUrl,
+		},
+	}
+
+	loc := time.FixedZone("UTC-8", -8*60*60)
+	start := time.Date(2022, 12, 31, 16, 0, 0, 0, loc)
+	end := start.Add(7 * 24 * time.Hour)
+	stats, _, err := ms.Metrics(context.Background(), "test", []string{"apiproxy"},
+		[]string{"sum(message_count)", "avg(total_response_time)"}, start, end)
+	if err != nil {
+		t.Fatalf("want no error got %v", err)
+	}
+	if len(stats.Environments) != 1 || stats.Environments[0].Dimensions[0].Metrics[0].Values[0] != "42.0" {
+		t.Errorf("unexpected stats %#v", stats)
+	}
+}
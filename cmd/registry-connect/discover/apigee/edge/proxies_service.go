@@ -37,6 +37,7 @@ const proxiesPath = "apis"
 type ProxiesService interface {
 	ListNames() ([]string, *Response, error)
 	Get(string) (*Proxy, *Response, error)
+	Export(proxyName string, rev Revision) ([]byte, *Response, error)
 	Import(proxyName string, source string) (*ProxyRevision, *Response, error)
 	Deploy(string, string, Revision) (*ProxyRevisionDeployment, *Response, error)
 	Undeploy(string, string, Revision) (*ProxyRevisionDeployment, *Response, error)
@@ -183,6 +184,22 @@ func (s *ProxiesServiceOp) Get(proxy string) (*Proxy, *Response, error) {
 	return &returnedProxy, resp, e
 }
 
+// Export downloads the bundle of a revision of an API Proxy as a zip archive.
+func (s *ProxiesServiceOp) Export(proxyName string, rev Revision) ([]byte, *Response, error) {
+	urlPath := path.Join(proxiesPath, proxyName, "revisions", fmt.Sprintf("%d", rev)) + "?format=bundle"
+	req, e := s.client.NewRequestNoEnv("GET", urlPath, nil)
+	if e != nil {
+		return nil, nil, e
+	}
+	req.Header.Set("Accept", octetStream)
+	var buf bytes.Buffer
+	resp, e := s.client.Do(req, &buf)
+	if e != nil {
+		return nil, resp, e
+	}
+	return buf.Bytes(), resp, e
+}
+
 func smartFilter(urlPath string) bool {
 	if strings.HasSuffix(urlPath, "~") {
 		return false
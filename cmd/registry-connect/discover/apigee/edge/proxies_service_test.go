@@ -83,6 +83,14 @@ func proxyTestServer(t *testing.T) *httptest.Server {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	}))
+	m.HandleFunc("/apis/proxy-1/revisions/3", (func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Query().Get("format") != "bundle" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("PK bundle"))
+	}))
 	m.HandleFunc("/apis/proxy-1/deployments/", (func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -148,6 +156,32 @@ func TestGetProxy(t *testing.T) {
 	}
 }
 
+func TestExportProxy(t *testing.T) {
+	ts := proxyTestServer(t)
+	defer ts.Close()
+
+	baseUrl, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &EdgeClient{
+		client:     http.DefaultClient,
+		BaseURLEnv: baseUrl,
+		BaseURL:    baseUrl,
+	}
+	ps := &ProxiesServiceOp{
+		client: client,
+	}
+
+	bundle, _, err := ps.Export("proxy-1", 3)
+	if err != nil {
+		t.Errorf("want no error got %v", err)
+	}
+	if string(bundle) != "PK bundle" {
+		t.Errorf("want bundle contents got %q", bundle)
+	}
+}
+
 // func TestImportProxy(t *testing.T) {
 // 	ts := proxyTestServer(t)
 // 	defer ts.Close()
@@ -18,9 +18,12 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	apigee "github.com/apigee/registry-experimental/cmd/registry-connect/discover/apigee/client"
+	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/common"
 	"github.com/apigee/registry/pkg/application/apihub"
 	"github.com/apigee/registry/pkg/encoding"
 	"github.com/apigee/registry/pkg/log"
@@ -47,8 +50,48 @@ func export(ctx context.Context, client apigee.Client) error {
 		proxyByName[p.Name] = p
 	}
 
+	var deps []*api.GoogleCloudApigeeV1Deployment
+	envMap := &apigee.EnvMap{}
+	if len(proxies) > 0 {
+		log.FromContext(ctx).Infof("retrieving envmap")
+		envMap, err = client.EnvMap(ctx)
+		if err != nil {
+			return err
+		}
+
+		log.FromContext(ctx).Infof("retrieving deployments")
+		deps, err = client.Deployments(ctx)
+		if err != nil {
+			return err
+		}
+		log.FromContext(ctx).Infof("%d deployments discovered", len(deps))
+		for _, dep := range deps {
+			if _, ok := proxyByName[dep.ApiProxy]; !ok {
+				log.FromContext(ctx).Warnf("unknown proxy: %q for deployment: %#v", dep.ApiProxy, dep)
+			}
+		}
+	}
+
+	var changed *changes
+	state := newState(client.Org(), products, proxies, deps, envMap)
+	if stateFile != "" {
+		prev, err := loadState(stateFile)
+		if err != nil {
+			return err
+		}
+		if prev.Org != "" && prev.Org != state.Org {
+			return fmt.Errorf("state file %s is for organization %q, not %q", stateFile, prev.Org, state.Org)
+		}
+		changed = diffState(prev, state)
+		log.FromContext(ctx).Infof("%d products and %d proxies changed since the last sync",
+			len(changed.products), len(changed.proxies))
+	}
+
 	var apis []interface{}
 	for _, product := range products {
+		if !changed.product(product.Name) {
+			continue
+		}
 		log.FromContext(ctx).Infof("encoding product %q", product.Name)
 		access := ""
 		for _, a := range product.Attributes {
@@ -63,7 +106,7 @@ func export(ctx context.Context, client apigee.Client) error {
 				ApiVersion: encoding.RegistryV1,
 				Kind:       "API",
 				Metadata: encoding.Metadata{
-					Name: name(fmt.Sprintf("%s-%s-product", client.Org(), product.Name)),
+					Name: productAPIID(client.Org(), product.Name),
 					Annotations: map[string]string{
 						"apigee-product": fmt.Sprintf("organizations/%s/apiproducts/%s", client.Org(), product.Name),
 					},
@@ -71,7 +114,7 @@ func export(ctx context.Context, client apigee.Client) error {
 						"apihub-kind":          "product",
 						"apihub-source":        "registry-connect",
 						"apihub-provider":      "apigee",
-						"apihub-business-unit": label(client.Org()),
+						"apihub-business-unit": common.Label(client.Org()),
 						"apihub-target-users":  access,
 					},
 				},
@@ -152,7 +195,7 @@ func export(ctx context.Context, client apigee.Client) error {
 		}
 	}
 
-	proxyAPIs, err := addProxies(ctx, client, proxies)
+	proxyAPIs, err := addProxies(ctx, client, exportedProxies(proxies, deps, changed), deps, envMap, changed)
 	if err != nil {
 		return err
 	}
@@ -163,12 +206,55 @@ func export(ctx context.Context, client apigee.Client) error {
 		Items:  apis,
 	}
 	log.FromContext(ctx).Infof("encoding yaml output")
-	err = yaml.NewEncoder(os.Stdout).Encode(items)
+	if err := yaml.NewEncoder(os.Stdout).Encode(items); err != nil {
+		return err
+	}
+
+	if stateFile != "" {
+		if err := writeDeletions(ctx, changed.deletions(client.Org())); err != nil {
+			return err
+		}
+		if err := state.save(stateFile); err != nil {
+			return err
+		}
+	}
 	log.FromContext(ctx).Infof("products export complete")
-	return err
+	return nil
+}
+
+// exportedProxies returns the proxies that changed and, if metrics are
+// collected, every deployed proxy. Metrics cover a time range that ends with
+// each run, so the deployments that carry them are always exported again.
+func exportedProxies(proxies []*api.GoogleCloudApigeeV1ApiProxy, deps []*api.GoogleCloudApigeeV1Deployment,
+	changed *changes) []*api.GoogleCloudApigeeV1ApiProxy {
+	deployed := map[string]bool{}
+	if len(metricsOpts.Metrics) > 0 {
+		for _, dep := range deps {
+			deployed[dep.ApiProxy] = true
+		}
+	}
+	var exported []*api.GoogleCloudApigeeV1ApiProxy
+	for _, proxy := range proxies {
+		if changed.proxy(proxy.Name) || deployed[proxy.Name] {
+			exported = append(exported, proxy)
+		}
+	}
+	return exported
 }
 
-func addProxies(ctx context.Context, client apigee.Client, proxies []*api.GoogleCloudApigeeV1ApiProxy) (apis []interface{}, err error) {
+// writeDeletions writes the names of deleted resources to the deletions file, one per line.
+// Deletions aren't reported again after the state is saved, so this must succeed first.
+func writeDeletions(ctx context.Context, deletions []string) error {
+	var b strings.Builder
+	for _, d := range deletions {
+		log.FromContext(ctx).Infof("deleted: %s", d)
+		b.WriteString(d + "\n")
+	}
+	return os.WriteFile(deletionsFile, []byte(b.String()), 0644)
+}
+
+func addProxies(ctx context.Context, client apigee.Client, proxies []*api.GoogleCloudApigeeV1ApiProxy,
+	deps []*api.GoogleCloudApigeeV1Deployment, envMap *apigee.EnvMap, changed *changes) (apis []interface{}, err error) {
 	apisByProxyName := map[string]*encoding.Api{}
 	for _, proxy := range proxies {
 		log.FromContext(ctx).Infof("encoding proxy %q", proxy.Name)
@@ -177,7 +263,7 @@ func addProxies(ctx context.Context, client apigee.Client, proxies []*api.Google
 				ApiVersion: encoding.RegistryV1,
 				Kind:       "API",
 				Metadata: encoding.Metadata{
-					Name: name(fmt.Sprintf("%s-%s-proxy", client.Org(), proxy.Name)),
+					Name: proxyAPIID(client.Org(), proxy.Name),
 					Annotations: map[string]string{
 						"apigee-proxy": fmt.Sprintf("%s/apis/%s", client.Org(), proxy.Name),
 					},
@@ -185,7 +271,7 @@ func addProxies(ctx context.Context, client apigee.Client, proxies []*api.Google
 						"apihub-kind":          "proxy",
 						"apihub-source":        "registry-connect",
 						"apihub-provider":      "apigee",
-						"apihub-business-unit": label(client.Org()),
+						"apihub-business-unit": common.Label(client.Org()),
 					},
 				},
 			},
@@ -219,11 +305,25 @@ func addProxies(ctx context.Context, client apigee.Client, proxies []*api.Google
 			Data: *node,
 		}
 		api.Data.Artifacts = append(api.Data.Artifacts, a)
+
+		if specsDir != "" {
+			for _, revision := range proxy.Revision {
+				if !changed.revision(proxy.Name, revision) {
+					continue
+				}
+				version, err := versionForRevision(ctx, client, api.Metadata.Name, proxy.Name, revision)
+				if err != nil {
+					return nil, err
+				}
+				api.Data.ApiVersions = append(api.Data.ApiVersions, version)
+			}
+		}
+
 		apis = append(apis, api)
 		apisByProxyName[proxy.Name] = api
 	}
 
-	err = addDeployments(ctx, client, apisByProxyName)
+	err = addDeployments(ctx, client, apisByProxyName, deps, envMap)
 	if err != nil {
 		return nil, err
 	}
@@ -231,27 +331,85 @@ func addProxies(ctx context.Context, client apigee.Client, proxies []*api.Google
 	return apis, nil
 }
 
-func addDeployments(ctx context.Context, client apigee.Client, apisByProxyName map[string]*encoding.Api) error {
-	if len(apisByProxyName) == 0 {
-		return nil
+// versionForRevision downloads the bundle of a proxy revision, writes its OpenAPI
+// spec to the specs directory, and returns a version that refers to the spec.
+func versionForRevision(ctx context.Context, client apigee.Client, apiID, proxy, revision string) (*encoding.ApiVersion, error) {
+	log.FromContext(ctx).Infof("retrieving revision %s of proxy %q", revision, proxy)
+	bundle, err := client.ProxyBundle(ctx, proxy, revision)
+	if err != nil {
+		return nil, err
 	}
-
-	metricsByEnv := map[string]*MetricsResponse{}
-
-	log.FromContext(ctx).Infof("retrieving envmap")
-	envMap, err := client.EnvMap(ctx)
+	spec, err := specForBundle(proxy, revision, bundle)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	log.FromContext(ctx).Infof("retrieving deployments")
-	deps, err := client.Deployments(ctx)
+	dir := filepath.Join(specsDir, apiID, revision)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	filename, err := filepath.Abs(filepath.Join(dir, spec.filename))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	log.FromContext(ctx).Infof("%d deployments discovered", len(deps))
+	if err := os.WriteFile(filename, spec.contents, 0644); err != nil {
+		return nil, err
+	}
+
+	source := "bundle"
+	if spec.synthesized {
+		source = "synthesized"
+	}
+	return &encoding.ApiVersion{
+		Header: encoding.Header{
+			ApiVersion: encoding.RegistryV1,
+			Kind:       "Version",
+			Metadata: encoding.Metadata{
+				Name: revision,
+				Annotations: map[string]string{
+					"apigee-proxy-revision": fmt.Sprintf("organizations/%s/apis/%s/revisions/%s", client.Org(), proxy, revision),
+				},
+			},
+		},
+		Data: encoding.ApiVersionData{
+			DisplayName: fmt.Sprintf("Revision %s", revision),
+			ApiSpecs: []*encoding.ApiSpec{{
+				Header: encoding.Header{
+					ApiVersion: encoding.RegistryV1,
+					Kind:       "Spec",
+					Metadata: encoding.Metadata{
+						Name: specID,
+						Annotations: map[string]string{
+							"apigee-spec-source": source,
+						},
+					},
+				},
+				Data: encoding.ApiSpecData{
+					FileName:  spec.filename,
+					MimeType:  spec.mimeType,
+					SourceURI: common.FileURI(filename),
+				},
+			}},
+		},
+	}, nil
+}
+
+func addDeployments(ctx context.Context, client apigee.Client, apisByProxyName map[string]*encoding.Api,
+	deps []*api.GoogleCloudApigeeV1Deployment, envMap *apigee.EnvMap) error {
+	if len(apisByProxyName) == 0 {
+		return nil
+	}
+
+	metricsByEnv := map[string]*MetricsResponse{}
+	end := time.Now().UTC().Truncate(time.Minute)
+	start := end.Add(-metricsOpts.Window)
 
 	for _, dep := range deps {
+		api, ok := apisByProxyName[dep.ApiProxy]
+		if !ok {
+			continue
+		}
+
 		hostnames, ok := envMap.Hostnames(dep.Environment)
 		if !ok {
 			log.FromContext(ctx).Warnf("failed to find hostnames for environment %s", dep.Environment)
@@ -260,7 +418,7 @@ func addDeployments(ctx context.Context, client apigee.Client, apisByProxyName m
 
 		mets, ok := metricsByEnv[dep.Environment]
 		if !ok {
-			m, err := metrics(ctx, client, dep.Environment)
+			m, err := metrics(ctx, client, dep.Environment, start, end)
 			if err != nil {
 				return err
 			}
@@ -269,24 +427,17 @@ func addDeployments(ctx context.Context, client apigee.Client, apisByProxyName m
 		}
 
 		for _, hostname := range hostnames {
-			api, ok := apisByProxyName[dep.ApiProxy]
-			if !ok {
-				log.FromContext(ctx).Warnf("unknown proxy: %q for deployment: %#v", dep.ApiProxy, dep)
-				continue
-			}
-
 			envgroup, _ := envMap.Envgroup(hostname)
 			deployment := &encoding.ApiDeployment{
 				Header: encoding.Header{
 					ApiVersion: encoding.RegistryV1,
 					Kind:       "Deployment",
 					Metadata: encoding.Metadata{
-						Name: label(hostname),
+						Name: common.Label(hostname),
 						Annotations: map[string]string{
 							"apigee-proxy-revision": fmt.Sprintf("organizations/%s/apis/%s/revisions/%s", client.Org(), dep.ApiProxy, dep.Revision),
 							"apigee-environment":    fmt.Sprintf("organizations/%s/environments/%s", client.Org(), dep.Environment),
 							"apigee-envgroup":       envgroup,
-							"message-count-7-days":  mets.Metric(dep.ApiProxy, "sum(message_count)"),
 						},
 						Labels: map[string]string{
 							"apihub-gateway": "apihub-google-cloud-apigee",
@@ -298,6 +449,16 @@ func addDeployments(ctx context.Context, client apigee.Client, apisByProxyName m
 					EndpointURI: hostname, // TODO: full resource path?
 				},
 			}
+			if mets != nil {
+				a, err := metricsArtifact(mets, dep, start, end)
+				if err != nil {
+					return err
+				}
+				deployment.Data.Artifacts = append(deployment.Data.Artifacts, a)
+			}
+			if specsDir != "" {
+				deployment.Data.ApiSpecRevision = common.SpecRevision(dep.Revision, specID)
+			}
 
 			api.Data.ApiDeployments = append(api.Data.ApiDeployments, deployment)
 		}
@@ -316,38 +477,3 @@ func boundProxies(prod *api.GoogleCloudApigeeV1ApiProduct) []string {
 	}
 	return proxies
 }
-
-func metrics(ctx context.Context, client apigee.Client, env string) (*MetricsResponse, error) {
-	dims := []string{"apiproxy"}
-	metrics := []string{"sum(message_count)"}
-	start := time.Now().Add(-7 * 24 * time.Hour)
-	end := time.Now()
-	m, err := client.Metrics(ctx, env, dims, metrics, start, end)
-	if err != nil {
-		return nil, err
-	}
-	return &MetricsResponse{m}, nil
-}
-
-type MetricsResponse struct {
-	*api.GoogleCloudApigeeV1Stats
-}
-
-func (m *MetricsResponse) Metric(dim, metric string) string {
-	if m == nil || len(m.Environments) < 1 {
-		return "0"
-	}
-	env := m.Environments[0]
-	for _, d := range env.Dimensions {
-		if d.Name == dim {
-			for _, m := range d.Metrics {
-				if m.Name == metric {
-					if len(m.Values) == 1 {
-						return m.Values[0].(string)
-					}
-				}
-			}
-		}
-	}
-	return "0"
-}
@@ -0,0 +1,151 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apigee
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	apigee "github.com/apigee/registry-experimental/cmd/registry-connect/discover/apigee/client"
+	"github.com/apigee/registry/pkg/encoding"
+	api "google.golang.org/api/apigee/v1"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	metricsArtifactID   = "apigee-metrics"
+	metricsArtifactKind = "ApigeeMetrics"
+)
+
+// metricsOptions selects the analytics that are collected for deployments.
+// See https://cloud.google.com/apigee/docs/api-platform/analytics/analytics-reference
+// for the available metrics and aggregate functions.
+type metricsOptions struct {
+	Metrics []string      `yaml:"metrics"`
+	Window  time.Duration `yaml:"window"`
+}
+
+var metricsOpts = metricsOptions{
+	Metrics: []string{"sum(message_count)"},
+	Window:  7 * 24 * time.Hour,
+}
+
+// loadMetricsConfig reads metrics options from a YAML file.
+// Options that aren't in the file are left unchanged.
+func loadMetricsConfig(path string, opts *metricsOptions) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, opts); err != nil {
+		return fmt.Errorf("invalid metrics config %s: %s", path, err)
+	}
+	if opts.Window <= 0 {
+		return fmt.Errorf("invalid metrics config %s: window must be positive", path)
+	}
+	return nil
+}
+
+// metrics returns the configured metrics of each proxy in an environment,
+// or nil if no metrics are configured.
+func metrics(ctx context.Context, client apigee.Client, env string, start, end time.Time) (*MetricsResponse, error) {
+	if len(metricsOpts.Metrics) == 0 {
+		return nil, nil
+	}
+	dims := []string{"apiproxy"}
+	m, err := client.Metrics(ctx, env, dims, metricsOpts.Metrics, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricsResponse{m}, nil
+}
+
+type MetricsResponse struct {
+	*api.GoogleCloudApigeeV1Stats
+}
+
+// Value returns the value of a metric for a dimension, or false if it has none.
+func (m *MetricsResponse) Value(dim, metric string) (float64, bool) {
+	if m == nil || m.GoogleCloudApigeeV1Stats == nil || len(m.Environments) < 1 {
+		return 0, false
+	}
+	env := m.Environments[0]
+	for _, d := range env.Dimensions {
+		if d.Name != dim {
+			continue
+		}
+		for _, m := range d.Metrics {
+			if m.Name != metric || len(m.Values) != 1 {
+				continue
+			}
+			switch v := m.Values[0].(type) {
+			case float64:
+				return v, true
+			case string:
+				f, err := strconv.ParseFloat(v, 64)
+				return f, err == nil
+			}
+		}
+	}
+	return 0, false
+}
+
+// metricsReport is the contents of a deployment's metrics artifact.
+type metricsReport struct {
+	Environment string        `yaml:"environment"`
+	Proxy       string        `yaml:"proxy"`
+	Revision    string        `yaml:"revision"`
+	StartTime   time.Time     `yaml:"startTime"`
+	EndTime     time.Time     `yaml:"endTime"`
+	Metrics     []metricValue `yaml:"metrics"`
+}
+
+type metricValue struct {
+	Name  string  `yaml:"name"`
+	Value float64 `yaml:"value"`
+}
+
+// metricsArtifact returns an artifact with the configured metrics of a deployed proxy.
+// Metrics without values (because the proxy had no traffic) are reported as zero.
+func metricsArtifact(m *MetricsResponse, dep *api.GoogleCloudApigeeV1Deployment, start, end time.Time) (*encoding.Artifact, error) {
+	report := &metricsReport{
+		Environment: dep.Environment,
+		Proxy:       dep.ApiProxy,
+		Revision:    dep.Revision,
+		StartTime:   start,
+		EndTime:     end,
+	}
+	for _, name := range metricsOpts.Metrics {
+		v, _ := m.Value(dep.ApiProxy, name)
+		report.Metrics = append(report.Metrics, metricValue{Name: name, Value: v})
+	}
+	var node yaml.Node
+	if err := node.Encode(report); err != nil {
+		return nil, err
+	}
+	return &encoding.Artifact{
+		Header: encoding.Header{
+			ApiVersion: encoding.RegistryV1,
+			Kind:       metricsArtifactKind,
+			Metadata: encoding.Metadata{
+				Name: metricsArtifactID,
+			},
+		},
+		Data: node,
+	}, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apigee
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	api "google.golang.org/api/apigee/v1"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadMetricsConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.yaml")
+	if err := os.WriteFile(path, []byte("metrics:\n- sum(is_error)\n- avg(total_response_time)\nwindow: 24h\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := metricsOptions{Metrics: []string{"sum(message_count)"}, Window: time.Hour}
+	if err := loadMetricsConfig(path, &opts); err != nil {
+		t.Fatalf("loadMetricsConfig() returned error: %s", err)
+	}
+	want := metricsOptions{Metrics: []string{"sum(is_error)", "avg(total_response_time)"}, Window: 24 * time.Hour}
+	if diff := cmp.Diff(want, opts); diff != "" {
+		t.Errorf("loadMetricsConfig() returned unexpected options (-want +got):\n%s", diff)
+	}
+
+	if err := os.WriteFile(path, []byte("window: -1h\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadMetricsConfig(path, &opts); err == nil {
+		t.Errorf("loadMetricsConfig() accepted a negative window")
+	}
+}
+
+func TestMetricsArtifact(t *testing.T) {
+	saved := metricsOpts
+	defer func() { metricsOpts = saved }()
+	metricsOpts.Metrics = []string{"sum(message_count)", "avg(total_response_time)", "sum(is_error)"}
+
+	m := &MetricsResponse{&api.GoogleCloudApigeeV1Stats{
+		Environments: []*api.GoogleCloudApigeeV1StatsEnvironmentStats{{
+			Name: "test",
+			Dimensions: []*api.GoogleCloudApigeeV1DimensionMetric{{
+				Name: "petstore",
+				Metrics: []*api.GoogleCloudApigeeV1Metric{
+					{Name: "sum(message_count)", Values: []interface{}{"42.0"}},
+					{Name: "avg(total_response_time)", Values: []interface{}{12.5}},
+				},
+			}},
+		}},
+	}}
+	dep := &api.GoogleCloudApigeeV1Deployment{ApiProxy: "petstore", Environment: "test", Revision: "3"}
+	end := time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC)
+	start := end.Add(-7 * 24 * time.Hour)
+	a, err := metricsArtifact(m, dep, start, end)
+	if err != nil {
+		t.Fatalf("metricsArtifact() returned error: %s", err)
+	}
+	if a.Kind != metricsArtifactKind || a.Metadata.Name != metricsArtifactID {
+		t.Errorf("metricsArtifact() returned %s %s", a.Kind, a.Metadata.Name)
+	}
+	got := &metricsReport{}
+	if err := a.Data.Decode(got); err != nil {
+		t.Fatal(err)
+	}
+	want := &metricsReport{
+		Environment: "test",
+		Proxy:       "petstore",
+		Revision:    "3",
+		StartTime:   start,
+		EndTime:     end,
+		Metrics: []metricValue{
+			{Name: "sum(message_count)", Value: 42},
+			{Name: "avg(total_response_time)", Value: 12.5},
+			{Name: "sum(is_error)", Value: 0},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("metricsArtifact() returned unexpected report (-want +got):\n%s", diff)
+	}
+	if _, err := yaml.Marshal(a); err != nil {
+		t.Errorf("artifact can't be encoded: %s", err)
+	}
+
+	if _, ok := (*MetricsResponse)(nil).Value("petstore", "sum(message_count)"); ok {
+		t.Errorf("nil response returned a value")
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apigee
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/apigee/registry/pkg/mime"
+	"gopkg.in/yaml.v3"
+)
+
+const specID = "openapi"
+
+// proxySpec is an OpenAPI description of a proxy revision, either found in
+// its bundle or synthesized from its proxy endpoints.
+type proxySpec struct {
+	filename    string
+	mimeType    string
+	contents    []byte
+	synthesized bool
+}
+
+// specForBundle returns the OpenAPI spec of a proxy revision bundle.
+func specForBundle(proxy, revision string, bundle []byte) (*proxySpec, error) {
+	files, err := unzipBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if spec := embeddedSpec(files); spec != nil {
+		return spec, nil
+	}
+	return synthesizeSpec(proxy, revision, files)
+}
+
+func unzipBundle(bundle []byte) (map[string][]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy bundle: %s", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = b
+	}
+	return files, nil
+}
+
+// embeddedSpec returns the first OpenAPI document found in a bundle's resources.
+// Specs stored as "oas" resources are preferred.
+func embeddedSpec(files map[string][]byte) *proxySpec {
+	var candidates []string
+	for name := range files {
+		if !strings.HasPrefix(name, "apiproxy/resources/") {
+			continue
+		}
+		switch path.Ext(name) {
+		case ".yaml", ".yml", ".json":
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		oi := strings.HasPrefix(candidates[i], "apiproxy/resources/oas/")
+		oj := strings.HasPrefix(candidates[j], "apiproxy/resources/oas/")
+		if oi != oj {
+			return oi
+		}
+		return candidates[i] < candidates[j]
+	})
+	for _, name := range candidates {
+		var doc struct {
+			OpenAPI string `yaml:"openapi"`
+			Swagger string `yaml:"swagger"`
+		}
+		if err := yaml.Unmarshal(files[name], &doc); err != nil {
+			continue
+		}
+		var version string
+		switch {
+		case strings.HasPrefix(doc.OpenAPI, "3"):
+			version = "3"
+		case doc.Swagger == "2.0":
+			version = "2"
+		default:
+			continue
+		}
+		return &proxySpec{
+			filename: path.Base(name),
+			mimeType: mime.OpenAPIMimeType("", version),
+			contents: files[name],
+		}
+	}
+	return nil
+}
+
+type bundleProxy struct {
+	DisplayName string `xml:"DisplayName"`
+	Description string `xml:"Description"`
+}
+
+type bundleProxyEndpoint struct {
+	Flows []struct {
+		Name        string `xml:"name,attr"`
+		Description string `xml:"Description"`
+		Condition   string `xml:"Condition"`
+	} `xml:"Flows>Flow"`
+	BasePath string `xml:"HTTPProxyConnection>BasePath"`
+}
+
+var (
+	pathCondition = regexp.MustCompile(`proxy\.pathsuffix\s+(?:MatchesPath|Matches|Like|JavaRegex|~~|~/|~|==|=|Equals|Is)\s+"([^"]*)"`)
+	verbCondition = regexp.MustCompile(`request\.verb\s+(?:==|=|Equals|Is)\s+"([A-Za-z]+)"`)
+)
+
+type synthesizedSpec struct {
+	OpenAPI string                          `yaml:"openapi"`
+	Info    synthesizedInfo                 `yaml:"info"`
+	Servers []synthesizedServer             `yaml:"servers,omitempty"`
+	Paths   map[string]map[string]operation `yaml:"paths"`
+}
+
+type synthesizedInfo struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description,omitempty"`
+	Version     string `yaml:"version"`
+}
+
+type synthesizedServer struct {
+	URL string `yaml:"url"`
+}
+
+type operation struct {
+	OperationID string                       `yaml:"operationId,omitempty"`
+	Summary     string                       `yaml:"summary,omitempty"`
+	Parameters  []parameter                  `yaml:"parameters,omitempty"`
+	Responses   map[string]map[string]string `yaml:"responses"`
+}
+
+type parameter struct {
+	Name     string            `yaml:"name"`
+	In       string            `yaml:"in"`
+	Required bool              `yaml:"required"`
+	Schema   map[string]string `yaml:"schema"`
+}
+
+// synthesizeSpec builds a minimal OpenAPI v3 spec from the base paths and
+// conditional flows of a bundle's proxy endpoints.
+func synthesizeSpec(proxy, revision string, files map[string][]byte) (*proxySpec, error) {
+	spec := &synthesizedSpec{
+		OpenAPI: "3.0.0",
+		Info: synthesizedInfo{
+			Title:   proxy,
+			Version: revision,
+		},
+		Paths: make(map[string]map[string]operation),
+	}
+	var root bundleProxy
+	if b, ok := files["apiproxy/"+proxy+".xml"]; ok {
+		if err := xml.Unmarshal(b, &root); err == nil {
+			if root.DisplayName != "" {
+				spec.Info.Title = root.DisplayName
+			}
+			spec.Info.Description = root.Description
+		}
+	}
+
+	var endpoints []*bundleProxyEndpoint
+	basePaths := make(map[string]bool)
+	for _, name := range sortedNames(files) {
+		if !strings.HasPrefix(name, "apiproxy/proxies/") || path.Ext(name) != ".xml" {
+			continue
+		}
+		e := &bundleProxyEndpoint{}
+		if err := xml.Unmarshal(files[name], e); err != nil {
+			return nil, fmt.Errorf("invalid proxy endpoint %s: %s", name, err)
+		}
+		endpoints = append(endpoints, e)
+		basePaths[e.BasePath] = true
+	}
+	// A single base path is described as the server; otherwise paths include their base paths.
+	prefix := len(basePaths) > 1
+	if len(basePaths) == 1 {
+		for p := range basePaths {
+			spec.Servers = []synthesizedServer{{URL: p}}
+		}
+	}
+
+	for _, e := range endpoints {
+		for _, flow := range e.Flows {
+			m := verbCondition.FindStringSubmatch(flow.Condition)
+			if m == nil {
+				continue
+			}
+			method := strings.ToLower(m[1])
+			p := "/"
+			var params []parameter
+			if m := pathCondition.FindStringSubmatch(flow.Condition); m != nil {
+				p, params = templatePath(m[1])
+			}
+			if prefix {
+				p = path.Join("/", e.BasePath, p)
+			}
+			if spec.Paths[p] == nil {
+				spec.Paths[p] = make(map[string]operation)
+			}
+			spec.Paths[p][method] = operation{
+				OperationID: flow.Name,
+				Summary:     flow.Description,
+				Parameters:  params,
+				Responses: map[string]map[string]string{
+					"default": {"description": "Default response"},
+				},
+			}
+		}
+	}
+
+	b, err := yaml.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &proxySpec{
+		filename:    "openapi.yaml",
+		mimeType:    mime.OpenAPIMimeType("", "3"),
+		contents:    b,
+		synthesized: true,
+	}, nil
+}
+
+// templatePath replaces wildcard segments of a flow path with path parameters
+// and returns the path with the parameters that it declares.
+func templatePath(p string) (string, []parameter) {
+	segments := strings.Split(p, "/")
+	var params []parameter
+	for i, s := range segments {
+		if s == "*" || s == "**" {
+			name := fmt.Sprintf("param%d", len(params)+1)
+			segments[i] = "{" + name + "}"
+			params = append(params, parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   map[string]string{"type": "string"},
+			})
+		}
+	}
+	p = strings.Join(segments, "/")
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p, params
+}
+
+func sortedNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apigee
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func bundleOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, contents := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const petstoreEndpoint = `<ProxyEndpoint name="default">
+  <Flows>
+    <Flow name="listPets">
+      <Description>List all pets</Description>
+      <Condition>(proxy.pathsuffix MatchesPath "/pets") and (request.verb = "GET")</Condition>
+    </Flow>
+    <Flow name="getPet">
+      <Condition>(proxy.pathsuffix MatchesPath "/pets/*") and (request.verb = "GET")</Condition>
+    </Flow>
+    <Flow name="unmatched">
+      <Condition>(proxy.pathsuffix MatchesPath "/other")</Condition>
+    </Flow>
+  </Flows>
+  <HTTPProxyConnection>
+    <BasePath>/v1/petstore</BasePath>
+  </HTTPProxyConnection>
+</ProxyEndpoint>`
+
+func TestEmbeddedSpec(t *testing.T) {
+	bundle := bundleOf(t, map[string]string{
+		"apiproxy/petstore.xml":               `<APIProxy name="petstore"/>`,
+		"apiproxy/proxies/default.xml":        petstoreEndpoint,
+		"apiproxy/resources/jsc/data.json":    `{"key": "value"}`,
+		"apiproxy/resources/oas/swagger.json": `{"swagger": "2.0", "info": {"title": "Petstore"}}`,
+	})
+	spec, err := specForBundle("petstore", "1", bundle)
+	if err != nil {
+		t.Fatalf("specForBundle() returned error: %s", err)
+	}
+	if spec.synthesized || spec.filename != "swagger.json" || spec.mimeType != mime.OpenAPIMimeType("", "2") {
+		t.Errorf("specForBundle() returned %s (%s), synthesized %t", spec.filename, spec.mimeType, spec.synthesized)
+	}
+}
+
+func TestSynthesizedSpec(t *testing.T) {
+	bundle := bundleOf(t, map[string]string{
+		"apiproxy/petstore.xml":        `<APIProxy name="petstore"><DisplayName>Petstore</DisplayName></APIProxy>`,
+		"apiproxy/proxies/default.xml": petstoreEndpoint,
+	})
+	spec, err := specForBundle("petstore", "2", bundle)
+	if err != nil {
+		t.Fatalf("specForBundle() returned error: %s", err)
+	}
+	if !spec.synthesized || spec.mimeType != mime.OpenAPIMimeType("", "3") {
+		t.Errorf("specForBundle() returned %s (%s), synthesized %t", spec.filename, spec.mimeType, spec.synthesized)
+	}
+
+	var got map[string]interface{}
+	if err := yaml.Unmarshal(spec.contents, &got); err != nil {
+		t.Fatal(err)
+	}
+	response := map[string]interface{}{"default": map[string]interface{}{"description": "Default response"}}
+	want := map[string]interface{}{
+		"openapi": "3.0.0",
+		"info":    map[string]interface{}{"title": "Petstore", "version": "2"},
+		"servers": []interface{}{map[string]interface{}{"url": "/v1/petstore"}},
+		"paths": map[string]interface{}{
+			"/pets": map[string]interface{}{
+				"get": map[string]interface{}{"operationId": "listPets", "summary": "List all pets", "responses": response},
+			},
+			"/pets/{param1}": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getPet",
+					"parameters": []interface{}{
+						map[string]interface{}{"name": "param1", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+					},
+					"responses": response,
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("synthesized spec differs (-want +got):\n%s", diff)
+	}
+}
+
+func TestSynthesizedSpecIsValid(t *testing.T) {
+	bundle := bundleOf(t, map[string]string{
+		"apiproxy/petstore.xml":        `<APIProxy name="petstore"/>`,
+		"apiproxy/proxies/default.xml": petstoreEndpoint,
+		"apiproxy/proxies/admin.xml": `<ProxyEndpoint name="admin">
+  <Flows>
+    <Flow name="deleteOwnerPet">
+      <Condition>(proxy.pathsuffix MatchesPath "/owners/*/pets/**") and (request.verb = "DELETE")</Condition>
+    </Flow>
+  </Flows>
+  <HTTPProxyConnection>
+    <BasePath>/admin</BasePath>
+  </HTTPProxyConnection>
+</ProxyEndpoint>`,
+	})
+	spec, err := specForBundle("petstore", "1", bundle)
+	if err != nil {
+		t.Fatalf("specForBundle() returned error: %s", err)
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(spec.contents)
+	if err != nil {
+		t.Fatalf("synthesized spec can't be loaded: %s", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Errorf("synthesized spec is invalid: %s\n%s", err, spec.contents)
+	}
+	if doc.Paths.Find("/admin/owners/{param1}/pets/{param2}") == nil {
+		t.Errorf("synthesized spec is missing a templated path:\n%s", spec.contents)
+	}
+}
+
+func TestInvalidBundle(t *testing.T) {
+	if _, err := specForBundle("petstore", "1", []byte("not a zip")); err == nil {
+		t.Errorf("specForBundle() of an invalid bundle succeeded")
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apigee
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apigee "github.com/apigee/registry-experimental/cmd/registry-connect/discover/apigee/client"
+	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/common"
+	api "google.golang.org/api/apigee/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// syncState records what was discovered in an organization so that later
+// runs can emit only what changed.
+type syncState struct {
+	Org string `yaml:"org"`
+	// Specs is true if versions with specs were exported for proxy revisions.
+	Specs    bool                    `yaml:"specs,omitempty"`
+	Products map[string]productState `yaml:"products,omitempty"`
+	Proxies  map[string]proxyState   `yaml:"proxies,omitempty"`
+}
+
+type productState struct {
+	LastModifiedAt int64 `yaml:"lastModifiedAt"`
+}
+
+type proxyState struct {
+	Revisions []string `yaml:"revisions,omitempty"`
+	// Deployments are recorded as "environment/revision/hostname".
+	Deployments []string `yaml:"deployments,omitempty"`
+}
+
+func newState(org string, products []*api.GoogleCloudApigeeV1ApiProduct, proxies []*api.GoogleCloudApigeeV1ApiProxy,
+	deps []*api.GoogleCloudApigeeV1Deployment, envMap *apigee.EnvMap) *syncState {
+	s := &syncState{
+		Org:      org,
+		Specs:    specsDir != "",
+		Products: make(map[string]productState),
+		Proxies:  make(map[string]proxyState),
+	}
+	for _, p := range products {
+		s.Products[p.Name] = productState{LastModifiedAt: p.LastModifiedAt}
+	}
+	for _, p := range proxies {
+		revisions := append([]string{}, p.Revision...)
+		sort.Strings(revisions)
+		s.Proxies[p.Name] = proxyState{Revisions: revisions}
+	}
+	for _, dep := range deps {
+		p, ok := s.Proxies[dep.ApiProxy]
+		if !ok {
+			continue
+		}
+		hostnames, _ := envMap.Hostnames(dep.Environment)
+		for _, hostname := range hostnames {
+			p.Deployments = append(p.Deployments, deploymentKey(dep.Environment, dep.Revision, hostname))
+		}
+		s.Proxies[dep.ApiProxy] = p
+	}
+	for name, p := range s.Proxies {
+		sort.Strings(p.Deployments)
+		s.Proxies[name] = p
+	}
+	return s
+}
+
+func deploymentKey(env, revision, hostname string) string {
+	return env + "/" + revision + "/" + hostname
+}
+
+func hostnameOf(key string) string {
+	parts := strings.SplitN(key, "/", 3)
+	return parts[len(parts)-1]
+}
+
+// loadState reads a state file. A missing file is an empty state.
+func loadState(path string) (*syncState, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &syncState{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	s := &syncState{}
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %s", path, err)
+	}
+	return s, nil
+}
+
+// save writes a state file, replacing any previous one only after the new
+// state has been completely written.
+func (s *syncState) save(path string) error {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// changes lists the differences between two states.
+// A nil *changes treats everything as changed.
+type changes struct {
+	products map[string]bool
+	proxies  map[string]bool
+
+	// revisions maps proxies to revisions whose versions were exported with the previous state.
+	revisions map[string]map[string]bool
+
+	deletedProducts []string
+	deletedProxies  []string
+	// deletedDeployments maps proxies that still exist to hostnames they are no longer deployed to.
+	deletedDeployments map[string][]string
+	// deletedRevisions maps proxies that still exist to removed revisions whose versions were exported.
+	deletedRevisions map[string][]string
+}
+
+func diffState(prev, next *syncState) *changes {
+	c := &changes{
+		products:           make(map[string]bool),
+		proxies:            make(map[string]bool),
+		revisions:          make(map[string]map[string]bool),
+		deletedDeployments: make(map[string][]string),
+		deletedRevisions:   make(map[string][]string),
+	}
+	for name, p := range next.Products {
+		if old, ok := prev.Products[name]; !ok || old.LastModifiedAt != p.LastModifiedAt || p.LastModifiedAt == 0 {
+			c.products[name] = true
+		}
+	}
+	for name := range prev.Products {
+		if _, ok := next.Products[name]; !ok {
+			c.deletedProducts = append(c.deletedProducts, name)
+		}
+	}
+	// When specs are first exported, every proxy is exported again with all of its revisions.
+	specsAdded := next.Specs && !prev.Specs
+	for name, p := range next.Proxies {
+		old, ok := prev.Proxies[name]
+		// Deploying or undeploying an existing revision changes only the deployments.
+		revised := !equal(old.Revisions, p.Revisions)
+		redeployed := !equal(old.Deployments, p.Deployments)
+		if !ok || specsAdded || revised || redeployed {
+			c.proxies[name] = true
+		}
+		if ok {
+			if deleted := removedHostnames(old.Deployments, p.Deployments); len(deleted) > 0 {
+				c.deletedDeployments[name] = deleted
+			}
+		}
+		if ok && prev.Specs && next.Specs {
+			if deleted := removed(old.Revisions, p.Revisions); len(deleted) > 0 {
+				c.deletedRevisions[name] = deleted
+			}
+			c.revisions[name] = make(map[string]bool)
+			for _, r := range old.Revisions {
+				c.revisions[name][r] = true
+			}
+		}
+	}
+	for name := range prev.Proxies {
+		if _, ok := next.Proxies[name]; !ok {
+			c.deletedProxies = append(c.deletedProxies, name)
+		}
+	}
+	sort.Strings(c.deletedProducts)
+	sort.Strings(c.deletedProxies)
+	return c
+}
+
+func (c *changes) product(name string) bool {
+	return c == nil || c.products[name]
+}
+
+func (c *changes) proxy(name string) bool {
+	return c == nil || c.proxies[name]
+}
+
+// revision returns true if a proxy revision is new since the previous state.
+func (c *changes) revision(proxy, revision string) bool {
+	return c == nil || !c.revisions[proxy][revision]
+}
+
+// deletions returns the names of registry resources that should be deleted.
+func (c *changes) deletions(org string) []string {
+	if c == nil {
+		return nil
+	}
+	var names []string
+	for _, p := range c.deletedProducts {
+		names = append(names, apiResource(productAPIID(org, p)))
+	}
+	for _, p := range c.deletedProxies {
+		names = append(names, apiResource(proxyAPIID(org, p)))
+	}
+	for p, hostnames := range c.deletedDeployments {
+		for _, h := range hostnames {
+			names = append(names, apiResource(proxyAPIID(org, p))+"/deployments/"+common.Label(h))
+		}
+	}
+	for p, revisions := range c.deletedRevisions {
+		for _, r := range revisions {
+			names = append(names, apiResource(proxyAPIID(org, p))+"/versions/"+r)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// removedHostnames returns the hostnames of old deployments that aren't in new ones.
+func removedHostnames(old, new []string) []string {
+	current := make(map[string]bool)
+	for _, k := range new {
+		current[hostnameOf(k)] = true
+	}
+	seen := make(map[string]bool)
+	var removed []string
+	for _, k := range old {
+		h := hostnameOf(k)
+		if !current[h] && !seen[h] {
+			removed = append(removed, h)
+			seen[h] = true
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// removed returns the elements of old that aren't in new.
+func removed(old, new []string) []string {
+	current := make(map[string]bool)
+	for _, s := range new {
+		current[s] = true
+	}
+	var removed []string
+	for _, s := range old {
+		if !current[s] {
+			removed = append(removed, s)
+		}
+	}
+	return removed
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func productAPIID(org, product string) string {
+	return name(fmt.Sprintf("%s-%s-product", org, product))
+}
+
+func proxyAPIID(org, proxy string) string {
+	return name(fmt.Sprintf("%s-%s-proxy", org, proxy))
+}
+
+func apiResource(id string) string {
+	return fmt.Sprintf("projects/%s/locations/global/apis/%s", project, id)
+}
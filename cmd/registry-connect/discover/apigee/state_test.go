@@ -0,0 +1,206 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apigee
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	api "google.golang.org/api/apigee/v1"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	s, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState() of a missing file returned error: %s", err)
+	}
+	if len(s.Proxies) != 0 || len(s.Products) != 0 {
+		t.Errorf("loadState() of a missing file returned %+v", s)
+	}
+
+	want := &syncState{
+		Org:      "my-org",
+		Specs:    true,
+		Products: map[string]productState{"p": {LastModifiedAt: 1}},
+		Proxies:  map[string]proxyState{"x": {Revisions: []string{"1"}, Deployments: []string{"test/1/example.com"}}},
+	}
+	if err := want.save(path); err != nil {
+		t.Fatalf("save() returned error: %s", err)
+	}
+	got, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState() returned error: %s", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("loadState() returned unexpected state (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiffState(t *testing.T) {
+	project = "my-project"
+	prev := &syncState{
+		Org:   "my-org",
+		Specs: true,
+		Products: map[string]productState{
+			"same":    {LastModifiedAt: 1},
+			"edited":  {LastModifiedAt: 1},
+			"removed": {LastModifiedAt: 1},
+		},
+		Proxies: map[string]proxyState{
+			"same":       {Revisions: []string{"1"}, Deployments: []string{"test/1/a.example.com"}},
+			"revised":    {Revisions: []string{"1"}},
+			"redeployed": {Revisions: []string{"1", "2"}, Deployments: []string{"test/1/a.example.com", "test/1/b.example.com"}},
+			"removed":    {Revisions: []string{"1"}},
+		},
+	}
+	next := &syncState{
+		Org:   "my-org",
+		Specs: true,
+		Products: map[string]productState{
+			"same":   {LastModifiedAt: 1},
+			"edited": {LastModifiedAt: 2},
+			"added":  {LastModifiedAt: 1},
+		},
+		Proxies: map[string]proxyState{
+			"same":       {Revisions: []string{"1"}, Deployments: []string{"test/1/a.example.com"}},
+			"revised":    {Revisions: []string{"1", "2"}},
+			"redeployed": {Revisions: []string{"1", "2"}, Deployments: []string{"test/2/a.example.com"}},
+			"added":      {Revisions: []string{"1"}},
+		},
+	}
+	c := diffState(prev, next)
+
+	for _, p := range []string{"edited", "added"} {
+		if !c.product(p) {
+			t.Errorf("product %q wasn't changed", p)
+		}
+	}
+	if c.product("same") {
+		t.Errorf("product %q was changed", "same")
+	}
+	for _, p := range []string{"revised", "redeployed", "added"} {
+		if !c.proxy(p) {
+			t.Errorf("proxy %q wasn't changed", p)
+		}
+	}
+	if c.proxy("same") {
+		t.Errorf("proxy %q was changed", "same")
+	}
+
+	want := []string{
+		"projects/my-project/locations/global/apis/my-org-redeployed-proxy/deployments/b-example-com",
+		"projects/my-project/locations/global/apis/my-org-removed-product",
+		"projects/my-project/locations/global/apis/my-org-removed-proxy",
+	}
+	if diff := cmp.Diff(want, c.deletions("my-org")); diff != "" {
+		t.Errorf("deletions() returned unexpected names (-want +got):\n%s", diff)
+	}
+
+	if c.revision("revised", "1") || !c.revision("revised", "2") || !c.revision("added", "1") {
+		t.Errorf("revision() should only include new revisions")
+	}
+
+	// Adding specs exports all proxies and revisions.
+	prev.Specs = false
+	c = diffState(prev, next)
+	if !c.proxy("same") || !c.revision("same", "1") {
+		t.Errorf("proxies weren't exported again when specs were added")
+	}
+
+	prev.Specs = true
+	prev.Proxies["revised"] = proxyState{Revisions: []string{"1", "2"}}
+	next.Proxies["revised"] = proxyState{Revisions: []string{"2", "3"}}
+	c = diffState(prev, next)
+	if c.proxy("same") || c.revision("revised", "2") || !c.revision("revised", "3") {
+		t.Errorf("revision() with specs should only include new revisions")
+	}
+	got := c.deletions("my-org")
+	if len(got) != 4 || got[3] != "projects/my-project/locations/global/apis/my-org-revised-proxy/versions/1" {
+		t.Errorf("deletions() with specs returned unexpected names %v", got)
+	}
+
+	// Deploying or undeploying an unchanged revision changes the proxy.
+	prev.Proxies["same"] = proxyState{Revisions: []string{"1"}, Deployments: []string{"test/1/a.example.com"}}
+	next.Proxies["same"] = proxyState{Revisions: []string{"1"}, Deployments: []string{"prod/1/b.example.com", "test/1/a.example.com"}}
+	if c := diffState(prev, next); !c.proxy("same") || c.revision("same", "1") {
+		t.Errorf("deploying an unchanged revision wasn't exported as a change of deployments only")
+	}
+	c = diffState(next, prev)
+	if !c.proxy("same") {
+		t.Errorf("undeploying an unchanged revision wasn't a change")
+	}
+	if got := c.deletions("my-org"); !contains(got, "projects/my-project/locations/global/apis/my-org-same-proxy/deployments/b-example-com") {
+		t.Errorf("undeployed deployment wasn't deleted: %v", got)
+	}
+
+	var all *changes
+	if !all.product("same") || !all.proxy("same") || all.deletions("my-org") != nil {
+		t.Errorf("nil changes should include everything and delete nothing")
+	}
+}
+
+func TestExportedProxies(t *testing.T) {
+	proxies := []*api.GoogleCloudApigeeV1ApiProxy{
+		{Name: "deployed", Revision: []string{"1"}},
+		{Name: "undeployed", Revision: []string{"1"}},
+	}
+	deps := []*api.GoogleCloudApigeeV1Deployment{
+		{ApiProxy: "deployed", Environment: "test", Revision: "1"},
+	}
+	s := &syncState{
+		Org:   "my-org",
+		Specs: true,
+		Proxies: map[string]proxyState{
+			"deployed":   {Revisions: []string{"1"}, Deployments: []string{"test/1/a.example.com"}},
+			"undeployed": {Revisions: []string{"1"}},
+		},
+	}
+	// A second run without changes to proxies.
+	c := diffState(s, s)
+	names := func() []string {
+		var names []string
+		for _, p := range exportedProxies(proxies, deps, c) {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	saved := metricsOpts
+	t.Cleanup(func() { metricsOpts = saved })
+	metricsOpts.Metrics = []string{"sum(message_count)"}
+	if diff := cmp.Diff([]string{"deployed"}, names()); diff != "" {
+		t.Errorf("exportedProxies() with metrics returned unexpected proxies (-want +got):\n%s", diff)
+	}
+	// Versions of unchanged revisions aren't exported again.
+	if c.revision("deployed", "1") {
+		t.Errorf("revision() included an unchanged revision")
+	}
+
+	metricsOpts.Metrics = nil
+	if got := names(); len(got) != 0 {
+		t.Errorf("exportedProxies() without metrics returned %v", got)
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
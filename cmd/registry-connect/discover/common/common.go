@@ -0,0 +1,40 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package common contains helpers shared by discovery sources.
+package common
+
+import (
+	"regexp"
+	"strings"
+)
+
+var labelReplacements = regexp.MustCompile(`([^A-Za-z0-9-_]+)`)
+
+// Label returns a registry label value for a name from an external source.
+func Label(s string) string {
+	return strings.ToLower(labelReplacements.ReplaceAllString(s, "-"))
+}
+
+// SpecRevision returns the spec revision of a deployment whose spec is in
+// one of its API's versions. Spec revisions are relative to the API's versions.
+func SpecRevision(versionID, specID string) string {
+	return versionID + "/specs/" + specID
+}
+
+// FileURI returns the source URI of a spec that registry apply reads from an
+// absolute path. An extra slash marks an absolute path for registry apply.
+func FileURI(path string) string {
+	return "file:///" + path
+}
@@ -17,6 +17,8 @@ package discover
 import (
 	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/apigateway"
 	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/apigee"
+	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/files"
+	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/kubernetes"
 	"github.com/spf13/cobra"
 )
 
@@ -38,5 +40,7 @@ See "registry apply --help" for more information.`,
 	}
 	cmd.AddCommand(apigee.Command())
 	cmd.AddCommand(apigateway.Command())
+	cmd.AddCommand(kubernetes.Command())
+	cmd.AddCommand(files.Command())
 	return cmd
 }
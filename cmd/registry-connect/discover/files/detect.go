@@ -0,0 +1,111 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/apigee/registry/pkg/mime"
+	"gopkg.in/yaml.v3"
+)
+
+// description is what is known about a detected spec file.
+type description struct {
+	mimeType string
+	title    string
+	version  string // the version of the API, if the document has one
+}
+
+func asyncAPIMimeType(version string) string {
+	return "application/x.asyncapi;version=" + version
+}
+
+const graphQLMimeType = "application/x.graphql"
+
+// detect returns a description of a spec file, or nil if it isn't a spec.
+// Proto files are detected separately because they are grouped by package.
+func detect(filename string, contents []byte) *description {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".graphql", ".graphqls", ".gql":
+		return &description{mimeType: graphQLMimeType}
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil
+	}
+
+	var doc struct {
+		OpenAPI  string `yaml:"openapi"`
+		Swagger  string `yaml:"swagger"`
+		AsyncAPI string `yaml:"asyncapi"`
+		Info     struct {
+			Title   string `yaml:"title"`
+			Version string `yaml:"version"`
+		} `yaml:"info"`
+		// Discovery documents.
+		Kind    string `yaml:"kind"`
+		Title   string `yaml:"title"`
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(contents, &doc); err != nil {
+		return nil
+	}
+	d := &description{title: doc.Info.Title, version: doc.Info.Version}
+	switch {
+	case doc.OpenAPI != "":
+		d.mimeType = mime.OpenAPIMimeType("", majorMinor(doc.OpenAPI))
+	case doc.Swagger != "":
+		d.mimeType = mime.OpenAPIMimeType("", majorMinor(doc.Swagger))
+	case doc.AsyncAPI != "":
+		d.mimeType = asyncAPIMimeType(majorMinor(doc.AsyncAPI))
+	case doc.Kind == "discovery#restDescription":
+		d.mimeType = mime.DiscoveryMimeType("")
+		d.title, d.version = doc.Title, doc.Version
+	default:
+		return nil
+	}
+	return d
+}
+
+// majorMinor returns the major and minor parts of a version number.
+func majorMinor(version string) string {
+	parts := strings.Split(version, ".")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, ".")
+}
+
+var protoPackage = regexp.MustCompile(`(?m)^\s*package\s+([A-Za-z0-9_.]+)\s*;`)
+
+// packageName returns the package of a proto file, or "" if it has none.
+func packageName(contents []byte) string {
+	if m := protoPackage.FindSubmatch(contents); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+var packageVersion = regexp.MustCompile(`^v[0-9]+[a-z0-9]*$`)
+
+// protoVersion returns the last component of a package if it is a version, like "v1beta1".
+func protoVersion(pkg string) string {
+	v := pkg[strings.LastIndex(pkg, ".")+1:]
+	if packageVersion.MatchString(v) {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,376 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/common"
+	"github.com/apigee/registry/pkg/encoding"
+	"github.com/apigee/registry/pkg/log"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var templateFlags []string
+var output string
+
+func Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "files PATH",
+		Short: "Exports spec files in a directory tree to YAML files compatible with API Registry.",
+		Long: `Exports the OpenAPI, AsyncAPI, Discovery, GraphQL and proto files in a
+directory tree. API, version and spec IDs are taken from the first path template
+that matches the path of each file (relative to PATH and without its extension).
+Proto files are zipped together by package, and matched as if each package were
+a file named "protos" in the directory of its first file.
+
+Templates are slash-separated segments that are "*", "**" (any number of
+segments), or literals with {api}, {version} and {spec} placeholders, which may
+be restricted with regular expressions like {version:v[0-9]+}. Without a
+{version}, the version of a spec is read from the spec, or from the last
+component of a proto package like "v1beta1".
+
+The APIs are written to apis.yaml in the output directory, and the spec files
+and proto archives to its "specs" subdirectory, so that the output directory can
+be applied with "registry apply -f".`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var templates []*template
+			for _, s := range templateFlags {
+				t, err := parseTemplate(s)
+				if err != nil {
+					return err
+				}
+				templates = append(templates, t)
+			}
+			return export(cmd.Context(), args[0], templates, output)
+		},
+	}
+	cmd.Flags().StringArrayVar(&templateFlags, "template", defaultTemplates, "path template (may be repeated; the first that matches is used)")
+	cmd.Flags().StringVar(&output, "output", "files-export", "output directory")
+	return cmd
+}
+
+// exporter collects APIs for the spec files of a directory tree.
+type exporter struct {
+	root      string
+	output    string
+	templates []*template
+	git       *gitInfo
+	apis      []*encoding.Api
+	byID      map[string]*encoding.Api
+	versions  map[string]*encoding.ApiVersion // by "api/version"
+	specIDs   map[string]map[string]bool      // by "api/version"
+}
+
+// export writes apis.yaml to the output directory, with specs that refer to
+// copies of their files (or archives of their packages) under "specs" with
+// file names relative to it. registry apply reads spec contents from these
+// files before it looks at source URIs.
+func export(ctx context.Context, root string, templates []*template, output string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	g, err := readGit(ctx, root)
+	if err != nil {
+		return err
+	}
+	e := &exporter{
+		root:      root,
+		output:    output,
+		templates: templates,
+		git:       g,
+		byID:      map[string]*encoding.Api{},
+		versions:  map[string]*encoding.ApiVersion{},
+		specIDs:   map[string]map[string]bool{},
+	}
+
+	protos := map[string][]string{}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !isCandidate(rel) {
+			return nil
+		}
+		contents, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if path.Ext(rel) == ".proto" {
+			pkg := packageName(contents)
+			if pkg == "" {
+				// Files without packages are grouped by directory.
+				pkg = path.Dir(rel) + "/"
+			}
+			protos[pkg] = append(protos[pkg], rel)
+			return nil
+		}
+		if desc := detect(rel, contents); desc != nil {
+			return e.addFile(ctx, rel, contents, desc)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	packages := make([]string, 0, len(protos))
+	for pkg := range protos {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	for _, pkg := range packages {
+		if err := e.addPackage(ctx, pkg, protos[pkg]); err != nil {
+			return err
+		}
+	}
+
+	items := &encoding.List{
+		Header: encoding.Header{ApiVersion: encoding.RegistryV1},
+	}
+	for _, api := range e.apis {
+		items.Items = append(items.Items, api)
+	}
+	log.FromContext(ctx).Infof("%d APIs discovered", len(e.apis))
+	b, err := yaml.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(output, "apis.yaml"), b, 0644)
+}
+
+// writeSpec writes the contents of a spec to the output directory and returns
+// its file name relative to the output directory.
+func (e *exporter) writeSpec(apiID, versionID, specID, ext string, contents []byte) (string, error) {
+	filename := path.Join("specs", apiID, versionID, specID+ext)
+	p := filepath.Join(e.output, filepath.FromSlash(filename))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(p, contents, 0644); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// isCandidate returns true for files that may be specs.
+func isCandidate(filename string) bool {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".proto", ".graphql", ".graphqls", ".gql", ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+func (e *exporter) addFile(ctx context.Context, rel string, contents []byte, desc *description) error {
+	matchPath := strings.TrimSuffix(rel, path.Ext(rel))
+	apiID, version, specID, ok := e.version(ctx, matchPath, desc.title, desc.version)
+	if !ok {
+		return nil
+	}
+	filename, err := e.writeSpec(apiID, version.Metadata.Name, specID, path.Ext(rel), contents)
+	if err != nil {
+		return err
+	}
+	annotations := e.git.annotations(rel)
+	annotations["source-path"] = rel
+	version.Data.ApiSpecs = append(version.Data.ApiSpecs, &encoding.ApiSpec{
+		Header: encoding.Header{
+			ApiVersion: encoding.RegistryV1,
+			Kind:       "Spec",
+			Metadata: encoding.Metadata{
+				Name:        specID,
+				Annotations: annotations,
+			},
+		},
+		Data: encoding.ApiSpecData{
+			FileName:  filename,
+			MimeType:  desc.mimeType,
+			SourceURI: common.FileURI(filepath.Join(e.root, filepath.FromSlash(rel))),
+		},
+	})
+	return nil
+}
+
+// addPackage writes a zip archive of the files of a proto package and adds a spec for it.
+func (e *exporter) addPackage(ctx context.Context, pkg string, files []string) error {
+	sort.Strings(files)
+	matchPath := path.Join(path.Dir(files[0]), "protos")
+	apiID, version, specID, ok := e.version(ctx, matchPath, "", protoVersion(pkg))
+	if !ok {
+		return nil
+	}
+
+	// Files keep their paths relative to the root so that imports can be resolved.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		contents, err := os.ReadFile(filepath.Join(e.root, filepath.FromSlash(f)))
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(f)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(contents); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	filename, err := e.writeSpec(apiID, version.Metadata.Name, specID, ".zip", buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	annotations := e.git.annotations(files...)
+	annotations["source-path"] = path.Dir(files[0])
+	if !strings.HasSuffix(pkg, "/") {
+		annotations["proto-package"] = pkg
+	}
+	version.Data.ApiSpecs = append(version.Data.ApiSpecs, &encoding.ApiSpec{
+		Header: encoding.Header{
+			ApiVersion: encoding.RegistryV1,
+			Kind:       "Spec",
+			Metadata: encoding.Metadata{
+				Name:        specID,
+				Annotations: annotations,
+			},
+		},
+		Data: encoding.ApiSpecData{
+			FileName: filename,
+			MimeType: mime.ProtobufMimeType("+zip"),
+		},
+	})
+	return nil
+}
+
+// version returns the API ID and version for a spec at a path and a unique ID
+// for the spec, adding the API and version if they don't exist.
+func (e *exporter) version(ctx context.Context, matchPath, title, docVersion string) (string, *encoding.ApiVersion, string, bool) {
+	var values map[string]string
+	for _, t := range e.templates {
+		if v, ok := t.match(matchPath); ok {
+			values = v
+			break
+		}
+	}
+	if values == nil {
+		log.FromContext(ctx).Warnf("no template matches %s", matchPath)
+		return "", nil, "", false
+	}
+
+	specID := id(values["spec"])
+	if specID == "" {
+		specID = id(path.Base(matchPath))
+	}
+	apiID := id(values["api"])
+	if apiID == "" {
+		apiID = specID
+	}
+	versionID := id(values["version"])
+	if versionID == "" {
+		versionID = id(docVersion)
+	}
+	if versionID == "" {
+		versionID = "default"
+	}
+
+	api, ok := e.byID[apiID]
+	if !ok {
+		api = &encoding.Api{
+			Header: encoding.Header{
+				ApiVersion: encoding.RegistryV1,
+				Kind:       "API",
+				Metadata: encoding.Metadata{
+					Name: apiID,
+					Labels: map[string]string{
+						"apihub-source": "registry-connect",
+					},
+				},
+			},
+		}
+		e.byID[apiID] = api
+		e.apis = append(e.apis, api)
+	}
+	if api.Data.DisplayName == "" {
+		api.Data.DisplayName = title
+	}
+
+	key := apiID + "/" + versionID
+	version, ok := e.versions[key]
+	if !ok {
+		version = &encoding.ApiVersion{
+			Header: encoding.Header{
+				ApiVersion: encoding.RegistryV1,
+				Kind:       "Version",
+				Metadata: encoding.Metadata{
+					Name: versionID,
+				},
+			},
+			Data: encoding.ApiVersionData{
+				DisplayName: docVersion,
+			},
+		}
+		e.versions[key] = version
+		e.specIDs[key] = map[string]bool{}
+		api.Data.ApiVersions = append(api.Data.ApiVersions, version)
+	}
+
+	ids := e.specIDs[key]
+	unique := specID
+	for i := 2; ids[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", specID, i)
+	}
+	ids[unique] = true
+	return apiID, version, unique, true
+}
+
+var invalidIDCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// id returns a registry ID for a path segment or version.
+func id(s string) string {
+	return strings.Trim(invalidIDCharacters.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
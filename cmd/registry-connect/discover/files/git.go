@@ -0,0 +1,123 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// commit is the last commit that changed a file.
+type commit struct {
+	hash        string
+	author      string
+	authorEmail string
+	time        string
+	unix        int64
+}
+
+// gitInfo is the git metadata of a directory tree.
+type gitInfo struct {
+	remote  string
+	branch  string
+	commits map[string]*commit // by slash-separated path relative to the tree
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir, "-c", "core.quotePath=false"}, args...)...)
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// readGit returns the git metadata of the files in a directory,
+// or nil if the directory isn't in a git repository.
+func readGit(ctx context.Context, dir string) (*gitInfo, error) {
+	if _, err := git(ctx, dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, nil
+	}
+	info := &gitInfo{commits: map[string]*commit{}}
+	remote, _ := git(ctx, dir, "remote", "get-url", "origin")
+	info.remote = withoutCredentials(remote)
+	info.branch, _ = git(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD")
+
+	// Commits are listed newest first, so the first commit that lists a file is its last change.
+	out, err := git(ctx, dir, "log", "--format=%x01%H%x00%an%x00%ae%x00%cI%x00%ct", "--name-only", "--no-renames", "--relative")
+	if err != nil {
+		// A repository without commits has no log.
+		return info, nil
+	}
+	var current *commit
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "\x01") {
+			fields := strings.Split(line[1:], "\x00")
+			if len(fields) != 5 {
+				current = nil
+				continue
+			}
+			unix, _ := strconv.ParseInt(fields[4], 10, 64)
+			current = &commit{hash: fields[0], author: fields[1], authorEmail: fields[2], time: fields[3], unix: unix}
+			continue
+		}
+		if line == "" || current == nil {
+			continue
+		}
+		if _, ok := info.commits[line]; !ok {
+			info.commits[line] = current
+		}
+	}
+	return info, nil
+}
+
+// withoutCredentials removes any user and password from a remote URL,
+// since remotes can include access tokens. SCP-like remotes (user@host:path)
+// are returned unchanged because they can't contain passwords.
+func withoutCredentials(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil {
+		return remote
+	}
+	u.User = nil
+	return u.String()
+}
+
+// annotations returns annotations that describe the last commit of a set of files.
+func (g *gitInfo) annotations(paths ...string) map[string]string {
+	a := map[string]string{}
+	if g == nil {
+		return a
+	}
+	var last *commit
+	for _, p := range paths {
+		if c := g.commits[p]; c != nil && (last == nil || c.unix > last.unix) {
+			last = c
+		}
+	}
+	if g.remote != "" {
+		a["git-remote"] = g.remote
+	}
+	if g.branch != "" && g.branch != "HEAD" {
+		a["git-branch"] = g.branch
+	}
+	if last != nil {
+		a["git-commit"] = last.hash
+		a["git-author"] = last.author
+		a["git-author-email"] = last.authorEmail
+		a["git-commit-time"] = last.time
+	}
+	return a
+}
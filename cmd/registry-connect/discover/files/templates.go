@@ -0,0 +1,127 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultTemplates match versioned directories like googleapis, then
+// directories named after APIs, then files at the root.
+var defaultTemplates = []string{
+	"**/{api}/{version:v[0-9][a-z0-9.]*}/{spec}",
+	"**/{api}/{spec}",
+	"{spec}",
+}
+
+// template matches slash-separated paths and captures API, version and spec IDs.
+// Each segment of a template is "*", which matches any segment, "**", which
+// matches any number of segments, or a literal that may contain placeholders.
+// Placeholders are written "{name}" or "{name:regexp}", where name is api,
+// version or spec, and match all or part of one segment.
+type template struct {
+	source   string
+	segments []*regexp.Regexp // nil for "**"
+	names    [][]string
+}
+
+var placeholder = regexp.MustCompile(`\{([a-z]+)(?::([^}]*))?\}`)
+
+func parseTemplate(s string) (*template, error) {
+	t := &template{source: s}
+	for _, segment := range strings.Split(strings.Trim(s, "/"), "/") {
+		if segment == "**" {
+			t.segments = append(t.segments, nil)
+			t.names = append(t.names, nil)
+			continue
+		}
+		if segment == "*" {
+			t.segments = append(t.segments, regexp.MustCompile(`^[^/]+$`))
+			t.names = append(t.names, nil)
+			continue
+		}
+		var names []string
+		var expr strings.Builder
+		expr.WriteString("^")
+		last := 0
+		for _, m := range placeholder.FindAllStringSubmatchIndex(segment, -1) {
+			expr.WriteString(regexp.QuoteMeta(segment[last:m[0]]))
+			name := segment[m[2]:m[3]]
+			switch name {
+			case "api", "version", "spec":
+			default:
+				return nil, fmt.Errorf("invalid template %q: unknown placeholder %q", s, name)
+			}
+			pattern := "[^/]+"
+			if m[4] >= 0 {
+				pattern = segment[m[4]:m[5]]
+			}
+			expr.WriteString("(?P<" + name + ">" + pattern + ")")
+			names = append(names, name)
+			last = m[1]
+		}
+		expr.WriteString(regexp.QuoteMeta(segment[last:]) + "$")
+		r, err := regexp.Compile(expr.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %s", s, err)
+		}
+		t.segments = append(t.segments, r)
+		t.names = append(t.names, names)
+	}
+	return t, nil
+}
+
+// match returns the values of the placeholders of a template that matches a path.
+func (t *template) match(path string) (map[string]string, bool) {
+	values := map[string]string{}
+	if t.matchSegments(0, strings.Split(path, "/"), values) {
+		return values, true
+	}
+	return nil, false
+}
+
+func (t *template) matchSegments(i int, segments []string, values map[string]string) bool {
+	if i == len(t.segments) {
+		return len(segments) == 0
+	}
+	if t.segments[i] == nil {
+		// Prefer the longest match of "**" so that placeholders match near the file.
+		for n := len(segments); n >= 0; n-- {
+			if t.matchSegments(i+1, segments[n:], values) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	m := t.segments[i].FindStringSubmatch(segments[0])
+	if m == nil {
+		return false
+	}
+	for _, name := range t.names[i] {
+		values[name] = m[t.segments[i].SubexpIndex(name)]
+	}
+	if t.matchSegments(i+1, segments[1:], values) {
+		return true
+	}
+	for _, name := range t.names[i] {
+		delete(values, name)
+	}
+	return false
+}
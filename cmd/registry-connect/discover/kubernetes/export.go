@@ -0,0 +1,501 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apigee/registry-experimental/cmd/registry-connect/discover/common"
+	"github.com/apigee/registry/pkg/encoding"
+	"github.com/apigee/registry/pkg/log"
+	"github.com/apigee/registry/pkg/mime"
+	"gopkg.in/yaml.v3"
+)
+
+// specAnnotation is the Service annotation with the path or URL of an OpenAPI document.
+const specAnnotation = "registry.apigee.com/openapi"
+
+const specID = "openapi"
+
+var specClient = &http.Client{Timeout: 30 * time.Second}
+
+func export(ctx context.Context, src source, w io.Writer) error {
+	log.FromContext(ctx).Infof("retrieving resources")
+	r, err := src.resources(ctx)
+	if err != nil {
+		return err
+	}
+	r = r.inNamespace(namespace)
+	log.FromContext(ctx).Infof("%d services, %d ingresses and %d HTTP routes discovered",
+		len(r.Services), len(r.Ingresses), len(r.HTTPRoutes))
+
+	apis := apisForResources(ctx, r)
+	items := &encoding.List{
+		Header: encoding.Header{ApiVersion: encoding.RegistryV1},
+	}
+	for _, api := range apis {
+		items.Items = append(items.Items, api)
+	}
+	log.FromContext(ctx).Infof("encoding yaml output")
+	return yaml.NewEncoder(w).Encode(items)
+}
+
+// serviceAPI is the API of a Service, with the IDs of its deployments.
+type serviceAPI struct {
+	api         *encoding.Api
+	service     *service
+	deployments map[string]bool
+}
+
+type serviceKey struct {
+	namespace, name string
+}
+
+// apisForResources returns an API for each Service. Services get a deployment
+// for their cluster address, their load balancer and each Ingress and HTTPRoute
+// that routes to them.
+func apisForResources(ctx context.Context, r *resources) []*encoding.Api {
+	var apis []*encoding.Api
+	services := map[serviceKey]*serviceAPI{}
+	for _, s := range r.Services {
+		sa := &serviceAPI{api: apiForService(s), service: s, deployments: map[string]bool{}}
+		services[serviceKey{s.Metadata.Namespace, s.Metadata.Name}] = sa
+		apis = append(apis, sa.api)
+
+		sa.addDeployment("cluster", "service", s.Metadata, []string{clusterEndpoint(s)})
+		if lb := loadBalancerHost(s.Status.LoadBalancer); lb != "" {
+			scheme, port := servicePort(s)
+			endpoint := scheme + "://" + lb
+			if port != 0 && port != 80 && port != 443 {
+				endpoint += fmt.Sprintf(":%d", port)
+			}
+			sa.addDeployment("load-balancer", "service", s.Metadata, []string{endpoint})
+		}
+	}
+
+	for _, i := range r.Ingresses {
+		for _, b := range ingressEndpoints(i) {
+			sa, ok := services[serviceKey{i.Metadata.Namespace, b.service}]
+			if !ok {
+				log.FromContext(ctx).Warnf("ingress %s/%s routes to unknown service %q", i.Metadata.Namespace, i.Metadata.Name, b.service)
+				continue
+			}
+			sa.addDeployment("ingress-"+i.Metadata.Name, "ingress", i.Metadata, b.endpoints)
+		}
+	}
+
+	for _, h := range r.HTTPRoutes {
+		for _, b := range routeEndpoints(h, r.Gateways) {
+			sa, ok := services[b.key]
+			if !ok {
+				log.FromContext(ctx).Warnf("HTTP route %s/%s routes to unknown service %s/%s", h.Metadata.Namespace, h.Metadata.Name, b.key.namespace, b.key.name)
+				continue
+			}
+			sa.addDeployment("httproute-"+h.Metadata.Name, "httproute", h.Metadata, b.endpoints)
+		}
+	}
+
+	if fetchSpecs {
+		for _, s := range r.Services {
+			sa := services[serviceKey{s.Metadata.Namespace, s.Metadata.Name}]
+			if err := sa.addSpec(ctx); err != nil {
+				log.FromContext(ctx).Warnf("failed to fetch spec of service %s/%s: %s", s.Metadata.Namespace, s.Metadata.Name, err)
+			}
+		}
+	}
+	return apis
+}
+
+func apiForService(s *service) *encoding.Api {
+	apiID := id(s.Metadata.Namespace + "-" + s.Metadata.Name)
+	if clusterName != "" {
+		apiID = id(clusterName + "-" + apiID)
+	}
+	labels := map[string]string{}
+	for k, v := range s.Metadata.Labels {
+		labels[truncate(common.Label(k))] = truncate(common.Label(v))
+	}
+	labels["apihub-source"] = "registry-connect"
+	labels["apihub-provider"] = "kubernetes"
+	labels["kubernetes-namespace"] = common.Label(s.Metadata.Namespace)
+	if clusterName != "" {
+		labels["kubernetes-cluster"] = common.Label(clusterName)
+	}
+	return &encoding.Api{
+		Header: encoding.Header{
+			ApiVersion: encoding.RegistryV1,
+			Kind:       "API",
+			Metadata: encoding.Metadata{
+				Name:   apiID,
+				Labels: labels,
+				Annotations: map[string]string{
+					"kubernetes-service": s.Metadata.Namespace + "/" + s.Metadata.Name,
+				},
+			},
+		},
+		Data: encoding.ApiData{
+			DisplayName: fmt.Sprintf("%s service: %s", s.Metadata.Namespace, s.Metadata.Name),
+		},
+	}
+}
+
+// addDeployment adds a deployment for a Kubernetes object that serves the API.
+// The first endpoint is the endpoint URI of the deployment, and all of them are
+// listed in an annotation if there are several.
+func (sa *serviceAPI) addDeployment(deploymentID, kind string, m metadata, endpoints []string) {
+	base := id(deploymentID)
+	deploymentID = base
+	for i := 2; sa.deployments[deploymentID]; i++ {
+		deploymentID = fmt.Sprintf("%s-%d", base, i)
+	}
+	sa.deployments[deploymentID] = true
+
+	d := &encoding.ApiDeployment{
+		Header: encoding.Header{
+			ApiVersion: encoding.RegistryV1,
+			Kind:       "Deployment",
+			Metadata: encoding.Metadata{
+				Name: deploymentID,
+				Labels: map[string]string{
+					"kubernetes-kind": kind,
+				},
+				Annotations: map[string]string{
+					"kubernetes-" + kind: m.Namespace + "/" + m.Name,
+				},
+			},
+		},
+		Data: encoding.ApiDeploymentData{
+			DisplayName: fmt.Sprintf("%s %s/%s", kind, m.Namespace, m.Name),
+		},
+	}
+	endpoints = unique(endpoints)
+	if len(endpoints) > 0 {
+		d.Data.EndpointURI = endpoints[0]
+	}
+	if len(endpoints) > 1 {
+		d.Metadata.Annotations["kubernetes-endpoints"] = strings.Join(endpoints, " ")
+	}
+	sa.api.Data.ApiDeployments = append(sa.api.Data.ApiDeployments, d)
+}
+
+// addSpec fetches the OpenAPI document named by the Service's spec annotation
+// and adds a version with a spec that refers to it. A relative path is resolved
+// against the first Ingress or HTTPRoute endpoint of the API, or else its load
+// balancer or cluster endpoint.
+func (sa *serviceAPI) addSpec(ctx context.Context) error {
+	ref := sa.service.Metadata.Annotations[specAnnotation]
+	if ref == "" {
+		return nil
+	}
+	base := ""
+	for _, d := range sa.api.Data.ApiDeployments {
+		if d.Data.EndpointURI == "" {
+			continue
+		}
+		// Load balancers are added after cluster addresses, and routes after both.
+		base = d.Data.EndpointURI
+		if d.Metadata.Labels["kubernetes-kind"] != "service" {
+			break
+		}
+	}
+	u, err := specURL(base, ref)
+	if err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Infof("fetching %s", u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := specClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var doc struct {
+		OpenAPI string `yaml:"openapi"`
+		Swagger string `yaml:"swagger"`
+		Info    struct {
+			Version string `yaml:"version"`
+		} `yaml:"info"`
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("%s is not an OpenAPI document: %s", u, err)
+	}
+	var version string
+	switch {
+	case strings.HasPrefix(doc.OpenAPI, "3"):
+		version = "3"
+	case doc.Swagger == "2.0":
+		version = "2"
+	default:
+		return fmt.Errorf("%s is not an OpenAPI document", u)
+	}
+
+	versionID := id(doc.Info.Version)
+	if versionID == "" {
+		versionID = "default"
+	}
+	sa.api.Data.ApiVersions = append(sa.api.Data.ApiVersions, &encoding.ApiVersion{
+		Header: encoding.Header{
+			ApiVersion: encoding.RegistryV1,
+			Kind:       "Version",
+			Metadata: encoding.Metadata{
+				Name: versionID,
+			},
+		},
+		Data: encoding.ApiVersionData{
+			DisplayName: doc.Info.Version,
+			ApiSpecs: []*encoding.ApiSpec{{
+				Header: encoding.Header{
+					ApiVersion: encoding.RegistryV1,
+					Kind:       "Spec",
+					Metadata: encoding.Metadata{
+						Name: specID,
+					},
+				},
+				Data: encoding.ApiSpecData{
+					MimeType: mime.OpenAPIMimeType("", version),
+					// registry apply reads the spec from its source.
+					SourceURI: u,
+				},
+			}},
+		},
+	})
+	for _, d := range sa.api.Data.ApiDeployments {
+		d.Data.ApiSpecRevision = common.SpecRevision(versionID, specID)
+	}
+	return nil
+}
+
+// specURL resolves a spec annotation against an endpoint.
+func specURL(endpoint, ref string) (string, error) {
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.IsAbs() {
+		return ref, nil
+	}
+	if endpoint == "" {
+		return "", fmt.Errorf("no endpoint to resolve %q", ref)
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/" + strings.TrimPrefix(ref, "/"), nil
+}
+
+// servicePort returns the scheme and port of the first port of a Service.
+func servicePort(s *service) (string, int) {
+	if len(s.Spec.Ports) == 0 {
+		return "http", 0
+	}
+	p := s.Spec.Ports[0]
+	if p.Port == 443 || p.Name == "https" || strings.HasPrefix(p.Name, "https-") {
+		return "https", p.Port
+	}
+	return "http", p.Port
+}
+
+func clusterEndpoint(s *service) string {
+	scheme, port := servicePort(s)
+	endpoint := fmt.Sprintf("%s://%s.%s.svc.cluster.local", scheme, s.Metadata.Name, s.Metadata.Namespace)
+	if port != 0 && !(scheme == "http" && port == 80) && !(scheme == "https" && port == 443) {
+		endpoint += fmt.Sprintf(":%d", port)
+	}
+	return endpoint
+}
+
+func loadBalancerHost(status loadBalancerStatus) string {
+	for _, i := range status.Ingress {
+		if i.Hostname != "" {
+			return i.Hostname
+		}
+		if i.IP != "" {
+			return i.IP
+		}
+	}
+	return ""
+}
+
+// backendEndpoints are the endpoints at which an object routes to a Service.
+type backendEndpoints struct {
+	service   string
+	key       serviceKey
+	endpoints []string
+}
+
+// endpoint returns the URL of a path on a host, without a trailing slash.
+func endpoint(scheme, host, path string) string {
+	return strings.TrimSuffix(scheme+"://"+host+path, "/")
+}
+
+// ingressEndpoints returns the endpoints of an Ingress for each of its backend
+// Services, in the order in which they appear. Rules without hosts are served
+// at the address of the Ingress's load balancer.
+func ingressEndpoints(i *ingress) []*backendEndpoints {
+	var backends []*backendEndpoints
+	byService := map[string]*backendEndpoints{}
+	add := func(b *ingressBackend, host, path string) {
+		if b == nil || b.Service == nil {
+			return
+		}
+		be, ok := byService[b.Service.Name]
+		if !ok {
+			be = &backendEndpoints{service: b.Service.Name}
+			byService[b.Service.Name] = be
+			backends = append(backends, be)
+		}
+		if host == "" {
+			return
+		}
+		scheme := "http"
+		for _, tls := range i.Spec.TLS {
+			if len(tls.Hosts) == 0 || contains(tls.Hosts, host) {
+				scheme = "https"
+			}
+		}
+		be.endpoints = append(be.endpoints, endpoint(scheme, host, path))
+	}
+
+	lb := loadBalancerHost(i.Status.LoadBalancer)
+	defaultHost := lb
+	for _, rule := range i.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = lb
+		} else if defaultHost == "" {
+			defaultHost = host
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			add(&p.Backend, host, p.Path)
+		}
+	}
+	add(i.Spec.DefaultBackend, defaultHost, "")
+	return backends
+}
+
+// routeEndpoints returns the endpoints of an HTTPRoute for each of its backend
+// Services. Routes without hostnames are served at the hostnames of the listeners
+// of their parent gateways, or at the addresses of those gateways.
+func routeEndpoints(h *httpRoute, gateways []*gateway) []*backendEndpoints {
+	hosts := h.Spec.Hostnames
+	scheme := "http"
+	var addresses []string
+	for _, ref := range h.Spec.ParentRefs {
+		if (ref.Kind != "" && ref.Kind != "Gateway") || (ref.Group != "" && ref.Group != gatewayGroup) {
+			continue
+		}
+		ns := ref.Namespace
+		if ns == "" {
+			ns = h.Metadata.Namespace
+		}
+		for _, g := range gateways {
+			if g.Metadata.Namespace != ns || g.Metadata.Name != ref.Name {
+				continue
+			}
+			for _, l := range g.Spec.Listeners {
+				if ref.SectionName != "" && l.Name != ref.SectionName {
+					continue
+				}
+				if l.Protocol == "HTTPS" {
+					scheme = "https"
+				}
+				if len(h.Spec.Hostnames) == 0 && l.Hostname != "" && !strings.HasPrefix(l.Hostname, "*") {
+					hosts = append(hosts, l.Hostname)
+				}
+			}
+			for _, a := range g.Status.Addresses {
+				addresses = append(addresses, a.Value)
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = addresses
+	}
+
+	var backends []*backendEndpoints
+	byService := map[serviceKey]*backendEndpoints{}
+	for _, rule := range h.Spec.Rules {
+		paths := []string{""}
+		if len(rule.Matches) > 0 {
+			paths = nil
+			for _, m := range rule.Matches {
+				if m.Path != nil {
+					paths = append(paths, m.Path.Value)
+				} else {
+					paths = append(paths, "")
+				}
+			}
+		}
+		for _, ref := range rule.BackendRefs {
+			if (ref.Kind != "" && ref.Kind != "Service") || ref.Group != "" {
+				continue
+			}
+			key := serviceKey{ref.Namespace, ref.Name}
+			if key.namespace == "" {
+				key.namespace = h.Metadata.Namespace
+			}
+			be, ok := byService[key]
+			if !ok {
+				be = &backendEndpoints{service: ref.Name, key: key}
+				byService[key] = be
+				backends = append(backends, be)
+			}
+			for _, host := range hosts {
+				for _, p := range paths {
+					be.endpoints = append(be.endpoints, endpoint(scheme, host, p))
+				}
+			}
+		}
+	}
+	return backends
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
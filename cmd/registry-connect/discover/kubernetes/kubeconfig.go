@@ -0,0 +1,232 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeconfig is the subset of a kubeconfig file that is needed to connect to a cluster.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string      `yaml:"token"`
+			TokenFile             string      `yaml:"tokenFile"`
+			ClientCertificate     string      `yaml:"client-certificate"`
+			ClientCertificateData string      `yaml:"client-certificate-data"`
+			ClientKey             string      `yaml:"client-key"`
+			ClientKeyData         string      `yaml:"client-key-data"`
+			Exec                  *execConfig `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// execConfig runs a credential plugin, such as gke-gcloud-auth-plugin.
+type execConfig struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+	Env     []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+// restConfig is what is needed to call the Kubernetes API of a cluster.
+type restConfig struct {
+	cluster string
+	server  string
+	token   string
+	client  *http.Client
+}
+
+// defaultKubeconfig returns the first file in $KUBECONFIG, or ~/.kube/config.
+func defaultKubeconfig() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)[0]
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// loadKubeconfig returns the configuration of a context of a kubeconfig file,
+// or of its current context if contextName is empty.
+func loadKubeconfig(ctx context.Context, filename, contextName string) (*restConfig, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := &kubeconfig{}
+	if err := yaml.Unmarshal(b, config); err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig %s: %s", filename, err)
+	}
+	// Relative file names are relative to the kubeconfig file.
+	dir := filepath.Dir(filename)
+	readFile := func(name string) ([]byte, error) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		return os.ReadFile(name)
+	}
+	// Data fields hold base64-encoded contents of files.
+	readData := func(data, name string) ([]byte, error) {
+		if data != "" {
+			return base64.StdEncoding.DecodeString(data)
+		}
+		if name != "" {
+			return readFile(name)
+		}
+		return nil, nil
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	var clusterName, userName string
+	found := false
+	for _, c := range config.Contexts {
+		if c.Name == contextName {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("context %q not found in %s", contextName, filename)
+	}
+
+	rest := &restConfig{cluster: clusterName}
+	tlsConfig := &tls.Config{}
+	found = false
+	for _, c := range config.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		rest.server = strings.TrimSuffix(c.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		ca, err := readData(c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate authority for cluster %q: %s", clusterName, err)
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("invalid certificate authority for cluster %q", clusterName)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("cluster %q not found in %s", clusterName, filename)
+	}
+
+	for _, u := range config.Users {
+		if u.Name != userName {
+			continue
+		}
+		switch {
+		case u.User.Token != "":
+			rest.token = u.User.Token
+		case u.User.TokenFile != "":
+			b, err := readFile(u.User.TokenFile)
+			if err != nil {
+				return nil, err
+			}
+			rest.token = strings.TrimSpace(string(b))
+		case u.User.Exec != nil:
+			rest.token, err = execToken(ctx, u.User.Exec)
+			if err != nil {
+				return nil, fmt.Errorf("credential plugin for user %q failed: %s", userName, err)
+			}
+		}
+		cert, err := readData(u.User.ClientCertificateData, u.User.ClientCertificate)
+		if err != nil {
+			return nil, err
+		}
+		key, err := readData(u.User.ClientKeyData, u.User.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client certificate for user %q: %s", userName, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	rest.client = &http.Client{Transport: transport}
+	return rest, nil
+}
+
+// execToken runs a credential plugin and returns the token of the ExecCredential it prints.
+func execToken(ctx context.Context, config *execConfig) (string, error) {
+	cmd := exec.CommandContext(ctx, config.Command, config.Args...)
+	cmd.Env = os.Environ()
+	for _, e := range config.Env {
+		cmd.Env = append(cmd.Env, e.Name+"="+e.Value)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	var credential struct {
+		Status struct {
+			Token string `json:"token"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(out, &credential); err != nil {
+		return "", err
+	}
+	if credential.Status.Token == "" {
+		return "", fmt.Errorf("%s returned no token", config.Command)
+	}
+	return credential.Status.Token, nil
+}
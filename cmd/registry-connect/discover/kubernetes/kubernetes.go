@@ -0,0 +1,86 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var kubeconfigFile string
+var kubeContext string
+var namespace string
+var manifests []string
+var clusterName string
+var fetchSpecs bool
+
+func Command() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "kubernetes",
+		Short: "Exports Kubernetes Services, Ingresses and HTTPRoutes to YAML files compatible with API Registry.",
+		Long: `Exports an API for each Kubernetes Service, with deployments for its cluster
+address, its load balancer, and each Ingress and Gateway API HTTPRoute that
+routes to it.
+
+Objects are read from the cluster of a kubeconfig context, or with --manifests
+from YAML or JSON manifest files.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			var src source
+			if len(manifests) > 0 {
+				if cmd.Flags().Changed("kubeconfig") || cmd.Flags().Changed("context") {
+					return fmt.Errorf("--manifests can't be used with --kubeconfig or --context")
+				}
+				src = &manifestSource{paths: manifests}
+			} else {
+				if kubeconfigFile == "" {
+					return fmt.Errorf("--kubeconfig must be set when there is no default kubeconfig")
+				}
+				config, err := loadKubeconfig(ctx, kubeconfigFile, kubeContext)
+				if err != nil {
+					return err
+				}
+				src = &clusterSource{config: config, namespace: namespace}
+			}
+			return export(ctx, src, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&kubeconfigFile, "kubeconfig", defaultKubeconfig(), "kubeconfig file of the cluster")
+	cmd.Flags().StringVar(&kubeContext, "context", "", "kubeconfig context (defaults to the current context)")
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace to export (defaults to all namespaces)")
+	cmd.Flags().StringArrayVar(&manifests, "manifests", nil, "manifest file or directory to read instead of a cluster (may be repeated)")
+	cmd.Flags().StringVar(&clusterName, "cluster", "", "cluster name to prefix API IDs with")
+	cmd.Flags().BoolVar(&fetchSpecs, "fetch-specs", false, "fetch OpenAPI specs of services annotated with "+specAnnotation)
+	return cmd
+}
+
+var idReplacements = regexp.MustCompile(`([^A-Za-z0-9-]+)`)
+
+// id returns a registry ID for a Kubernetes name.
+func id(s string) string {
+	return strings.Trim(strings.ToLower(idReplacements.ReplaceAllString(s, "-")), "-")
+}
+
+// truncate shortens a label to its maximum length.
+func truncate(s string) string {
+	if len(s) > 63 {
+		return s[:63]
+	}
+	return s
+}
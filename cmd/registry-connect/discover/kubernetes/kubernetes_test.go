@@ -0,0 +1,215 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apigee/registry/pkg/encoding"
+	"github.com/google/go-cmp/cmp"
+)
+
+// summary describes the deployments of APIs as "api/deployment endpoint spec".
+func summary(apis []*encoding.Api) []string {
+	var s []string
+	for _, a := range apis {
+		for _, d := range a.Data.ApiDeployments {
+			line := fmt.Sprintf("%s/%s %s", a.Metadata.Name, d.Metadata.Name, d.Data.EndpointURI)
+			if e := d.Metadata.Annotations["kubernetes-endpoints"]; e != "" {
+				line += " [" + e + "]"
+			}
+			if d.Data.ApiSpecRevision != "" {
+				line += " " + d.Data.ApiSpecRevision
+			}
+			s = append(s, line)
+		}
+	}
+	return s
+}
+
+func TestManifests(t *testing.T) {
+	ctx := context.Background()
+	src := &manifestSource{paths: []string{"testdata"}}
+	r, err := src.resources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apis := apisForResources(ctx, r.inNamespace("shop"))
+
+	want := []string{
+		"shop-petstore/cluster http://petstore.shop.svc.cluster.local:8080",
+		"shop-petstore/ingress-shop https://shop.example.com/pets [https://shop.example.com/pets http://internal.example.com/pets]",
+		"shop-petstore/httproute-petstore https://api.example.com/v1/pets",
+		"shop-orders/cluster https://orders.shop.svc.cluster.local",
+		"shop-orders/load-balancer https://203.0.113.10",
+		"shop-orders/ingress-shop https://shop.example.com/orders",
+	}
+	if diff := cmp.Diff(want, summary(apis)); diff != "" {
+		t.Errorf("unexpected deployments (-want +got):\n%s", diff)
+	}
+
+	labels := apis[0].Metadata.Labels
+	if labels["app-kubernetes-io-name"] != "petstore" || labels["kubernetes-namespace"] != "shop" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+
+	if got := apisForResources(ctx, r.inNamespace("other")); len(got) != 0 {
+		t.Errorf("want no APIs in namespace other, got %d", len(got))
+	}
+}
+
+func TestCluster(t *testing.T) {
+	m := http.NewServeMux()
+	m.HandleFunc("/api/v1/namespaces/shop/services", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// Services are returned in two chunks, and list items don't have kinds.
+		if r.URL.Query().Get("continue") == "" {
+			fmt.Fprint(w, `{"kind": "ServiceList", "metadata": {"continue": "next"}, "items": [
+				{"metadata": {"name": "petstore", "namespace": "shop"}, "spec": {"ports": [{"port": 80}]}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"kind": "ServiceList", "metadata": {}, "items": [
+			{"metadata": {"name": "orders", "namespace": "shop"}, "spec": {"ports": [{"port": 80}]}}]}`)
+	})
+	m.HandleFunc("/apis/networking.k8s.io/v1/namespaces/shop/ingresses", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"kind": "IngressList", "metadata": {}, "items": [
+			{"metadata": {"name": "shop", "namespace": "shop"},
+			 "spec": {"rules": [{"http": {"paths": [{"path": "/pets", "backend": {"service": {"name": "petstore"}}}]}}]},
+			 "status": {"loadBalancer": {"ingress": [{"ip": "198.51.100.1"}]}}}]}`)
+	})
+	// The Gateway API isn't installed.
+	s := httptest.NewServer(m)
+	defer s.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(kubeconfig, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+users:
+- name: test
+  user:
+    token: secret
+`, s.URL)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	config, err := loadKubeconfig(ctx, kubeconfig, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKubeconfig(ctx, kubeconfig, "missing"); err == nil {
+		t.Error("loadKubeconfig() of a missing context succeeded")
+	}
+
+	src := &clusterSource{config: config, namespace: "shop"}
+	r, err := src.resources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"shop-petstore/cluster http://petstore.shop.svc.cluster.local",
+		"shop-petstore/ingress-shop http://198.51.100.1/pets",
+		"shop-orders/cluster http://orders.shop.svc.cluster.local",
+	}
+	if diff := cmp.Diff(want, summary(apisForResources(ctx, r))); diff != "" {
+		t.Errorf("unexpected deployments (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchSpecs(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/petstore/openapi.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "openapi: 3.0.0\ninfo:\n  title: Petstore\n  version: 1.0.2\npaths: {}\n")
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	src := &manifestSource{paths: []string{"testdata"}}
+	r, err := src.resources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = r.inNamespace("shop")
+	r.Services[0].Metadata.Annotations = map[string]string{specAnnotation: s.URL + "/petstore/openapi.yaml"}
+	r.Services[1].Metadata.Annotations = map[string]string{specAnnotation: s.URL + "/orders/openapi.yaml"}
+
+	fetchSpecs = true
+	defer func() { fetchSpecs = false }()
+	apis := apisForResources(ctx, r)
+
+	versions := apis[0].Data.ApiVersions
+	if len(versions) != 1 || versions[0].Metadata.Name != "1-0-2" {
+		t.Fatalf("unexpected versions %+v", versions)
+	}
+	spec := versions[0].Data.ApiSpecs[0]
+	if spec.Data.SourceURI != s.URL+"/petstore/openapi.yaml" || spec.Data.MimeType != "application/x.openapi;version=3" {
+		t.Errorf("unexpected spec %+v", spec.Data)
+	}
+	for _, d := range apis[0].Data.ApiDeployments {
+		if d.Data.ApiSpecRevision != "1-0-2/specs/openapi" {
+			t.Errorf("deployment %s has spec revision %q", d.Metadata.Name, d.Data.ApiSpecRevision)
+		}
+	}
+	// Specs that can't be fetched are skipped.
+	if len(apis[1].Data.ApiVersions) != 0 {
+		t.Errorf("unexpected versions %+v", apis[1].Data.ApiVersions)
+	}
+}
+
+func TestSpecURL(t *testing.T) {
+	tests := []struct {
+		endpoint, ref, want string
+	}{
+		{"https://a.example.com/pets", "/openapi.json", "https://a.example.com/pets/openapi.json"},
+		{"https://a.example.com/", "openapi.json", "https://a.example.com/openapi.json"},
+		{"https://a.example.com", "https://b.example.com/openapi.json", "https://b.example.com/openapi.json"},
+	}
+	for _, test := range tests {
+		got, err := specURL(test.endpoint, test.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("specURL(%q, %q) = %q, want %q", test.endpoint, test.ref, got, test.want)
+		}
+	}
+	if _, err := specURL("", "/openapi.json"); err == nil {
+		t.Error("specURL() without an endpoint succeeded")
+	}
+}
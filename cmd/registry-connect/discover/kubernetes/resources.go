@@ -0,0 +1,245 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// These types include only the fields of Kubernetes objects that are used
+// for discovery. JSON is valid YAML, so they decode both manifests and
+// responses of the Kubernetes API.
+
+type metadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type object struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Metadata   metadata `yaml:"metadata"`
+}
+
+type loadBalancerStatus struct {
+	Ingress []struct {
+		IP       string `yaml:"ip"`
+		Hostname string `yaml:"hostname"`
+	} `yaml:"ingress"`
+}
+
+type service struct {
+	object `yaml:",inline"`
+	Spec   struct {
+		Type  string `yaml:"type"`
+		Ports []struct {
+			Name string `yaml:"name"`
+			Port int    `yaml:"port"`
+		} `yaml:"ports"`
+	} `yaml:"spec"`
+	Status struct {
+		LoadBalancer loadBalancerStatus `yaml:"loadBalancer"`
+	} `yaml:"status"`
+}
+
+type ingressBackend struct {
+	Service *struct {
+		Name string `yaml:"name"`
+	} `yaml:"service"`
+}
+
+type ingress struct {
+	object `yaml:",inline"`
+	Spec   struct {
+		DefaultBackend *ingressBackend `yaml:"defaultBackend"`
+		TLS            []struct {
+			Hosts []string `yaml:"hosts"`
+		} `yaml:"tls"`
+		Rules []struct {
+			Host string `yaml:"host"`
+			HTTP *struct {
+				Paths []struct {
+					Path    string         `yaml:"path"`
+					Backend ingressBackend `yaml:"backend"`
+				} `yaml:"paths"`
+			} `yaml:"http"`
+		} `yaml:"rules"`
+	} `yaml:"spec"`
+	Status struct {
+		LoadBalancer loadBalancerStatus `yaml:"loadBalancer"`
+	} `yaml:"status"`
+}
+
+type parentReference struct {
+	Group       string `yaml:"group"`
+	Kind        string `yaml:"kind"`
+	Namespace   string `yaml:"namespace"`
+	Name        string `yaml:"name"`
+	SectionName string `yaml:"sectionName"`
+}
+
+type httpRoute struct {
+	object `yaml:",inline"`
+	Spec   struct {
+		ParentRefs []parentReference `yaml:"parentRefs"`
+		Hostnames  []string          `yaml:"hostnames"`
+		Rules      []struct {
+			Matches []struct {
+				Path *struct {
+					Value string `yaml:"value"`
+				} `yaml:"path"`
+			} `yaml:"matches"`
+			BackendRefs []struct {
+				Group     string `yaml:"group"`
+				Kind      string `yaml:"kind"`
+				Namespace string `yaml:"namespace"`
+				Name      string `yaml:"name"`
+			} `yaml:"backendRefs"`
+		} `yaml:"rules"`
+	} `yaml:"spec"`
+}
+
+type gatewayListener struct {
+	Name     string `yaml:"name"`
+	Hostname string `yaml:"hostname"`
+	Protocol string `yaml:"protocol"`
+}
+
+type gateway struct {
+	object `yaml:",inline"`
+	Spec   struct {
+		Listeners []gatewayListener `yaml:"listeners"`
+	} `yaml:"spec"`
+	Status struct {
+		Addresses []struct {
+			Value string `yaml:"value"`
+		} `yaml:"addresses"`
+	} `yaml:"status"`
+}
+
+// resources are the Kubernetes objects that are mapped to registry resources.
+type resources struct {
+	Services   []*service
+	Ingresses  []*ingress
+	HTTPRoutes []*httpRoute
+	Gateways   []*gateway
+}
+
+const (
+	networkingGroup = "networking.k8s.io"
+	gatewayGroup    = "gateway.networking.k8s.io"
+)
+
+// group returns the API group of an apiVersion.
+func group(apiVersion string) string {
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i]
+	}
+	return ""
+}
+
+// add adds the object in a node to the resources, expanding lists.
+// Objects of other kinds are ignored.
+func (r *resources) add(node *yaml.Node) error {
+	var o object
+	if err := node.Decode(&o); err != nil {
+		return err
+	}
+	if strings.HasSuffix(o.Kind, "List") {
+		var list struct {
+			Items []yaml.Node `yaml:"items"`
+		}
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		for i := range list.Items {
+			if err := r.add(&list.Items[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return r.addKind(group(o.APIVersion), o.Kind, node)
+}
+
+// addKind decodes a node as an object of a kind and adds it to the resources.
+// Items of Kubernetes API lists don't include their kinds, so callers provide them.
+func (r *resources) addKind(group, kind string, node *yaml.Node) error {
+	var err error
+	switch {
+	case group == "" && kind == "Service":
+		s := &service{}
+		if err = node.Decode(s); err == nil {
+			r.Services = append(r.Services, s)
+		}
+	case group == networkingGroup && kind == "Ingress":
+		i := &ingress{}
+		if err = node.Decode(i); err == nil {
+			r.Ingresses = append(r.Ingresses, i)
+		}
+	case group == gatewayGroup && kind == "HTTPRoute":
+		h := &httpRoute{}
+		if err = node.Decode(h); err == nil {
+			r.HTTPRoutes = append(r.HTTPRoutes, h)
+		}
+	case group == gatewayGroup && kind == "Gateway":
+		g := &gateway{}
+		if err = node.Decode(g); err == nil {
+			r.Gateways = append(r.Gateways, g)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", kind, err)
+	}
+	return nil
+}
+
+// inNamespace removes objects that aren't in a namespace, and sets the namespace
+// of objects that don't have one to the default namespace. Gateways are kept
+// because routes can refer to gateways in other namespaces.
+func (r *resources) inNamespace(namespace string) *resources {
+	keep := func(m *metadata) bool {
+		if m.Namespace == "" {
+			m.Namespace = "default"
+		}
+		return namespace == "" || m.Namespace == namespace
+	}
+	filtered := &resources{}
+	for _, s := range r.Services {
+		if keep(&s.Metadata) {
+			filtered.Services = append(filtered.Services, s)
+		}
+	}
+	for _, i := range r.Ingresses {
+		if keep(&i.Metadata) {
+			filtered.Ingresses = append(filtered.Ingresses, i)
+		}
+	}
+	for _, h := range r.HTTPRoutes {
+		if keep(&h.Metadata) {
+			filtered.HTTPRoutes = append(filtered.HTTPRoutes, h)
+		}
+	}
+	for _, g := range r.Gateways {
+		keep(&g.Metadata)
+		filtered.Gateways = append(filtered.Gateways, g)
+	}
+	return filtered
+}
@@ -0,0 +1,184 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/apigee/registry/pkg/log"
+	"gopkg.in/yaml.v3"
+)
+
+// source reads Kubernetes objects from a cluster or from manifests.
+type source interface {
+	resources(ctx context.Context) (*resources, error)
+}
+
+// manifestSource reads objects from YAML or JSON manifest files.
+// Directories are read recursively.
+type manifestSource struct {
+	paths []string
+}
+
+func (s *manifestSource) resources(ctx context.Context) (*resources, error) {
+	r := &resources{}
+	for _, p := range s.paths {
+		err := filepath.WalkDir(p, func(filename string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			switch filepath.Ext(filename) {
+			case ".yaml", ".yml", ".json":
+			default:
+				if filename != p {
+					return nil
+				}
+			}
+			log.FromContext(ctx).Debugf("reading %s", filename)
+			if err := r.read(filename); err != nil {
+				return fmt.Errorf("%s: %s", filename, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *resources) read(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d := yaml.NewDecoder(f)
+	for {
+		var node yaml.Node
+		if err := d.Decode(&node); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := r.add(&node); err != nil {
+			return err
+		}
+	}
+}
+
+// clusterSource reads objects with the Kubernetes API.
+type clusterSource struct {
+	config    *restConfig
+	namespace string
+}
+
+// clusterList is a list of objects of one kind in the Kubernetes API.
+type clusterList struct {
+	group, version, resource, kind string
+	namespaced                     bool
+}
+
+var clusterLists = []clusterList{
+	{group: "", version: "v1", resource: "services", kind: "Service", namespaced: true},
+	{group: networkingGroup, version: "v1", resource: "ingresses", kind: "Ingress", namespaced: true},
+	{group: gatewayGroup, version: "v1beta1", resource: "httproutes", kind: "HTTPRoute", namespaced: true},
+	// Routes can refer to gateways in other namespaces.
+	{group: gatewayGroup, version: "v1beta1", resource: "gateways", kind: "Gateway"},
+}
+
+func (s *clusterSource) resources(ctx context.Context) (*resources, error) {
+	r := &resources{}
+	for _, l := range clusterLists {
+		path := "/apis/" + l.group + "/" + l.version
+		if l.group == "" {
+			path = "/api/" + l.version
+		}
+		if l.namespaced && s.namespace != "" {
+			path += "/namespaces/" + s.namespace
+		}
+		path += "/" + l.resource
+		if err := s.list(ctx, path, func(item *yaml.Node) error {
+			return r.addKind(l.group, l.kind, item)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// list calls f with each item of a list, following continue tokens.
+// Resources that aren't served by the cluster are treated as empty lists,
+// since the Gateway API is often not installed.
+func (s *clusterSource) list(ctx context.Context, path string, f func(*yaml.Node) error) error {
+	token := ""
+	for {
+		u := s.config.server + path
+		if token != "" {
+			u += "?continue=" + url.QueryEscape(token)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if s.config.token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.config.token)
+		}
+		log.FromContext(ctx).Debugf("GET %s", u)
+		resp, err := s.config.client.Do(req)
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			log.FromContext(ctx).Infof("%s is not served by the cluster", path)
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s: %s: %s", path, resp.Status, b)
+		}
+
+		var list struct {
+			Metadata struct {
+				Continue string `yaml:"continue"`
+			} `yaml:"metadata"`
+			Items []yaml.Node `yaml:"items"`
+		}
+		if err := yaml.Unmarshal(b, &list); err != nil {
+			return fmt.Errorf("GET %s: %s", path, err)
+		}
+		for i := range list.Items {
+			if err := f(&list.Items[i]); err != nil {
+				return err
+			}
+		}
+		if token = list.Metadata.Continue; token == "" {
+			return nil
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package backstage
 
 import (
+	"fmt"
+
 	"github.com/apigee/registry/pkg/connection"
 	"github.com/apigee/registry/pkg/log"
 	"github.com/spf13/cobra"
@@ -23,11 +25,23 @@ import (
 var ownerName, ownerDesc string
 
 func Command() *cobra.Command {
-	var filter string
+	var filter, locationBase, mappingFile string
+	var importEntities, allVersions bool
 	var cmd = &cobra.Command{
 		Use:   "backstage [OUTPUT FOLDER]",
 		Short: "Export APIs for a Backstage.io project",
-		Args:  cobra.ExactArgs(1),
+		Long: `Export APIs for a Backstage.io project.
+
+Entities are written to the output folder with an apihub-catalog.yaml Location
+that refers to all of them. Files written by earlier runs that are no longer
+exported, such as entities of deleted APIs, are removed.
+
+Links, owners, systems, domains and lifecycles of entities can be configured
+with a YAML mapping file. See the README for its format.
+
+With --import, the folder is read instead, and Backstage API entities that
+weren't published from the registry are imported as APIs with specs.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			ctx := cmd.Context()
 			config, err := connection.ActiveConfig()
@@ -39,11 +53,35 @@ func Command() *cobra.Command {
 				return err
 			}
 
+			if importEntities {
+				i := &importer{
+					client: client,
+					config: config,
+					root:   args[0],
+				}
+				return i.Run(ctx)
+			}
+			if ownerName == "" || ownerDesc == "" {
+				return fmt.Errorf("--owner-name and --owner-desc are required")
+			}
+
+			m := defaultMapping()
+			if mappingFile != "" {
+				if m, err = loadMapping(mappingFile); err != nil {
+					return err
+				}
+			}
+			if allVersions {
+				m.AllVersions = true
+			}
+
 			catalog := catalog{
-				client: client,
-				config: config,
-				filter: filter,
-				root:   args[0],
+				client:       client,
+				config:       config,
+				filter:       filter,
+				mapping:      m,
+				root:         args[0],
+				locationBase: locationBase,
 			}
 			return catalog.Run(ctx)
 		},
@@ -51,7 +89,9 @@ func Command() *cobra.Command {
 	cmd.Flags().StringVar(&filter, "filter", "", "filter selected apis")
 	cmd.Flags().StringVar(&ownerName, "owner-name", "", "Apigee contact name")
 	cmd.Flags().StringVar(&ownerDesc, "owner-desc", "", "Apigee contact description")
-	_ = cmd.MarkFlagRequired("owner-name")
-	_ = cmd.MarkFlagRequired("owner-desc")
+	cmd.Flags().StringVar(&locationBase, "location-base", "", "URL the output folder is published at, used in entity location annotations")
+	cmd.Flags().StringVar(&mappingFile, "mapping", "", "YAML file that configures how resources are mapped to entities")
+	cmd.Flags().BoolVar(&allVersions, "all-versions", false, "publish an API entity for every version, like allVersions in the mapping file")
+	cmd.Flags().BoolVar(&importEntities, "import", false, "import Backstage API entities from the folder into the registry")
 	return cmd
 }
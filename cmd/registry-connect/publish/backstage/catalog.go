@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/apigee/registry-experimental/cmd/registry-connect/publish/backstage/encoding"
@@ -33,21 +34,25 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-const (
-	apiLinkFormat        = "https://pantheon.corp.google.com/apigee/hub/apis/%s/overview?project=%s"
-	taxonomiesLinkFormat = "https://pantheon.corp.google.com/apigee/hub/settings/taxonomies?project=%s"
-)
+const catalogFile = "apihub-catalog.yaml"
 
 type catalog struct {
 	client         connection.RegistryClient
 	config         connection.Config
 	filter         string
+	mapping        *mapping
 	root           string
+	locationBase   string // URL of the root when it is published, or "" for file locations
 	entitiesByKind map[string][]*encoding.Envelope
+	written        map[string]bool // files written by this run, relative to the root
 }
 
 func (c *catalog) Run(ctx context.Context) error {
 	c.entitiesByKind = map[string][]*encoding.Envelope{}
+	c.written = map[string]bool{}
+	if c.mapping == nil {
+		c.mapping = defaultMapping()
+	}
 
 	if err := c.createGroups(ctx); err != nil {
 		return err
@@ -55,14 +60,17 @@ func (c *catalog) Run(ctx context.Context) error {
 	if err := c.createAPIs(ctx); err != nil {
 		return err
 	}
-	return c.writeCatalog()
+	if err := c.writeCatalog(); err != nil {
+		return err
+	}
+	return c.prune(ctx)
 }
 
 func (c *catalog) apigeeOwner() (group *encoding.Envelope, err error) {
 	return c.createGroup("apg-owner", ownerName, ownerDesc)
 }
 
-func (c *catalog) createDeployment(d *rpc.ApiDeployment) (deployment *encoding.Envelope, err error) {
+func (c *catalog) createDeployment(d *rpc.ApiDeployment, apiLifecycle string) (deployment *encoding.Envelope, err error) {
 	var org, env, gateway, owner *encoding.Envelope
 	if owner, err = c.apigeeOwner(); err != nil {
 		return
@@ -112,19 +120,30 @@ func (c *catalog) createDeployment(d *rpc.ApiDeployment) (deployment *encoding.E
 
 	depName, _ := names.ParseDeployment(d.Name)
 	depId := depName.ApiID + "-" + depName.DeploymentID
+	depLinks, err := links(c.mapping.Links.Deployment, linkData{
+		Project:     depName.ProjectID,
+		API:         depName.ApiID,
+		Deployment:  depName.DeploymentID,
+		Labels:      d.Labels,
+		Annotations: d.Annotations,
+	})
+	if err != nil {
+		return
+	}
 	deployment, err = c.addEntity(&encoding.Metadata{
 		Name:        "apg-dep-" + depId,
 		Title:       "Apigee Deployment " + firstOf(d.DisplayName, depId),
 		Description: "Apigee Deployment " + firstOf(d.DisplayName, depId) + " of API " + depName.ApiID,
 		Labels:      d.Labels,
+		Annotations: map[string]string{resourceAnnotation: d.Name},
+		Links:       depLinks,
 	}, &encoding.Component{
 		Type:           "Service",
-		Lifecycle:      required(""), // TODO: nothing to map from API Hub?
+		Lifecycle:      required(firstOf(c.mapping.lifecycle(d.Labels["apihub-lifecycle"]), apiLifecycle)),
 		Owner:          requiredRef(owner.Reference()),
 		System:         env.Reference(),
 		SubComponentOf: gateway.Reference(),
 	})
-	_ = gateway
 	return
 }
 
@@ -150,11 +169,11 @@ func (c *catalog) createGroups(ctx context.Context) error {
 					if err != nil {
 						return err
 					}
-					group.Metadata.Links = []encoding.Link{
-						{
-							URL:   fmt.Sprintf(taxonomiesLinkFormat, artifactName.ProjectID()),
-							Title: "API Hub Taxonomies",
-						},
+					group.Metadata.Links, err = links(c.mapping.Links.Taxonomies, linkData{
+						Project: artifactName.ProjectID(),
+					})
+					if err != nil {
+						return err
 					}
 				}
 			}
@@ -184,104 +203,191 @@ func (c *catalog) createAPIs(ctx context.Context) error {
 	return visitor.ListAPIs(ctx, c.client, project.Api("-"), 0, c.filter, func(ctx context.Context, a *rpc.Api) error {
 		log.FromContext(ctx).Infof("publishing %s", a.Name)
 
-		var specContents string
-		style := strings.TrimPrefix(a.Labels["apihub-style"], "apihub-")
-		if style == "" {
-			if _, ok := a.Annotations["apigee-proxy"]; ok {
-				style = "apigee-proxy"
-			} else if _, ok := a.Annotations["apigee-product"]; ok {
-				style = "apigee-product"
-			}
-		}
-		lifecycle := a.Labels["apihub-lifecycle"]
-
-		primaryContact, err := c.createGroup(a.Labels["apihub-primary-contact"], a.Labels["apihub-primary-contact"], a.Labels["apihub-primary-contact-description"])
+		owner, system, err := c.createOwnership(a)
 		if err != nil {
 			return err
 		}
 
-		// TODO: denormalize or take one? one for now.
-		av, err := recommendedOrLatestVersion(ctx, c.client, a)
-		if err != nil {
+		defaultVersion, err := recommendedOrLatestVersion(ctx, c.client, a)
+		if err != nil || defaultVersion == nil {
 			return err
 		}
-		if av != nil {
-			if av.State != "" {
-				lifecycle = av.State
-			}
-
-			var specs []*rpc.ApiSpec
-			vName, _ := names.ParseVersion(av.Name)
-			err = visitor.ListSpecs(ctx, c.client, vName.Spec("-"), 0, "", true, func(ctx context.Context, as *rpc.ApiSpec) error {
-				specs = append(specs, as)
+		versions := []*rpc.ApiVersion{defaultVersion}
+		if c.mapping.AllVersions {
+			versions = nil
+			apiName, _ := names.ParseApi(a.Name)
+			err = visitor.ListVersions(ctx, c.client, apiName.Version("-"), 0, "", func(ctx context.Context, av *rpc.ApiVersion) error {
+				versions = append(versions, av)
 				return nil
 			})
 			if err != nil {
 				return err
 			}
+		}
 
-			// TODO: denormalize or take one? one for now.
-			var as *rpc.ApiSpec
-			for _, s := range specs { // take primary
-				if av.PrimarySpec == s.Name {
-					as = s
-				}
-			}
-			if as == nil { // or take first
-				if len(specs) > 0 {
-					as = specs[0]
-				}
+		// Deployments provide the version of their spec revision, or else the
+		// recommended or latest version.
+		apisByVersion := map[string]*encoding.Envelope{}
+		for _, av := range versions {
+			api, err := c.createAPI(ctx, a, av, owner, system)
+			if err != nil {
+				return err
 			}
+			apisByVersion[av.Name] = api
+		}
+		defaultAPI, ok := apisByVersion[defaultVersion.Name]
+		if !ok {
+			return nil
+		}
 
-			if as != nil && as.MimeType != "application/x.proto+zip" { // no binary
-				// Backstage well-known types: openapi, asyncapi, graphql, grpc
-				if strings.Contains(as.MimeType, "openapi") || strings.Contains(as.MimeType, "yaml") {
-					style = "openapi"
-				} else if strings.Contains(as.MimeType, "proto") || strings.Contains(as.MimeType, "grpc") {
-					style = "grpc"
-				} else if strings.Contains(as.MimeType, "asyncapi") {
-					style = "asyncapi"
-				} else if strings.Contains(as.MimeType, "graphql") {
-					style = "graphql"
+		apiName, _ := names.ParseApi(a.Name)
+		return visitor.ListDeployments(ctx, c.client, apiName.Deployment("-"), 0, "", func(ctx context.Context, d *rpc.ApiDeployment) error {
+			api := defaultAPI
+			if rev, err := names.ParseSpecRevision(d.ApiSpecRevision); err == nil {
+				if v, ok := apisByVersion[rev.Spec().Version().String()]; ok {
+					api = v
 				}
-				specContents = string(as.Contents)
 			}
-
-			apiName, _ := names.ParseApi(a.Name)
-			apiHubLinks := []encoding.Link{{
-				URL:   fmt.Sprintf(apiLinkFormat, apiName.ApiID, c.config.Project),
-				Title: "API Hub",
-			}}
-			api, err := c.addEntity(&encoding.Metadata{
-				Name:        "apg-" + apiName.ApiID,
-				Title:       "Apigee " + firstOf(a.DisplayName, apiName.ApiID),
-				Description: firstOf(a.Description, a.DisplayName),
-				Labels:      a.Labels, // note: labels and links are not viewable in default backstage API plugin
-				Links:       apiHubLinks,
-			}, &encoding.Api{
-				Type:       required(style),
-				Lifecycle:  required(lifecycle), // backstage well-known types: experimental, production, deprecated
-				Owner:      requiredRef(primaryContact.Reference()),
-				Definition: required(specContents),
-			})
+			env, err := c.createDeployment(d, api.Spec.(*encoding.Api).Lifecycle)
 			if err != nil {
 				return err
 			}
+			dep := env.Spec.(*encoding.Component)
+			dep.ProvidesApis = append(dep.ProvidesApis, api.Reference())
+			return nil
+		})
+	})
+}
 
-			err = visitor.ListDeployments(ctx, c.client, apiName.Deployment("-"), 0, "", func(ctx context.Context, d *rpc.ApiDeployment) error {
-				env, err := c.createDeployment(d)
-				dep := env.Spec.(*encoding.Component)
-				dep.ProvidesApis = append(dep.ProvidesApis, api.Reference())
-				env.Metadata.Links = append(env.Metadata.Links, apiHubLinks...)
-				return err
-			})
-			return err
+// createOwnership returns the owner and system of an API, which are named by
+// the rules of the mapping.
+func (c *catalog) createOwnership(a *rpc.Api) (owner, system *encoding.Envelope, err error) {
+	ownerName, ownerDescription := resolve(c.mapping.Owners, a.Labels)
+	if owner, err = c.createGroup(ownerName, ownerName, ownerDescription); err != nil {
+		return
+	}
+
+	var domain *encoding.Envelope
+	if name, description := resolve(c.mapping.Domains, a.Labels); name != "" {
+		if domain, err = c.addEntity(&encoding.Metadata{
+			Name:        name,
+			Title:       name,
+			Description: description,
+		}, &encoding.Domain{
+			Owner: requiredRef(owner.Reference()),
+		}); err != nil {
+			return
 		}
-		return err
+	}
+	if name, description := resolve(c.mapping.Systems, a.Labels); name != "" {
+		system, err = c.addEntity(&encoding.Metadata{
+			Name:        name,
+			Title:       name,
+			Description: description,
+		}, &encoding.System{
+			Owner:  requiredRef(owner.Reference()),
+			Domain: domain.Reference(),
+		})
+	}
+	return
+}
+
+// createAPI adds an entity for a version of an API with the definition of its
+// primary spec, or of its first spec if it has no primary spec.
+func (c *catalog) createAPI(ctx context.Context, a *rpc.Api, av *rpc.ApiVersion, owner, system *encoding.Envelope) (*encoding.Envelope, error) {
+	style := strings.TrimPrefix(a.Labels["apihub-style"], "apihub-")
+	if style == "" {
+		if _, ok := a.Annotations["apigee-proxy"]; ok {
+			style = "apigee-proxy"
+		} else if _, ok := a.Annotations["apigee-product"]; ok {
+			style = "apigee-product"
+		}
+	}
+	lifecycle := c.mapping.lifecycle(firstOf(av.State, a.Labels["apihub-lifecycle"]))
+
+	var specs []*rpc.ApiSpec
+	vName, _ := names.ParseVersion(av.Name)
+	err := visitor.ListSpecs(ctx, c.client, vName.Spec("-"), 0, "", true, func(ctx context.Context, as *rpc.ApiSpec) error {
+		specs = append(specs, as)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var as *rpc.ApiSpec
+	for _, s := range specs { // take primary
+		if av.PrimarySpec == s.Name {
+			as = s
+		}
+	}
+	if as == nil { // or take first
+		if len(specs) > 0 {
+			as = specs[0]
+		}
+	}
+
+	var specContents string
+	if as != nil && as.MimeType != "application/x.proto+zip" { // no binary
+		// Backstage well-known types: openapi, asyncapi, graphql, grpc
+		if strings.Contains(as.MimeType, "openapi") || strings.Contains(as.MimeType, "yaml") {
+			style = "openapi"
+		} else if strings.Contains(as.MimeType, "proto") || strings.Contains(as.MimeType, "grpc") {
+			style = "grpc"
+		} else if strings.Contains(as.MimeType, "asyncapi") {
+			style = "asyncapi"
+		} else if strings.Contains(as.MimeType, "graphql") {
+			style = "graphql"
+		}
+		specContents = string(as.Contents)
+	}
+
+	apiLinks, err := links(c.mapping.Links.API, linkData{
+		Project:     vName.ProjectID,
+		API:         vName.ApiID,
+		Version:     vName.VersionID,
+		Labels:      a.Labels,
+		Annotations: a.Annotations,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions are only named when each one is published.
+	name := "apg-" + vName.ApiID
+	title := "Apigee " + firstOf(a.DisplayName, vName.ApiID)
+	resource := a.Name
+	if c.mapping.AllVersions {
+		name += "-" + vName.VersionID
+		title += " " + firstOf(av.DisplayName, vName.VersionID)
+		resource = av.Name
+	}
+	return c.addEntity(&encoding.Metadata{
+		Name:        name,
+		Title:       title,
+		Description: firstOf(a.Description, a.DisplayName),
+		Labels:      a.Labels, // note: labels and links are not viewable in default backstage API plugin
+		Annotations: map[string]string{resourceAnnotation: resource},
+		Links:       apiLinks,
+	}, &encoding.Api{
+		Type:       required(style),
+		Lifecycle:  required(lifecycle), // backstage well-known types: experimental, production, deprecated
+		Owner:      requiredRef(owner.Reference()),
+		Definition: required(specContents),
+		System:     system.Reference(),
 	})
 }
 
+// writeYAML writes an entity to a file relative to the root and annotates it
+// with the locations that manage it.
 func (c *catalog) writeYAML(file string, data *encoding.Envelope) error {
+	if data.Metadata.Annotations == nil {
+		data.Metadata.Annotations = map[string]string{}
+	}
+	data.Metadata.Annotations[managedByLocation] = c.location(file)
+	data.Metadata.Annotations[managedByOriginLocation] = c.location(catalogFile)
+	c.written[filepath.ToSlash(file)] = true
+
 	file = filepath.Join(c.root, file)
 	if err := os.MkdirAll(filepath.Dir(file), os.FileMode(0755)); err != nil { // rwx,rx,rx
 		return err
@@ -332,7 +438,17 @@ func (c *catalog) findEntity(kind, namespace, name string) *encoding.Envelope {
 func (c *catalog) writeCatalog() error {
 	subCatalogs := []string{}
 
-	for k, entities := range c.entitiesByKind {
+	// Kinds and entities are sorted so that the index doesn't change between runs.
+	kinds := make([]string, 0, len(c.entitiesByKind))
+	for k := range c.entitiesByKind {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		entities := c.entitiesByKind[k]
+		sort.Slice(entities, func(i, j int) bool {
+			return entities[i].Reference() < entities[j].Reference()
+		})
 		files := []string{}
 		pluralKind := strings.ToLower(k) + "s"
 		for _, entity := range entities {
@@ -358,7 +474,7 @@ func (c *catalog) writeCatalog() error {
 		if err := c.writeYAML(fileName, kindCatalog); err != nil {
 			return err
 		}
-		subCatalogs = append(subCatalogs, "./"+fileName)
+		subCatalogs = append(subCatalogs, "./"+filepath.ToSlash(fileName))
 	}
 
 	catalog, err := encoding.NewEnvelope(&encoding.Metadata{
@@ -370,7 +486,7 @@ func (c *catalog) writeCatalog() error {
 	if err != nil {
 		return err
 	}
-	return c.writeYAML("apihub-catalog.yaml", catalog)
+	return c.writeYAML(catalogFile, catalog)
 }
 
 func recommendedOrLatestVersion(ctx context.Context, client connection.RegistryClient, a *rpc.Api) (*rpc.ApiVersion, error) {
@@ -0,0 +1,351 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backstage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apigee/registry-experimental/cmd/registry-connect/publish/backstage/encoding"
+	"github.com/apigee/registry/pkg/application/apihub"
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMain(m *testing.M) {
+	grpctest.TestMain(m, registry.Config{})
+}
+
+func readEntity(t *testing.T, filename string) *encoding.Envelope {
+	t.Helper()
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &encoding.Envelope{}
+	if err := yaml.Unmarshal(b, e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// taxonomies returns the taxonomies artifact that publishing requires.
+func taxonomies(t *testing.T, project string) *rpc.Artifact {
+	t.Helper()
+	contents, err := proto.Marshal(&apihub.TaxonomyList{
+		Taxonomies: []*apihub.TaxonomyList_Taxonomy{{
+			Id:       "apihub-team",
+			Elements: []*apihub.TaxonomyList_Taxonomy_Element{{Id: "pets", DisplayName: "Pets"}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &rpc.Artifact{
+		Name:     "projects/" + project + "/locations/global/artifacts/apihub-taxonomies",
+		MimeType: mime.MimeTypeForKind("TaxonomyList"),
+		Contents: contents,
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	ctx := context.Background()
+	client, _ := grpctest.SetupRegistry(ctx, t, "backstage-test", []seeder.RegistryResource{
+		taxonomies(t, "backstage-test"),
+		&rpc.ApiSpec{
+			Name:     "projects/backstage-test/locations/global/apis/petstore/versions/v1/specs/openapi",
+			MimeType: mime.OpenAPIMimeType("", "3"),
+			Contents: []byte("openapi: 3.0.0\n"),
+		},
+		&rpc.ApiDeployment{
+			Name: "projects/backstage-test/locations/global/apis/petstore/deployments/prod",
+		},
+		&rpc.ApiVersion{
+			Name: "projects/backstage-test/locations/global/apis/retired/versions/v1",
+		},
+	})
+
+	root := t.TempDir()
+	foreign := filepath.Join(root, "apis", "custom.yaml")
+	if err := os.MkdirAll(filepath.Dir(foreign), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(foreign, []byte("apiVersion: backstage.io/v1alpha1\nkind: API\nmetadata:\n  name: custom\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Directories made by users are kept even if they are empty.
+	empty := filepath.Join(root, "docs", "drafts")
+	if err := os.MkdirAll(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Stale entities in other directories are pruned with their directories.
+	stale := filepath.Join(root, "old", "apis", "api_stale.yaml")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("apiVersion: backstage.io/v1alpha1\nkind: API\nmetadata:\n  name: stale\n  annotations:\n    "+
+		managedByOriginLocation+": url:https://example.com/catalog/apihub-catalog.yaml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ownerName, ownerDesc = "Owner", "Owner of Apigee resources"
+	c := &catalog{
+		client:       client,
+		config:       connection.Config{Project: "backstage-test"},
+		root:         root,
+		locationBase: "https://example.com/catalog/",
+	}
+	if err := c.Run(ctx); err != nil {
+		t.Fatalf("Run() returned error: %s", err)
+	}
+
+	petstore := filepath.Join(root, "apis", "api_backstage-test_apg-petstore.yaml")
+	retired := filepath.Join(root, "apis", "api_backstage-test_apg-retired.yaml")
+	e := readEntity(t, petstore)
+	want := map[string]string{
+		managedByLocation:       "url:https://example.com/catalog/apis/api_backstage-test_apg-petstore.yaml",
+		managedByOriginLocation: "url:https://example.com/catalog/apihub-catalog.yaml",
+		resourceAnnotation:      "projects/backstage-test/locations/global/apis/petstore",
+	}
+	for k, v := range want {
+		if e.Metadata.Annotations[k] != v {
+			t.Errorf("annotation %s is %q, want %q", k, e.Metadata.Annotations[k], v)
+		}
+	}
+	if _, err := os.Stat(retired); err != nil {
+		t.Fatalf("entity of retired API wasn't written: %s", err)
+	}
+	index := readEntity(t, filepath.Join(root, "apihub-catalog.yaml"))
+	if index.Kind != "Location" || index.Metadata.Annotations[managedByOriginLocation] != want[managedByOriginLocation] {
+		t.Errorf("unexpected index %+v", index)
+	}
+
+	// Entities of deleted APIs are pruned, and other files are kept.
+	if err := client.DeleteApi(ctx, &rpc.DeleteApiRequest{
+		Name:  "projects/backstage-test/locations/global/apis/retired",
+		Force: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(ctx); err != nil {
+		t.Fatalf("Run() returned error: %s", err)
+	}
+	if _, err := os.Stat(retired); !os.IsNotExist(err) {
+		t.Errorf("entity of deleted API wasn't pruned: %v", err)
+	}
+	for _, f := range []string{petstore, foreign, empty} {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("%s was removed: %s", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "old")); !os.IsNotExist(err) {
+		t.Errorf("directory of a pruned entity wasn't removed: %v", err)
+	}
+}
+
+func TestCatalogMapping(t *testing.T) {
+	ctx := context.Background()
+	client, _ := grpctest.SetupRegistry(ctx, t, "mapping-test", []seeder.RegistryResource{
+		taxonomies(t, "mapping-test"),
+		&rpc.Api{
+			Name:               "projects/mapping-test/locations/global/apis/petstore",
+			Labels:             map[string]string{"team": "blue", "unit": "retail"},
+			RecommendedVersion: "projects/mapping-test/locations/global/apis/petstore/versions/v2",
+		},
+		&rpc.ApiVersion{
+			Name:  "projects/mapping-test/locations/global/apis/petstore/versions/v1",
+			State: "retired",
+		},
+		&rpc.ApiVersion{
+			Name:  "projects/mapping-test/locations/global/apis/petstore/versions/v2",
+			State: "production",
+		},
+		&rpc.ApiSpec{
+			Name:     "projects/mapping-test/locations/global/apis/petstore/versions/v1/specs/openapi",
+			MimeType: mime.OpenAPIMimeType("", "3"),
+			Contents: []byte("openapi: 3.0.0\n"),
+		},
+		&rpc.ApiDeployment{
+			Name:            "projects/mapping-test/locations/global/apis/petstore/deployments/legacy",
+			ApiSpecRevision: "projects/mapping-test/locations/global/apis/petstore/versions/v1/specs/openapi@-",
+		},
+		&rpc.ApiDeployment{
+			Name:   "projects/mapping-test/locations/global/apis/petstore/deployments/prod",
+			Labels: map[string]string{"apihub-lifecycle": "staging"},
+		},
+	})
+
+	ownerName, ownerDesc = "Owner", "Owner of Apigee resources"
+	m := defaultMapping()
+	m.Links.API = []linkTemplate{{Title: "Docs", URL: "https://docs.example.com/{{.API}}/{{.Version}}"}}
+	m.Owners = []rule{{Label: "team", Prefix: "team-"}}
+	m.Systems = []rule{{Match: map[string]string{"unit": "*"}, Name: "pets"}}
+	m.Domains = []rule{{Label: "unit"}}
+	m.Lifecycles = map[string]string{"retired": "deprecated", "staging": "experimental"}
+	m.AllVersions = true
+	root := t.TempDir()
+	c := &catalog{
+		client:  client,
+		config:  connection.Config{Project: "mapping-test"},
+		root:    root,
+		mapping: m,
+	}
+	if err := c.Run(ctx); err != nil {
+		t.Fatalf("Run() returned error: %s", err)
+	}
+
+	v1 := readEntity(t, filepath.Join(root, "apis", "api_mapping-test_apg-petstore-v1.yaml"))
+	spec := v1.Spec.(map[string]interface{})
+	want := map[string]interface{}{
+		"type":       "openapi",
+		"lifecycle":  "deprecated",
+		"owner":      "Group:mapping-test/team-blue",
+		"system":     "System:mapping-test/pets",
+		"definition": "openapi: 3.0.0\n",
+	}
+	if diff := cmp.Diff(want, spec); diff != "" {
+		t.Errorf("unexpected spec of v1 (-want +got):\n%s", diff)
+	}
+	wantLinks := []encoding.Link{{Title: "Docs", URL: "https://docs.example.com/petstore/v1"}}
+	if diff := cmp.Diff(wantLinks, v1.Metadata.Links); diff != "" {
+		t.Errorf("unexpected links of v1 (-want +got):\n%s", diff)
+	}
+	if v1.Metadata.Annotations[resourceAnnotation] != "projects/mapping-test/locations/global/apis/petstore/versions/v1" ||
+		v1.Metadata.Annotations[managedByLocation] != "file:./apis/api_mapping-test_apg-petstore-v1.yaml" ||
+		v1.Metadata.Annotations[managedByOriginLocation] != "file:./apihub-catalog.yaml" {
+		t.Errorf("unexpected annotations of v1 %v", v1.Metadata.Annotations)
+	}
+	if _, err := os.Stat(filepath.Join(root, "apis", "api_mapping-test_apg-petstore-v2.yaml")); err != nil {
+		t.Errorf("v2 wasn't published: %s", err)
+	}
+
+	system := readEntity(t, filepath.Join(root, "systems", "system_mapping-test_pets.yaml"))
+	if got := system.Spec.(map[string]interface{})["domain"]; got != "Domain:mapping-test/retail" {
+		t.Errorf("unexpected domain of system %v", got)
+	}
+
+	deployments := []struct {
+		file, provides, lifecycle string
+	}{
+		{"component_mapping-test_apg-dep-petstore-legacy.yaml", "API:mapping-test/apg-petstore-v1", "deprecated"},
+		{"component_mapping-test_apg-dep-petstore-prod.yaml", "API:mapping-test/apg-petstore-v2", "experimental"},
+	}
+	for _, test := range deployments {
+		spec := readEntity(t, filepath.Join(root, "components", test.file)).Spec.(map[string]interface{})
+		if got := spec["providesApis"].([]interface{})[0]; got != test.provides {
+			t.Errorf("%s provides %v, want %s", test.file, got, test.provides)
+		}
+		if got := spec["lifecycle"]; got != test.lifecycle {
+			t.Errorf("%s has lifecycle %v, want %s", test.file, got, test.lifecycle)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	client, _ := grpctest.SetupRegistry(ctx, t, "backstage-import-test", nil)
+
+	root := t.TempDir()
+	files := map[string]string{
+		"catalog-info.yaml": `apiVersion: backstage.io/v1alpha1
+kind: API
+metadata:
+  name: Pet_Store
+  title: Pet Store
+  labels:
+    tier: gold
+spec:
+  type: openapi
+  lifecycle: production
+  owner: group:default/pets
+  definition:
+    $text: ./pets.yaml
+---
+apiVersion: backstage.io/v1alpha1
+kind: Component
+metadata:
+  name: petstore-service
+spec:
+  type: service
+---
+apiVersion: backstage.io/v1alpha1
+kind: API
+metadata:
+  name: apg-published
+  annotations:
+    registry.apigee.com/resource: projects/other/locations/global/apis/published
+spec:
+  type: openapi
+  definition: "openapi: 3.0.0"
+`,
+		"pets.yaml": "openapi: 3.0.1\ninfo:\n  title: Pets\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	i := &importer{
+		client: client,
+		config: connection.Config{Project: "backstage-import-test"},
+		root:   root,
+	}
+	if err := i.Run(ctx); err != nil {
+		t.Fatalf("Run() returned error: %s", err)
+	}
+
+	api, err := client.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/backstage-import-test/locations/global/apis/pet-store"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.DisplayName != "Pet Store" || api.Labels["apihub-lifecycle"] != "production" || api.Labels["tier"] != "gold" ||
+		api.Annotations["backstage-owner"] != "group:default/pets" {
+		t.Errorf("unexpected API %+v", api)
+	}
+	version, err := client.GetApiVersion(ctx, &rpc.GetApiVersionRequest{Name: api.RecommendedVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := client.GetApiSpec(ctx, &rpc.GetApiSpecRequest{Name: version.PrimarySpec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.MimeType != mime.OpenAPIMimeType("", "3.0.1") || spec.Filename != "openapi.yaml" {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+	contents, err := client.GetApiSpecContents(ctx, &rpc.GetApiSpecContentsRequest{Name: spec.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents.Data) != files["pets.yaml"] {
+		t.Errorf("unexpected spec contents %q", contents.Data)
+	}
+
+	// Entities published from the registry aren't imported.
+	if _, err := client.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/backstage-import-test/locations/global/apis/apg-published"}); err == nil {
+		t.Error("published entity was imported")
+	}
+}
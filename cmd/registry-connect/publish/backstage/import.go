@@ -0,0 +1,272 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backstage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/apigee/registry-experimental/cmd/registry-connect/publish/backstage/encoding"
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/log"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/pkg/names"
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"gopkg.in/yaml.v3"
+)
+
+// importVersionID is the version that imported definitions are stored in,
+// since Backstage API entities aren't versioned.
+const importVersionID = "default"
+
+// importer reads Backstage API entities from catalog files and stores
+// them in the registry as APIs with specs of their definitions.
+type importer struct {
+	client connection.RegistryClient
+	config connection.Config
+	root   string
+}
+
+// entity is a Backstage entity with an undecoded spec.
+type entity struct {
+	Kind     string            `yaml:"kind"`
+	Metadata encoding.Metadata `yaml:"metadata"`
+	Spec     yaml.Node         `yaml:"spec"`
+}
+
+// apiEntitySpec is the spec of an API entity. Definitions can be strings or
+// substitutions like {"$text": "./openapi.yaml"}.
+type apiEntitySpec struct {
+	Type       string    `yaml:"type"`
+	Lifecycle  string    `yaml:"lifecycle"`
+	Owner      string    `yaml:"owner"`
+	System     string    `yaml:"system"`
+	Definition yaml.Node `yaml:"definition"`
+}
+
+func (i *importer) Run(ctx context.Context) error {
+	project, err := names.ParseProject("projects/" + i.config.Project)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(i.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ext := filepath.Ext(p); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dec := yaml.NewDecoder(f)
+		for {
+			e := &entity{}
+			if err := dec.Decode(e); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				log.FromContext(ctx).Warnf("skipping %s: %s", p, err)
+				return nil
+			}
+			if e.Kind != "API" {
+				continue
+			}
+			if _, ok := e.Metadata.Annotations[resourceAnnotation]; ok {
+				log.FromContext(ctx).Debugf("skipping %s, which was published from the registry", e.Metadata.Name)
+				continue
+			}
+			if err := i.importAPI(ctx, project, filepath.Dir(p), e); err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+		}
+	})
+}
+
+func (i *importer) importAPI(ctx context.Context, project names.Project, dir string, e *entity) error {
+	spec := &apiEntitySpec{}
+	if err := e.Spec.Decode(spec); err != nil {
+		return err
+	}
+	definition, err := readDefinition(ctx, dir, &spec.Definition)
+	if err != nil {
+		return err
+	}
+
+	apiName := project.Api(registryID(e.Metadata.Name))
+	log.FromContext(ctx).Infof("importing %s as %s", e.Metadata.Name, apiName)
+	labels := map[string]string{
+		"apihub-source": "backstage",
+	}
+	for k, v := range e.Metadata.Labels {
+		labels[labelValue(k)] = labelValue(v)
+	}
+	if spec.Lifecycle != "" {
+		labels["apihub-lifecycle"] = labelValue(spec.Lifecycle)
+	}
+	if spec.Type != "" {
+		labels["apihub-style"] = "apihub-" + labelValue(spec.Type)
+	}
+	annotations := map[string]string{
+		"backstage-entity": fmt.Sprintf("api:%s/%s", firstOf(e.Metadata.Namespace, "default"), e.Metadata.Name),
+	}
+	if spec.Owner != "" {
+		annotations["backstage-owner"] = spec.Owner
+	}
+	if spec.System != "" {
+		annotations["backstage-system"] = spec.System
+	}
+
+	versionName := apiName.Version(importVersionID)
+	if _, err := i.client.UpdateApi(ctx, &rpc.UpdateApiRequest{
+		Api: &rpc.Api{
+			Name:               apiName.String(),
+			DisplayName:        firstOf(e.Metadata.Title, e.Metadata.Name),
+			Description:        e.Metadata.Description,
+			Labels:             labels,
+			Annotations:        annotations,
+			RecommendedVersion: versionName.String(),
+		},
+		AllowMissing: true,
+	}); err != nil {
+		return err
+	}
+	if _, err := i.client.UpdateApiVersion(ctx, &rpc.UpdateApiVersionRequest{
+		ApiVersion: &rpc.ApiVersion{
+			Name:  versionName.String(),
+			State: labelValue(spec.Lifecycle),
+		},
+		AllowMissing: true,
+	}); err != nil {
+		return err
+	}
+	if definition == "" {
+		return nil
+	}
+
+	specID, filename, mimeType := definitionType(spec.Type, definition)
+	specName := versionName.Spec(specID)
+	if _, err := i.client.UpdateApiSpec(ctx, &rpc.UpdateApiSpecRequest{
+		ApiSpec: &rpc.ApiSpec{
+			Name:     specName.String(),
+			Filename: filename,
+			MimeType: mimeType,
+			Contents: []byte(definition),
+		},
+		AllowMissing: true,
+	}); err != nil {
+		return err
+	}
+	_, err = i.client.UpdateApiVersion(ctx, &rpc.UpdateApiVersionRequest{
+		ApiVersion: &rpc.ApiVersion{
+			Name:        versionName.String(),
+			PrimarySpec: specName.String(),
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"primary_spec"}},
+	})
+	return err
+}
+
+// readDefinition returns the text of a definition, reading files and URLs
+// named by $text, $json and $yaml substitutions.
+func readDefinition(ctx context.Context, dir string, node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case 0:
+		return "", nil
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.MappingNode:
+		var substitution map[string]string
+		if err := node.Decode(&substitution); err != nil {
+			return "", err
+		}
+		for _, key := range []string{"$text", "$json", "$yaml"} {
+			target, ok := substitution[key]
+			if !ok {
+				continue
+			}
+			if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+				if err != nil {
+					return "", err
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					return "", err
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					return "", fmt.Errorf("GET %s: %s", target, resp.Status)
+				}
+				b, err := io.ReadAll(resp.Body)
+				return string(b), err
+			}
+			b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(target)))
+			return string(b), err
+		}
+	}
+	return "", fmt.Errorf("unsupported definition at line %d", node.Line)
+}
+
+// definitionType returns the spec ID, file name and MIME type of a definition
+// of one of the well-known Backstage API types.
+func definitionType(apiType, definition string) (string, string, string) {
+	var doc struct {
+		OpenAPI  string `yaml:"openapi"`
+		Swagger  string `yaml:"swagger"`
+		AsyncAPI string `yaml:"asyncapi"`
+	}
+	_ = yaml.Unmarshal([]byte(definition), &doc)
+	switch apiType {
+	case "openapi":
+		version := firstOf(doc.OpenAPI, doc.Swagger, "3")
+		return "openapi", "openapi.yaml", mime.OpenAPIMimeType("", version)
+	case "asyncapi":
+		return "asyncapi", "asyncapi.yaml", "application/x.asyncapi;version=" + firstOf(doc.AsyncAPI, "2")
+	case "graphql":
+		return "graphql", "schema.graphql", "application/x.graphql"
+	case "grpc":
+		return "proto", "api.proto", mime.ProtobufMimeType("")
+	default:
+		return firstOf(registryID(apiType), "definition"), "definition.txt", "text/plain"
+	}
+}
+
+var invalidIDCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// registryID returns a registry ID for a Backstage name.
+func registryID(name string) string {
+	return strings.Trim(invalidIDCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+var invalidLabelCharacters = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+func labelValue(s string) string {
+	s = invalidLabelCharacters.ReplaceAllString(strings.ToLower(s), "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
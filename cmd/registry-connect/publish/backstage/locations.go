@@ -0,0 +1,109 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backstage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apigee/registry/pkg/log"
+	"gopkg.in/yaml.v3"
+)
+
+// https://backstage.io/docs/features/software-catalog/well-known-annotations
+const (
+	managedByLocation       = "backstage.io/managed-by-location"
+	managedByOriginLocation = "backstage.io/managed-by-origin-location"
+)
+
+// resourceAnnotation holds the name of the registry resource of an entity.
+const resourceAnnotation = "registry.apigee.com/resource"
+
+// location returns the Backstage location of a file relative to the root.
+// Locations are URLs when the root is published at a base URL, and otherwise
+// paths relative to the root, so that they don't depend on where the catalog
+// was written.
+func (c *catalog) location(file string) string {
+	file = filepath.ToSlash(file)
+	if c.locationBase != "" {
+		return "url:" + strings.TrimSuffix(c.locationBase, "/") + "/" + file
+	}
+	return "file:./" + file
+}
+
+// prune removes files in the root that were written by an earlier run but not
+// by this one, such as entities of APIs that were deleted from the registry.
+// Files are recognized by their origin location, so other files are kept.
+func (c *catalog) prune(ctx context.Context) error {
+	var removed []string
+	err := filepath.WalkDir(c.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if ext := filepath.Ext(p); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		rel, err := filepath.Rel(c.root, p)
+		if err != nil {
+			return err
+		}
+		if c.written[filepath.ToSlash(rel)] {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		var entity struct {
+			Metadata struct {
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal(b, &entity); err != nil {
+			return nil
+		}
+		origin := entity.Metadata.Annotations[managedByOriginLocation]
+		if !strings.HasSuffix(origin, "/"+catalogFile) {
+			return nil
+		}
+		log.FromContext(ctx).Infof("removing stale %s", rel)
+		removed = append(removed, p)
+		return os.Remove(p)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove the directories of removed files that are left empty, up to
+	// but not including the root. Other empty directories are kept.
+	root := filepath.Clean(c.root)
+	for _, p := range removed {
+		for d := filepath.Dir(p); d != root && strings.HasPrefix(d, root+string(filepath.Separator)); d = filepath.Dir(d) {
+			if entries, err := os.ReadDir(d); err != nil || len(entries) > 0 {
+				break
+			}
+			if err := os.Remove(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
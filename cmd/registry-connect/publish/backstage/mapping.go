@@ -0,0 +1,171 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backstage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/apigee/registry-experimental/cmd/registry-connect/publish/backstage/encoding"
+	"gopkg.in/yaml.v3"
+)
+
+// mapping configures how registry resources are mapped to Backstage entities.
+type mapping struct {
+	Links       linkTemplates     `yaml:"links"`
+	Owners      []rule            `yaml:"owners"`
+	Systems     []rule            `yaml:"systems"`
+	Domains     []rule            `yaml:"domains"`
+	Lifecycles  map[string]string `yaml:"lifecycles"` // registry states to Backstage lifecycles
+	AllVersions bool              `yaml:"allVersions"`
+}
+
+// linkTemplates are the links of entities by the kind of resource they're for.
+type linkTemplates struct {
+	API        []linkTemplate `yaml:"api"`
+	Deployment []linkTemplate `yaml:"deployment"`
+	Taxonomies []linkTemplate `yaml:"taxonomies"`
+}
+
+// linkTemplate is a link with a URL that is a text/template executed with linkData.
+type linkTemplate struct {
+	Title string `yaml:"title"`
+	URL   string `yaml:"url"`
+	Icon  string `yaml:"icon"`
+	Type  string `yaml:"type"`
+}
+
+// linkData is the data that link templates are executed with.
+type linkData struct {
+	Project     string
+	API         string
+	Version     string
+	Deployment  string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// rule names an entity for resources with all of the labels in Match, where
+// a value of "*" matches any value. The name is the value of Label with
+// Prefix, or Name if the resource doesn't have that label.
+type rule struct {
+	Match            map[string]string `yaml:"match"`
+	Label            string            `yaml:"label"`
+	Prefix           string            `yaml:"prefix"`
+	Name             string            `yaml:"name"`
+	DescriptionLabel string            `yaml:"descriptionLabel"`
+}
+
+func defaultMapping() *mapping {
+	apiHub := linkTemplate{
+		Title: "API Hub",
+		URL:   "https://pantheon.corp.google.com/apigee/hub/apis/{{.API}}/overview?project={{.Project}}",
+	}
+	return &mapping{
+		Links: linkTemplates{
+			API:        []linkTemplate{apiHub},
+			Deployment: []linkTemplate{apiHub},
+			Taxonomies: []linkTemplate{{
+				Title: "API Hub Taxonomies",
+				URL:   "https://pantheon.corp.google.com/apigee/hub/settings/taxonomies?project={{.Project}}",
+			}},
+		},
+		Owners: []rule{{
+			Label:            "apihub-primary-contact",
+			DescriptionLabel: "apihub-primary-contact-description",
+		}},
+	}
+}
+
+// loadMapping reads a mapping from a YAML file.
+// Settings that aren't in the file keep their default values.
+func loadMapping(path string) (*mapping, error) {
+	m := defaultMapping()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("invalid mapping %s: %s", path, err)
+	}
+	for _, links := range [][]linkTemplate{m.Links.API, m.Links.Deployment, m.Links.Taxonomies} {
+		for _, l := range links {
+			if _, err := template.New(l.Title).Parse(l.URL); err != nil {
+				return nil, fmt.Errorf("invalid mapping %s: %s", path, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// links returns the links of an entity.
+func links(templates []linkTemplate, data linkData) ([]encoding.Link, error) {
+	var result []encoding.Link
+	for _, l := range templates {
+		t, err := template.New(l.Title).Option("missingkey=zero").Parse(l.URL)
+		if err != nil {
+			return nil, err
+		}
+		var url strings.Builder
+		if err := t.Execute(&url, data); err != nil {
+			return nil, err
+		}
+		result = append(result, encoding.Link{
+			URL:   url.String(),
+			Title: l.Title,
+			Icon:  l.Icon,
+			Type:  l.Type,
+		})
+	}
+	return result, nil
+}
+
+// resolve returns the name and description of the entity named by the first
+// rule that matches labels, or empty strings if none do.
+func resolve(rules []rule, labels map[string]string) (string, string) {
+	for _, r := range rules {
+		if !matches(r.Match, labels) {
+			continue
+		}
+		name := r.Name
+		if v := labels[r.Label]; r.Label != "" && v != "" {
+			name = r.Prefix + v
+		}
+		if name != "" {
+			return name, labels[r.DescriptionLabel]
+		}
+	}
+	return "", ""
+}
+
+func matches(match, labels map[string]string) bool {
+	for k, v := range match {
+		if got, ok := labels[k]; !ok || (v != "*" && got != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// lifecycle returns the Backstage lifecycle of a registry state.
+// States that aren't mapped are used as they are.
+func (m *mapping) lifecycle(state string) string {
+	if l, ok := m.Lifecycles[state]; ok {
+		return l
+	}
+	return state
+}
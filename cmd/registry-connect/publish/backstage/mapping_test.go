@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backstage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apigee/registry-experimental/cmd/registry-connect/publish/backstage/encoding"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapping.yaml")
+	if err := os.WriteFile(path, []byte(`
+links:
+  api:
+    - title: Console
+      url: https://console.example.com/{{.Project}}/apis/{{.API}}/versions/{{.Version}}?team={{index .Labels "team"}}
+owners:
+  - match: {tier: gold}
+    name: platform-team
+  - label: team
+    prefix: team-
+lifecycles:
+  design: experimental
+  retired: deprecated
+allVersions: true
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadMapping(path)
+	if err != nil {
+		t.Fatalf("loadMapping() returned error: %s", err)
+	}
+	if !m.AllVersions {
+		t.Error("allVersions wasn't read")
+	}
+	if len(m.Links.Taxonomies) != 1 {
+		t.Errorf("default taxonomies links weren't kept: %+v", m.Links.Taxonomies)
+	}
+
+	got, err := links(m.Links.API, linkData{Project: "p", API: "pets", Version: "v1", Labels: map[string]string{"team": "blue"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []encoding.Link{{Title: "Console", URL: "https://console.example.com/p/apis/pets/versions/v1?team=blue"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected links (-want +got):\n%s", diff)
+	}
+
+	owners := []struct {
+		labels map[string]string
+		want   string
+	}{
+		{map[string]string{"tier": "gold", "team": "blue"}, "platform-team"},
+		{map[string]string{"tier": "silver", "team": "blue"}, "team-blue"},
+		{map[string]string{"apihub-primary-contact": "someone"}, ""},
+	}
+	for _, test := range owners {
+		if got, _ := resolve(m.Owners, test.labels); got != test.want {
+			t.Errorf("resolve(%v) = %q, want %q", test.labels, got, test.want)
+		}
+	}
+
+	for state, want := range map[string]string{"design": "experimental", "retired": "deprecated", "production": "production"} {
+		if got := m.lifecycle(state); got != want {
+			t.Errorf("lifecycle(%q) = %q, want %q", state, got, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("links:\n  api:\n    - url: '{{.API'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadMapping(path); err == nil {
+		t.Error("loadMapping() with an invalid template succeeded")
+	}
+}
@@ -0,0 +1,443 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// https://schema.getpostman.com/json/collection/v2.1.0/collection.json
+const collectionSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// baseURL is the variable that requests are relative to. Collections default
+// it to the first server of their spec, and environments set it to the
+// endpoint of a deployment.
+const baseURL = "baseUrl"
+
+type collection struct {
+	Info     info       `json:"info"`
+	Item     []*item    `json:"item"`
+	Variable []variable `json:"variable,omitempty"`
+}
+
+type info struct {
+	PostmanID   string `json:"_postman_id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Schema      string `json:"schema"`
+}
+
+// item is a folder of items or a request.
+type item struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Item        []*item  `json:"item,omitempty"`
+	Request     *request `json:"request,omitempty"`
+}
+
+type request struct {
+	Method      string     `json:"method"`
+	Header      []variable `json:"header"`
+	Body        *body      `json:"body,omitempty"`
+	URL         url        `json:"url"`
+	Description string     `json:"description,omitempty"`
+}
+
+type url struct {
+	Raw      string     `json:"raw"`
+	Host     []string   `json:"host"`
+	Path     []string   `json:"path,omitempty"`
+	Query    []variable `json:"query,omitempty"`
+	Variable []variable `json:"variable,omitempty"`
+}
+
+type body struct {
+	Mode    string       `json:"mode"`
+	Raw     string       `json:"raw"`
+	Options *bodyOptions `json:"options,omitempty"`
+}
+
+type bodyOptions struct {
+	Raw struct {
+		Language string `json:"language"`
+	} `json:"raw"`
+}
+
+// variable is a key and value, which Postman uses for variables, headers and
+// query parameters.
+type variable struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+// loadOpenAPI returns an OpenAPI v3 document for an OpenAPI v2 or v3 spec.
+func loadOpenAPI(mimeType string, contents []byte) (*openapi3.T, error) {
+	if mime.IsOpenAPIv3(mimeType) {
+		return openapi3.NewLoader().LoadFromData(contents)
+	}
+	// v2 documents are JSON-tagged, so YAML is converted to JSON first.
+	var doc interface{}
+	if err := yaml.Unmarshal(contents, &doc); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	doc2 := &openapi2.T{}
+	if err := json.Unmarshal(b, doc2); err != nil {
+		return nil, err
+	}
+	return openapi2conv.ToV3(doc2)
+}
+
+// httpMethods are the methods of path items in the order that requests are listed.
+var httpMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+	http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodConnect,
+}
+
+// newCollection returns a collection with a request for each operation of
+// a document. Requests are in folders named by the first tag of their
+// operations, or by the first segment of their paths. IDs are derived from
+// the ID of the collection and everything is sorted, so that collections of
+// unchanged specs are identical.
+func newCollection(id, name string, doc *openapi3.T) *collection {
+	c := &collection{
+		Info: info{
+			PostmanID: uuid.NewSHA1(uuid.NameSpaceURL, []byte(id)).String(),
+			Name:      name,
+			Schema:    collectionSchema,
+		},
+		Item: []*item{},
+	}
+	if doc.Info != nil {
+		c.Info.Description = doc.Info.Description
+	}
+	server := variable{Key: baseURL}
+	if len(doc.Servers) > 0 {
+		server.Value = serverURL(doc.Servers[0])
+	}
+	c.Variable = append(c.Variable, server)
+
+	tagDescriptions := map[string]string{}
+	for _, t := range doc.Tags {
+		tagDescriptions[t.Name] = t.Description
+	}
+	folders := map[string]*item{}
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		pathItem := doc.Paths[p]
+		for _, method := range httpMethods {
+			op := pathItem.GetOperation(method)
+			if op == nil {
+				continue
+			}
+			folderName := firstSegment(p)
+			if len(op.Tags) > 0 {
+				folderName = op.Tags[0]
+			}
+			folder, ok := folders[folderName]
+			if !ok {
+				folder = &item{Name: folderName, Description: tagDescriptions[folderName]}
+				folders[folderName] = folder
+			}
+			folder.Item = append(folder.Item, newRequest(p, method, pathItem.Parameters, op))
+		}
+	}
+
+	names := make([]string, 0, len(folders))
+	for n := range folders {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		c.Item = append(c.Item, folders[n])
+	}
+	return c
+}
+
+func newRequest(path, method string, shared openapi3.Parameters, op *openapi3.Operation) *item {
+	r := &request{
+		Method:      method,
+		Header:      []variable{},
+		Description: op.Description,
+		URL: url{
+			Host: []string{"{{" + baseURL + "}}"},
+		},
+	}
+
+	// Parameters of operations override parameters of their paths.
+	params := map[string]*openapi3.Parameter{}
+	var keys []string
+	for _, refs := range []openapi3.Parameters{shared, op.Parameters} {
+		for _, ref := range refs {
+			if ref == nil || ref.Value == nil {
+				continue
+			}
+			key := ref.Value.In + ":" + ref.Value.Name
+			if _, ok := params[key]; !ok {
+				keys = append(keys, key)
+			}
+			params[key] = ref.Value
+		}
+	}
+	for _, key := range keys {
+		p := params[key]
+		v := variable{
+			Key:         p.Name,
+			Value:       parameterValue(p),
+			Description: p.Description,
+		}
+		switch p.In {
+		case openapi3.ParameterInPath:
+			r.URL.Variable = append(r.URL.Variable, v)
+		case openapi3.ParameterInQuery:
+			v.Disabled = !p.Required
+			r.URL.Query = append(r.URL.Query, v)
+		case openapi3.ParameterInHeader:
+			v.Disabled = !p.Required
+			r.Header = append(r.Header, v)
+		}
+	}
+
+	// Path parameters are written as :name in Postman.
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segment = ":" + strings.Trim(segment, "{}")
+		}
+		if segment != "" {
+			r.URL.Path = append(r.URL.Path, segment)
+		}
+	}
+	r.URL.Raw = "{{" + baseURL + "}}/" + strings.Join(r.URL.Path, "/")
+	var query []string
+	for _, q := range r.URL.Query {
+		if !q.Disabled {
+			query = append(query, q.Key+"="+q.Value)
+		}
+	}
+	if len(query) > 0 {
+		r.URL.Raw += "?" + strings.Join(query, "&")
+	}
+
+	if op.Responses != nil {
+		if contentType := firstContentType(op.Responses.Get(200), op.Responses.Get(201), op.Responses.Default()); contentType != "" {
+			r.Header = append(r.Header, variable{Key: "Accept", Value: contentType})
+		}
+	}
+	if op.RequestBody != nil && op.RequestBody.Value != nil {
+		if contentType, raw := requestBody(op.RequestBody.Value.Content); contentType != "" {
+			r.Header = append(r.Header, variable{Key: "Content-Type", Value: contentType})
+			r.Body = &body{Mode: "raw", Raw: raw}
+			if strings.Contains(contentType, "json") {
+				r.Body.Options = &bodyOptions{}
+				r.Body.Options.Raw.Language = "json"
+			}
+		}
+	}
+
+	name := op.Summary
+	if name == "" {
+		name = op.OperationID
+	}
+	if name == "" {
+		name = method + " " + path
+	}
+	return &item{Name: name, Request: r}
+}
+
+// serverURL returns the URL of a server with the default values of its variables.
+func serverURL(s *openapi3.Server) string {
+	u := s.URL
+	for name, v := range s.Variables {
+		if v != nil {
+			u = strings.ReplaceAll(u, "{"+name+"}", v.Default)
+		}
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+func firstSegment(path string) string {
+	segment := strings.Split(strings.Trim(path, "/"), "/")[0]
+	if segment == "" || strings.HasPrefix(segment, "{") {
+		return "default"
+	}
+	return segment
+}
+
+func parameterValue(p *openapi3.Parameter) string {
+	if p.Example != nil {
+		return fmt.Sprint(p.Example)
+	}
+	if p.Schema != nil && p.Schema.Value != nil {
+		s := p.Schema.Value
+		switch {
+		case s.Example != nil:
+			return fmt.Sprint(s.Example)
+		case s.Default != nil:
+			return fmt.Sprint(s.Default)
+		case len(s.Enum) > 0:
+			return fmt.Sprint(s.Enum[0])
+		}
+	}
+	return ""
+}
+
+// firstContentType returns the first media type of the first response that has one.
+func firstContentType(responses ...*openapi3.ResponseRef) string {
+	for _, r := range responses {
+		if r == nil || r.Value == nil || len(r.Value.Content) == 0 {
+			continue
+		}
+		return preferredContentType(r.Value.Content)
+	}
+	return ""
+}
+
+// preferredContentType returns the first JSON media type of content, or else
+// the first media type.
+func preferredContentType(content openapi3.Content) string {
+	types := make([]string, 0, len(content))
+	for t := range content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		if strings.Contains(t, "json") {
+			return t
+		}
+	}
+	if len(types) > 0 {
+		return types[0]
+	}
+	return ""
+}
+
+// requestBody returns the content type and an example body of a request.
+func requestBody(content openapi3.Content) (string, string) {
+	contentType := preferredContentType(content)
+	if contentType == "" {
+		return "", ""
+	}
+	media := content[contentType]
+	var value interface{}
+	switch {
+	case media.Example != nil:
+		value = media.Example
+	case len(media.Examples) > 0:
+		names := make([]string, 0, len(media.Examples))
+		for n := range media.Examples {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if e := media.Examples[names[0]]; e != nil && e.Value != nil {
+			value = e.Value.Value
+		}
+	case media.Schema != nil:
+		value = example(media.Schema, 0)
+	}
+	if s, ok := value.(string); ok && !strings.Contains(contentType, "json") {
+		return contentType, s
+	}
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return contentType, ""
+	}
+	return contentType, string(b)
+}
+
+// maxExampleDepth limits examples of recursive schemas.
+const maxExampleDepth = 5
+
+// example returns an example value of a schema.
+func example(ref *openapi3.SchemaRef, depth int) interface{} {
+	if ref == nil || ref.Value == nil || depth > maxExampleDepth {
+		return nil
+	}
+	s := ref.Value
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	}
+	if len(s.AllOf) > 0 {
+		merged := map[string]interface{}{}
+		for _, part := range s.AllOf {
+			if m, ok := example(part, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, alternatives := range []openapi3.SchemaRefs{s.OneOf, s.AnyOf} {
+		if len(alternatives) > 0 {
+			return example(alternatives[0], depth+1)
+		}
+	}
+	switch s.Type {
+	case "object", "":
+		if len(s.Properties) == 0 && s.Type == "" {
+			return nil
+		}
+		m := map[string]interface{}{}
+		for name, p := range s.Properties {
+			m[name] = example(p, depth+1)
+		}
+		return m
+	case "array":
+		if item := example(s.Items, depth+1); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "string":
+		switch s.Format {
+		case "date":
+			return "2023-01-01"
+		case "date-time":
+			return "2023-01-01T00:00:00Z"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		}
+		return "string"
+	}
+	return nil
+}
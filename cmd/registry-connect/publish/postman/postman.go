@@ -0,0 +1,168 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postman
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/log"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/pkg/names"
+	"github.com/apigee/registry/pkg/visitor"
+	"github.com/apigee/registry/rpc"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+func Command() *cobra.Command {
+	var filter string
+	var cmd = &cobra.Command{
+		Use:   "postman OUTPUT",
+		Short: "Export OpenAPI specs as Postman collections",
+		Long: `Export OpenAPI specs as Postman v2.1 collections.
+
+Each OpenAPI spec is written to OUTPUT/collections as a collection with a
+request for each operation, relative to a {{baseUrl}} variable. Each deployment
+with an endpoint is written to OUTPUT/environments as an environment that sets
+{{baseUrl}} to its endpoint. Collections can also be imported into Insomnia.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			config, err := connection.ActiveConfig()
+			if err != nil {
+				log.FromContext(ctx).WithError(err).Fatal("Failed to get config")
+			}
+			client, err := connection.NewRegistryClientWithSettings(ctx, config)
+			if err != nil {
+				return err
+			}
+			w := &workspace{
+				client: client,
+				config: config,
+				filter: filter,
+				root:   args[0],
+			}
+			return w.Run(ctx)
+		},
+	}
+	cmd.Flags().StringVar(&filter, "filter", "", "filter selected apis")
+	return cmd
+}
+
+// workspace writes the collections and environments of a project.
+type workspace struct {
+	client connection.RegistryClient
+	config connection.Config
+	filter string
+	root   string
+}
+
+type environment struct {
+	ID     string             `json:"id"`
+	Name   string             `json:"name"`
+	Values []environmentValue `json:"values"`
+	Scope  string             `json:"_postman_variable_scope"`
+}
+
+type environmentValue struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (w *workspace) Run(ctx context.Context) error {
+	project, err := names.ParseProject("projects/" + w.config.Project)
+	if err != nil {
+		return err
+	}
+	collections, environments := 0, 0
+	err = visitor.ListAPIs(ctx, w.client, project.Api("-"), 0, w.filter, func(ctx context.Context, a *rpc.Api) error {
+		apiName, _ := names.ParseApi(a.Name)
+		apiTitle := a.DisplayName
+		if apiTitle == "" {
+			apiTitle = apiName.ApiID
+		}
+
+		err := visitor.ListSpecs(ctx, w.client, apiName.Version("-").Spec("-"), 0, "", true, func(ctx context.Context, s *rpc.ApiSpec) error {
+			if !mime.IsOpenAPIv2(s.MimeType) && !mime.IsOpenAPIv3(s.MimeType) {
+				return nil
+			}
+			specName, _ := names.ParseSpec(s.Name)
+			doc, err := loadOpenAPI(s.MimeType, s.Contents)
+			if err != nil {
+				log.FromContext(ctx).WithError(err).Warnf("skipping %s", s.Name)
+				return nil
+			}
+			c := newCollection(specName.String(), apiTitle+" "+specName.VersionID+" "+specName.SpecID, doc)
+			collections++
+			return w.writeJSON(filepath.Join("collections", specName.ApiID+"-"+specName.VersionID+"-"+specName.SpecID+".postman_collection.json"), c)
+		})
+		if err != nil {
+			return err
+		}
+
+		return visitor.ListDeployments(ctx, w.client, apiName.Deployment("-"), 0, "", func(ctx context.Context, d *rpc.ApiDeployment) error {
+			if d.EndpointUri == "" {
+				return nil
+			}
+			deploymentName, _ := names.ParseDeployment(d.Name)
+			name := d.DisplayName
+			if name == "" {
+				name = deploymentName.DeploymentID
+			}
+			env := &environment{
+				ID:   uuid.NewSHA1(uuid.NameSpaceURL, []byte(deploymentName.String())).String(),
+				Name: apiTitle + " " + name,
+				Values: []environmentValue{{
+					Key:     baseURL,
+					Value:   d.EndpointUri,
+					Type:    "default",
+					Enabled: true,
+				}},
+				Scope: "environment",
+			}
+			environments++
+			return w.writeJSON(filepath.Join("environments", deploymentName.ApiID+"-"+deploymentName.DeploymentID+".postman_environment.json"), env)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Infof("%d collections and %d environments written to %s", collections, environments, w.root)
+	return nil
+}
+
+// writeJSON writes a value to a file relative to the root. HTML characters
+// aren't escaped, so that descriptions stay readable.
+func (w *workspace) writeJSON(file string, v interface{}) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	file = filepath.Join(w.root, file)
+	if err := os.MkdirAll(filepath.Dir(file), os.FileMode(0755)); err != nil { // rwx,rx,rx
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), os.FileMode(0644)) // rw,r,r
+}
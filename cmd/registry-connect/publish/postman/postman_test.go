@@ -0,0 +1,309 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postman
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMain(m *testing.M) {
+	grpctest.TestMain(m, registry.Config{})
+}
+
+const petstore = `openapi: 3.0.0
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: https://{region}.pets.example.com/v1
+    variables:
+      region:
+        default: us
+tags:
+  - name: pets
+    description: Everything about pets
+paths:
+  /pets:
+    post:
+      tags: [pets]
+      summary: Create a pet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+      responses:
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+    get:
+      tags: [pets]
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            default: 10
+        - name: kind
+          in: query
+          required: true
+          example: cat
+      responses:
+        "200":
+          description: Pets
+  /pets/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        description: ID of a pet
+    get:
+      tags: [pets]
+      summary: Get a pet
+      responses:
+        "200":
+          description: A pet
+  /health:
+    get:
+      responses:
+        "200":
+          description: OK
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name:
+          type: string
+        tags:
+          type: array
+          items:
+            type: string
+        born:
+          type: string
+          format: date
+`
+
+const swagger = `swagger: "2.0"
+info:
+  title: Stores
+  version: 1.0.0
+host: stores.example.com
+basePath: /v2
+schemes: [https]
+paths:
+  /stores/{store}:
+    get:
+      summary: Get a store
+      parameters:
+        - name: store
+          in: path
+          required: true
+          type: string
+      responses:
+        "200":
+          description: A store
+`
+
+func TestCollection(t *testing.T) {
+	doc, err := loadOpenAPI(mime.OpenAPIMimeType("", "3"), []byte(petstore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newCollection("petstore", "Petstore", doc)
+	if diff := cmp.Diff([]variable{{Key: "baseUrl", Value: "https://us.pets.example.com/v1"}}, c.Variable); diff != "" {
+		t.Errorf("unexpected variables (-want +got):\n%s", diff)
+	}
+
+	var folders, requests []string
+	for _, f := range c.Item {
+		folders = append(folders, f.Name)
+		for _, r := range f.Item {
+			requests = append(requests, r.Request.Method+" "+r.Request.URL.Raw+" "+r.Name)
+		}
+	}
+	if diff := cmp.Diff([]string{"health", "pets"}, folders); diff != "" {
+		t.Errorf("unexpected folders (-want +got):\n%s", diff)
+	}
+	wantRequests := []string{
+		"GET {{baseUrl}}/health GET /health",
+		"GET {{baseUrl}}/pets?kind=cat listPets",
+		"POST {{baseUrl}}/pets Create a pet",
+		"GET {{baseUrl}}/pets/:id Get a pet",
+	}
+	if diff := cmp.Diff(wantRequests, requests); diff != "" {
+		t.Errorf("unexpected requests (-want +got):\n%s", diff)
+	}
+
+	pets := c.Item[1]
+	if pets.Description != "Everything about pets" {
+		t.Errorf("unexpected folder description %q", pets.Description)
+	}
+	list := pets.Item[0].Request
+	if diff := cmp.Diff([]variable{{Key: "limit", Value: "10", Disabled: true}, {Key: "kind", Value: "cat"}}, list.URL.Query); diff != "" {
+		t.Errorf("unexpected query (-want +got):\n%s", diff)
+	}
+	create := pets.Item[1].Request
+	if diff := cmp.Diff([]variable{{Key: "Accept", Value: "application/json"}, {Key: "Content-Type", Value: "application/json"}}, create.Header); diff != "" {
+		t.Errorf("unexpected headers (-want +got):\n%s", diff)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(create.Body.Raw), &body); err != nil {
+		t.Fatal(err)
+	}
+	wantBody := map[string]interface{}{"name": "string", "tags": []interface{}{"string"}, "born": "2023-01-01"}
+	if diff := cmp.Diff(wantBody, body); diff != "" {
+		t.Errorf("unexpected body (-want +got):\n%s", diff)
+	}
+	get := pets.Item[2].Request
+	if diff := cmp.Diff(url{
+		Raw:      "{{baseUrl}}/pets/:id",
+		Host:     []string{"{{baseUrl}}"},
+		Path:     []string{"pets", ":id"},
+		Variable: []variable{{Key: "id", Description: "ID of a pet"}},
+	}, get.URL); diff != "" {
+		t.Errorf("unexpected URL (-want +got):\n%s", diff)
+	}
+
+	doc, err = loadOpenAPI(mime.OpenAPIMimeType("", "2"), []byte(swagger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = newCollection("stores", "Stores", doc)
+	if c.Variable[0].Value != "https://stores.example.com/v2" {
+		t.Errorf("unexpected base URL %q", c.Variable[0].Value)
+	}
+	if got := c.Item[0].Item[0].Request.URL.Raw; got != "{{baseUrl}}/stores/:store" {
+		t.Errorf("unexpected URL %q", got)
+	}
+}
+
+func readDir(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(p)
+		rel, _ := filepath.Rel(root, p)
+		files[filepath.ToSlash(rel)] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestWorkspace(t *testing.T) {
+	ctx := context.Background()
+	api := "projects/postman-test/locations/global/apis/petstore"
+	client, _ := grpctest.SetupRegistry(ctx, t, "postman-test", []seeder.RegistryResource{
+		&rpc.Api{
+			Name:        api,
+			DisplayName: "Pet Store",
+		},
+		&rpc.ApiSpec{
+			Name:     api + "/versions/v1/specs/openapi",
+			MimeType: mime.OpenAPIMimeType("", "3"),
+			Contents: []byte(petstore),
+		},
+		&rpc.ApiSpec{
+			Name:     api + "/versions/v1/specs/swagger",
+			MimeType: mime.OpenAPIMimeType("", "2"),
+			Contents: []byte(swagger),
+		},
+		&rpc.ApiSpec{
+			Name:     api + "/versions/v1/specs/protos",
+			MimeType: mime.ProtobufMimeType("+zip"),
+		},
+		&rpc.ApiDeployment{
+			Name:        api + "/deployments/prod",
+			DisplayName: "Production",
+			EndpointUri: "https://pets.example.com/v1",
+		},
+		&rpc.ApiDeployment{
+			Name: api + "/deployments/unknown",
+		},
+	})
+
+	roots := []string{t.TempDir(), t.TempDir()}
+	for _, root := range roots {
+		w := &workspace{
+			client: client,
+			config: connection.Config{Project: "postman-test"},
+			root:   root,
+		}
+		if err := w.Run(ctx); err != nil {
+			t.Fatalf("Run() returned error: %s", err)
+		}
+	}
+
+	files := readDir(t, roots[0])
+	var got []string
+	for f := range files {
+		got = append(got, f)
+	}
+	want := []string{
+		"collections/petstore-v1-openapi.postman_collection.json",
+		"collections/petstore-v1-swagger.postman_collection.json",
+		"environments/petstore-prod.postman_environment.json",
+	}
+	if diff := cmp.Diff(want, got, cmpSorted); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(files, readDir(t, roots[1])); diff != "" {
+		t.Errorf("runs wrote different files (-first +second):\n%s", diff)
+	}
+
+	env := &environment{}
+	if err := json.Unmarshal([]byte(files["environments/petstore-prod.postman_environment.json"]), env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Name != "Pet Store Production" || len(env.Values) != 1 || env.Values[0].Value != "https://pets.example.com/v1" {
+		t.Errorf("unexpected environment %+v", env)
+	}
+	c := &collection{}
+	if err := json.Unmarshal([]byte(files["collections/petstore-v1-openapi.postman_collection.json"]), c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Info.Name != "Pet Store v1 openapi" || c.Info.Schema != collectionSchema || c.Info.PostmanID == "" {
+		t.Errorf("unexpected info %+v", c.Info)
+	}
+}
+
+var cmpSorted = cmp.Transformer("sort", func(in []string) []string {
+	out := append([]string(nil), in...)
+	sort.Strings(out)
+	return out
+})
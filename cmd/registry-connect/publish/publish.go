@@ -16,6 +16,8 @@ package publish
 
 import (
 	"github.com/apigee/registry-experimental/cmd/registry-connect/publish/backstage"
+	"github.com/apigee/registry-experimental/cmd/registry-connect/publish/postman"
+	"github.com/apigee/registry-experimental/cmd/registry-connect/publish/site"
 	"github.com/spf13/cobra"
 )
 
@@ -26,5 +28,7 @@ func Command() *cobra.Command {
 		Args:  cobra.NoArgs,
 	}
 	cmd.AddCommand(backstage.Command())
+	cmd.AddCommand(postman.Command())
+	cmd.AddCommand(site.Command())
 	return cmd
 }
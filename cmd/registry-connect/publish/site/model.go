@@ -0,0 +1,351 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package site
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/apigee/registry/cmd/registry/patch"
+	"github.com/apigee/registry/pkg/application/apihub"
+	"github.com/apigee/registry/pkg/application/scoring"
+	"github.com/apigee/registry/pkg/application/style"
+	"github.com/apigee/registry/pkg/log"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/pkg/names"
+	"github.com/apigee/registry/pkg/visitor"
+	"github.com/apigee/registry/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// api is an API and everything that is shown on its pages.
+type api struct {
+	ID          string
+	DisplayName string
+	Description string
+	Labels      map[string]string
+	Recommended string // ID of the recommended version
+	Versions    []*version
+	Deployments []*deployment
+	Badges      []badge
+}
+
+type version struct {
+	ID          string
+	DisplayName string
+	Description string
+	State       string
+	PrimarySpec string // ID of the primary spec
+	Specs       []*spec
+	Badges      []badge
+}
+
+type spec struct {
+	ID        string
+	Filename  string
+	MimeType  string
+	SourceURI string
+	Contents  []byte
+	Reference *reference
+	Badges    []badge
+}
+
+type deployment struct {
+	ID                 string
+	DisplayName        string
+	Description        string
+	EndpointURI        string
+	ExternalChannelURI string
+	Environment        string
+	Version            string // version and ID of the deployed spec, if it is known
+	Spec               string
+	Badges             []badge
+}
+
+// badge is a summary of a lint or score artifact.
+type badge struct {
+	Label    string
+	Value    string
+	Severity string // "ok", "warning", "alert" or ""
+	URI      string
+}
+
+// facet is a label (or taxonomy) that APIs can be filtered by.
+type facet struct {
+	Key         string
+	DisplayName string
+	Values      []facetValue
+}
+
+type facetValue struct {
+	Value       string
+	DisplayName string
+	Count       int
+}
+
+// collect reads the APIs of a project and their versions, specs, deployments
+// and badges.
+func (s *site) collect(ctx context.Context) ([]*api, error) {
+	project, err := names.ParseProject("projects/" + s.config.Project)
+	if err != nil {
+		return nil, err
+	}
+	var apis []*api
+	err = visitor.ListAPIs(ctx, s.client, project.Api("-"), 0, s.filter, func(ctx context.Context, a *rpc.Api) error {
+		log.FromContext(ctx).Infof("rendering %s", a.Name)
+		apiName, _ := names.ParseApi(a.Name)
+		result := &api{
+			ID:          apiName.ApiID,
+			DisplayName: firstOf(a.DisplayName, apiName.ApiID),
+			Description: a.Description,
+			Labels:      a.Labels,
+		}
+		if v, err := names.ParseVersion(a.RecommendedVersion); err == nil {
+			result.Recommended = v.VersionID
+		}
+		if result.Badges, err = s.badges(ctx, apiName.Artifact("-")); err != nil {
+			return err
+		}
+
+		err := visitor.ListVersions(ctx, s.client, apiName.Version("-"), 0, "", func(ctx context.Context, v *rpc.ApiVersion) error {
+			versionName, _ := names.ParseVersion(v.Name)
+			rv := &version{
+				ID:          versionName.VersionID,
+				DisplayName: firstOf(v.DisplayName, versionName.VersionID),
+				Description: v.Description,
+				State:       v.State,
+			}
+			if primary, err := names.ParseSpec(v.PrimarySpec); err == nil {
+				rv.PrimarySpec = primary.SpecID
+			}
+			var err error
+			if rv.Badges, err = s.badges(ctx, versionName.Artifact("-")); err != nil {
+				return err
+			}
+			err = visitor.ListSpecs(ctx, s.client, versionName.Spec("-"), 0, "", true, func(ctx context.Context, sp *rpc.ApiSpec) error {
+				specName, _ := names.ParseSpec(sp.Name)
+				rs := &spec{
+					ID:        specName.SpecID,
+					Filename:  firstOf(sp.Filename, specName.SpecID),
+					MimeType:  sp.MimeType,
+					SourceURI: sp.SourceUri,
+					Contents:  sp.Contents,
+				}
+				rs.Reference, err = referenceForSpec(sp.MimeType, sp.Contents)
+				if err != nil {
+					log.FromContext(ctx).WithError(err).Warnf("failed to render reference of %s", sp.Name)
+				}
+				if rs.Badges, err = s.badges(ctx, specName.Artifact("-")); err != nil {
+					return err
+				}
+				rv.Specs = append(rv.Specs, rs)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			result.Versions = append(result.Versions, rv)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = visitor.ListDeployments(ctx, s.client, apiName.Deployment("-"), 0, "", func(ctx context.Context, d *rpc.ApiDeployment) error {
+			deploymentName, _ := names.ParseDeployment(d.Name)
+			rd := &deployment{
+				ID:                 deploymentName.DeploymentID,
+				DisplayName:        firstOf(d.DisplayName, deploymentName.DeploymentID),
+				Description:        d.Description,
+				EndpointURI:        d.EndpointUri,
+				ExternalChannelURI: d.ExternalChannelUri,
+				Environment:        d.Annotations["apigee-environment"],
+			}
+			if r, err := names.ParseSpecRevision(d.ApiSpecRevision); err == nil {
+				rd.Version, rd.Spec = r.VersionID, r.SpecID
+			}
+			var err error
+			if rd.Badges, err = s.badges(ctx, deploymentName.Artifact("-")); err != nil {
+				return err
+			}
+			result.Deployments = append(result.Deployments, rd)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		apis = append(apis, result)
+		return nil
+	})
+	return apis, err
+}
+
+// badges returns badges for the lint and score artifacts of a resource.
+func (s *site) badges(ctx context.Context, artifacts names.Artifact) ([]badge, error) {
+	var badges []badge
+	err := visitor.ListArtifacts(ctx, s.client, artifacts, 0, "", true, func(ctx context.Context, a *rpc.Artifact) error {
+		messageType, err := mime.MessageTypeForMimeType(a.MimeType)
+		if err != nil {
+			return nil // not a message
+		}
+		switch messageType {
+		case "google.cloud.apigeeregistry.v1.style.Lint",
+			"google.cloud.apigeeregistry.v1.style.LintStats",
+			"google.cloud.apigeeregistry.v1.scoring.Score",
+			"google.cloud.apigeeregistry.v1.scoring.ScoreCard":
+		default:
+			return nil
+		}
+		message, err := mime.MessageForMimeType(a.MimeType)
+		if err != nil {
+			return err
+		}
+		if err := patch.UnmarshalContents(a.Contents, a.MimeType, message); err != nil {
+			log.FromContext(ctx).WithError(err).Warnf("skipping %s", a.Name)
+			return nil
+		}
+		artifactName, _ := names.ParseArtifact(a.Name)
+		switch m := message.(type) {
+		case *style.Lint:
+			problems := 0
+			for _, f := range m.Files {
+				problems += len(f.Problems)
+			}
+			badges = append(badges, lintBadge(firstOf(m.Name, artifactName.ArtifactID()), problems))
+		case *style.LintStats:
+			problems := 0
+			for _, c := range m.ProblemCounts {
+				problems += int(c.Count)
+			}
+			badges = append(badges, lintBadge(artifactName.ArtifactID(), problems))
+		case *scoring.Score:
+			badges = append(badges, scoreBadge(m))
+		case *scoring.ScoreCard:
+			for _, score := range m.Scores {
+				badges = append(badges, scoreBadge(score))
+			}
+		}
+		return nil
+	})
+	return badges, err
+}
+
+func lintBadge(label string, problems int) badge {
+	b := badge{Label: label, Value: fmt.Sprintf("%d problems", problems), Severity: "ok"}
+	if problems == 1 {
+		b.Value = "1 problem"
+	}
+	if problems > 0 {
+		b.Severity = "warning"
+	}
+	return b
+}
+
+func scoreBadge(score *scoring.Score) badge {
+	b := badge{
+		Label: firstOf(score.DisplayName, score.Id),
+		URI:   score.Uri,
+	}
+	switch v := score.Value.(type) {
+	case *scoring.Score_PercentValue:
+		b.Value = fmt.Sprintf("%.0f%%", v.PercentValue.GetValue())
+	case *scoring.Score_IntegerValue:
+		b.Value = strconv.Itoa(int(v.IntegerValue.GetValue()))
+	case *scoring.Score_BooleanValue:
+		b.Value = firstOf(v.BooleanValue.GetDisplayValue(), strconv.FormatBool(v.BooleanValue.GetValue()))
+	}
+	switch score.Severity {
+	case scoring.Severity_OK:
+		b.Severity = "ok"
+	case scoring.Severity_WARNING:
+		b.Severity = "warning"
+	case scoring.Severity_ALERT:
+		b.Severity = "alert"
+	}
+	return b
+}
+
+// facets returns the labels of APIs with the values they have. Labels that
+// are taxonomies get the display names of the taxonomy and its elements.
+func (s *site) facets(ctx context.Context, apis []*api) ([]facet, error) {
+	taxonomies := map[string]*apihub.TaxonomyList_Taxonomy{}
+	name, err := names.ParseArtifact(s.config.FQName("artifacts/apihub-taxonomies"))
+	if err != nil {
+		return nil, err
+	}
+	err = visitor.GetArtifact(ctx, s.client, name, true, func(ctx context.Context, a *rpc.Artifact) error {
+		message, err := mime.MessageForMimeType(a.GetMimeType())
+		if err != nil {
+			return err
+		}
+		if err := patch.UnmarshalContents(a.GetContents(), a.GetMimeType(), message); err != nil {
+			return err
+		}
+		if list, ok := message.(*apihub.TaxonomyList); ok {
+			for _, t := range list.Taxonomies {
+				taxonomies[t.Id] = t
+			}
+		}
+		return nil
+	})
+	if status.Code(err) != codes.NotFound && err != nil {
+		return nil, err
+	}
+
+	counts := map[string]map[string]int{}
+	for _, a := range apis {
+		for k, v := range a.Labels {
+			if counts[k] == nil {
+				counts[k] = map[string]int{}
+			}
+			counts[k][v]++
+		}
+	}
+	var facets []facet
+	for key, values := range counts {
+		f := facet{Key: key, DisplayName: key}
+		t := taxonomies[key]
+		if t != nil {
+			f.DisplayName = firstOf(t.DisplayName, key)
+		}
+		for v, n := range values {
+			fv := facetValue{Value: v, DisplayName: v, Count: n}
+			if t != nil {
+				for _, e := range t.Elements {
+					if e.Id == v {
+						fv.DisplayName = firstOf(e.DisplayName, v)
+					}
+				}
+			}
+			f.Values = append(f.Values, fv)
+		}
+		sort.Slice(f.Values, func(i, j int) bool { return f.Values[i].Value < f.Values[j].Value })
+		facets = append(facets, f)
+	}
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Key < facets[j].Key })
+	return facets, nil
+}
+
+func firstOf(args ...string) string {
+	for _, a := range args {
+		if a != "" {
+			return a
+		}
+	}
+	return ""
+}
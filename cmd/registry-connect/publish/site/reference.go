@@ -0,0 +1,303 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/apigee/registry/pkg/mime"
+	"gopkg.in/yaml.v3"
+)
+
+// reference is the reference documentation of a spec.
+type reference struct {
+	Title       string
+	Version     string
+	Description string
+	Servers     []string
+	Operations  []operation // OpenAPI operations
+	Services    []service   // proto services
+	Messages    []message   // proto messages
+}
+
+type operation struct {
+	Method      string
+	Path        string
+	ID          string
+	Summary     string
+	Description string
+	Parameters  []parameter
+	Responses   []response
+}
+
+type parameter struct {
+	Name        string
+	In          string
+	Required    bool
+	Description string
+}
+
+type response struct {
+	Code        string
+	Description string
+}
+
+type service struct {
+	Name    string
+	Package string
+	File    string
+	Methods []method
+}
+
+type method struct {
+	Name     string
+	Request  string
+	Response string
+	Comment  string
+}
+
+type message struct {
+	Name   string
+	Fields []string
+}
+
+// referenceForSpec returns the reference documentation of an OpenAPI spec or
+// a zip archive of protos, or nil for other specs.
+func referenceForSpec(mimeType string, contents []byte) (*reference, error) {
+	switch {
+	case mime.IsOpenAPIv2(mimeType) || mime.IsOpenAPIv3(mimeType):
+		return openAPIReference(contents)
+	case mime.IsProto(mimeType) && mime.IsZipArchive(mimeType):
+		return protoReference(contents)
+	default:
+		return nil, nil
+	}
+}
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// openAPIDocument has the parts of OpenAPI v2 and v3 documents that are shown in references.
+type openAPIDocument struct {
+	Info struct {
+		Title       string `yaml:"title"`
+		Version     string `yaml:"version"`
+		Description string `yaml:"description"`
+	} `yaml:"info"`
+	Host     string   `yaml:"host"`
+	BasePath string   `yaml:"basePath"`
+	Schemes  []string `yaml:"schemes"`
+	Servers  []struct {
+		URL string `yaml:"url"`
+	} `yaml:"servers"`
+	Paths map[string]map[string]yaml.Node `yaml:"paths"`
+}
+
+type openAPIOperation struct {
+	OperationID string             `yaml:"operationId"`
+	Summary     string             `yaml:"summary"`
+	Description string             `yaml:"description"`
+	Parameters  []openAPIParameter `yaml:"parameters"`
+	Responses   map[string]struct {
+		Description string `yaml:"description"`
+	} `yaml:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string `yaml:"name"`
+	In          string `yaml:"in"`
+	Required    bool   `yaml:"required"`
+	Description string `yaml:"description"`
+	Ref         string `yaml:"$ref"`
+}
+
+func openAPIReference(contents []byte) (*reference, error) {
+	doc := &openAPIDocument{}
+	if err := yaml.Unmarshal(contents, doc); err != nil {
+		return nil, err
+	}
+	ref := &reference{
+		Title:       doc.Info.Title,
+		Version:     doc.Info.Version,
+		Description: doc.Info.Description,
+	}
+	for _, s := range doc.Servers {
+		ref.Servers = append(ref.Servers, s.URL)
+	}
+	if doc.Host != "" {
+		schemes := doc.Schemes
+		if len(schemes) == 0 {
+			schemes = []string{"https"}
+		}
+		for _, scheme := range schemes {
+			ref.Servers = append(ref.Servers, scheme+"://"+doc.Host+doc.BasePath)
+		}
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		item := doc.Paths[p]
+		// Parameters of a path apply to all of its operations.
+		var shared []openAPIParameter
+		if node, ok := item["parameters"]; ok {
+			_ = node.Decode(&shared)
+		}
+		for _, m := range httpMethods {
+			node, ok := item[m]
+			if !ok {
+				continue
+			}
+			op := &openAPIOperation{}
+			if err := node.Decode(op); err != nil {
+				return nil, err
+			}
+			o := operation{
+				Method:      strings.ToUpper(m),
+				Path:        p,
+				ID:          op.OperationID,
+				Summary:     op.Summary,
+				Description: op.Description,
+			}
+			for _, param := range append(shared, op.Parameters...) {
+				if param.Ref != "" {
+					param.Name = path.Base(param.Ref)
+				}
+				o.Parameters = append(o.Parameters, parameter{
+					Name:        param.Name,
+					In:          param.In,
+					Required:    param.Required,
+					Description: param.Description,
+				})
+			}
+			codes := make([]string, 0, len(op.Responses))
+			for code := range op.Responses {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			for _, code := range codes {
+				o.Responses = append(o.Responses, response{Code: code, Description: op.Responses[code].Description})
+			}
+			ref.Operations = append(ref.Operations, o)
+		}
+	}
+	return ref, nil
+}
+
+var (
+	protoPackage = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+	protoService = regexp.MustCompile(`^\s*service\s+(\w+)\s*\{`)
+	protoMessage = regexp.MustCompile(`^\s*message\s+(\w+)\s*\{`)
+	protoRPC     = regexp.MustCompile(`^\s*rpc\s+(\w+)\s*\(\s*(stream\s+)?([\w.]+)\s*\)\s*returns\s*\(\s*(stream\s+)?([\w.]+)\s*\)`)
+	protoField   = regexp.MustCompile(`^\s*((?:repeated|optional)\s+)?(map\s*<[^>]+>|[\w.]+)\s+(\w+)\s*=\s*\d+`)
+)
+
+// protoReference returns the services and top-level messages of the files in
+// a zip archive of protos. Files are scanned line by line, which is enough for
+// the conventional formatting of published protos.
+func protoReference(contents []byte) (*reference, error) {
+	r, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return nil, err
+	}
+	ref := &reference{}
+	for _, f := range r.File {
+		if path.Ext(f.Name) != ".proto" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		scanProto(ref, f.Name, b)
+	}
+	sort.Slice(ref.Services, func(i, j int) bool { return ref.Services[i].Name < ref.Services[j].Name })
+	sort.Slice(ref.Messages, func(i, j int) bool { return ref.Messages[i].Name < ref.Messages[j].Name })
+	return ref, nil
+}
+
+func scanProto(ref *reference, filename string, contents []byte) {
+	pkg := ""
+	if m := protoPackage.FindSubmatch(contents); m != nil {
+		pkg = string(m[1])
+	}
+	if ref.Title == "" {
+		ref.Title = pkg
+	}
+
+	var svc *service
+	var msg *message
+	var comments []string
+	depth := 0
+	for _, line := range strings.Split(string(contents), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "//") {
+			comments = append(comments, strings.TrimSpace(strings.TrimPrefix(trimmed, "//")))
+			continue
+		}
+		if depth == 0 {
+			if m := protoService.FindStringSubmatch(line); m != nil {
+				svc = &service{Name: m[1], Package: pkg, File: filename}
+			} else if m := protoMessage.FindStringSubmatch(line); m != nil {
+				msg = &message{Name: qualified(pkg, m[1])}
+			}
+		} else if depth == 1 {
+			if m := protoRPC.FindStringSubmatch(line); svc != nil && m != nil {
+				svc.Methods = append(svc.Methods, method{
+					Name:     m[1],
+					Request:  strings.TrimSpace(m[2] + m[3]),
+					Response: strings.TrimSpace(m[4] + m[5]),
+					Comment:  strings.Join(comments, " "),
+				})
+			} else if m := protoField.FindStringSubmatch(line); msg != nil && m != nil {
+				msg.Fields = append(msg.Fields, strings.TrimSpace(m[1]+m[2])+" "+m[3])
+			}
+		}
+		if trimmed != "" {
+			comments = nil
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth == 0 {
+			if svc != nil {
+				ref.Services = append(ref.Services, *svc)
+				svc = nil
+			}
+			if msg != nil {
+				ref.Messages = append(ref.Messages, *msg)
+				msg = nil
+			}
+		}
+	}
+}
+
+func qualified(pkg, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
@@ -0,0 +1,250 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package site
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+func Command() *cobra.Command {
+	var filter, title string
+	var cmd = &cobra.Command{
+		Use:   "site OUTPUT",
+		Short: "Render the APIs of a project as a static developer portal",
+		Long: `Render the APIs of a project as a static developer portal.
+
+The output folder gets an HTML catalog of APIs that can be filtered by labels
+and taxonomies, pages for APIs and their versions with reference documentation
+of OpenAPI and proto specs, deployment endpoints, and badges for lint and score
+artifacts. A search index is included, so the folder can be hosted on any
+static file server.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			config, err := connection.ActiveConfig()
+			if err != nil {
+				log.FromContext(ctx).WithError(err).Fatal("Failed to get config")
+			}
+			client, err := connection.NewRegistryClientWithSettings(ctx, config)
+			if err != nil {
+				return err
+			}
+			s := &site{
+				client: client,
+				config: config,
+				filter: filter,
+				title:  title,
+				root:   args[0],
+			}
+			return s.Run(ctx)
+		},
+	}
+	cmd.Flags().StringVar(&filter, "filter", "", "filter selected apis")
+	cmd.Flags().StringVar(&title, "title", "", "title of the portal (defaults to the project ID)")
+	return cmd
+}
+
+type site struct {
+	client connection.RegistryClient
+	config connection.Config
+	filter string
+	title  string
+	root   string
+}
+
+// page holds the values used to render a page. Root is the relative path from
+// the page to the root of the site, so that the site can be hosted anywhere.
+type page struct {
+	Root    string
+	Site    string
+	Title   string
+	API     *api
+	Version *version
+	APIs    []*api
+	Facets  []facet
+}
+
+func (s *site) Run(ctx context.Context) error {
+	apis, err := s.collect(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(apis, func(i, j int) bool { return apis[i].ID < apis[j].ID })
+	facets, err := s.facets(ctx, apis)
+	if err != nil {
+		return err
+	}
+	siteTitle := firstOf(s.title, s.config.Project)
+
+	if err := s.writeFile("static/site.css", []byte(siteCSS)); err != nil {
+		return err
+	}
+	if err := s.writeFile("static/site.js", []byte(siteJS)); err != nil {
+		return err
+	}
+	index, err := json.Marshal(searchIndex(apis))
+	if err != nil {
+		return err
+	}
+	if err := s.writeFile("search-index.js", []byte("var searchIndex = "+string(index)+";\n")); err != nil {
+		return err
+	}
+	if err := s.writePage("index.html", "catalog", &page{
+		Site:   siteTitle,
+		Title:  siteTitle,
+		APIs:   apis,
+		Facets: facets,
+	}); err != nil {
+		return err
+	}
+	for _, a := range apis {
+		if err := s.writePage(path.Join("apis", a.ID, "index.html"), "api", &page{
+			Site:  siteTitle,
+			Title: a.DisplayName,
+			API:   a,
+		}); err != nil {
+			return err
+		}
+		for _, v := range a.Versions {
+			dir := path.Join("apis", a.ID, "versions", v.ID)
+			if err := s.writePage(path.Join(dir, "index.html"), "version", &page{
+				Site:    siteTitle,
+				Title:   a.DisplayName + " " + v.DisplayName,
+				API:     a,
+				Version: v,
+			}); err != nil {
+				return err
+			}
+			for _, sp := range v.Specs {
+				if err := s.writeFile(path.Join(dir, "specs", sp.ID, path.Base(sp.Filename)), sp.Contents); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	log.FromContext(ctx).Infof("%d APIs rendered to %s", len(apis), s.root)
+	return nil
+}
+
+// writePage renders a page using the named template for its body.
+func (s *site) writePage(file, body string, p *page) error {
+	p.Root = strings.Repeat("../", strings.Count(file, "/"))
+	t, err := siteTemplates.Clone()
+	if err != nil {
+		return err
+	}
+	if _, err := t.New("body").Parse(`{{template "` + body + `" .}}`); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "page", p); err != nil {
+		return fmt.Errorf("failed to render %s: %s", file, err)
+	}
+	return s.writeFile(file, buf.Bytes())
+}
+
+func (s *site) writeFile(file string, contents []byte) error {
+	file = filepath.Join(s.root, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(file), os.FileMode(0755)); err != nil { // rwx,rx,rx
+		return err
+	}
+	return os.WriteFile(file, contents, os.FileMode(0644)) // rw,r,r
+}
+
+// searchEntry is an entry of the client-side search index.
+type searchEntry struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Kind  string `json:"kind"`
+	Text  string `json:"text,omitempty"`
+}
+
+// searchIndex returns entries for APIs, versions, operations and deployments.
+// URLs are relative to the root of the site.
+func searchIndex(apis []*api) []searchEntry {
+	var entries []searchEntry
+	for _, a := range apis {
+		apiURL := "apis/" + a.ID + "/index.html"
+		var labels []string
+		for k, v := range a.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		entries = append(entries, searchEntry{
+			Title: a.DisplayName,
+			URL:   apiURL,
+			Kind:  "API",
+			Text:  strings.TrimSpace(a.ID + " " + a.Description + " " + strings.Join(labels, " ")),
+		})
+		for _, v := range a.Versions {
+			versionURL := "apis/" + a.ID + "/versions/" + v.ID + "/index.html"
+			entries = append(entries, searchEntry{
+				Title: a.DisplayName + " " + v.DisplayName,
+				URL:   versionURL,
+				Kind:  "Version",
+				Text:  strings.TrimSpace(v.ID + " " + v.State + " " + v.Description),
+			})
+			for _, sp := range v.Specs {
+				if sp.Reference == nil {
+					continue
+				}
+				for _, o := range sp.Reference.Operations {
+					entries = append(entries, searchEntry{
+						Title: o.Method + " " + o.Path,
+						URL:   versionURL + "#" + anchor(sp.ID, o.Method, o.Path),
+						Kind:  "Operation",
+						Text:  strings.TrimSpace(a.DisplayName + " " + o.ID + " " + o.Summary),
+					})
+				}
+				for _, svc := range sp.Reference.Services {
+					for _, m := range svc.Methods {
+						entries = append(entries, searchEntry{
+							Title: svc.Name + "." + m.Name,
+							URL:   versionURL + "#" + anchor(sp.ID, svc.Name, m.Name),
+							Kind:  "Method",
+							Text:  strings.TrimSpace(a.DisplayName + " " + svc.Package + " " + m.Comment),
+						})
+					}
+				}
+			}
+		}
+		for _, d := range a.Deployments {
+			entries = append(entries, searchEntry{
+				Title: a.DisplayName + " " + d.DisplayName,
+				URL:   apiURL + "#deployment-" + d.ID,
+				Kind:  "Deployment",
+				Text:  strings.TrimSpace(d.EndpointURI + " " + d.Environment),
+			})
+		}
+	}
+	return entries
+}
+
+// anchor returns the ID of an element of a version page.
+func anchor(parts ...string) string {
+	return strings.Trim(invalidAnchorCharacters.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-"), "-")
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apigee/registry/pkg/application/apihub"
+	"github.com/apigee/registry/pkg/application/scoring"
+	"github.com/apigee/registry/pkg/application/style"
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMain(m *testing.M) {
+	grpctest.TestMain(m, registry.Config{})
+}
+
+const petstore = `openapi: 3.0.0
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: https://pets.example.com/v1
+paths:
+  /pets/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+    get:
+      operationId: getPet
+      summary: Get a pet
+      parameters:
+        - name: fields
+          in: query
+      responses:
+        "404":
+          description: Not found
+        "200":
+          description: A pet
+    delete:
+      summary: Delete a pet
+      responses:
+        "204":
+          description: Deleted
+`
+
+const library = `syntax = "proto3";
+
+package google.example.library.v1;
+
+// Manages shelves.
+service LibraryService {
+  // Gets a shelf.
+  rpc GetShelf(GetShelfRequest) returns (Shelf) {
+    option (google.api.http) = { get: "/v1/{name=shelves/*}" };
+  }
+  rpc ListShelves(ListShelvesRequest) returns (stream Shelf);
+}
+
+message Shelf {
+  string name = 1;
+  repeated string books = 2;
+  message Inner {
+    int32 ignored = 1;
+  }
+}
+`
+
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenAPIReference(t *testing.T) {
+	ref, err := referenceForSpec(mime.OpenAPIMimeType("", "3"), []byte(petstore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &reference{
+		Title:   "Petstore",
+		Version: "1.0.0",
+		Servers: []string{"https://pets.example.com/v1"},
+		Operations: []operation{
+			{
+				Method:     "GET",
+				Path:       "/pets/{id}",
+				ID:         "getPet",
+				Summary:    "Get a pet",
+				Parameters: []parameter{{Name: "id", In: "path", Required: true}, {Name: "fields", In: "query"}},
+				Responses:  []response{{Code: "200", Description: "A pet"}, {Code: "404", Description: "Not found"}},
+			},
+			{
+				Method:     "DELETE",
+				Path:       "/pets/{id}",
+				Summary:    "Delete a pet",
+				Parameters: []parameter{{Name: "id", In: "path", Required: true}},
+				Responses:  []response{{Code: "204", Description: "Deleted"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, ref); diff != "" {
+		t.Errorf("unexpected reference (-want +got):\n%s", diff)
+	}
+}
+
+func TestProtoReference(t *testing.T) {
+	contents := zipFiles(t, map[string]string{"google/example/library/v1/library.proto": library})
+	ref, err := referenceForSpec(mime.ProtobufMimeType("+zip"), contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &reference{
+		Title: "google.example.library.v1",
+		Services: []service{{
+			Name:    "LibraryService",
+			Package: "google.example.library.v1",
+			File:    "google/example/library/v1/library.proto",
+			Methods: []method{
+				{Name: "GetShelf", Request: "GetShelfRequest", Response: "Shelf", Comment: "Gets a shelf."},
+				{Name: "ListShelves", Request: "ListShelvesRequest", Response: "stream Shelf"},
+			},
+		}},
+		Messages: []message{{
+			Name:   "google.example.library.v1.Shelf",
+			Fields: []string{"string name", "repeated string books"},
+		}},
+	}
+	if diff := cmp.Diff(want, ref); diff != "" {
+		t.Errorf("unexpected reference (-want +got):\n%s", diff)
+	}
+}
+
+func marshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestSite(t *testing.T) {
+	ctx := context.Background()
+	api := "projects/site-test/locations/global/apis/petstore"
+	client, _ := grpctest.SetupRegistry(ctx, t, "site-test", []seeder.RegistryResource{
+		&rpc.Artifact{
+			Name:     "projects/site-test/locations/global/artifacts/apihub-taxonomies",
+			MimeType: mime.MimeTypeForKind("TaxonomyList"),
+			Contents: marshal(t, &apihub.TaxonomyList{
+				Taxonomies: []*apihub.TaxonomyList_Taxonomy{{
+					Id:          "apihub-team",
+					DisplayName: "Team",
+					Elements:    []*apihub.TaxonomyList_Taxonomy_Element{{Id: "pets", DisplayName: "Pet Team"}},
+				}},
+			}),
+		},
+		&rpc.Api{
+			Name:               api,
+			DisplayName:        "Pet Store",
+			Labels:             map[string]string{"apihub-team": "pets"},
+			RecommendedVersion: api + "/versions/v1",
+		},
+		&rpc.ApiSpec{
+			Name:     api + "/versions/v1/specs/openapi",
+			Filename: "openapi.yaml",
+			MimeType: mime.OpenAPIMimeType("", "3"),
+			Contents: []byte(petstore),
+		},
+		&rpc.ApiSpec{
+			Name:     "projects/site-test/locations/global/apis/library/versions/v1/specs/protos",
+			Filename: "protos.zip",
+			MimeType: mime.ProtobufMimeType("+zip"),
+			Contents: zipFiles(t, map[string]string{"library.proto": library}),
+		},
+		&rpc.ApiDeployment{
+			Name:            api + "/deployments/prod",
+			EndpointUri:     "https://pets.example.com/v1",
+			ApiSpecRevision: api + "/versions/v1/specs/openapi@-",
+		},
+		&rpc.Artifact{
+			Name:     api + "/versions/v1/specs/openapi/artifacts/lint-spectral",
+			MimeType: mime.MimeTypeForKind("Lint"),
+			Contents: marshal(t, &style.Lint{
+				Name: "spectral",
+				Files: []*style.LintFile{{
+					FilePath: "openapi.yaml",
+					Problems: []*style.LintProblem{{Message: "missing contact"}, {Message: "missing license"}},
+				}},
+			}),
+		},
+		&rpc.Artifact{
+			Name:     api + "/artifacts/score-quality",
+			MimeType: mime.MimeTypeForKind("Score"),
+			Contents: marshal(t, &scoring.Score{
+				Id:          "score-quality",
+				DisplayName: "Quality",
+				Severity:    scoring.Severity_OK,
+				Value:       &scoring.Score_PercentValue{PercentValue: &scoring.PercentValue{Value: 90}},
+			}),
+		},
+	})
+
+	root := t.TempDir()
+	s := &site{
+		client: client,
+		config: connection.Config{Project: "site-test"},
+		title:  "Developer Portal",
+		root:   root,
+	}
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run() returned error: %s", err)
+	}
+
+	pages := map[string][]string{
+		"index.html": {
+			"<title>Developer Portal</title>",
+			`data-labels="apihub-team=pets"`,
+			"<legend>Team</legend>",
+			`value="apihub-team=pets"> Pet Team (1)`,
+			`<a href="apis/library/index.html">library</a>`,
+			`Quality<b>90%</b>`,
+		},
+		"apis/petstore/index.html": {
+			`href="../../static/site.css"`,
+			`<span class="tag">recommended</span>`,
+			`<a href="https://pets.example.com/v1">`,
+			`<a href="versions/v1/index.html#openapi">v1/openapi</a>`,
+		},
+		"apis/petstore/versions/v1/index.html": {
+			`<a href="specs/openapi/openapi.yaml">`,
+			`id="openapi-get-pets-id"`,
+			"<code>/pets/{id}</code>",
+			`spectral<b>2 problems</b>`,
+		},
+		"apis/library/versions/v1/index.html": {
+			"service <code>google.example.library.v1.LibraryService</code>",
+			`id="protos-libraryservice-getshelf"`,
+		},
+	}
+	for file, wants := range pages {
+		b, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(file)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("%s doesn't contain %q", file, want)
+			}
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(root, "apis", "petstore", "versions", "v1", "specs", "openapi", "openapi.yaml"))
+	if err != nil || string(b) != petstore {
+		t.Errorf("spec file wasn't written: %v", err)
+	}
+
+	b, err = os.ReadFile(filepath.Join(root, "search-index.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []searchEntry
+	if err := json.Unmarshal(bytes.TrimSuffix(bytes.TrimPrefix(b, []byte("var searchIndex = ")), []byte(";\n")), &entries); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]string{}
+	for _, e := range entries {
+		found[e.Kind+" "+e.Title] = e.URL
+	}
+	for entry, url := range map[string]string{
+		"API Pet Store":                  "apis/petstore/index.html",
+		"Operation GET /pets/{id}":       "apis/petstore/versions/v1/index.html#openapi-get-pets-id",
+		"Method LibraryService.GetShelf": "apis/library/versions/v1/index.html#protos-libraryservice-getshelf",
+		"Deployment Pet Store prod":      "apis/petstore/index.html#deployment-prod",
+	} {
+		if found[entry] != url {
+			t.Errorf("search index entry %q has URL %q, want %q", entry, found[entry], url)
+		}
+	}
+}
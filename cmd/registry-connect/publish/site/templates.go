@@ -0,0 +1,277 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package site
+
+import (
+	"html/template"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var invalidAnchorCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// siteTemplates render the pages of the site. Each page is rendered with
+// the "page" template and a "body" template that includes the page type.
+var siteTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"anchor":   anchor,
+	"base":     path.Base,
+	"labelSet": labelSet,
+}).Parse(`
+{{define "page"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Root}}static/site.css">
+</head>
+<body data-root="{{.Root}}">
+<header>
+<a class="site" href="{{.Root}}index.html">{{.Site}}</a>
+<input id="search" type="search" placeholder="Search APIs, operations and deployments" autocomplete="off">
+<ul id="results"></ul>
+</header>
+<main>
+{{template "body" .}}
+</main>
+<script src="{{.Root}}search-index.js"></script>
+<script src="{{.Root}}static/site.js"></script>
+</body>
+</html>
+{{end}}
+
+{{define "badges"}}{{if .}}<div class="badges">{{range .}}<span class="badge {{.Severity}}">{{if .URI}}<a href="{{.URI}}">{{.Label}}</a>{{else}}{{.Label}}{{end}}<b>{{.Value}}</b></span>{{end}}</div>{{end}}{{end}}
+
+{{define "labels"}}{{if .}}<div class="labels">{{range $k, $v := .}}<span class="label">{{$k}}: {{$v}}</span>{{end}}</div>{{end}}{{end}}
+
+{{define "catalog"}}
+<h1>{{.Title}}</h1>
+<div class="catalog">
+<aside>
+{{range .Facets}}
+<fieldset>
+<legend>{{.DisplayName}}</legend>
+{{$key := .Key}}{{range .Values}}<label><input type="checkbox" class="facet" name="{{$key}}" value="{{$key}}={{.Value}}"> {{.DisplayName}} ({{.Count}})</label>
+{{end}}
+</fieldset>
+{{end}}
+</aside>
+<section>
+{{range .APIs}}
+<article class="card" data-labels="{{labelSet .Labels}}">
+<h2><a href="apis/{{.ID}}/index.html">{{.DisplayName}}</a></h2>
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{template "labels" .Labels}}
+{{template "badges" .Badges}}
+<p class="meta">{{len .Versions}} versions, {{len .Deployments}} deployments</p>
+</article>
+{{else}}<p>No APIs found.</p>
+{{end}}
+</section>
+</div>
+{{end}}
+
+{{define "api"}}
+{{$api := .API}}
+<p class="breadcrumbs"><a href="{{.Root}}index.html">APIs</a></p>
+<h1>{{.API.DisplayName}}</h1>
+{{with .API.Description}}<p>{{.}}</p>{{end}}
+{{template "labels" .API.Labels}}
+{{template "badges" .API.Badges}}
+<h2>Versions</h2>
+<table>
+<tr><th>Version</th><th>State</th><th>Specs</th><th>Badges</th></tr>
+{{range .API.Versions}}
+<tr>
+<td><a href="versions/{{.ID}}/index.html">{{.DisplayName}}</a>{{if eq .ID $api.Recommended}} <span class="tag">recommended</span>{{end}}</td>
+<td>{{.State}}</td>
+<td>{{$version := .ID}}{{range .Specs}}<a href="versions/{{$version}}/index.html#{{anchor .ID}}">{{.ID}}</a> {{end}}</td>
+<td>{{template "badges" .Badges}}</td>
+</tr>
+{{end}}
+</table>
+{{if .API.Deployments}}
+<h2>Deployments</h2>
+<table>
+<tr><th>Deployment</th><th>Endpoint</th><th>Environment</th><th>Spec</th><th>Badges</th></tr>
+{{range .API.Deployments}}
+<tr id="deployment-{{.ID}}">
+<td>{{.DisplayName}}{{with .Description}}<br><small>{{.}}</small>{{end}}</td>
+<td>{{with .EndpointURI}}<a href="{{.}}">{{.}}</a>{{end}}{{with .ExternalChannelURI}}<br><a href="{{.}}">Developer portal</a>{{end}}</td>
+<td>{{.Environment}}</td>
+<td>{{if .Spec}}<a href="versions/{{.Version}}/index.html#{{anchor .Spec}}">{{.Version}}/{{.Spec}}</a>{{end}}</td>
+<td>{{template "badges" .Badges}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+
+{{define "version"}}
+<p class="breadcrumbs"><a href="{{.Root}}index.html">APIs</a> / <a href="../../index.html">{{.API.DisplayName}}</a></p>
+<h1>{{.Title}}</h1>
+{{with .Version.State}}<p><span class="tag">{{.}}</span></p>{{end}}
+{{with .Version.Description}}<p>{{.}}</p>{{end}}
+{{template "badges" .Version.Badges}}
+{{range .Version.Specs}}
+{{$spec := .ID}}
+<section class="spec" id="{{anchor .ID}}">
+<h2>{{.ID}}{{if eq .ID $.Version.PrimarySpec}} <span class="tag">primary</span>{{end}}</h2>
+<p class="meta">{{.MimeType}} · <a href="specs/{{.ID}}/{{base .Filename}}">Download {{base .Filename}}</a>{{with .SourceURI}} · <a href="{{.}}">Source</a>{{end}}</p>
+{{template "badges" .Badges}}
+{{with .Reference}}
+{{with .Title}}<h3>{{.}}</h3>{{end}}
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{if .Servers}}<ul class="servers">{{range .Servers}}<li><code>{{.}}</code></li>{{end}}</ul>{{end}}
+{{range .Operations}}
+<div class="operation" id="{{anchor $spec .Method .Path}}">
+<h4><span class="method {{.Method}}">{{.Method}}</span> <code>{{.Path}}</code></h4>
+{{with .Summary}}<p>{{.}}</p>{{end}}
+{{with .Description}}<p class="description">{{.}}</p>{{end}}
+{{if .Parameters}}
+<table>
+<tr><th>Parameter</th><th>In</th><th>Required</th><th>Description</th></tr>
+{{range .Parameters}}<tr><td><code>{{.Name}}</code></td><td>{{.In}}</td><td>{{if .Required}}yes{{end}}</td><td>{{.Description}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{if .Responses}}
+<table>
+<tr><th>Response</th><th>Description</th></tr>
+{{range .Responses}}<tr><td><code>{{.Code}}</code></td><td>{{.Description}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</div>
+{{end}}
+{{range .Services}}
+{{$service := .Name}}
+<h4>service <code>{{.Package}}.{{.Name}}</code></h4>
+<table>
+<tr><th>Method</th><th>Request</th><th>Response</th><th>Description</th></tr>
+{{range .Methods}}<tr id="{{anchor $spec $service .Name}}"><td><code>{{.Name}}</code></td><td><code>{{.Request}}</code></td><td><code>{{.Response}}</code></td><td>{{.Comment}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{if .Messages}}
+<h4>Messages</h4>
+<dl class="messages">
+{{range .Messages}}<dt><code>{{.Name}}</code></dt><dd>{{range .Fields}}<code>{{.}}</code> {{end}}</dd>
+{{end}}
+</dl>
+{{end}}
+{{end}}
+</section>
+{{else}}<p>This version has no specs.</p>
+{{end}}
+{{end}}
+`))
+
+// labelSet returns labels as a space-separated list of key=value pairs.
+func labelSet(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+const siteCSS = `body { font-family: sans-serif; margin: 0; color: #202124; }
+header { background: #1a73e8; padding: 0.75em 2em; display: flex; align-items: center; gap: 2em; position: relative; }
+header a.site { color: #fff; font-size: 1.25em; font-weight: bold; text-decoration: none; }
+#search { flex: 1; max-width: 40em; padding: 0.4em; font-size: 1em; }
+#results { position: absolute; top: 100%; left: 2em; right: 2em; margin: 0; padding: 0; list-style: none; background: #fff; box-shadow: 0 2px 6px rgba(0,0,0,.3); z-index: 1; }
+#results li { padding: 0.4em 1em; }
+#results li small { color: #5f6368; margin-left: 1em; }
+main { padding: 1em 2em; }
+.catalog { display: flex; gap: 2em; }
+.catalog aside { min-width: 14em; }
+.catalog section { flex: 1; }
+fieldset { border: 1px solid #dadce0; margin-bottom: 1em; }
+fieldset label { display: block; }
+.card { border: 1px solid #dadce0; border-radius: 4px; padding: 0 1em; margin-bottom: 1em; }
+.card.hidden { display: none; }
+.meta, .breadcrumbs { color: #5f6368; }
+.label, .tag { display: inline-block; background: #e8eaed; border-radius: 4px; padding: 0 0.4em; margin: 0 0.3em 0.3em 0; font-size: 0.85em; }
+.badge { display: inline-block; border-radius: 4px; overflow: hidden; margin: 0 0.3em 0.3em 0; font-size: 0.85em; background: #5f6368; color: #fff; padding-left: 0.4em; }
+.badge a { color: #fff; }
+.badge b { display: inline-block; margin-left: 0.4em; padding: 0 0.4em; background: #9aa0a6; }
+.badge.ok b { background: #188038; }
+.badge.warning b { background: #e37400; }
+.badge.alert b { background: #d93025; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #dadce0; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f1f3f4; }
+.spec { border-top: 1px solid #dadce0; margin-top: 2em; }
+.operation { margin: 1em 0 2em; }
+.method { display: inline-block; min-width: 4em; color: #fff; background: #5f6368; border-radius: 4px; text-align: center; font-size: 0.85em; }
+.method.GET { background: #1a73e8; }
+.method.POST { background: #188038; }
+.method.PUT, .method.PATCH { background: #e37400; }
+.method.DELETE { background: #d93025; }
+`
+
+// siteJS filters the catalog by the selected facets and searches the index.
+// Facets with the same label are alternatives, and different labels must all match.
+const siteJS = `(function() {
+  var root = document.body.getAttribute("data-root") || "";
+
+  var facets = document.querySelectorAll("input.facet");
+  function filter() {
+    var selected = {};
+    facets.forEach(function(f) {
+      if (f.checked) {
+        (selected[f.name] = selected[f.name] || []).push(f.value);
+      }
+    });
+    document.querySelectorAll(".card").forEach(function(card) {
+      var labels = (card.getAttribute("data-labels") || "").split(" ");
+      var visible = Object.keys(selected).every(function(key) {
+        return selected[key].some(function(value) { return labels.indexOf(value) >= 0; });
+      });
+      card.classList.toggle("hidden", !visible);
+    });
+  }
+  facets.forEach(function(f) { f.addEventListener("change", filter); });
+
+  var search = document.getElementById("search");
+  var results = document.getElementById("results");
+  search.addEventListener("input", function() {
+    results.innerHTML = "";
+    var terms = search.value.toLowerCase().split(/\s+/).filter(Boolean);
+    if (!terms.length || typeof searchIndex === "undefined") {
+      return;
+    }
+    var matches = searchIndex.filter(function(e) {
+      var text = (e.title + " " + (e.text || "")).toLowerCase();
+      return terms.every(function(t) { return text.indexOf(t) >= 0; });
+    });
+    matches.slice(0, 20).forEach(function(e) {
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = root + e.url;
+      a.textContent = e.title;
+      var kind = document.createElement("small");
+      kind.textContent = e.kind;
+      li.appendChild(a);
+      li.appendChild(kind);
+      results.appendChild(li);
+    });
+  });
+})();
+`
@@ -0,0 +1,310 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apigee/registry-experimental/cmd/registry-graphql/graphql"
+	"github.com/apigee/registry/pkg/log"
+	"gopkg.in/yaml.v2"
+)
+
+// ServerConfig is the top-level configuration structure.
+type ServerConfig struct {
+	// Address to listen on, such as ":8088" or "localhost:8088".
+	Address string    `yaml:"address"`
+	TLS     TLSConfig `yaml:"tls"`
+	// Origins that are allowed to make CORS requests and open WebSocket
+	// connections. "*" allows all origins.
+	CORSAllowedOrigins []string      `yaml:"cors_allowed_origins"`
+	Auth               AuthConfig    `yaml:"auth"`
+	Limits             LimitsConfig  `yaml:"limits"`
+	Logging            LoggingConfig `yaml:"logging"`
+	Pubsub             PubsubConfig  `yaml:"pubsub"`
+	Search             SearchConfig  `yaml:"search"`
+	Cache              CacheConfig   `yaml:"cache"`
+	// Address to serve Prometheus metrics on. If unset, metrics aren't served.
+	MetricsAddress string `yaml:"metrics_address"`
+	// Time to wait for requests to finish when the server is stopped.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// TLSConfig holds the certificate and key files for serving HTTPS.
+// If they are unset, the server uses HTTP.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// AuthConfig holds the handling of caller credentials.
+type AuthConfig struct {
+	// Forward the bearer tokens of callers to the registry, so that their
+	// permissions apply. Callers without tokens use the server's registry
+	// config, but can't make mutations. Otherwise the server's registry config
+	// is used for every caller.
+	Forward bool `yaml:"forward"`
+	// Reject requests without bearer tokens.
+	Require bool `yaml:"require"`
+}
+
+// LimitsConfig holds limits on the cost of queries. Zero disables a limit.
+type LimitsConfig struct {
+	MaxDepth      int `yaml:"max_depth"`
+	MaxComplexity int `yaml:"max_complexity"`
+}
+
+// LoggingConfig holds logging configuration.
+type LoggingConfig struct {
+	// Level of logging to print to standard error.
+	// Values: [ debug, info, warn, error, fatal ]
+	Level string `yaml:"level"`
+	// Format of log entries.
+	// Options: [ json, text ]
+	Format string `yaml:"format"`
+	// Log each HTTP request.
+	Requests bool `yaml:"requests"`
+}
+
+// PubsubConfig holds the Pub/Sub subscription that receives registry
+// notifications for GraphQL subscriptions.
+type PubsubConfig struct {
+	// Project of the registry's notification topic. If unset, GraphQL
+	// subscriptions are disabled.
+	Project string `yaml:"project"`
+	// Subscription of this server, which must not be shared with other servers.
+	// Defaults to "registry-graphql-" followed by the host name.
+	Subscription string `yaml:"subscription"`
+}
+
+var invalidSubscriptionCharacters = regexp.MustCompile(`[^A-Za-z0-9_.~+%-]+`)
+
+// defaultSubscription returns a subscription name for this server. Pub/Sub
+// distributes the messages of a subscription among its subscribers, so each
+// server needs its own, and host names distinguish the replicas of a server.
+func defaultSubscription() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "registry-graphql"
+	}
+	return "registry-graphql-" + strings.Trim(invalidSubscriptionCharacters.ReplaceAllString(host, "-"), "-")
+}
+
+// SearchConfig holds the experimental Search service that search queries
+// are sent to.
+type SearchConfig struct {
+	// Address of the Search service. If unset, searches use registry filters.
+	Address string `yaml:"address"`
+	// Connect to the Search service without TLS.
+	Insecure bool `yaml:"insecure"`
+}
+
+// CacheConfig holds the caches of query results and persisted queries.
+type CacheConfig struct {
+	// Cached responses are only invalidated by other clients' changes if
+	// notifications are received from Pub/Sub.
+	Responses        CacheSettings `yaml:"responses"`
+	PersistedQueries CacheSettings `yaml:"persisted_queries"`
+}
+
+// CacheSettings holds the settings of one cache.
+type CacheSettings struct {
+	Enabled bool `yaml:"enabled"`
+	// Time that entries are kept. Zero keeps entries until they are evicted.
+	TTL time.Duration `yaml:"ttl"`
+	// Maximum number of entries. Zero allows any number.
+	MaxEntries int `yaml:"max_entries"`
+}
+
+// default configuration
+func defaultConfig() ServerConfig {
+	return ServerConfig{
+		Address: ":8088",
+		Auth: AuthConfig{
+			Forward: true,
+		},
+		Limits: LimitsConfig{
+			MaxDepth:      graphql.MaxDepth,
+			MaxComplexity: graphql.MaxComplexity,
+		},
+		Logging: LoggingConfig{
+			Level:    "info",
+			Format:   "text",
+			Requests: true,
+		},
+		Pubsub: PubsubConfig{
+			Subscription: defaultSubscription(),
+		},
+		Cache: CacheConfig{
+			Responses: CacheSettings{
+				Enabled:    false,
+				TTL:        time.Minute,
+				MaxEntries: 1000,
+			},
+			PersistedQueries: CacheSettings{
+				Enabled:    true,
+				TTL:        24 * time.Hour,
+				MaxEntries: 10000,
+			},
+		},
+		ShutdownTimeout: 10 * time.Second,
+	}
+}
+
+// loadConfig returns the configuration from a file, if one is named with
+// the -c flag, with the values of other flags that were set.
+func loadConfig(args []string) (ServerConfig, error) {
+	config := defaultConfig()
+	fs := flag.NewFlagSet("registry-graphql", flag.ContinueOnError)
+	configPath := fs.String("c", "", "the server configuration file to load")
+	address := fs.String("address", config.Address, "address to listen on")
+	certFile := fs.String("tls-cert", "", "certificate file for HTTPS")
+	keyFile := fs.String("tls-key", "", "key file for HTTPS")
+	origins := fs.String("cors-allow-origin", "", "comma-separated origins that are allowed to make CORS requests ('*' allows all)")
+	maxDepth := fs.Int("max-depth", config.Limits.MaxDepth, "maximum depth of queries (0 for no limit)")
+	maxComplexity := fs.Int("max-complexity", config.Limits.MaxComplexity, "maximum complexity of queries (0 for no limit)")
+	pubsubProject := fs.String("pubsub-project", "", "project of the Pub/Sub topic of registry notifications (enables subscriptions)")
+	pubsubSubscription := fs.String("pubsub-subscription", config.Pubsub.Subscription, "Pub/Sub subscription of this server")
+	searchAddress := fs.String("search-address", "", "address of the Search service (searches use registry filters without one)")
+	searchInsecure := fs.Bool("search-insecure", false, "connect to the Search service without TLS")
+	cacheTTL := fs.Duration("cache-ttl", config.Cache.Responses.TTL, "time to cache query results (enables the response cache)")
+	metricsAddress := fs.String("metrics-address", "", "address to serve Prometheus metrics on")
+	if err := fs.Parse(args); err != nil {
+		return config, err
+	}
+
+	if *configPath != "" {
+		raw, err := os.ReadFile(*configPath)
+		if err != nil {
+			return config, err
+		}
+		// Expand environment variables before unmarshaling.
+		expanded := []byte(os.ExpandEnv(string(raw)))
+		if err := yaml.UnmarshalStrict(expanded, &config); err != nil {
+			return config, err
+		}
+	}
+
+	// Flags that were set override the configuration file.
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "address":
+			config.Address = *address
+		case "tls-cert":
+			config.TLS.CertFile = *certFile
+		case "tls-key":
+			config.TLS.KeyFile = *keyFile
+		case "cors-allow-origin":
+			config.CORSAllowedOrigins = strings.Split(*origins, ",")
+		case "max-depth":
+			config.Limits.MaxDepth = *maxDepth
+		case "max-complexity":
+			config.Limits.MaxComplexity = *maxComplexity
+		case "pubsub-project":
+			config.Pubsub.Project = *pubsubProject
+		case "pubsub-subscription":
+			config.Pubsub.Subscription = *pubsubSubscription
+		case "search-address":
+			config.Search.Address = *searchAddress
+		case "search-insecure":
+			config.Search.Insecure = *searchInsecure
+		case "cache-ttl":
+			config.Cache.Responses.Enabled = true
+			config.Cache.Responses.TTL = *cacheTTL
+		case "metrics-address":
+			config.MetricsAddress = *metricsAddress
+		}
+	})
+	return config, validateConfig(config)
+}
+
+func validateConfig(config ServerConfig) error {
+	if config.Address == "" {
+		return fmt.Errorf("invalid address %q: must not be empty", config.Address)
+	}
+
+	if (config.TLS.CertFile == "") != (config.TLS.KeyFile == "") {
+		return fmt.Errorf("invalid tls: cert_file and key_file must be set together")
+	}
+
+	if config.Auth.Require && !config.Auth.Forward {
+		return fmt.Errorf("invalid auth: credentials can't be required unless they are forwarded")
+	}
+
+	if config.Limits.MaxDepth < 0 || config.Limits.MaxComplexity < 0 {
+		return fmt.Errorf("invalid limits: must be non-negative")
+	}
+
+	switch level := config.Logging.Level; level {
+	case "fatal", "error", "warn", "info", "debug":
+	default:
+		return fmt.Errorf("invalid logging.level %q: must be one of [fatal, error, warn, info, debug]", level)
+	}
+
+	switch format := config.Logging.Format; format {
+	case "json", "text":
+	default:
+		return fmt.Errorf("invalid logging format %q: must be one of [json, text]", format)
+	}
+
+	if sub := config.Pubsub.Subscription; config.Pubsub.Project != "" && sub == "" {
+		return fmt.Errorf("invalid pubsub.subscription %q: must be set with pubsub.project", sub)
+	}
+
+	if config.Search.Insecure && config.Search.Address == "" {
+		return fmt.Errorf("invalid search: insecure must be set with address")
+	}
+
+	for name, c := range map[string]CacheSettings{
+		"responses":         config.Cache.Responses,
+		"persisted_queries": config.Cache.PersistedQueries,
+	} {
+		if c.TTL < 0 || c.MaxEntries < 0 {
+			return fmt.Errorf("invalid cache.%s: ttl and max_entries must be non-negative", name)
+		}
+	}
+
+	return nil
+}
+
+func loggerOptions(conf LoggingConfig) []log.Option {
+	opts := make([]log.Option, 0, 2)
+	switch conf.Level {
+	case "debug":
+		opts = append(opts, log.DebugLevel)
+	case "info":
+		opts = append(opts, log.InfoLevel)
+	case "warn":
+		opts = append(opts, log.WarnLevel)
+	case "error":
+		opts = append(opts, log.ErrorLevel)
+	case "fatal":
+		opts = append(opts, log.FatalLevel)
+	}
+
+	switch conf.Format {
+	case "json":
+		opts = append(opts, log.JSONFormat(os.Stderr))
+	case "text":
+		opts = append(opts, log.TextFormat(os.Stderr))
+	}
+
+	return opts
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("GRAPHQL_CERT", "cert.pem")
+	err := os.WriteFile(path, []byte(`
+address: localhost:9000
+tls:
+  cert_file: ${GRAPHQL_CERT}
+  key_file: key.pem
+cors_allowed_origins:
+  - https://example.com
+auth:
+  require: true
+logging:
+  format: json
+shutdown_timeout: 30s
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadConfig([]string{"-c", path, "-address", ":8080", "-cors-allow-origin", "https://a.com,https://b.com"})
+	if err != nil {
+		t.Fatalf("loadConfig() returned error: %s", err)
+	}
+	want := defaultConfig()
+	want.Address = ":8080"
+	want.TLS = TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}
+	want.CORSAllowedOrigins = []string{"https://a.com", "https://b.com"}
+	want.Auth.Require = true
+	want.Logging.Format = "json"
+	want.ShutdownTimeout = 30 * time.Second
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("loadConfig() returned unexpected config (-want +got):\n%s", diff)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config string
+	}{
+		{"unknown field", "port: 8088"},
+		{"cert without key", "tls: {cert_file: cert.pem}"},
+		{"required credentials that aren't forwarded", "auth: {forward: false, require: true}"},
+		{"negative limit", "limits: {max_depth: -1}"},
+		{"unknown logging level", "logging: {level: verbose}"},
+		{"insecure search without an address", "search: {insecure: true}"},
+		{"negative cache ttl", "cache: {responses: {ttl: -1m}}"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadConfig([]string{"-c", path}); err == nil {
+				t.Errorf("loadConfig() succeeded with %q, expected error", test.config)
+			}
+		})
+	}
+}
+
+func TestDefaultSubscription(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip("host name is unavailable")
+	}
+	got := defaultConfig().Pubsub.Subscription
+	if !strings.HasPrefix(got, "registry-graphql-") || invalidSubscriptionCharacters.MatchString(got) {
+		t.Errorf("default subscription for host %q is %q", host, got)
+	}
+}
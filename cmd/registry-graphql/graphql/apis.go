@@ -15,9 +15,9 @@
 package graphql
 
 import (
+	"context"
 	"errors"
 
-	"github.com/apigee/registry/pkg/connection"
 	"github.com/apigee/registry/rpc"
 	"github.com/graphql-go/graphql"
 )
@@ -35,11 +35,31 @@ var apiType = graphql.NewObject(
 			"description": &graphql.Field{
 				Type: graphql.String,
 			},
+			"availability": &graphql.Field{
+				Type: graphql.String,
+			},
+			"recommended_version": &graphql.Field{
+				Type: graphql.String,
+			},
+			"recommended_deployment": &graphql.Field{
+				Type: graphql.String,
+			},
+			"labels": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
+			"annotations": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
 			"versions": &graphql.Field{
 				Type:    connectionType(versionType),
 				Args:    argumentsForCollectionQuery,
 				Resolve: resolveVersions,
 			},
+			"deployments": &graphql.Field{
+				Type:    connectionType(deploymentType),
+				Args:    argumentsForCollectionQuery,
+				Resolve: resolveDeployments,
+			},
 			"artifacts": &graphql.Field{
 				Type:    connectionType(artifactType),
 				Args:    argumentsForCollectionQuery,
@@ -57,70 +77,64 @@ var apiType = graphql.NewObject(
 
 func representationForAPI(api *rpc.Api) map[string]interface{} {
 	return map[string]interface{}{
-		"id":                  api.Name,
-		"display_name":        api.DisplayName,
-		"description":         api.Description,
-		"availability":        api.Availability,
-		"recommended_version": api.RecommendedVersion,
-		"created":             representationForTimestamp(api.CreateTime),
-		"updated":             representationForTimestamp(api.UpdateTime),
+		"id":                     api.Name,
+		"display_name":           api.DisplayName,
+		"description":            api.Description,
+		"availability":           api.Availability,
+		"recommended_version":    api.RecommendedVersion,
+		"recommended_deployment": api.RecommendedDeployment,
+		"labels":                 representationForMap(api.Labels),
+		"annotations":            representationForMap(api.Annotations),
+		"created":                representationForTimestamp(api.CreateTime),
+		"updated":                representationForTimestamp(api.UpdateTime),
 	}
 }
 
-func resolveAPIs(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewRegistryClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-	req := &rpc.ListApisRequest{
-		Parent: getParentFromParams(p) + "/locations/global",
-	}
-	filter, isFound := p.Args["filter"].(string)
-	if isFound {
-		req.Filter = filter
-	}
-	pageToken, isFound := p.Args["after"].(string)
-	if isFound {
-		req.PageToken = pageToken
-	}
-	pageSize, isFound := p.Args["first"].(int)
-	if isFound {
-		req.PageSize = int32(pageSize)
-	} else {
-		pageSize = 50
-	}
-	var response *rpc.ListApisResponse
-	edges := []map[string]interface{}{}
-	for len(edges) < pageSize {
-		response, _ = c.GrpcClient().ListApis(ctx, req)
-		for _, api := range response.GetApis() {
-			edges = append(edges, representationForEdge(representationForAPI(api)))
+var apiCollection = &collection{
+	kind: "apis",
+	get: func(ctx context.Context, name string) (map[string]interface{}, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, err
 		}
-		req.PageToken = response.GetNextPageToken()
-		if req.PageToken == "" {
-			break
+		api, err := c.GetApi(ctx, &rpc.GetApiRequest{
+			Name: name,
+		})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return connectionForEdgesAndEndCursor(edges, response.GetNextPageToken()), nil
+		return representationForAPI(api), nil
+	},
+	list: func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := c.GrpcClient().ListApis(ctx, &rpc.ListApisRequest{
+			Parent:    req.Parent,
+			Filter:    req.Filter,
+			PageToken: req.PageToken,
+			PageSize:  req.PageSize,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		var items []map[string]interface{}
+		for _, api := range response.GetApis() {
+			items = append(items, representationForAPI(api))
+		}
+		return items, response.GetNextPageToken(), nil
+	},
+}
+
+func resolveAPIs(p graphql.ResolveParams) (interface{}, error) {
+	return loaderFor(p.Context).list(p.Context, apiCollection, getParentFromParams(p)+"/locations/global", p.Args), nil
 }
 
 func resolveAPI(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewRegistryClient(ctx)
-	if err != nil {
-		return nil, err
-	}
 	name, isFound := p.Args["id"].(string)
 	if !isFound {
 		return nil, errors.New("missing id field")
 	}
-	req := &rpc.GetApiRequest{
-		Name: name,
-	}
-	api, err := c.GetApi(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return representationForAPI(api), err
+	return loaderFor(p.Context).get(p.Context, apiCollection, name), nil
 }
@@ -15,9 +15,9 @@
 package graphql
 
 import (
+	"context"
 	"errors"
 
-	"github.com/apigee/registry/pkg/connection"
 	"github.com/apigee/registry/rpc"
 	"github.com/graphql-go/graphql"
 )
@@ -29,6 +29,30 @@ var artifactType = graphql.NewObject(
 			"id": &graphql.Field{
 				Type: graphql.String,
 			},
+			"mime_type": &graphql.Field{
+				Type: graphql.String,
+			},
+			"size_bytes": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"hash": &graphql.Field{
+				Type: graphql.String,
+			},
+			"labels": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
+			"annotations": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
+			"contents": &graphql.Field{
+				Type:        jsonType,
+				Description: "Contents decoded with the message type named by their MIME type, or as JSON, YAML or text.",
+				Resolve:     resolveContents(artifactContentsCollection, valueContents),
+			},
+			"contents_base64": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: resolveContents(artifactContentsCollection, base64Contents),
+			},
 			"created": &graphql.Field{
 				Type: timestampType,
 			},
@@ -41,62 +65,62 @@ var artifactType = graphql.NewObject(
 
 func representationForArtifact(artifact *rpc.Artifact) map[string]interface{} {
 	return map[string]interface{}{
-		"id":      artifact.Name,
-		"created": representationForTimestamp(artifact.CreateTime),
-		"updated": representationForTimestamp(artifact.UpdateTime),
+		"id":          artifact.Name,
+		"mime_type":   artifact.MimeType,
+		"size_bytes":  artifact.SizeBytes,
+		"hash":        artifact.Hash,
+		"labels":      representationForMap(artifact.Labels),
+		"annotations": representationForMap(artifact.Annotations),
+		"created":     representationForTimestamp(artifact.CreateTime),
+		"updated":     representationForTimestamp(artifact.UpdateTime),
 	}
 }
 
-func resolveArtifacts(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewRegistryClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-	req := &rpc.ListArtifactsRequest{
-		Parent: getParentFromParams(p),
-	}
-	pageToken, isFound := p.Args["after"].(string)
-	if isFound {
-		req.PageToken = pageToken
-	}
-	pageSize, isFound := p.Args["first"].(int)
-	if isFound {
-		req.PageSize = int32(pageSize)
-	} else {
-		pageSize = 50
-	}
-	var response *rpc.ListArtifactsResponse
-	edges := []map[string]interface{}{}
-	for len(edges) < pageSize {
-		response, _ = c.GrpcClient().ListArtifacts(ctx, req)
-		for _, artifact := range response.GetArtifacts() {
-			edges = append(edges, representationForEdge(representationForArtifact(artifact)))
+var artifactCollection = &collection{
+	kind: "artifacts",
+	get: func(ctx context.Context, name string) (map[string]interface{}, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, err
 		}
-		req.PageToken = response.GetNextPageToken()
-		if req.PageToken == "" {
-			break
+		artifact, err := c.GetArtifact(ctx, &rpc.GetArtifactRequest{
+			Name: name,
+		})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return connectionForEdgesAndEndCursor(edges, response.GetNextPageToken()), nil
+		return representationForArtifact(artifact), nil
+	},
+	list: func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := c.GrpcClient().ListArtifacts(ctx, &rpc.ListArtifactsRequest{
+			Parent:    req.Parent,
+			Filter:    req.Filter,
+			PageToken: req.PageToken,
+			PageSize:  req.PageSize,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		var items []map[string]interface{}
+		for _, artifact := range response.GetArtifacts() {
+			items = append(items, representationForArtifact(artifact))
+		}
+		return items, response.GetNextPageToken(), nil
+	},
+}
+
+func resolveArtifacts(p graphql.ResolveParams) (interface{}, error) {
+	return loaderFor(p.Context).list(p.Context, artifactCollection, getParentFromParams(p), p.Args), nil
 }
 
 func resolveArtifact(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewRegistryClient(ctx)
-	if err != nil {
-		return nil, err
-	}
 	name, isFound := p.Args["id"].(string)
 	if !isFound {
 		return nil, errors.New("missing id field")
 	}
-	req := &rpc.GetArtifactRequest{
-		Name: name,
-	}
-	artifact, err := c.GetArtifact(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return representationForArtifact(artifact), err
+	return loaderFor(p.Context).get(p.Context, artifactCollection, name), nil
 }
@@ -0,0 +1,154 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/apigee/registry/gapic"
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type bearerTokenKey struct{}
+
+// WithBearerToken returns a context that carries the bearer token of a caller.
+// Registry requests made while resolving a GraphQL request with this context
+// send that token instead of the credentials of the active configuration.
+func WithBearerToken(ctx context.Context, token string) context.Context {
+	ctx = context.WithValue(ctx, bearerTokenKey{}, token)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// ForwardCredentials controls whether the bearer tokens of callers are sent
+// to the registry. Otherwise every request uses the active configuration.
+// When credentials are forwarded, requests without bearer tokens use the
+// active configuration too, but can't make mutations.
+var ForwardCredentials = true
+
+// RequireCredentials controls whether requests without bearer tokens fail.
+var RequireCredentials = false
+
+// WithAuthorization returns a context for a request with the value of an
+// Authorization header. Its bearer token is used if credentials are
+// forwarded, and it fails if credentials are required and it has none.
+func WithAuthorization(ctx context.Context, authorization string) (context.Context, error) {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	if token == authorization {
+		token = ""
+	}
+	if token == "" && RequireCredentials {
+		return ctx, status.Error(codes.Unauthenticated, "a bearer token is required")
+	}
+	if token != "" && ForwardCredentials {
+		ctx = WithBearerToken(ctx, token)
+	}
+	return ctx, nil
+}
+
+func hasBearerToken(ctx context.Context) bool {
+	token, _ := ctx.Value(bearerTokenKey{}).(string)
+	return token != ""
+}
+
+// authenticated wraps a mutation resolver so that, when credentials are
+// forwarded, callers without bearer tokens can't make changes with the
+// credentials of the active configuration.
+func authenticated(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if ForwardCredentials && !hasBearerToken(p.Context) {
+			return nil, status.Error(codes.Unauthenticated, "mutations require a bearer token")
+		}
+		return resolve(p)
+	}
+}
+
+// clients are shared by all requests. Requests with bearer tokens use
+// clients without credentials, which send the token from the context.
+var clients struct {
+	sync.Mutex
+	registry            connection.RegistryClient
+	admin               connection.AdminClient
+	passthroughRegistry connection.RegistryClient
+	passthroughAdmin    connection.AdminClient
+}
+
+// passthroughOptions returns options for clients that send no credentials
+// of their own. Insecure connections never send credentials.
+func passthroughOptions(config connection.Config) []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(config.Address),
+		option.WithoutAuthentication(),
+	}
+}
+
+func registryClient(ctx context.Context) (connection.RegistryClient, error) {
+	clients.Lock()
+	defer clients.Unlock()
+	passthrough := hasBearerToken(ctx)
+	if passthrough && clients.passthroughRegistry != nil {
+		return clients.passthroughRegistry, nil
+	} else if !passthrough && clients.registry != nil {
+		return clients.registry, nil
+	}
+	config, err := connection.ActiveConfig()
+	if err != nil {
+		return nil, err
+	}
+	// Clients outlive requests, so they aren't created with request contexts.
+	if !passthrough {
+		clients.registry, err = connection.NewRegistryClientWithSettings(context.Background(), config)
+		return clients.registry, err
+	}
+	if config.Insecure {
+		config.Token = ""
+		clients.passthroughRegistry, err = connection.NewRegistryClientWithSettings(context.Background(), config)
+	} else {
+		clients.passthroughRegistry, err = gapic.NewRegistryClient(context.Background(), passthroughOptions(config)...)
+	}
+	return clients.passthroughRegistry, err
+}
+
+func adminClient(ctx context.Context) (connection.AdminClient, error) {
+	clients.Lock()
+	defer clients.Unlock()
+	passthrough := hasBearerToken(ctx)
+	if passthrough && clients.passthroughAdmin != nil {
+		return clients.passthroughAdmin, nil
+	} else if !passthrough && clients.admin != nil {
+		return clients.admin, nil
+	}
+	config, err := connection.ActiveConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !passthrough {
+		clients.admin, err = connection.NewAdminClientWithSettings(context.Background(), config)
+		return clients.admin, err
+	}
+	if config.Insecure {
+		config.Token = ""
+		clients.passthroughAdmin, err = connection.NewAdminClientWithSettings(context.Background(), config)
+	} else {
+		clients.passthroughAdmin, err = gapic.NewAdminClient(context.Background(), passthroughOptions(config)...)
+	}
+	return clients.passthroughAdmin, err
+}
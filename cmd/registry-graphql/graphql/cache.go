@@ -0,0 +1,252 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "registry_graphql_cache_requests_total",
+		Help: "Number of cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+	cacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "registry_graphql_cache_evictions_total",
+		Help: "Number of entries removed from caches, by cache and reason (expired, capacity or invalidated).",
+	}, []string{"cache", "reason"})
+	cacheEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "registry_graphql_cache_entries",
+		Help: "Number of entries in caches, by cache.",
+	}, []string{"cache"})
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequests, cacheEvictions, cacheEntries)
+}
+
+// An lru is a map with a maximum size and a time to live for its entries.
+// When it is full, the least recently used entry is removed. Its owner
+// guards it with a lock.
+type lru struct {
+	name    string // for metrics
+	max     int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List // of *lruEntry, most recently used first
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	created time.Time
+}
+
+func newLRU(name string, max int, ttl time.Duration) *lru {
+	cacheEntries.WithLabelValues(name).Set(0)
+	return &lru{
+		name:    name,
+		max:     max,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *lru) get(key string) (interface{}, bool) {
+	e, ok := c.entries[key]
+	if ok && c.ttl > 0 && time.Since(e.Value.(*lruEntry).created) > c.ttl {
+		c.remove(e, "expired")
+		ok = false
+	}
+	if !ok {
+		cacheRequests.WithLabelValues(c.name, "miss").Inc()
+		return nil, false
+	}
+	cacheRequests.WithLabelValues(c.name, "hit").Inc()
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+func (c *lru) put(key string, value interface{}, created time.Time) {
+	if e, ok := c.entries[key]; ok {
+		c.remove(e, "replaced")
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, created: created})
+	for c.max > 0 && c.order.Len() > c.max {
+		c.remove(c.order.Back(), "capacity")
+	}
+	cacheEntries.WithLabelValues(c.name).Set(float64(c.order.Len()))
+}
+
+func (c *lru) remove(e *list.Element, reason string) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*lruEntry).key)
+	if reason != "replaced" {
+		cacheEvictions.WithLabelValues(c.name, reason).Inc()
+	}
+	cacheEntries.WithLabelValues(c.name).Set(float64(c.order.Len()))
+}
+
+// Cache holds the results of queries. If it is nil, results aren't cached.
+var Cache *ResponseCache
+
+// A ResponseCache holds the results of queries by their query documents,
+// variables and callers. Results are removed when they expire and when the
+// resources that they include change.
+type ResponseCache struct {
+	mu      sync.Mutex
+	results *lru
+}
+
+type cachedResult struct {
+	result *graphql.Result
+	// patterns of the names of the resources and collections in the result,
+	// where IDs can be "-"
+	dependencies []string
+}
+
+// NewResponseCache returns a cache of at most maxEntries results that
+// expire after ttl. Zero values disable the limits.
+func NewResponseCache(maxEntries int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{results: newLRU("responses", maxEntries, ttl)}
+}
+
+func (c *ResponseCache) get(key string) (*graphql.Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.results.get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(*cachedResult).result, true
+}
+
+// put caches a result of an operation that started at a time, so that
+// changes made while it ran invalidate it.
+func (c *ResponseCache) put(key string, result *graphql.Result, dependencies []string, started time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results.put(key, &cachedResult{result: result, dependencies: dependencies}, started)
+}
+
+// Invalidate removes the results that include a resource, its collection or
+// its children and were cached before the time that it changed.
+func (c *ResponseCache) Invalidate(name string, changed time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.results.order.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*lruEntry)
+		if entry.created.Before(changed) && affects(name, entry.value.(*cachedResult).dependencies) {
+			c.results.remove(e, "invalidated")
+		}
+		e = next
+	}
+}
+
+// Watch invalidates results with the notifications of a source until the
+// context is canceled. Notifications without times are applied when they
+// are received. Sources that can send every notification are asked to, so
+// that invalidations aren't dropped when many changes arrive at once.
+func (c *ResponseCache) Watch(ctx context.Context, source NotificationSource) error {
+	subscribe := source.Subscribe
+	if s, ok := source.(losslessSource); ok {
+		subscribe = s.subscribeAll
+	}
+	notifications, err := subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	for n := range notifications {
+		changed := time.Now()
+		if n.ChangeTime != nil {
+			changed = n.ChangeTime.AsTime()
+		}
+		c.Invalidate(n.Resource, changed)
+	}
+	return nil
+}
+
+// affects reports whether a change to a resource affects any of the
+// patterns of a result: the resource itself, the collections that include
+// it, or the resources below it.
+func affects(name string, dependencies []string) bool {
+	name, _, _ = strings.Cut(name, "@")
+	n := len(strings.Split(name, "/"))
+	for _, d := range dependencies {
+		parts := strings.Split(d, "/")
+		if len(parts) >= n && matchesPattern(name, strings.Join(parts[:n], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependency returns the pattern that a result of a request depends on.
+func (r *result) dependency() string {
+	if r.list == nil {
+		name, _, _ := strings.Cut(r.name, "@")
+		return name
+	}
+	switch r.collection.kind {
+	case "spec revisions", "deployment revisions":
+		return r.list.Parent
+	case "projects":
+		return "projects/-"
+	default:
+		return r.list.Parent + "/" + r.collection.kind + "/-"
+	}
+}
+
+// cacheKey identifies an operation and its caller.
+func cacheKey(params graphql.Params) string {
+	token, _ := params.Context.Value(bearerTokenKey{}).(string)
+	b, _ := json.Marshal([]interface{}{token, params.RequestString, params.OperationName, params.VariableValues})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// invalidating wraps the resolver of a mutation so that cached results that
+// include the resource that it changes are removed.
+func invalidating(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		v, err := resolve(p)
+		if err != nil || Cache == nil {
+			return v, err
+		}
+		var name string
+		switch v := v.(type) {
+		case map[string]interface{}:
+			name, _ = v["id"].(string)
+		case string:
+			name = v
+		}
+		if name != "" {
+			Cache.Invalidate(name, time.Now())
+		}
+		return v, err
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+	"github.com/graphql-go/graphql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAffects(t *testing.T) {
+	api := "projects/p/locations/global/apis/a"
+	tests := []struct {
+		name         string
+		dependencies []string
+		want         bool
+	}{
+		{api, []string{api}, true},
+		{api + "@123", []string{api}, true},
+		{api, []string{"projects/p/locations/global/apis/-"}, true},
+		{api, []string{"projects/-/locations/global/apis/-"}, true},
+		{api, []string{api + "/versions/v1/specs/s"}, true},
+		{api, []string{"projects/p"}, false},
+		{api, []string{"projects/p/locations/global/apis/b"}, false},
+		{api, []string{api + "/versions/-"}, true},
+		{api + "/versions/v1", []string{"projects/p/locations/global/apis/-"}, false},
+		{"projects/p", []string{"projects/-", "projects/q"}, true},
+		{"projects/p", []string{api}, true},
+	}
+	for _, test := range tests {
+		if got := affects(test.name, test.dependencies); got != test.want {
+			t.Errorf("affects(%q, %v) = %t, want %t", test.name, test.dependencies, got, test.want)
+		}
+	}
+}
+
+// run executes an operation as the HTTP handler does, and returns its
+// result as JSON and the number of registry requests that were made.
+func run(t *testing.T, ctx context.Context, q string) (string, int) {
+	t.Helper()
+	ctx = WithLoader(ctx)
+	r := execute(graphql.Params{
+		Schema:        Schema,
+		RequestString: q,
+		Context:       ctx,
+	})
+	if len(r.Errors) > 0 {
+		t.Fatalf("operation returned errors: %v", r.Errors)
+	}
+	b, err := json.Marshal(r.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), loaderFor(ctx).calls
+}
+
+func TestResponseCache(t *testing.T) {
+	ctx := context.Background()
+	api := "projects/graphql-cache/locations/global/apis/a"
+	grpctest.SetupRegistry(ctx, t, "graphql-cache", []seeder.RegistryResource{
+		&rpc.Api{Name: api, Description: "first"},
+	})
+	Cache = NewResponseCache(10, time.Minute)
+	defer func() { Cache = nil }()
+	hits := testutil.ToFloat64(cacheRequests.WithLabelValues("responses", "hit"))
+	misses := testutil.ToFloat64(cacheRequests.WithLabelValues("responses", "miss"))
+
+	q := `{ apis(parent: "projects/graphql-cache") { edges { node { id description } } } }`
+	first, calls := run(t, ctx, q)
+	if calls != 1 {
+		t.Errorf("first query made %d registry requests, want 1", calls)
+	}
+	if cached, calls := run(t, ctx, q); calls != 0 || cached != first {
+		t.Errorf("second query made %d registry requests and returned %s, want 0 and %s", calls, cached, first)
+	}
+
+	// Changes made before results were cached don't invalidate them.
+	Cache.Invalidate(api, time.Now().Add(-time.Hour))
+	if _, calls := run(t, ctx, q); calls != 0 {
+		t.Errorf("query after an earlier change made %d registry requests, want 0", calls)
+	}
+
+	// Mutations invalidate the results that include their resources.
+	run(t, WithBearerToken(ctx, "caller-token"), `mutation { updateApi(id: "`+api+`", api: {description: "second"}) { id } }`)
+	got, calls := run(t, ctx, q)
+	if calls != 1 || !strings.Contains(got, "second") {
+		t.Errorf("query after an update made %d registry requests and returned %s, want 1 and the update", calls, got)
+	}
+
+	// Changes to other resources don't invalidate results.
+	Cache.Invalidate("projects/graphql-cache/locations/global/apis/a/versions/v1", time.Now())
+	if _, calls := run(t, ctx, q); calls != 0 {
+		t.Errorf("query after an unrelated change made %d registry requests, want 0", calls)
+	}
+
+	// Notifications invalidate results.
+	source := NewMemorySource()
+	watchCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- Cache.Watch(watchCtx, source) }()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		source.mu.Lock()
+		n := len(source.subscribers)
+		source.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("cache did not watch notifications")
+		}
+	}
+	source.Publish(&rpc.Notification{Change: rpc.Notification_CREATED, Resource: "projects/graphql-cache/locations/global/apis/b"})
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		Cache.mu.Lock()
+		n := Cache.results.order.Len()
+		Cache.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("notification did not invalidate results")
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch() returned error: %s", err)
+	}
+
+	if got := testutil.ToFloat64(cacheRequests.WithLabelValues("responses", "hit")) - hits; got != 3 {
+		t.Errorf("cache counted %v hits, want 3", got)
+	}
+	if got := testutil.ToFloat64(cacheRequests.WithLabelValues("responses", "miss")) - misses; got != 2 {
+		t.Errorf("cache counted %v misses, want 2", got)
+	}
+
+	// Results are cached for each caller.
+	if cacheKey(graphql.Params{RequestString: q, Context: WithBearerToken(ctx, "a")}) ==
+		cacheKey(graphql.Params{RequestString: q, Context: WithBearerToken(ctx, "b")}) {
+		t.Errorf("callers with different tokens have the same cache keys")
+	}
+}
+
+func TestNotificationsForCache(t *testing.T) {
+	source := NewMemorySource()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dropping, _ := source.Subscribe(ctx)
+	all, _ := source.subscribeAll(ctx)
+
+	// Notifications for the cache are queued until they are received.
+	const count = subscriberBuffer + 10
+	go func() {
+		for i := 0; i < count; i++ {
+			source.Publish(&rpc.Notification{Resource: "projects/p/locations/global/apis/a"})
+		}
+	}()
+	for i := 0; i < count; i++ {
+		select {
+		case <-all:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d notifications, want %d", i, count)
+		}
+	}
+	if len(dropping) != subscriberBuffer {
+		t.Errorf("other subscriber has %d queued notifications, want %d", len(dropping), subscriberBuffer)
+	}
+}
+
+func TestResponseCacheExpiration(t *testing.T) {
+	c := NewResponseCache(2, time.Minute)
+	result := &graphql.Result{}
+	c.put("old", result, nil, time.Now().Add(-2*time.Minute))
+	if _, ok := c.get("old"); ok {
+		t.Errorf("expired result was returned")
+	}
+	c.put("a", result, nil, time.Now())
+	c.put("b", result, nil, time.Now())
+	c.get("a")
+	c.put("c", result, nil, time.Now())
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := c.get(key); ok != want {
+			t.Errorf("get(%q) returned %t, want %t", key, ok, want)
+		}
+	}
+}
+
+func TestPersistedQueries(t *testing.T) {
+	PersistedQueries = NewQueryStore(10, time.Hour)
+	defer func() { PersistedQueries = nil }()
+	s := httptest.NewServer(Handler)
+	defer s.Close()
+
+	q := "{ __typename }"
+	sum := sha256.Sum256([]byte(q))
+	hash := hex.EncodeToString(sum[:])
+	extensions := `{"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}`
+	post := func(body string) interface{} {
+		t.Helper()
+		resp, err := http.Post(s.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decodeResponse(t, resp)
+	}
+	notFound := `{"data": null, "errors": [{"message": "PersistedQueryNotFound", "locations": [], "extensions": {"code": "PERSISTED_QUERY_NOT_FOUND"}}]}`
+	found := `{"data": {"__typename": "Query"}}`
+
+	if diff := cmp.Diff(unmarshal(t, notFound), post(`{"extensions": `+extensions+`}`)); diff != "" {
+		t.Errorf("unknown hash returned unexpected response (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(unmarshal(t, found), post(`{"query": "`+q+`", "extensions": `+extensions+`}`)); diff != "" {
+		t.Errorf("query with hash returned unexpected response (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(unmarshal(t, found), post(`{"extensions": `+extensions+`}`)); diff != "" {
+		t.Errorf("known hash returned unexpected response (-want +got):\n%s", diff)
+	}
+	resp, err := http.Get(s.URL + "?extensions=" + url.QueryEscape(extensions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(unmarshal(t, found), decodeResponse(t, resp)); diff != "" {
+		t.Errorf("GET with known hash returned unexpected response (-want +got):\n%s", diff)
+	}
+
+	mismatch := `{"data": null, "errors": [{"message": "provided sha does not match query", "locations": [], "extensions": {"code": "INVALID_ARGUMENT"}}]}`
+	if diff := cmp.Diff(unmarshal(t, mismatch), post(`{"query": "{ project(id: \"x\") { id } }", "extensions": `+extensions+`}`)); diff != "" {
+		t.Errorf("query with wrong hash returned unexpected response (-want +got):\n%s", diff)
+	}
+
+	// Without a store, clients are told to send full queries.
+	PersistedQueries = nil
+	notSupported := `{"data": null, "errors": [{"message": "PersistedQueryNotSupported", "locations": [], "extensions": {"code": "PERSISTED_QUERY_NOT_SUPPORTED"}}]}`
+	if diff := cmp.Diff(unmarshal(t, notSupported), post(`{"extensions": `+extensions+`}`)); diff != "" {
+		t.Errorf("request without a store returned unexpected response (-want +got):\n%s", diff)
+	}
+}
+
+func decodeResponse(t *testing.T, resp *http.Response) interface{} {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unmarshal(t, string(b))
+}
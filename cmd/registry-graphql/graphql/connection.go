@@ -15,15 +15,32 @@
 package graphql
 
 import (
+	"encoding/base64"
+	"encoding/json"
+
 	"github.com/graphql-go/graphql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Paging support
 
+// Connections follow the Relay Cursor Connections Specification
+// (https://relay.dev/graphql/connections.htm) for forward pagination.
+
 var pageInfoType = graphql.NewObject(
 	graphql.ObjectConfig{
 		Name: "PageInfo",
 		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+			},
+			"hasPreviousPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+			},
+			"startCursor": &graphql.Field{
+				Type: graphql.String,
+			},
 			"endCursor": &graphql.Field{
 				Type: graphql.String,
 			},
@@ -57,13 +74,16 @@ func connectionType(t graphql.Type) *graphql.Object {
 									"node": &graphql.Field{
 										Type: t,
 									},
+									"cursor": &graphql.Field{
+										Type: graphql.NewNonNull(graphql.String),
+									},
 								},
 							},
 						),
 					),
 				},
 				"pageInfo": &graphql.Field{
-					Type: pageInfoType,
+					Type: graphql.NewNonNull(pageInfoType),
 				},
 			},
 		},
@@ -72,21 +92,57 @@ func connectionType(t graphql.Type) *graphql.Object {
 	return p
 }
 
-func representationForPageInfo(endCursor string) map[string]interface{} {
-	return map[string]interface{}{
-		"endCursor": endCursor,
+// A cursor is the position after an item in a list. Items are listed from
+// a registry page token, skipping the items before the position. Searches
+// list several sources in turn, and also record the index of the source.
+type cursor struct {
+	Token  string `json:"t,omitempty"`
+	Skip   int    `json:"s,omitempty"`
+	Source int    `json:"k,omitempty"`
+}
+
+func (c cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseCursor(s string) (cursor, error) {
+	var c cursor
+	if s == "" {
+		return c, nil
 	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || c.Skip < 0 || c.Source < 0 {
+		return cursor{}, status.Errorf(codes.InvalidArgument, "invalid cursor %q", s)
+	}
+	return c, nil
 }
 
-func representationForEdge(node interface{}) map[string]interface{} {
+func representationForEdge(node interface{}, c cursor) map[string]interface{} {
 	return map[string]interface{}{
-		"node": node,
+		"node":   node,
+		"cursor": c.String(),
 	}
 }
 
-func connectionForEdgesAndEndCursor(edges []map[string]interface{}, cursor string) map[string]interface{} {
+// connectionForEdges returns a page of edges. Lists are only paginated
+// forward, so there are previous pages if the page starts after a cursor.
+func connectionForEdges(edges []map[string]interface{}, after string, hasNextPage bool) map[string]interface{} {
+	pageInfo := map[string]interface{}{
+		"hasNextPage":     hasNextPage,
+		"hasPreviousPage": after != "",
+		"startCursor":     nil,
+		"endCursor":       nil,
+	}
+	if len(edges) > 0 {
+		pageInfo["startCursor"] = edges[0]["cursor"]
+		pageInfo["endCursor"] = edges[len(edges)-1]["cursor"]
+	}
 	return map[string]interface{}{
 		"edges":    edges,
-		"pageInfo": representationForPageInfo(cursor),
+		"pageInfo": pageInfo,
 	}
 }
@@ -0,0 +1,202 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+)
+
+type page struct {
+	Edges []struct {
+		Node struct {
+			ID string `json:"id"`
+		} `json:"node"`
+		Cursor string `json:"cursor"`
+	} `json:"edges"`
+	PageInfo struct {
+		HasNextPage     bool    `json:"hasNextPage"`
+		HasPreviousPage bool    `json:"hasPreviousPage"`
+		StartCursor     *string `json:"startCursor"`
+		EndCursor       *string `json:"endCursor"`
+	} `json:"pageInfo"`
+}
+
+// listAPIs gets a page of the APIs of the test project.
+func listAPIs(t *testing.T, filter string, first int, after string) page {
+	t.Helper()
+	r, data, _ := query(t, fmt.Sprintf(`{
+	  apis(parent: "projects/graphql-pagination", filter: %q, first: %d, after: %q) {
+	    edges { node { id } cursor }
+	    pageInfo { hasNextPage hasPreviousPage startCursor endCursor }
+	  }
+	}`, filter, first, after))
+	if len(r.Errors) > 0 {
+		t.Fatalf("query returned errors: %+v", r.Errors)
+	}
+	var result struct {
+		APIs page `json:"apis"`
+	}
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result.APIs
+}
+
+func TestPagination(t *testing.T) {
+	ctx := context.Background()
+	project := "projects/graphql-pagination/locations/global"
+	var seeds []seeder.RegistryResource
+	var all []string
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("%s/apis/api%d", project, i)
+		seeds = append(seeds, &rpc.Api{Name: name})
+		all = append(all, name)
+	}
+	grpctest.SetupRegistry(ctx, t, "graphql-pagination", seeds)
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{want: all},
+		{filter: `api_id != "api3"`, want: append(append([]string{}, all[:3]...), all[4:]...)},
+	}
+	for _, test := range tests {
+		for _, first := range []int{1, 2, 3, 6, 7, 10} {
+			t.Run(fmt.Sprintf("filter=%q first=%d", test.filter, first), func(t *testing.T) {
+				var got []string
+				after := ""
+				for pages := 0; pages < 10; pages++ {
+					p := listAPIs(t, test.filter, first, after)
+					if len(p.Edges) > first {
+						t.Errorf("page returned %d edges, want at most %d", len(p.Edges), first)
+					}
+					if p.PageInfo.HasPreviousPage != (after != "") {
+						t.Errorf("page has hasPreviousPage %t after %q", p.PageInfo.HasPreviousPage, after)
+					}
+					for _, edge := range p.Edges {
+						got = append(got, edge.Node.ID)
+					}
+					if len(p.Edges) > 0 && *p.PageInfo.EndCursor != p.Edges[len(p.Edges)-1].Cursor {
+						t.Errorf("page has endCursor %q, want the cursor of its last edge", *p.PageInfo.EndCursor)
+					}
+					if !p.PageInfo.HasNextPage {
+						break
+					}
+					if len(p.Edges) != first {
+						t.Errorf("page with a next page returned %d edges, want %d", len(p.Edges), first)
+					}
+					after = *p.PageInfo.EndCursor
+				}
+				if diff := cmp.Diff(test.want, got); diff != "" {
+					t.Errorf("unexpected APIs (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+
+	t.Run("cursors of edges", func(t *testing.T) {
+		p := listAPIs(t, "", 5, "")
+		next := listAPIs(t, "", 2, p.Edges[1].Cursor)
+		if next.Edges[0].Node.ID != all[2] {
+			t.Errorf("page after the second edge starts with %q, want %q", next.Edges[0].Node.ID, all[2])
+		}
+	})
+
+	t.Run("cursors of batched connections", func(t *testing.T) {
+		_, data, _ := query(t, `{
+		  projects(filter: "project_id == 'graphql-pagination'") { edges { node {
+		    apis(first: 4) { pageInfo { endCursor } }
+		  } } }
+		}`)
+		var result struct {
+			Projects struct {
+				Edges []struct {
+					Node struct {
+						APIs page `json:"apis"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"projects"`
+		}
+		if err := json.Unmarshal([]byte(data), &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Projects.Edges) != 1 {
+			t.Fatalf("query returned %d projects, want 1: %s", len(result.Projects.Edges), data)
+		}
+		p := listAPIs(t, "", 10, *result.Projects.Edges[0].Node.APIs.PageInfo.EndCursor)
+		if len(p.Edges) != 3 || p.Edges[0].Node.ID != all[4] || p.PageInfo.HasNextPage {
+			t.Errorf("unexpected page after the batched connection: %+v", p)
+		}
+	})
+
+	t.Run("empty pages", func(t *testing.T) {
+		p := listAPIs(t, `api_id == "none"`, 5, "")
+		if len(p.Edges) != 0 || p.PageInfo.HasNextPage || p.PageInfo.StartCursor != nil || p.PageInfo.EndCursor != nil {
+			t.Errorf("unexpected empty page: %+v", p)
+		}
+	})
+}
+
+func TestErrorCodes(t *testing.T) {
+	ctx := context.Background()
+	grpctest.SetupRegistry(ctx, t, "graphql-errors", nil)
+	tests := []struct {
+		name  string
+		query string
+		code  string
+	}{
+		{
+			name:  "missing resource",
+			query: `{ api(id: "projects/graphql-errors/locations/global/apis/missing") { id } }`,
+			code:  "NOT_FOUND",
+		},
+		{
+			name:  "invalid filter in a nested connection",
+			query: `{ project(id: "projects/graphql-errors") { apis(first: 1, filter: "bad filter") { edges { node { id } } } } }`,
+			code:  "INVALID_ARGUMENT",
+		},
+		{
+			name:  "invalid cursor",
+			query: `{ apis(parent: "projects/graphql-errors", after: "not a cursor") { edges { node { id } } } }`,
+			code:  "INVALID_ARGUMENT",
+		},
+		{
+			name: "invalid input",
+			query: `mutation { createSpec(parent: "projects/graphql-errors/locations/global/apis/a/versions/v1", id: "s",
+			  spec: {contents: "a", contents_base64: "Yg=="}) { id } }`,
+			code: "INVALID_ARGUMENT",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _, _ := query(t, test.query, "caller-token")
+			if len(r.Errors) != 1 {
+				t.Fatalf("query returned errors %+v, want one", r.Errors)
+			}
+			if code := r.Errors[0].Extensions["code"]; code != test.code {
+				t.Errorf("query returned an error with code %v, want %s: %s", code, test.code, r.Errors[0].Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apigee/registry-experimental/pkg/artifacttypes"
+	"github.com/apigee/registry/cmd/registry/compress"
+	"github.com/apigee/registry/cmd/registry/patch"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/rpc"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+var fileType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "File",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type: graphql.String,
+			},
+			"contents": &graphql.Field{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+// Contents are loaded like resources, so that they are only loaded once.
+// They are represented with their uncompressed data and MIME type.
+
+var specContentsCollection = &collection{
+	kind:      "spec contents",
+	unbatched: true,
+	get: func(ctx context.Context, name string) (map[string]interface{}, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		body, err := c.GetApiSpecContents(ctx, &rpc.GetApiSpecContentsRequest{
+			Name: name,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return representationForContents(name, body.GetContentType(), body.GetData())
+	},
+}
+
+var artifactContentsCollection = &collection{
+	kind:      "artifact contents",
+	unbatched: true,
+	get: func(ctx context.Context, name string) (map[string]interface{}, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		body, err := c.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{
+			Name: name,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return representationForContents(name, body.GetContentType(), body.GetData())
+	},
+}
+
+func representationForContents(name, mimeType string, data []byte) (map[string]interface{}, error) {
+	if mime.IsGZipCompressed(mimeType) {
+		var err error
+		data, err = compress.GUnzippedBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		mimeType = mime.GUnzippedType(mimeType)
+	}
+	return map[string]interface{}{
+		"id":        name,
+		"mime_type": mimeType,
+		"data":      data,
+	}, nil
+}
+
+// resolveContents returns a resolver for a field that is computed from the
+// contents of the resource that contains it.
+func resolveContents(c *collection, value func(mimeType string, data []byte) (interface{}, error)) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		name, _ := p.Source.(map[string]interface{})["id"].(string)
+		thunk := loaderFor(p.Context).get(p.Context, c, name)
+		return func() (interface{}, error) {
+			v, err := thunk()
+			if err != nil {
+				return nil, err
+			}
+			contents := v.(map[string]interface{})
+			return value(contents["mime_type"].(string), contents["data"].([]byte))
+		}, nil
+	}
+}
+
+// textContents returns contents as text, unless they are binary.
+func textContents(mimeType string, data []byte) (interface{}, error) {
+	if mime.IsZipArchive(mimeType) || !utf8.Valid(data) {
+		return nil, nil
+	}
+	return string(data), nil
+}
+
+func base64Contents(mimeType string, data []byte) (interface{}, error) {
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// zipFiles returns the files of zip archives, sorted by name.
+func zipFiles(mimeType string, data []byte) (interface{}, error) {
+	if !mime.IsZipArchive(mimeType) {
+		return nil, nil
+	}
+	m, err := compress.UnzipArchiveToMap(data)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]map[string]interface{}, 0, len(m))
+	for name, contents := range m {
+		files = append(files, map[string]interface{}{
+			"name":     name,
+			"contents": string(contents),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i]["name"].(string) < files[j]["name"].(string)
+	})
+	return files, nil
+}
+
+// valueContents decodes contents as a JSON value. Messages with types
+// that are named in their MIME types are returned in their JSON form,
+// JSON and YAML documents are parsed, and other text is returned as a
+// string. Binary contents of other types are returned as null.
+func valueContents(mimeType string, data []byte) (interface{}, error) {
+	if messageType, err := mime.MessageTypeForMimeType(mimeType); err == nil {
+		if message, err := newMessage(mimeType, messageType); err == nil {
+			if err := patch.UnmarshalContents(data, mimeType, message); err != nil {
+				return nil, err
+			}
+			b, err := protojson.Marshal(message)
+			if err != nil {
+				return nil, err
+			}
+			var v interface{}
+			return v, json.Unmarshal(b, &v)
+		}
+	}
+	var v interface{}
+	switch {
+	case strings.HasPrefix(mimeType, "application/json"):
+		return v, json.Unmarshal(data, &v)
+	case strings.HasPrefix(mimeType, "application/yaml"):
+		return v, yaml.Unmarshal(data, &v)
+	}
+	return textContents(mimeType, data)
+}
+
+// newMessage returns an empty message of a type, which is found with the
+// artifact types of the mime package or else with the global proto registry.
+func newMessage(mimeType, messageType string) (proto.Message, error) {
+	if message, err := mime.MessageForMimeType(mimeType); err == nil {
+		return message, nil
+	}
+	return artifacttypes.NewMessage(messageType)
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/apigee/registry/cmd/registry/compress"
+	"github.com/apigee/registry/pkg/application/scoring"
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/pkg/mime"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, contents := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestContentsAndRevisions(t *testing.T) {
+	ctx := context.Background()
+	api := "projects/graphql-contents/locations/global/apis/petstore"
+	gzipped, err := compress.GZippedBytes([]byte("openapi: 3.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	score, err := proto.Marshal(&scoring.Score{Id: "lint-errors", Kind: "Score"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds := []seeder.RegistryResource{
+		&rpc.ApiSpec{
+			Name:     api + "/versions/v1/specs/openapi",
+			MimeType: mime.OpenAPIMimeType("+gzip", "3"),
+			Contents: gzipped,
+			Labels:   map[string]string{"team": "pets", "tier": "gold"},
+		},
+		&rpc.ApiSpec{
+			Name:     api + "/versions/v1/specs/protos",
+			MimeType: mime.ProtobufMimeType("+zip"),
+			Contents: zipArchive(t, map[string]string{"b.proto": "syntax = \"proto3\";", "a.proto": "package a;"}),
+		},
+		&rpc.ApiDeployment{
+			Name:        api + "/deployments/prod",
+			EndpointUri: "https://v1.example.com",
+			Annotations: map[string]string{"region": "us"},
+		},
+		&rpc.Artifact{
+			Name:     api + "/artifacts/score",
+			MimeType: mime.MimeTypeForMessageType("google.cloud.apigeeregistry.v1.scoring.Score"),
+			Contents: score,
+		},
+		&rpc.Artifact{
+			Name:     api + "/artifacts/notes",
+			MimeType: "application/json",
+			Contents: []byte(`{"owners": ["pets"]}`),
+		},
+	}
+	client, _ := grpctest.SetupRegistry(ctx, t, "graphql-contents", seeds)
+
+	// Changes to contents and endpoints create new revisions.
+	if _, err := client.UpdateApiSpec(ctx, &rpc.UpdateApiSpecRequest{
+		ApiSpec: &rpc.ApiSpec{
+			Name:     api + "/versions/v1/specs/openapi",
+			MimeType: mime.OpenAPIMimeType("", "3"),
+			Contents: []byte("openapi: 3.1.0"),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.UpdateApiDeployment(ctx, &rpc.UpdateApiDeploymentRequest{
+		ApiDeployment: &rpc.ApiDeployment{
+			Name:        api + "/deployments/prod",
+			EndpointUri: "https://v2.example.com",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, data, _ := query(t, `{
+	  openapi: spec(id: "projects/graphql-contents/locations/global/apis/petstore/versions/v1/specs/openapi") {
+	    contents labels { key value }
+	    revisions(first: 5) { edges { node { contents } } }
+	  }
+	  protos: spec(id: "projects/graphql-contents/locations/global/apis/petstore/versions/v1/specs/protos") {
+	    contents files { name contents }
+	  }
+	  deployments(parent: "projects/graphql-contents/locations/global/apis/petstore", first: 5) { edges { node {
+	    annotations { key value }
+	    revisions(first: 5) { edges { node { endpoint_uri } } }
+	  } } }
+	  score: artifact(id: "projects/graphql-contents/locations/global/apis/petstore/artifacts/score") { contents }
+	  notes: artifact(id: "projects/graphql-contents/locations/global/apis/petstore/artifacts/notes") { contents }
+	}`)
+	if len(r.Errors) > 0 {
+		t.Fatalf("query returned errors: %+v", r.Errors)
+	}
+	got := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"openapi": map[string]interface{}{
+			"contents": "openapi: 3.1.0",
+			"labels": []interface{}{
+				map[string]interface{}{"key": "team", "value": "pets"},
+				map[string]interface{}{"key": "tier", "value": "gold"},
+			},
+			"revisions": map[string]interface{}{"edges": []interface{}{
+				map[string]interface{}{"node": map[string]interface{}{"contents": "openapi: 3.1.0"}},
+				map[string]interface{}{"node": map[string]interface{}{"contents": "openapi: 3.0.0"}},
+			}},
+		},
+		"protos": map[string]interface{}{
+			"contents": nil,
+			"files": []interface{}{
+				map[string]interface{}{"name": "a.proto", "contents": "package a;"},
+				map[string]interface{}{"name": "b.proto", "contents": "syntax = \"proto3\";"},
+			},
+		},
+		"deployments": map[string]interface{}{"edges": []interface{}{
+			map[string]interface{}{"node": map[string]interface{}{
+				"annotations": []interface{}{
+					map[string]interface{}{"key": "region", "value": "us"},
+				},
+				"revisions": map[string]interface{}{"edges": []interface{}{
+					map[string]interface{}{"node": map[string]interface{}{"endpoint_uri": "https://v2.example.com"}},
+					map[string]interface{}{"node": map[string]interface{}{"endpoint_uri": "https://v1.example.com"}},
+				}},
+			}},
+		}},
+		"score": map[string]interface{}{
+			"contents": map[string]interface{}{"id": "lint-errors", "kind": "Score"},
+		},
+		"notes": map[string]interface{}{
+			"contents": map[string]interface{}{"owners": []interface{}{"pets"}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+}
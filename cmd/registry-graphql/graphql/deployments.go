@@ -0,0 +1,187 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/graphql-go/graphql"
+)
+
+var deploymentType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "Deployment",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.String,
+			},
+			"display_name": &graphql.Field{
+				Type: graphql.String,
+			},
+			"description": &graphql.Field{
+				Type: graphql.String,
+			},
+			"revision_id": &graphql.Field{
+				Type: graphql.String,
+			},
+			"api_spec_revision": &graphql.Field{
+				Type: graphql.String,
+			},
+			"endpoint_uri": &graphql.Field{
+				Type: graphql.String,
+			},
+			"external_channel_uri": &graphql.Field{
+				Type: graphql.String,
+			},
+			"intended_audience": &graphql.Field{
+				Type: graphql.String,
+			},
+			"access_guidance": &graphql.Field{
+				Type: graphql.String,
+			},
+			"labels": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
+			"annotations": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
+			"artifacts": &graphql.Field{
+				Type:    connectionType(artifactType),
+				Args:    argumentsForCollectionQuery,
+				Resolve: resolveArtifacts,
+			},
+			"created": &graphql.Field{
+				Type: timestampType,
+			},
+			"revision_created": &graphql.Field{
+				Type: timestampType,
+			},
+			"updated": &graphql.Field{
+				Type: timestampType,
+			},
+		},
+	},
+)
+
+func init() {
+	// Revisions are deployments, so this field can't be in the literal.
+	deploymentType.AddFieldConfig("revisions", &graphql.Field{
+		Type:    connectionType(deploymentType),
+		Args:    argumentsForCollectionQuery,
+		Resolve: resolveDeploymentRevisions,
+	})
+}
+
+func representationForDeployment(deployment *rpc.ApiDeployment) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                   deployment.Name,
+		"display_name":         deployment.DisplayName,
+		"description":          deployment.Description,
+		"revision_id":          deployment.RevisionId,
+		"api_spec_revision":    deployment.ApiSpecRevision,
+		"endpoint_uri":         deployment.EndpointUri,
+		"external_channel_uri": deployment.ExternalChannelUri,
+		"intended_audience":    deployment.IntendedAudience,
+		"access_guidance":      deployment.AccessGuidance,
+		"labels":               representationForMap(deployment.Labels),
+		"annotations":          representationForMap(deployment.Annotations),
+		"created":              representationForTimestamp(deployment.CreateTime),
+		"revision_created":     representationForTimestamp(deployment.RevisionCreateTime),
+		"updated":              representationForTimestamp(deployment.RevisionUpdateTime),
+	}
+}
+
+var deploymentCollection = &collection{
+	kind: "deployments",
+	get: func(ctx context.Context, name string) (map[string]interface{}, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		deployment, err := c.GetApiDeployment(ctx, &rpc.GetApiDeploymentRequest{
+			Name: name,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return representationForDeployment(deployment), nil
+	},
+	list: func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := c.GrpcClient().ListApiDeployments(ctx, &rpc.ListApiDeploymentsRequest{
+			Parent:    req.Parent,
+			Filter:    req.Filter,
+			PageToken: req.PageToken,
+			PageSize:  req.PageSize,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		var items []map[string]interface{}
+		for _, deployment := range response.GetApiDeployments() {
+			items = append(items, representationForDeployment(deployment))
+		}
+		return items, response.GetNextPageToken(), nil
+	},
+}
+
+func resolveDeployments(p graphql.ResolveParams) (interface{}, error) {
+	return loaderFor(p.Context).list(p.Context, deploymentCollection, getParentFromParams(p), p.Args), nil
+}
+
+func resolveDeployment(p graphql.ResolveParams) (interface{}, error) {
+	name, isFound := p.Args["id"].(string)
+	if !isFound {
+		return nil, errors.New("missing id field")
+	}
+	return loaderFor(p.Context).get(p.Context, deploymentCollection, name), nil
+}
+
+var deploymentRevisionCollection = &collection{
+	kind:      "deployment revisions",
+	unbatched: true,
+	list: func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := c.GrpcClient().ListApiDeploymentRevisions(ctx, &rpc.ListApiDeploymentRevisionsRequest{
+			Name:      req.Parent,
+			Filter:    req.Filter,
+			PageToken: req.PageToken,
+			PageSize:  req.PageSize,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		var items []map[string]interface{}
+		for _, deployment := range response.GetApiDeployments() {
+			items = append(items, representationForDeployment(deployment))
+		}
+		return items, response.GetNextPageToken(), nil
+	},
+}
+
+func resolveDeploymentRevisions(p graphql.ResolveParams) (interface{}, error) {
+	name, _ := p.Source.(map[string]interface{})["id"].(string)
+	name, _, _ = strings.Cut(name, "@")
+	return loaderFor(p.Context).list(p.Context, deploymentRevisionCollection, name, p.Args), nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/status"
+)
+
+// A codedError is an error that is returned to GraphQL clients with the
+// message and code of its gRPC status, such as
+// {"message": "...", "extensions": {"code": "NOT_FOUND"}}.
+type codedError struct {
+	status *status.Status
+}
+
+func (e *codedError) Error() string {
+	return e.status.Message()
+}
+
+func (e *codedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code": code.Code_name[int32(e.status.Code())],
+	}
+}
+
+// GRPCStatus allows the status of the error to be recovered with
+// status.FromError.
+func (e *codedError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// withCodes wraps a resolver so that the errors that it returns, including
+// errors returned by thunks, have codes. Errors without a gRPC status have
+// the code UNKNOWN.
+func withCodes(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		v, err := resolve(p)
+		if thunk, ok := v.(func() (interface{}, error)); ok && err == nil {
+			return func() (interface{}, error) {
+				v, err := thunk()
+				return v, codedErrorFor(err)
+			}, nil
+		}
+		return v, codedErrorFor(err)
+	}
+}
+
+func codedErrorFor(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{status: status.Convert(err)}
+}
+
+// errorCodes is a schema extension that adds the codes of errors that are
+// returned by thunks to results. graphql-go formats these errors twice and
+// drops their extensions the second time.
+type errorCodes struct{}
+
+func (errorCodes) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return ctx
+}
+
+func (errorCodes) Name() string {
+	return "errorCodes"
+}
+
+func (errorCodes) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (errorCodes) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (errorCodes) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(r *graphql.Result) {
+		for i, err := range r.Errors {
+			if err.Extensions == nil {
+				r.Errors[i].Extensions = extensionsOf(err.OriginalError())
+			}
+		}
+	}
+}
+
+func (errorCodes) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	return ctx, func(interface{}, error) {}
+}
+
+func (errorCodes) HasResult() bool {
+	return false
+}
+
+func (errorCodes) GetResult(context.Context) interface{} {
+	return nil
+}
+
+// extensionsOf returns the extensions of the first error in a chain of
+// wrapped and formatted errors that has them.
+func extensionsOf(err error) map[string]interface{} {
+	for err != nil {
+		var extended gqlerrors.ExtendedError
+		if errors.As(err, &extended) {
+			return extended.Extensions()
+		}
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			if e.Extensions != nil {
+				return e.Extensions
+			}
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/handler"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxRequestSize limits the size of request bodies.
+const maxRequestSize = 1 << 20
+
+// Handler serves GraphQL operations over HTTP. Requests are parsed like the
+// requests of graphql-go/handler, and can also have automatic persisted
+// queries in their "extensions". Results of queries are cached if Cache is
+// set. Mutations are only run for POST requests with JSON or GraphQL bodies,
+// which HTML forms and links can't send across sites.
+var Handler http.Handler = httpHandler{}
+
+type httpHandler struct{}
+
+func (httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	opts := handler.NewRequestOptions(r)
+
+	// Extensions are sent in the body of POST requests and in a parameter
+	// of GET requests.
+	var request struct {
+		Extensions map[string]interface{} `json:"extensions"`
+	}
+	if extensions := r.URL.Query().Get("extensions"); extensions != "" {
+		_ = json.Unmarshal([]byte(extensions), &request.Extensions)
+	} else if r.Method == http.MethodPost {
+		_ = json.Unmarshal(body, &request)
+	}
+
+	var result *graphql.Result
+	code := http.StatusOK
+	if query, err := persistedQuery(opts.Query, request.Extensions); err != nil {
+		result = &graphql.Result{Errors: []gqlerrors.FormattedError{formatError(err)}}
+	} else if operationType(query, opts.OperationName) == ast.OperationTypeMutation && !mutationsAllowed(r) {
+		err := &codedError{status: status.New(codes.FailedPrecondition,
+			"mutations must be sent in POST requests with application/json or application/graphql bodies")}
+		result = &graphql.Result{Errors: []gqlerrors.FormattedError{formatError(err)}}
+		code = http.StatusUnsupportedMediaType
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			code = http.StatusMethodNotAllowed
+		}
+	} else {
+		ctx := r.Context()
+		if _, ok := ctx.Value(loaderKey{}).(*loader); !ok {
+			ctx = WithLoader(ctx)
+		}
+		result = execute(graphql.Params{
+			Schema:         Schema,
+			RequestString:  query,
+			VariableValues: opts.Variables,
+			OperationName:  opts.OperationName,
+			Context:        ctx,
+		})
+	}
+	b, _ := json.MarshalIndent(result, "", "\t")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+// mutationsAllowed returns true if a request can run mutations.
+func mutationsAllowed(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json" || mediaType == "application/graphql"
+}
+
+func formatError(err error) gqlerrors.FormattedError {
+	formatted := gqlerrors.FormattedError{
+		Message:   err.Error(),
+		Locations: []location.SourceLocation{},
+	}
+	if extended, ok := err.(gqlerrors.ExtendedError); ok {
+		formatted.Extensions = extended.Extensions()
+	}
+	return formatted
+}
+
+// execute runs an operation. Results of queries are cached without errors,
+// and are returned from the cache until they expire or are invalidated.
+func execute(params graphql.Params) *graphql.Result {
+	if Cache == nil || operationType(params.RequestString, params.OperationName) != ast.OperationTypeQuery {
+		return graphql.Do(params)
+	}
+	key := cacheKey(params)
+	if result, ok := Cache.get(key); ok {
+		return result
+	}
+	started := time.Now()
+	result := graphql.Do(params)
+	if len(result.Errors) == 0 {
+		Cache.put(key, result, loaderFor(params.Context).dependencies(), started)
+	}
+	return result
+}
+
+// operationType returns the type of the operation of a request, or "" if
+// the request is invalid.
+func operationType(query, operationName string) string {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return ""
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation
+		}
+	}
+	return ""
+}
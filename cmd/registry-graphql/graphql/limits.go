@@ -0,0 +1,122 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxDepth limits the nesting of fields in operations. Zero disables it.
+var MaxDepth = 15
+
+// MaxComplexity limits the estimated cost of operations. Each field costs
+// one, and fields below the edges of a connection cost one for each node
+// that the connection may return. Zero disables it.
+var MaxComplexity = 5000
+
+// limited wraps the resolver of a top-level field so that it fails if its
+// operation exceeds the limits. Operations are checked once per loader.
+func limited(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		l := loaderFor(p.Context)
+		l.mu.Lock()
+		if !l.limitsChecked {
+			l.limitsChecked = true
+			l.limitsErr = checkLimits(p.Info)
+		}
+		err := l.limitsErr
+		l.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return resolve(p)
+	}
+}
+
+func checkLimits(info graphql.ResolveInfo) error {
+	op, ok := info.Operation.(*ast.OperationDefinition)
+	if !ok {
+		return nil
+	}
+	m := &measure{fragments: info.Fragments, variables: info.VariableValues}
+	cost := m.selectionCost(op.SelectionSet, defaultPageSize, 1)
+	if MaxDepth > 0 && m.depth > MaxDepth {
+		return status.Errorf(codes.InvalidArgument, "query depth %d exceeds the limit of %d", m.depth, MaxDepth)
+	}
+	if MaxComplexity > 0 && cost > MaxComplexity {
+		return status.Errorf(codes.InvalidArgument, "query complexity %d exceeds the limit of %d", cost, MaxComplexity)
+	}
+	return nil
+}
+
+type measure struct {
+	fragments map[string]ast.Definition
+	variables map[string]interface{}
+	depth     int
+}
+
+// selectionCost returns the cost of a selection set, where pageSize is the
+// number of nodes that the field with the selection set may return.
+func (m *measure) selectionCost(set *ast.SelectionSet, pageSize, depth int) int {
+	if set == nil {
+		return 0
+	}
+	cost := 0
+	for _, s := range set.Selections {
+		switch s := s.(type) {
+		case *ast.Field:
+			if depth > m.depth {
+				m.depth = depth
+			}
+			children := m.selectionCost(s.SelectionSet, m.pageSize(s), depth+1)
+			if s.Name.Value == "edges" {
+				children *= pageSize
+			}
+			cost += 1 + children
+		case *ast.InlineFragment:
+			cost += m.selectionCost(s.SelectionSet, pageSize, depth)
+		case *ast.FragmentSpread:
+			if f, ok := m.fragments[s.Name.Value].(*ast.FragmentDefinition); ok {
+				cost += m.selectionCost(f.SelectionSet, pageSize, depth)
+			}
+		}
+	}
+	return cost
+}
+
+// pageSize returns the "first" argument of a field, or the default.
+func (m *measure) pageSize(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := m.variables[v.Name.Value].(int); ok && n > 0 {
+				return n
+			}
+		}
+	}
+	return defaultPageSize
+}
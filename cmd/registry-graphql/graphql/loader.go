@@ -0,0 +1,395 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultPageSize is the number of results returned by collection queries
+// without a "first" argument.
+const defaultPageSize = 50
+
+// batchPageSize is the page size of list requests made for batches.
+const batchPageSize = 1000
+
+// A collection describes how to get and list one kind of registry resource.
+// Resources are returned as GraphQL representations, with names in "id".
+// Requests for unbatched collections are cached but always sent alone.
+type collection struct {
+	kind      string
+	unbatched bool
+	get       func(ctx context.Context, name string) (map[string]interface{}, error)
+	list      func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error)
+}
+
+type listRequest struct {
+	Parent    string
+	Filter    string
+	PageToken string
+	PageSize  int32
+}
+
+// idFields are the filter fields of the IDs that follow collection names in
+// resource names.
+var idFields = map[string]string{
+	"apis":        "api_id",
+	"versions":    "version_id",
+	"specs":       "spec_id",
+	"deployments": "deployment_id",
+	"artifacts":   "artifact_id",
+}
+
+type loaderKey struct{}
+
+// WithLoader returns a context with a loader that batches and caches the
+// registry requests made while resolving one GraphQL request.
+func WithLoader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loaderKey{}, newLoader())
+}
+
+// loaderFor returns the loader of a request. Without one, requests are made
+// as they are resolved.
+func loaderFor(ctx context.Context) *loader {
+	if l, ok := ctx.Value(loaderKey{}).(*loader); ok {
+		return l
+	}
+	return newLoader()
+}
+
+// A loader batches and caches registry requests. Resolvers queue their
+// requests and return thunks, which graphql-go calls after all of the fields
+// at one level of a query have been resolved. The first thunk to be called
+// sends all of the queued requests, grouping gets and lists of resources
+// with the same kind of parent into one list request with a filter.
+type loader struct {
+	mu      sync.Mutex
+	results map[string]*result
+	queue   []*result
+	calls   int             // registry requests, counted for tests
+	deps    map[string]bool // patterns of requested names, for the cache
+
+	limitsChecked bool
+	limitsErr     error
+}
+
+type result struct {
+	collection *collection
+	name       string       // set for gets
+	list       *listRequest // set for lists
+	done       bool
+	value      interface{}
+	err        error
+}
+
+func newLoader() *loader {
+	return &loader{results: make(map[string]*result), deps: make(map[string]bool)}
+}
+
+// dependencies returns the patterns of the names of the resources and
+// collections that were requested, for invalidating cached results.
+func (l *loader) dependencies() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	deps := make([]string, 0, len(l.deps))
+	for d := range l.deps {
+		deps = append(deps, d)
+	}
+	return deps
+}
+
+// get returns a thunk that returns the named resource.
+func (l *loader) get(ctx context.Context, c *collection, name string) func() (interface{}, error) {
+	return l.enqueue(ctx, c.kind+" "+name, &result{collection: c, name: name})
+}
+
+// list returns a thunk that returns a connection with a page of the
+// resources of a parent, as requested by the arguments of a field.
+func (l *loader) list(ctx context.Context, c *collection, parent string, args map[string]interface{}) func() (interface{}, error) {
+	req := &listRequest{Parent: parent}
+	req.Filter, _ = args["filter"].(string)
+	req.PageToken, _ = args["after"].(string)
+	if first, ok := args["first"].(int); ok {
+		req.PageSize = int32(first)
+	}
+	key := fmt.Sprintf("%s %s?filter=%q&after=%q&first=%d", c.kind, parent, req.Filter, req.PageToken, req.PageSize)
+	return l.enqueue(ctx, key, &result{collection: c, list: req})
+}
+
+func (l *loader) enqueue(ctx context.Context, key string, r *result) func() (interface{}, error) {
+	l.mu.Lock()
+	l.deps[r.dependency()] = true
+	if cached, ok := l.results[key]; ok {
+		r = cached
+	} else {
+		l.results[key] = r
+		l.queue = append(l.queue, r)
+	}
+	l.mu.Unlock()
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !r.done {
+			l.dispatch(ctx)
+		}
+		return r.value, r.err
+	}
+}
+
+// dispatch sends the queued requests. It is called with the lock held.
+func (l *loader) dispatch(ctx context.Context) {
+	var keys []string
+	batches := make(map[string][]*result)
+	for _, r := range l.queue {
+		key := r.batchKey()
+		if _, ok := batches[key]; !ok {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], r)
+	}
+	l.queue = nil
+	for _, key := range keys {
+		if batch := batches[key]; batch[0].list != nil {
+			l.loadLists(ctx, batch)
+		} else {
+			l.loadResources(ctx, batch)
+		}
+	}
+}
+
+// batchKey identifies the requests that can be sent together. Gets of
+// revisions and requests for later pages, with wildcard parents or of
+// unbatched collections are sent alone.
+func (r *result) batchKey() string {
+	if r.list != nil {
+		if r.collection.unbatched || r.list.PageToken != "" || strings.Contains(r.list.Parent+"/", "/-/") {
+			return "list " + r.collection.kind + " " + strconv.Quote(r.list.Parent) + " " + strconv.Quote(r.list.PageToken)
+		}
+		return "list " + r.collection.kind + " " + wildcardParent(r.list.Parent) + " " + strconv.Quote(r.list.Filter)
+	}
+	if r.collection.unbatched || strings.Contains(r.name, "@") || strings.Contains(r.name+"/", "/-/") {
+		return "get " + r.collection.kind + " " + r.name
+	}
+	return "get " + r.collection.kind + " " + wildcardParent(parentOf(r.name))
+}
+
+// loadResources gets resources, with one list request if there are several.
+func (l *loader) loadResources(ctx context.Context, batch []*result) {
+	c := batch[0].collection
+	if len(batch) == 1 {
+		r := batch[0]
+		l.calls++
+		r.value, r.err = c.get(ctx, r.name)
+		r.done = true
+		return
+	}
+	clauses := make([]string, len(batch))
+	for i, r := range batch {
+		clauses[i] = "name == " + strconv.Quote(r.name)
+	}
+	req := listRequest{
+		Parent: wildcardParent(parentOf(batch[0].name)),
+		Filter: strings.Join(clauses, " || "),
+	}
+	items, _, err := l.listAll(ctx, c, req, -1)
+	found := make(map[string]map[string]interface{})
+	for _, item := range items {
+		found[item["id"].(string)] = item
+	}
+	for _, r := range batch {
+		if item, ok := found[r.name]; ok {
+			r.value = item
+		} else if err != nil {
+			r.err = err
+		} else {
+			r.err = status.Errorf(codes.NotFound, "%q not found", r.name)
+		}
+		r.done = true
+	}
+}
+
+// loadLists lists the resources of several parents with one list request.
+// If there are too many resources to list them all, each parent is listed
+// alone.
+func (l *loader) loadLists(ctx context.Context, batch []*result) {
+	if len(batch) == 1 {
+		l.loadList(ctx, batch[0])
+		return
+	}
+	c := batch[0].collection
+	var clauses []string
+	seen := make(map[string]bool)
+	limit := 1
+	for _, r := range batch {
+		if clause := parentClause(r.list.Parent); clause != "" && !seen[clause] {
+			seen[clause] = true
+			clauses = append(clauses, "("+clause+")")
+		}
+		limit += pageSize(r.list) + 1
+	}
+	req := listRequest{
+		Parent: wildcardParent(batch[0].list.Parent),
+		Filter: strings.Join(clauses, " || "),
+	}
+	if filter := batch[0].list.Filter; filter != "" && req.Filter != "" {
+		req.Filter = "(" + req.Filter + ") && (" + filter + ")"
+	} else if filter != "" {
+		req.Filter = filter
+	}
+	items, complete, err := l.listAll(ctx, c, req, limit)
+	children := make(map[string][]map[string]interface{})
+	for _, item := range items {
+		parent := parentOf(item["id"].(string))
+		children[parent] = append(children[parent], item)
+	}
+	for _, r := range batch {
+		if err != nil || !complete {
+			l.loadList(ctx, r)
+			continue
+		}
+		// Every resource was listed, so cursors are positions in the
+		// lists of each parent.
+		edges := []map[string]interface{}{}
+		for i, item := range children[r.list.Parent] {
+			edges = append(edges, representationForEdge(item, cursor{Skip: i + 1}))
+		}
+		hasNextPage := len(edges) > pageSize(r.list)
+		if hasNextPage {
+			edges = edges[:pageSize(r.list)]
+		}
+		r.value = connectionForEdges(edges, "", hasNextPage)
+		r.done = true
+	}
+}
+
+// loadList lists a page of the resources of one parent, starting after
+// the cursor in the request's PageToken. One more resource than requested
+// is listed to find whether there is a next page.
+func (l *loader) loadList(ctx context.Context, r *result) {
+	defer func() { r.done = true }()
+	after, err := parseCursor(r.list.PageToken)
+	if err != nil {
+		r.err = err
+		return
+	}
+	req := *r.list
+	req.PageToken = after.Token
+	skip := after.Skip
+	want := pageSize(r.list)
+	edges := []map[string]interface{}{}
+	for len(edges) <= want {
+		req.PageSize = int32(want + 1 - len(edges) + skip)
+		if req.PageSize > batchPageSize {
+			req.PageSize = batchPageSize
+		}
+		l.calls++
+		items, next, err := r.collection.list(ctx, req)
+		if err != nil {
+			r.err = err
+			return
+		}
+		for i, item := range items {
+			if i < skip {
+				continue
+			}
+			c := cursor{Token: req.PageToken, Skip: i + 1}
+			if i == len(items)-1 && next != "" {
+				c = cursor{Token: next}
+			}
+			edges = append(edges, representationForEdge(item, c))
+		}
+		if skip -= len(items); skip < 0 {
+			skip = 0
+		}
+		if next == "" {
+			break
+		}
+		req.PageToken = next
+	}
+	hasNextPage := len(edges) > want
+	if hasNextPage {
+		edges = edges[:want]
+	}
+	r.value = connectionForEdges(edges, r.list.PageToken, hasNextPage)
+}
+
+// listAll lists resources until there are none left or more than limit
+// have been listed, and reports whether there were none left.
+func (l *loader) listAll(ctx context.Context, c *collection, req listRequest, limit int) ([]map[string]interface{}, bool, error) {
+	var all []map[string]interface{}
+	req.PageSize = batchPageSize
+	for {
+		l.calls++
+		items, next, err := c.list(ctx, req)
+		if err != nil {
+			return nil, false, err
+		}
+		all = append(all, items...)
+		if next == "" {
+			return all, true, nil
+		}
+		if limit >= 0 && len(all) > limit {
+			return all, false, nil
+		}
+		req.PageToken = next
+	}
+}
+
+func pageSize(req *listRequest) int {
+	if req.PageSize > 0 {
+		return int(req.PageSize)
+	}
+	return defaultPageSize
+}
+
+// parentOf returns the parent of a resource name.
+func parentOf(name string) string {
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.Join(parts[:len(parts)-2], "/")
+}
+
+// wildcardParent replaces the IDs below the project and location of a name
+// with "-", so that a list request with it includes every similar parent.
+func wildcardParent(name string) string {
+	parts := strings.Split(name, "/")
+	for i := 5; i < len(parts); i += 2 {
+		parts[i] = "-"
+	}
+	return strings.Join(parts, "/")
+}
+
+// parentClause returns a filter that selects the children of a parent
+// from a list request with its wildcard parent, or "" if the parent has no
+// IDs that were replaced.
+func parentClause(name string) string {
+	parts := strings.Split(name, "/")
+	var clauses []string
+	for i := 4; i+1 < len(parts); i += 2 {
+		if field, ok := idFields[parts[i]]; ok {
+			clauses = append(clauses, field+" == "+strconv.Quote(parts[i+1]))
+		}
+	}
+	return strings.Join(clauses, " && ")
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+	"github.com/graphql-go/graphql"
+)
+
+// query runs a query with a new loader and returns the result as JSON along
+// with the number of registry requests that were made.
+// query runs an operation, with the bearer token of a caller if one is given.
+func query(t *testing.T, q string, token ...string) (*graphql.Result, string, int) {
+	t.Helper()
+	ctx := WithLoader(context.Background())
+	if len(token) > 0 {
+		ctx = WithBearerToken(ctx, token[0])
+	}
+	r := graphql.Do(graphql.Params{
+		Schema:        Schema,
+		RequestString: q,
+		Context:       ctx,
+	})
+	b, err := json.Marshal(r.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, string(b), loaderFor(ctx).calls
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	project := "projects/graphql-loader/locations/global"
+	var seeds []seeder.RegistryResource
+	for _, api := range []string{"a", "b", "c"} {
+		for _, version := range []string{"v1", "v2"} {
+			seeds = append(seeds, &rpc.ApiSpec{
+				Name: project + "/apis/" + api + "/versions/" + version + "/specs/openapi",
+			})
+		}
+	}
+	grpctest.SetupRegistry(ctx, t, "graphql-loader", seeds)
+
+	t.Run("batched lists", func(t *testing.T) {
+		r, data, calls := query(t, `{
+		  project(id: "projects/graphql-loader") {
+		    apis(first: 10) { edges { node { id
+		      versions(first: 5) { edges { node { id
+		        specs(first: 5) { edges { node { id } } }
+		      } } }
+		    } } }
+		  }
+		}`)
+		if len(r.Errors) > 0 {
+			t.Fatalf("query returned errors: %+v", r.Errors)
+		}
+		if n := strings.Count(data, "/specs/openapi"); n != 6 {
+			t.Errorf("query returned %d specs, want 6: %s", n, data)
+		}
+		// One request each for the project, its APIs, their versions and their specs.
+		if calls != 4 {
+			t.Errorf("query made %d registry requests, want 4", calls)
+		}
+	})
+
+	t.Run("lists with more results than requested", func(t *testing.T) {
+		r, data, calls := query(t, `{
+		  apis(parent: "projects/graphql-loader") { edges { node {
+		    versions(first: 1) { edges { node { id } } pageInfo { hasNextPage } }
+		  } } }
+		}`)
+		if len(r.Errors) > 0 {
+			t.Fatalf("query returned errors: %+v", r.Errors)
+		}
+		if n := strings.Count(data, `"hasNextPage":true`); n != 3 {
+			t.Errorf("query returned %d connections with next pages, want 3: %s", n, data)
+		}
+		// Cursors are positions in the batch, so the versions are listed together.
+		if calls != 2 {
+			t.Errorf("query made %d registry requests, want 2", calls)
+		}
+	})
+
+	t.Run("batched and cached gets", func(t *testing.T) {
+		r, data, calls := query(t, `{
+		  a: api(id: "projects/graphql-loader/locations/global/apis/a") { id }
+		  b: api(id: "projects/graphql-loader/locations/global/apis/b") { id }
+		  again: api(id: "projects/graphql-loader/locations/global/apis/a") { id }
+		  missing: api(id: "projects/graphql-loader/locations/global/apis/missing") { id }
+		}`)
+		want := map[string]interface{}{
+			"a":       map[string]interface{}{"id": project + "/apis/a"},
+			"b":       map[string]interface{}{"id": project + "/apis/b"},
+			"again":   map[string]interface{}{"id": project + "/apis/a"},
+			"missing": nil,
+		}
+		got := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected results (-want +got):\n%s", diff)
+		}
+		if len(r.Errors) != 1 || !strings.Contains(r.Errors[0].Message, "not found") {
+			t.Errorf("query returned errors %+v, want one not found error", r.Errors)
+		}
+		if calls != 1 {
+			t.Errorf("query made %d registry requests, want 1", calls)
+		}
+	})
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{
+			name:  "within limits",
+			query: `{ projects(first: 5) { edges { node { apis(first: 10) { edges { node { id } } } } } } }`,
+		},
+		{
+			name:  "too complex",
+			query: `{ projects { edges { node { apis { edges { node { versions { edges { node { id } } } } } } } } } }`,
+			err:   "query complexity",
+		},
+		{
+			name: "too complex with fragments",
+			query: `{ projects(first: 100) { edges { node { ...apis } } } }
+			fragment apis on Project { apis(first: 100) { edges { node { id } } } }`,
+			err: "query complexity",
+		},
+		{
+			name: "too deep",
+			query: `{ projects(first: 1) { edges { node { apis(first: 1) { edges { node {
+			  versions(first: 1) { edges { node { specs(first: 1) { edges { node {
+			    artifacts(first: 1) { edges { node { id } } }
+			  } } } } } } } } } } } } }`,
+			err: "query depth",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _, calls := query(t, test.query)
+			if test.err == "" {
+				if len(r.Errors) > 0 {
+					t.Errorf("query returned errors: %+v", r.Errors)
+				}
+				return
+			}
+			if len(r.Errors) == 0 || !strings.Contains(r.Errors[0].Message, test.err) {
+				t.Errorf("query returned errors %+v, want %q", r.Errors, test.err)
+			}
+			if calls != 0 {
+				t.Errorf("query made %d registry requests, want none", calls)
+			}
+		})
+	}
+}
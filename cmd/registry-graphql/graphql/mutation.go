@@ -0,0 +1,574 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+var mutationType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createApi": &graphql.Field{
+				Type:    apiType,
+				Args:    argumentsForCreateMutation("api", apiInputType),
+				Resolve: resolveCreateAPI,
+			},
+			"updateApi": &graphql.Field{
+				Type:    apiType,
+				Args:    argumentsForUpdateMutation("api", apiInputType),
+				Resolve: resolveUpdateAPI,
+			},
+			"deleteApi": &graphql.Field{
+				Type:    graphql.String,
+				Args:    argumentsForDeleteMutation,
+				Resolve: resolveDeleteAPI,
+			},
+			"createVersion": &graphql.Field{
+				Type:    versionType,
+				Args:    argumentsForCreateMutation("version", versionInputType),
+				Resolve: resolveCreateVersion,
+			},
+			"updateVersion": &graphql.Field{
+				Type:    versionType,
+				Args:    argumentsForUpdateMutation("version", versionInputType),
+				Resolve: resolveUpdateVersion,
+			},
+			"deleteVersion": &graphql.Field{
+				Type:    graphql.String,
+				Args:    argumentsForDeleteMutation,
+				Resolve: resolveDeleteVersion,
+			},
+			"createSpec": &graphql.Field{
+				Type:    specType,
+				Args:    argumentsForCreateMutation("spec", specInputType),
+				Resolve: resolveCreateSpec,
+			},
+			"updateSpec": &graphql.Field{
+				Type:    specType,
+				Args:    argumentsForUpdateMutation("spec", specInputType),
+				Resolve: resolveUpdateSpec,
+			},
+			"deleteSpec": &graphql.Field{
+				Type:    graphql.String,
+				Args:    argumentsForDeleteMutation,
+				Resolve: resolveDeleteSpec,
+			},
+			"createDeployment": &graphql.Field{
+				Type:    deploymentType,
+				Args:    argumentsForCreateMutation("deployment", deploymentInputType),
+				Resolve: resolveCreateDeployment,
+			},
+			"updateDeployment": &graphql.Field{
+				Type:    deploymentType,
+				Args:    argumentsForUpdateMutation("deployment", deploymentInputType),
+				Resolve: resolveUpdateDeployment,
+			},
+			"deleteDeployment": &graphql.Field{
+				Type:    graphql.String,
+				Args:    argumentsForDeleteMutation,
+				Resolve: resolveDeleteDeployment,
+			},
+			"createArtifact": &graphql.Field{
+				Type:    artifactType,
+				Args:    argumentsForCreateMutation("artifact", artifactInputType),
+				Resolve: resolveCreateArtifact,
+			},
+			"replaceArtifact": &graphql.Field{
+				Type: artifactType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"artifact": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(artifactInputType),
+					},
+				},
+				Resolve: resolveReplaceArtifact,
+			},
+			"deleteArtifact": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: resolveDeleteArtifact,
+			},
+		},
+	})
+
+// Input types mirror the fields of rpc messages that can be set by callers.
+
+var keyValueInputType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "KeyValueInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"key": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"value": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+// inputFields returns string fields with the specified names, along with
+// labels and annotations, which every resource has.
+func inputFields(names ...string) graphql.InputObjectConfigFieldMap {
+	fields := graphql.InputObjectConfigFieldMap{
+		"labels": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewList(keyValueInputType),
+		},
+		"annotations": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewList(keyValueInputType),
+		},
+	}
+	for _, name := range names {
+		fields[name] = &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		}
+	}
+	return fields
+}
+
+var apiInputType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "APIInput",
+		Fields: inputFields("display_name", "description", "availability",
+			"recommended_version", "recommended_deployment"),
+	},
+)
+
+var versionInputType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name:   "VersionInput",
+		Fields: inputFields("display_name", "description", "state", "primary_spec"),
+	},
+)
+
+// Spec and artifact contents can be set with text in contents or with
+// base64-encoded bytes in contents_base64.
+var specInputType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "SpecInput",
+		Fields: inputFields("filename", "description", "mime_type", "source_uri",
+			"contents", "contents_base64"),
+	},
+)
+
+var deploymentInputType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name: "DeploymentInput",
+		Fields: inputFields("display_name", "description", "api_spec_revision",
+			"endpoint_uri", "external_channel_uri", "intended_audience", "access_guidance"),
+	},
+)
+
+var artifactInputType = graphql.NewInputObject(
+	graphql.InputObjectConfig{
+		Name:   "ArtifactInput",
+		Fields: inputFields("mime_type", "contents", "contents_base64"),
+	},
+)
+
+func argumentsForCreateMutation(key string, input *graphql.InputObject) graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"parent": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"id": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		key: &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(input),
+		},
+	}
+}
+
+func argumentsForUpdateMutation(key string, input *graphql.InputObject) graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"id": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		key: &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(input),
+		},
+		"allow_missing": &graphql.ArgumentConfig{
+			Type: graphql.Boolean,
+		},
+	}
+}
+
+var argumentsForDeleteMutation = graphql.FieldConfigArgument{
+	"id": &graphql.ArgumentConfig{
+		Type: graphql.NewNonNull(graphql.String),
+	},
+	"force": &graphql.ArgumentConfig{
+		Type: graphql.Boolean,
+	},
+}
+
+// messageFromInput sets the fields of a message from an input object and
+// returns a mask of the fields that were set, which is used for updates.
+func messageFromInput(input interface{}, m proto.Message) (*fieldmaskpb.FieldMask, error) {
+	values, ok := input.(map[string]interface{})
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "missing input")
+	}
+	fields := map[string]interface{}{}
+	for name, value := range values {
+		switch name {
+		case "labels", "annotations":
+			pairs := map[string]string{}
+			list, _ := value.([]interface{})
+			for _, item := range list {
+				if pair, ok := item.(map[string]interface{}); ok {
+					k, _ := pair["key"].(string)
+					v, _ := pair["value"].(string)
+					pairs[k] = v
+				}
+			}
+			fields[name] = pairs
+		case "contents":
+			s, _ := value.(string)
+			fields[name] = []byte(s) // encoded as base64, like protojson bytes
+		case "contents_base64":
+			name = "contents"
+			fields[name] = value
+		default:
+			fields[name] = value
+		}
+	}
+	if _, ok := values["contents"]; ok {
+		if _, ok := values["contents_base64"]; ok {
+			return nil, status.Error(codes.InvalidArgument, "only one of contents and contents_base64 can be set")
+		}
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := protojson.Unmarshal(b, m); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid input: %s", err)
+	}
+	mask := &fieldmaskpb.FieldMask{}
+	for name := range fields {
+		mask.Paths = append(mask.Paths, name)
+	}
+	sort.Strings(mask.Paths)
+	return mask, nil
+}
+
+func resolveCreateAPI(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	api := &rpc.Api{}
+	if _, err := messageFromInput(p.Args["api"], api); err != nil {
+		return nil, err
+	}
+	api, err = c.CreateApi(ctx, &rpc.CreateApiRequest{
+		Parent: p.Args["parent"].(string) + "/locations/global",
+		ApiId:  p.Args["id"].(string),
+		Api:    api,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForAPI(api), nil
+}
+
+func resolveUpdateAPI(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	api := &rpc.Api{}
+	mask, err := messageFromInput(p.Args["api"], api)
+	if err != nil {
+		return nil, err
+	}
+	api.Name = p.Args["id"].(string)
+	allowMissing, _ := p.Args["allow_missing"].(bool)
+	api, err = c.UpdateApi(ctx, &rpc.UpdateApiRequest{
+		Api:          api,
+		UpdateMask:   mask,
+		AllowMissing: allowMissing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForAPI(api), nil
+}
+
+func resolveDeleteAPI(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := p.Args["id"].(string)
+	force, _ := p.Args["force"].(bool)
+	if err := c.DeleteApi(ctx, &rpc.DeleteApiRequest{Name: name, Force: force}); err != nil {
+		return nil, err
+	}
+	return name, nil
+}
+
+func resolveCreateVersion(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version := &rpc.ApiVersion{}
+	if _, err := messageFromInput(p.Args["version"], version); err != nil {
+		return nil, err
+	}
+	version, err = c.CreateApiVersion(ctx, &rpc.CreateApiVersionRequest{
+		Parent:       p.Args["parent"].(string),
+		ApiVersionId: p.Args["id"].(string),
+		ApiVersion:   version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForVersion(version), nil
+}
+
+func resolveUpdateVersion(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version := &rpc.ApiVersion{}
+	mask, err := messageFromInput(p.Args["version"], version)
+	if err != nil {
+		return nil, err
+	}
+	version.Name = p.Args["id"].(string)
+	allowMissing, _ := p.Args["allow_missing"].(bool)
+	version, err = c.UpdateApiVersion(ctx, &rpc.UpdateApiVersionRequest{
+		ApiVersion:   version,
+		UpdateMask:   mask,
+		AllowMissing: allowMissing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForVersion(version), nil
+}
+
+func resolveDeleteVersion(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := p.Args["id"].(string)
+	force, _ := p.Args["force"].(bool)
+	if err := c.DeleteApiVersion(ctx, &rpc.DeleteApiVersionRequest{Name: name, Force: force}); err != nil {
+		return nil, err
+	}
+	return name, nil
+}
+
+func resolveCreateSpec(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spec := &rpc.ApiSpec{}
+	if _, err := messageFromInput(p.Args["spec"], spec); err != nil {
+		return nil, err
+	}
+	spec, err = c.CreateApiSpec(ctx, &rpc.CreateApiSpecRequest{
+		Parent:    p.Args["parent"].(string),
+		ApiSpecId: p.Args["id"].(string),
+		ApiSpec:   spec,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForSpec(spec), nil
+}
+
+func resolveUpdateSpec(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spec := &rpc.ApiSpec{}
+	mask, err := messageFromInput(p.Args["spec"], spec)
+	if err != nil {
+		return nil, err
+	}
+	spec.Name = p.Args["id"].(string)
+	allowMissing, _ := p.Args["allow_missing"].(bool)
+	spec, err = c.UpdateApiSpec(ctx, &rpc.UpdateApiSpecRequest{
+		ApiSpec:      spec,
+		UpdateMask:   mask,
+		AllowMissing: allowMissing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForSpec(spec), nil
+}
+
+func resolveDeleteSpec(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := p.Args["id"].(string)
+	force, _ := p.Args["force"].(bool)
+	if err := c.DeleteApiSpec(ctx, &rpc.DeleteApiSpecRequest{Name: name, Force: force}); err != nil {
+		return nil, err
+	}
+	return name, nil
+}
+
+func resolveCreateDeployment(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deployment := &rpc.ApiDeployment{}
+	if _, err := messageFromInput(p.Args["deployment"], deployment); err != nil {
+		return nil, err
+	}
+	deployment, err = c.CreateApiDeployment(ctx, &rpc.CreateApiDeploymentRequest{
+		Parent:          p.Args["parent"].(string),
+		ApiDeploymentId: p.Args["id"].(string),
+		ApiDeployment:   deployment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForDeployment(deployment), nil
+}
+
+func resolveUpdateDeployment(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deployment := &rpc.ApiDeployment{}
+	mask, err := messageFromInput(p.Args["deployment"], deployment)
+	if err != nil {
+		return nil, err
+	}
+	deployment.Name = p.Args["id"].(string)
+	allowMissing, _ := p.Args["allow_missing"].(bool)
+	deployment, err = c.UpdateApiDeployment(ctx, &rpc.UpdateApiDeploymentRequest{
+		ApiDeployment: deployment,
+		UpdateMask:    mask,
+		AllowMissing:  allowMissing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForDeployment(deployment), nil
+}
+
+func resolveDeleteDeployment(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := p.Args["id"].(string)
+	force, _ := p.Args["force"].(bool)
+	if err := c.DeleteApiDeployment(ctx, &rpc.DeleteApiDeploymentRequest{Name: name, Force: force}); err != nil {
+		return nil, err
+	}
+	return name, nil
+}
+
+func resolveCreateArtifact(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	artifact := &rpc.Artifact{}
+	if _, err := messageFromInput(p.Args["artifact"], artifact); err != nil {
+		return nil, err
+	}
+	artifact, err = c.CreateArtifact(ctx, &rpc.CreateArtifactRequest{
+		Parent:     p.Args["parent"].(string),
+		ArtifactId: p.Args["id"].(string),
+		Artifact:   artifact,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForArtifact(artifact), nil
+}
+
+func resolveReplaceArtifact(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	artifact := &rpc.Artifact{}
+	if _, err := messageFromInput(p.Args["artifact"], artifact); err != nil {
+		return nil, err
+	}
+	artifact.Name = p.Args["id"].(string)
+	artifact, err = c.ReplaceArtifact(ctx, &rpc.ReplaceArtifactRequest{
+		Artifact: artifact,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return representationForArtifact(artifact), nil
+}
+
+func resolveDeleteArtifact(p graphql.ResolveParams) (interface{}, error) {
+	ctx := p.Context
+	c, err := registryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := p.Args["id"].(string)
+	if err := c.DeleteArtifact(ctx, &rpc.DeleteArtifactRequest{Name: name}); err != nil {
+		return nil, err
+	}
+	return name, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/rpc"
+	"github.com/google/go-cmp/cmp"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func mutate(t *testing.T, ctx context.Context, mutation string) map[string]interface{} {
+	t.Helper()
+	r := graphql.Do(graphql.Params{
+		Schema:        Schema,
+		RequestString: mutation,
+		Context:       WithBearerToken(ctx, "caller-token"),
+	})
+	if len(r.Errors) > 0 {
+		t.Fatalf("mutation returned errors: %+v", r.Errors)
+	}
+	return r.Data.(map[string]interface{})
+}
+
+func TestMutations(t *testing.T) {
+	ctx := context.Background()
+	client, _ := grpctest.SetupRegistry(ctx, t, "graphql-mutations", nil)
+	api := "projects/graphql-mutations/locations/global/apis/petstore"
+
+	data := mutate(t, ctx, `mutation {
+	  createApi(parent: "projects/graphql-mutations", id: "petstore", api: {
+	    display_name: "Petstore", labels: [{key: "team", value: "pets"}]
+	  }) { id display_name }
+	  createVersion(parent: "projects/graphql-mutations/locations/global/apis/petstore", id: "v1", version: {
+	    state: "production"
+	  }) { id }
+	  createSpec(parent: "projects/graphql-mutations/locations/global/apis/petstore/versions/v1", id: "openapi", spec: {
+	    filename: "openapi.yaml", mime_type: "application/x.openapi;version=3", contents: "openapi: 3.0.0"
+	  }) { id size_bytes }
+	  createDeployment(parent: "projects/graphql-mutations/locations/global/apis/petstore", id: "prod", deployment: {
+	    endpoint_uri: "https://pets.example.com"
+	  }) { id endpoint_uri }
+	  createArtifact(parent: "projects/graphql-mutations/locations/global/apis/petstore", id: "notes", artifact: {
+	    mime_type: "text/plain", contents_base64: "aGVsbG8="
+	  }) { id }
+	}`)
+	want := map[string]interface{}{
+		"createApi":        map[string]interface{}{"id": api, "display_name": "Petstore"},
+		"createVersion":    map[string]interface{}{"id": api + "/versions/v1"},
+		"createSpec":       map[string]interface{}{"id": api + "/versions/v1/specs/openapi", "size_bytes": 14},
+		"createDeployment": map[string]interface{}{"id": api + "/deployments/prod", "endpoint_uri": "https://pets.example.com"},
+		"createArtifact":   map[string]interface{}{"id": api + "/artifacts/notes"},
+	}
+	if diff := cmp.Diff(want, data); diff != "" {
+		t.Errorf("unexpected create results (-want +got):\n%s", diff)
+	}
+
+	// Updates only change the fields that are set.
+	data = mutate(t, ctx, `mutation {
+	  updateApi(id: "projects/graphql-mutations/locations/global/apis/petstore", api: {
+	    description: "All about pets"
+	  }) { display_name description }
+	  replaceArtifact(id: "projects/graphql-mutations/locations/global/apis/petstore/artifacts/notes", artifact: {
+	    mime_type: "text/plain", contents: "goodbye"
+	  }) { id }
+	}`)
+	want = map[string]interface{}{
+		"updateApi":       map[string]interface{}{"display_name": "Petstore", "description": "All about pets"},
+		"replaceArtifact": map[string]interface{}{"id": api + "/artifacts/notes"},
+	}
+	if diff := cmp.Diff(want, data); diff != "" {
+		t.Errorf("unexpected update results (-want +got):\n%s", diff)
+	}
+	got, err := client.GetApi(ctx, &rpc.GetApiRequest{Name: api})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"team": "pets"}, got.Labels); diff != "" {
+		t.Errorf("unexpected labels (-want +got):\n%s", diff)
+	}
+	contents, err := client.GetArtifactContents(ctx, &rpc.GetArtifactContentsRequest{Name: api + "/artifacts/notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents.Data) != "goodbye" {
+		t.Errorf("unexpected artifact contents %q", contents.Data)
+	}
+
+	data = mutate(t, ctx, `mutation {
+	  deleteArtifact(id: "projects/graphql-mutations/locations/global/apis/petstore/artifacts/notes")
+	  deleteApi(id: "projects/graphql-mutations/locations/global/apis/petstore", force: true)
+	}`)
+	want = map[string]interface{}{
+		"deleteArtifact": api + "/artifacts/notes",
+		"deleteApi":      api,
+	}
+	if diff := cmp.Diff(want, data); diff != "" {
+		t.Errorf("unexpected delete results (-want +got):\n%s", diff)
+	}
+	if _, err := client.GetApi(ctx, &rpc.GetApiRequest{Name: api}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetApi() after deleteApi returned %v, want NotFound", err)
+	}
+}
+
+func TestMutationRequests(t *testing.T) {
+	ctx := context.Background()
+	client, _ := grpctest.SetupRegistry(ctx, t, "graphql-mutation-requests", nil)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Handler.ServeHTTP(w, r.WithContext(WithBearerToken(r.Context(), "caller-token")))
+	}))
+	defer s.Close()
+
+	mutation := `mutation { createApi(parent: "projects/graphql-mutation-requests", id: "petstore", api: {}) { id } }`
+	body, _ := json.Marshal(map[string]string{"query": mutation})
+	tests := []struct {
+		desc        string
+		method      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"GET", http.MethodGet, "", "", http.StatusMethodNotAllowed},
+		{"form", http.MethodPost, "application/x-www-form-urlencoded", "query=" + url.QueryEscape(mutation), http.StatusUnsupportedMediaType},
+		{"text", http.MethodPost, "text/plain", string(body), http.StatusUnsupportedMediaType},
+		{"JSON", http.MethodPost, "application/json", string(body), http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, s.URL+"?query="+url.QueryEscape(mutation), strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.want {
+				t.Errorf("%s request returned status %d, want %d", test.desc, resp.StatusCode, test.want)
+			}
+		})
+	}
+
+	// Only the JSON request created the API.
+	if _, err := client.GetApi(ctx, &rpc.GetApiRequest{Name: "projects/graphql-mutation-requests/locations/global/apis/petstore"}); err != nil {
+		t.Errorf("JSON mutation didn't create the API: %s", err)
+	}
+}
+
+func TestMessageFromInput(t *testing.T) {
+	spec := &rpc.ApiSpec{}
+	mask, err := messageFromInput(map[string]interface{}{
+		"filename":    "openapi.yaml",
+		"contents":    "openapi: 3.0.0",
+		"annotations": []interface{}{map[string]interface{}{"key": "owner", "value": "me"}},
+	}, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &rpc.ApiSpec{
+		Filename:    "openapi.yaml",
+		Contents:    []byte("openapi: 3.0.0"),
+		Annotations: map[string]string{"owner": "me"},
+	}
+	if diff := cmp.Diff(want, spec, protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected message (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"annotations", "contents", "filename"}, mask.Paths); diff != "" {
+		t.Errorf("unexpected mask (-want +got):\n%s", diff)
+	}
+
+	_, err = messageFromInput(map[string]interface{}{"contents": "a", "contents_base64": "Yg=="}, &rpc.Artifact{})
+	if err == nil {
+		t.Error("messageFromInput() with contents and contents_base64 succeeded, want error")
+	}
+}
+
+func TestMutationsWithoutCredentials(t *testing.T) {
+	grpctest.SetupRegistry(context.Background(), t, "graphql-anonymous", nil)
+	mutation := `mutation { createApi(parent: "projects/graphql-anonymous", id: "a", api: {}) { id } }`
+	r, _, _ := query(t, mutation)
+	if len(r.Errors) != 1 || r.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Errorf("mutation without a token returned errors %+v, want UNAUTHENTICATED", r.Errors)
+	}
+
+	// Without forwarding, every request uses the active configuration.
+	ForwardCredentials = false
+	defer func() { ForwardCredentials = true }()
+	if r, _, _ := query(t, mutation); len(r.Errors) > 0 {
+		t.Errorf("mutation without forwarding returned errors %+v", r.Errors)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	ctx := WithBearerToken(context.Background(), "caller-token")
+	if !hasBearerToken(ctx) {
+		t.Error("hasBearerToken() returned false for a context with a token")
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if diff := cmp.Diff([]string{"Bearer caller-token"}, md.Get("authorization")); diff != "" {
+		t.Errorf("unexpected authorization metadata (-want +got):\n%s", diff)
+	}
+	if hasBearerToken(context.Background()) {
+		t.Error("hasBearerToken() returned true for a context without a token")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// A NotificationSource provides the change notifications that are sent by
+// the registry server. Subscribe returns a channel of notifications that is
+// closed when its context is done.
+type NotificationSource interface {
+	Subscribe(ctx context.Context) (<-chan *rpc.Notification, error)
+}
+
+// Notifications is the source of notifications for subscriptions. If it is
+// nil, subscriptions fail.
+var Notifications NotificationSource
+
+// subscriberBuffer is the number of notifications that can be queued for a
+// subscriber. Notifications for subscribers with full queues are dropped,
+// unless the subscribers must receive every notification.
+const subscriberBuffer = 100
+
+// A losslessSource is a NotificationSource whose subscribers can receive
+// every notification, even if they fall behind.
+type losslessSource interface {
+	subscribeAll(ctx context.Context) (<-chan *rpc.Notification, error)
+}
+
+// A MemorySource is a NotificationSource that sends the notifications that
+// are published to it to all of its subscribers.
+type MemorySource struct {
+	mu sync.Mutex
+	// subscribers maps queues to whether they receive every notification.
+	subscribers map[chan *rpc.Notification]bool
+}
+
+func NewMemorySource() *MemorySource {
+	return &MemorySource{subscribers: make(map[chan *rpc.Notification]bool)}
+}
+
+func (s *MemorySource) Subscribe(ctx context.Context) (<-chan *rpc.Notification, error) {
+	return s.subscribe(ctx, false)
+}
+
+// subscribeAll is like Subscribe, but Publish waits for the subscriber to
+// receive each notification instead of dropping it. Subscribers must receive
+// from the channel until it is closed.
+func (s *MemorySource) subscribeAll(ctx context.Context) (<-chan *rpc.Notification, error) {
+	return s.subscribe(ctx, true)
+}
+
+func (s *MemorySource) subscribe(ctx context.Context, all bool) (<-chan *rpc.Notification, error) {
+	c := make(chan *rpc.Notification, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[c] = all
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, c)
+		close(c)
+		s.mu.Unlock()
+	}()
+	return c, nil
+}
+
+// Publish sends a notification to the current subscribers.
+func (s *MemorySource) Publish(n *rpc.Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c, all := range s.subscribers {
+		if all {
+			c <- n
+			continue
+		}
+		select {
+		case c <- n:
+		default:
+			log.Printf("Dropped notification for a slow subscriber: %s", n.GetResource())
+		}
+	}
+}
+
+// A PubSubSource receives the notifications that the registry server
+// publishes to Pub/Sub. Each server needs its own Pub/Sub subscription,
+// which is created if it doesn't exist and expires a day after it was last
+// used.
+type PubSubSource struct {
+	*MemorySource
+	subscription *pubsub.Subscription
+}
+
+func NewPubSubSource(ctx context.Context, project, subscription string) (*PubSubSource, error) {
+	client, err := pubsub.NewClient(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	topic, err := client.CreateTopic(ctx, registry.TopicName)
+	if status.Code(err) == codes.AlreadyExists {
+		topic = client.Topic(registry.TopicName)
+	} else if err != nil {
+		return nil, err
+	}
+	sub, err := client.CreateSubscription(ctx, subscription, pubsub.SubscriptionConfig{
+		Topic:            topic,
+		AckDeadline:      10 * time.Second,
+		ExpirationPolicy: 24 * time.Hour,
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		sub = client.Subscription(subscription)
+	} else if err != nil {
+		return nil, err
+	}
+	return &PubSubSource{MemorySource: NewMemorySource(), subscription: sub}, nil
+}
+
+// Receive publishes the notifications that are received from Pub/Sub to
+// subscribers until its context is done.
+func (s *PubSubSource) Receive(ctx context.Context) error {
+	return s.subscription.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		n := &rpc.Notification{}
+		if err := protojson.Unmarshal(msg.Data, n); err != nil {
+			log.Printf("Error in protojson.Unmarshal: %v", err)
+		} else {
+			s.Publish(n)
+		}
+		msg.Ack()
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PersistedQueries holds the documents of automatic persisted queries. If it
+// is nil, persisted queries aren't supported.
+var PersistedQueries *QueryStore
+
+// A QueryStore holds query documents by their SHA-256 hashes, following the
+// automatic persisted queries protocol of Apollo clients:
+// https://www.apollographql.com/docs/apollo-server/performance/apq/
+type QueryStore struct {
+	mu      sync.Mutex
+	queries *lru
+}
+
+// NewQueryStore returns a store of at most maxEntries queries that expire
+// after ttl. Zero values disable the limits.
+func NewQueryStore(maxEntries int, ttl time.Duration) *QueryStore {
+	return &QueryStore{queries: newLRU("persisted_queries", maxEntries, ttl)}
+}
+
+func (s *QueryStore) get(hash string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query, ok := s.queries.get(hash)
+	if !ok {
+		return "", false
+	}
+	return query.(string), true
+}
+
+func (s *QueryStore) put(hash, query string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries.put(hash, query, time.Now())
+}
+
+// A persistedQueryError is returned to clients with the codes of the
+// protocol, which tell them to send their full queries.
+type persistedQueryError struct {
+	message string
+	code    string
+}
+
+func (e *persistedQueryError) Error() string {
+	return e.message
+}
+
+func (e *persistedQueryError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code": e.code,
+	}
+}
+
+// persistedQuery returns the query document of a request with the
+// extensions of a request. Queries with hashes are stored, and requests
+// with only hashes get stored queries.
+func persistedQuery(query string, extensions map[string]interface{}) (string, error) {
+	ext, ok := extensions["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return query, nil
+	}
+	if PersistedQueries == nil {
+		return "", &persistedQueryError{message: "PersistedQueryNotSupported", code: "PERSISTED_QUERY_NOT_SUPPORTED"}
+	}
+	if version, _ := ext["version"].(float64); version != 1 {
+		return "", &persistedQueryError{message: "unsupported persisted query version", code: "INVALID_ARGUMENT"}
+	}
+	hash, _ := ext["sha256Hash"].(string)
+	hash = strings.ToLower(hash)
+	if query == "" {
+		if query, ok := PersistedQueries.get(hash); ok {
+			return query, nil
+		}
+		return "", &persistedQueryError{message: "PersistedQueryNotFound", code: "PERSISTED_QUERY_NOT_FOUND"}
+	}
+	sum := sha256.Sum256([]byte(query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return "", &persistedQueryError{message: "provided sha does not match query", code: "INVALID_ARGUMENT"}
+	}
+	PersistedQueries.put(hash, query)
+	return query, nil
+}
@@ -15,9 +15,9 @@
 package graphql
 
 import (
+	"context"
 	"errors"
 
-	"github.com/apigee/registry/pkg/connection"
 	"github.com/apigee/registry/rpc"
 	"github.com/graphql-go/graphql"
 )
@@ -65,58 +65,50 @@ func representationForProject(project *rpc.Project) map[string]interface{} {
 	}
 }
 
-func resolveProjects(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewAdminClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-	req := &rpc.ListProjectsRequest{}
-	filter, isFound := p.Args["filter"].(string)
-	if isFound {
-		req.Filter = filter
-	}
-	pageToken, isFound := p.Args["after"].(string)
-	if isFound {
-		req.PageToken = pageToken
-	}
-	pageSize, isFound := p.Args["first"].(int)
-	if isFound {
-		req.PageSize = int32(pageSize)
-	} else {
-		pageSize = 50
-	}
-	var response *rpc.ListProjectsResponse
-	edges := []map[string]interface{}{}
-	for len(edges) < pageSize {
-		response, _ = c.GrpcClient().ListProjects(ctx, req)
-		for _, project := range response.GetProjects() {
-			edges = append(edges, representationForEdge(representationForProject(project)))
+var projectCollection = &collection{
+	kind: "projects",
+	get: func(ctx context.Context, name string) (map[string]interface{}, error) {
+		c, err := adminClient(ctx)
+		if err != nil {
+			return nil, err
 		}
-		req.PageToken = response.GetNextPageToken()
-		if req.PageToken == "" {
-			break
+		project, err := c.GetProject(ctx, &rpc.GetProjectRequest{
+			Name: name,
+		})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return connectionForEdgesAndEndCursor(edges, response.GetNextPageToken()), nil
+		return representationForProject(project), nil
+	},
+	list: func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error) {
+		c, err := adminClient(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := c.GrpcClient().ListProjects(ctx, &rpc.ListProjectsRequest{
+			Filter:    req.Filter,
+			PageToken: req.PageToken,
+			PageSize:  req.PageSize,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		var items []map[string]interface{}
+		for _, project := range response.GetProjects() {
+			items = append(items, representationForProject(project))
+		}
+		return items, response.GetNextPageToken(), nil
+	},
+}
+
+func resolveProjects(p graphql.ResolveParams) (interface{}, error) {
+	return loaderFor(p.Context).list(p.Context, projectCollection, "", p.Args), nil
 }
 
 func resolveProject(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewAdminClient(ctx)
-	if err != nil {
-		return nil, err
-	}
 	name, isFound := p.Args["id"].(string)
 	if !isFound {
 		return nil, errors.New("missing id field")
 	}
-	req := &rpc.GetProjectRequest{
-		Name: name,
-	}
-	api, err := c.GetProject(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return representationForProject(api), err
+	return loaderFor(p.Context).get(p.Context, projectCollection, name), nil
 }
@@ -40,6 +40,11 @@ var queryType = graphql.NewObject(
 				Args:    argumentsForParentedCollectionQuery,
 				Resolve: resolveSpecs,
 			},
+			"deployments": &graphql.Field{
+				Type:    connectionType(deploymentType),
+				Args:    argumentsForParentedCollectionQuery,
+				Resolve: resolveDeployments,
+			},
 			"artifacts": &graphql.Field{
 				Type:    connectionType(artifactType),
 				Args:    argumentsForParentedCollectionQuery,
@@ -65,11 +70,23 @@ var queryType = graphql.NewObject(
 				Args:    argumentsForResourceQuery,
 				Resolve: resolveSpec,
 			},
+			"deployment": &graphql.Field{
+				Type:    deploymentType,
+				Args:    argumentsForResourceQuery,
+				Resolve: resolveDeployment,
+			},
 			"artifact": &graphql.Field{
 				Type:    artifactType,
 				Args:    argumentsForResourceQuery,
 				Resolve: resolveArtifact,
 			},
+			"search": &graphql.Field{
+				Type: searchConnectionType,
+				Description: "Resources of all projects that match a query, with highlights of the matching text. " +
+					"Without kinds, every kind of resource is searched.",
+				Args:    argumentsForSearchQuery,
+				Resolve: resolveSearch,
+			},
 		},
 	})
 
@@ -21,6 +21,39 @@ import (
 // Schema is the top-level schema for the Registry service.
 var Schema, _ = graphql.NewSchema(
 	graphql.SchemaConfig{
-		Query: queryType,
+		Query:        queryType,
+		Mutation:     mutationType,
+		Subscription: subscriptionType,
+		Extensions: []graphql.Extension{
+			errorCodes{},
+		},
 	},
 )
+
+func init() {
+	// Operations are checked against limits before their top-level fields
+	// are resolved.
+	for _, t := range []*graphql.Object{queryType, mutationType} {
+		for _, f := range t.Fields() {
+			f.Resolve = limited(f.Resolve)
+		}
+	}
+	// Mutations invalidate cached results, and are only made with the
+	// credentials of callers.
+	for _, f := range mutationType.Fields() {
+		f.Resolve = authenticated(invalidating(f.Resolve))
+	}
+	// Errors are returned with the codes of their gRPC statuses.
+	for _, t := range Schema.TypeMap() {
+		if o, ok := t.(*graphql.Object); ok {
+			for _, f := range o.Fields() {
+				if f.Resolve != nil {
+					f.Resolve = withCodes(f.Resolve)
+				}
+				if f.Subscribe != nil {
+					f.Subscribe = withCodes(f.Subscribe)
+				}
+			}
+		}
+	}
+}
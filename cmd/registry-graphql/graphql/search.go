@@ -0,0 +1,414 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	experimental_rpc "github.com/apigee/registry-experimental/rpc"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SearchService is the experimental Search service that search queries are
+// sent to. If it is nil, resources are searched with registry filters.
+var SearchService experimental_rpc.SearchClient
+
+var searchKindType = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name: "SearchKind",
+		Values: graphql.EnumValueConfigMap{
+			"API": &graphql.EnumValueConfig{
+				Value: "apis",
+			},
+			"VERSION": &graphql.EnumValueConfig{
+				Value: "versions",
+			},
+			"SPEC": &graphql.EnumValueConfig{
+				Value: "specs",
+			},
+			"DEPLOYMENT": &graphql.EnumValueConfig{
+				Value: "deployments",
+			},
+			"ARTIFACT": &graphql.EnumValueConfig{
+				Value: "artifacts",
+			},
+		},
+	},
+)
+
+var searchResultType = graphql.NewUnion(
+	graphql.UnionConfig{
+		Name:  "SearchResult",
+		Types: []*graphql.Object{apiType, versionType, specType, deploymentType, artifactType},
+		ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object {
+			item, _ := p.Value.(map[string]interface{})
+			name, _ := item["id"].(string)
+			switch kindOf(name) {
+			case "apis":
+				return apiType
+			case "versions":
+				return versionType
+			case "specs":
+				return specType
+			case "deployments":
+				return deploymentType
+			case "artifacts":
+				return artifactType
+			}
+			return nil
+		},
+	},
+)
+
+var highlightType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "Highlight",
+		Fields: graphql.Fields{
+			"field": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"snippet": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+	},
+)
+
+var searchConnectionType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "SearchResultConnection",
+		Fields: graphql.Fields{
+			"edges": &graphql.Field{
+				Type: graphql.NewList(
+					graphql.NewObject(
+						graphql.ObjectConfig{
+							Name: "SearchResultEdges",
+							Fields: graphql.Fields{
+								"node": &graphql.Field{
+									Type: searchResultType,
+								},
+								"cursor": &graphql.Field{
+									Type: graphql.NewNonNull(graphql.String),
+								},
+								"highlights": &graphql.Field{
+									Type: graphql.NewList(graphql.NewNonNull(highlightType)),
+								},
+							},
+						},
+					),
+				),
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(pageInfoType),
+			},
+		},
+	},
+)
+
+var argumentsForSearchQuery = graphql.FieldConfigArgument{
+	"query": &graphql.ArgumentConfig{
+		Type: graphql.NewNonNull(graphql.String),
+	},
+	"kinds": &graphql.ArgumentConfig{
+		Type: graphql.NewList(graphql.NewNonNull(searchKindType)),
+	},
+	"after": &graphql.ArgumentConfig{
+		Type: graphql.String,
+	},
+	"first": &graphql.ArgumentConfig{
+		Type: graphql.Int,
+	},
+}
+
+// A searchSource is a list of resources that searches without the Search
+// service look through, with the fields that queries are matched against.
+type searchSource struct {
+	collection *collection
+	parent     string
+	fields     map[string]string // GraphQL fields by filter field
+}
+
+// searchSources are listed in order, across all projects.
+var searchSources = []searchSource{
+	{apiCollection, "projects/-/locations/global", map[string]string{
+		"api_id": "id", "display_name": "display_name", "description": "description"}},
+	{versionCollection, "projects/-/locations/global/apis/-", map[string]string{
+		"version_id": "id", "display_name": "display_name", "description": "description"}},
+	{specCollection, "projects/-/locations/global/apis/-/versions/-", map[string]string{
+		"spec_id": "id", "filename": "filename", "description": "description"}},
+	{deploymentCollection, "projects/-/locations/global/apis/-", map[string]string{
+		"deployment_id": "id", "display_name": "display_name", "description": "description"}},
+	{artifactCollection, "projects/-/locations/global", map[string]string{"artifact_id": "id"}},
+	{artifactCollection, "projects/-/locations/global/apis/-", map[string]string{"artifact_id": "id"}},
+	{artifactCollection, "projects/-/locations/global/apis/-/versions/-", map[string]string{"artifact_id": "id"}},
+	{artifactCollection, "projects/-/locations/global/apis/-/versions/-/specs/-", map[string]string{"artifact_id": "id"}},
+	{artifactCollection, "projects/-/locations/global/apis/-/deployments/-", map[string]string{"artifact_id": "id"}},
+}
+
+// filter returns a filter that matches resources with fields that contain
+// a query, ignoring case.
+func (s searchSource) filter(pattern string) string {
+	var clauses []string
+	for field := range s.fields {
+		clauses = append(clauses, field+".matches("+strconv.Quote(pattern)+")")
+	}
+	// Sort the clauses so that identical searches make identical requests.
+	sort.Strings(clauses)
+	return strings.Join(clauses, " || ")
+}
+
+// highlights returns the matches of a pattern in the fields of a resource.
+func (s searchSource) highlights(item map[string]interface{}, re *regexp.Regexp) []map[string]interface{} {
+	var fields []string
+	for _, field := range s.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	highlights := []map[string]interface{}{}
+	for _, field := range fields {
+		text, _ := item[field].(string)
+		if field == "id" {
+			text = text[strings.LastIndex(text, "/")+1:]
+		}
+		if loc := re.FindStringIndex(text); loc != nil {
+			highlights = append(highlights, representationForHighlight(field, snippet(text, loc)))
+		}
+	}
+	return highlights
+}
+
+func representationForHighlight(field, snippet string) map[string]interface{} {
+	return map[string]interface{}{
+		"field":   field,
+		"snippet": snippet,
+	}
+}
+
+// snippetContext is the number of characters kept on each side of a match.
+const snippetContext = 40
+
+// snippet returns the text around a match.
+func snippet(text string, loc []int) string {
+	start, end := loc[0]-snippetContext, loc[1]+snippetContext
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(text) {
+		end, suffix = len(text), ""
+	}
+	// Don't split multibyte characters.
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	return prefix + text[start:end] + suffix
+}
+
+// kindOf returns the collection of a resource name, such as "apis".
+func kindOf(name string) string {
+	name, _, _ = strings.Cut(name, "@")
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
+
+// searchKinds returns the kinds of resources that a search includes.
+func searchKinds(p graphql.ResolveParams) map[string]bool {
+	kinds := make(map[string]bool)
+	list, _ := p.Args["kinds"].([]interface{})
+	for _, k := range list {
+		if k, ok := k.(string); ok {
+			kinds[k] = true
+		}
+	}
+	if len(kinds) == 0 {
+		for _, v := range searchKindType.Values() {
+			kinds[v.Value.(string)] = true
+		}
+	}
+	return kinds
+}
+
+func resolveSearch(p graphql.ResolveParams) (interface{}, error) {
+	q, _ := p.Args["query"].(string)
+	if strings.TrimSpace(q) == "" {
+		return nil, status.Error(codes.InvalidArgument, "query must not be empty")
+	}
+	after, _ := p.Args["after"].(string)
+	c, err := parseCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	want := defaultPageSize
+	if first, ok := p.Args["first"].(int); ok && first > 0 {
+		want = first
+	}
+	if SearchService != nil {
+		return searchService(p.Context, q, searchKinds(p), c, after, want)
+	}
+	return searchRegistry(p.Context, q, searchKinds(p), c, after, want)
+}
+
+// searchRegistry lists the resources of each kind that have fields that
+// contain a query. Cursors are positions in the list of a source.
+func searchRegistry(ctx context.Context, q string, kinds map[string]bool, after cursor, token string, want int) (interface{}, error) {
+	pattern := "(?i)" + regexp.QuoteMeta(q)
+	re := regexp.MustCompile(pattern)
+	l := loaderFor(ctx)
+	edges := []map[string]interface{}{}
+	for i := after.Source; i < len(searchSources) && len(edges) <= want; i++ {
+		s := searchSources[i]
+		if !kinds[s.collection.kind] {
+			continue
+		}
+		r := &result{collection: s.collection, list: &listRequest{
+			Parent:   s.parent,
+			Filter:   s.filter(pattern),
+			PageSize: int32(want + 1 - len(edges)),
+		}}
+		if i == after.Source && (after.Token != "" || after.Skip > 0) {
+			r.list.PageToken = cursor{Token: after.Token, Skip: after.Skip}.String()
+		}
+		l.mu.Lock()
+		l.deps[r.dependency()] = true
+		l.loadList(ctx, r)
+		l.mu.Unlock()
+		if r.err != nil {
+			return nil, r.err
+		}
+		for _, edge := range r.value.(map[string]interface{})["edges"].([]map[string]interface{}) {
+			c, _ := parseCursor(edge["cursor"].(string))
+			c.Source = i
+			item := edge["node"].(map[string]interface{})
+			edges = append(edges, representationForSearchEdge(item, c, s.highlights(item, re)))
+		}
+	}
+	hasNextPage := len(edges) > want
+	if hasNextPage {
+		edges = edges[:want]
+	}
+	return connectionForEdges(edges, token, hasNextPage), nil
+}
+
+// searchService sends a query to the Search service and gets the resources
+// that it finds. Results for resources that no longer exist are dropped.
+func searchService(ctx context.Context, q string, kinds map[string]bool, after cursor, token string, want int) (interface{}, error) {
+	type match struct {
+		name       string
+		cursor     cursor
+		highlights []map[string]interface{}
+	}
+	var matches []*match
+	req := &experimental_rpc.QueryRequest{Q: q, PageToken: after.Token}
+	skip := after.Skip
+	for len(matches) <= want {
+		req.PageSize = int32(want + 1 - len(matches) + skip)
+		response, err := SearchService.Query(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		// Results for one resource are combined.
+		var page []*match
+		byName := make(map[string]*match)
+		for _, result := range response.GetResults() {
+			m, ok := byName[result.GetKey()]
+			if !ok {
+				m = &match{name: result.GetKey(), highlights: []map[string]interface{}{}}
+				byName[m.name] = m
+				page = append(page, m)
+			}
+			if result.GetExcerpt() != "" {
+				m.highlights = append(m.highlights, representationForHighlight("contents", result.GetExcerpt()))
+			}
+		}
+		next := response.GetNextPageToken()
+		for i, m := range page {
+			if i < skip || !kinds[kindOf(m.name)] {
+				continue
+			}
+			m.cursor = cursor{Token: req.PageToken, Skip: i + 1}
+			if i == len(page)-1 && next != "" {
+				m.cursor = cursor{Token: next}
+			}
+			matches = append(matches, m)
+		}
+		if skip -= len(page); skip < 0 {
+			skip = 0
+		}
+		if next == "" {
+			break
+		}
+		req.PageToken = next
+	}
+	hasNextPage := len(matches) > want
+	if hasNextPage {
+		matches = matches[:want]
+	}
+
+	// Resources are loaded together.
+	l := loaderFor(ctx)
+	thunks := make([]func() (interface{}, error), len(matches))
+	for i, m := range matches {
+		thunks[i] = l.get(ctx, collectionForKind(kindOf(m.name)), m.name)
+	}
+	return func() (interface{}, error) {
+		edges := []map[string]interface{}{}
+		for i, m := range matches {
+			item, err := thunks[i]()
+			// The index covers every project, so skip results that were
+			// deleted or that the caller can't read.
+			if code := status.Code(err); code == codes.NotFound || code == codes.PermissionDenied {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			edges = append(edges, representationForSearchEdge(item, m.cursor, m.highlights))
+		}
+		return connectionForEdges(edges, token, hasNextPage), nil
+	}, nil
+}
+
+func collectionForKind(kind string) *collection {
+	switch kind {
+	case "apis":
+		return apiCollection
+	case "versions":
+		return versionCollection
+	case "specs":
+		return specCollection
+	case "deployments":
+		return deploymentCollection
+	default:
+		return artifactCollection
+	}
+}
+
+func representationForSearchEdge(node interface{}, c cursor, highlights []map[string]interface{}) map[string]interface{} {
+	edge := representationForEdge(node, c)
+	edge["highlights"] = highlights
+	return edge
+}
@@ -0,0 +1,256 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	longrunning "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	experimental_rpc "github.com/apigee/registry-experimental/rpc"
+	"github.com/apigee/registry/gapic"
+	"github.com/apigee/registry/pkg/connection"
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func seedSearch(t *testing.T) {
+	t.Helper()
+	api := "projects/graphql-search/locations/global/apis/zebra"
+	seeds := []seeder.RegistryResource{
+		&rpc.Api{
+			Name:        api,
+			DisplayName: "Zebra Store",
+		},
+		&rpc.ApiVersion{
+			Name:        api + "/versions/v1",
+			Description: "The first version of the ZEBRA API",
+		},
+		&rpc.ApiSpec{
+			Name:     api + "/versions/v1/specs/openapi",
+			Filename: "zebra.yaml",
+		},
+		&rpc.ApiDeployment{
+			Name:        api + "/deployments/prod",
+			DisplayName: "Production",
+		},
+		&rpc.Artifact{
+			Name: api + "/artifacts/zebra-notes",
+		},
+	}
+	grpctest.SetupRegistry(context.Background(), t, "graphql-search", seeds)
+}
+
+func TestSearchRegistry(t *testing.T) {
+	seedSearch(t)
+
+	r, data, _ := query(t, `{
+	  search(query: "zebra") {
+	    edges {
+	      node {
+	        __typename
+	        ... on API { id }
+	        ... on Version { id }
+	        ... on Spec { id }
+	        ... on Deployment { id }
+	        ... on Artifact { id }
+	      }
+	      highlights { field snippet }
+	    }
+	    pageInfo { hasNextPage }
+	  }
+	}`)
+	if len(r.Errors) > 0 {
+		t.Fatalf("query returned errors: %v", r.Errors)
+	}
+	want := `{"search": {"edges": [
+	  {"node": {"__typename": "API", "id": "projects/graphql-search/locations/global/apis/zebra"},
+	   "highlights": [{"field": "display_name", "snippet": "Zebra Store"}, {"field": "id", "snippet": "zebra"}]},
+	  {"node": {"__typename": "Version", "id": "projects/graphql-search/locations/global/apis/zebra/versions/v1"},
+	   "highlights": [{"field": "description", "snippet": "The first version of the ZEBRA API"}]},
+	  {"node": {"__typename": "Spec", "id": "projects/graphql-search/locations/global/apis/zebra/versions/v1/specs/openapi"},
+	   "highlights": [{"field": "filename", "snippet": "zebra.yaml"}]},
+	  {"node": {"__typename": "Artifact", "id": "projects/graphql-search/locations/global/apis/zebra/artifacts/zebra-notes"},
+	   "highlights": [{"field": "id", "snippet": "zebra-notes"}]}
+	], "pageInfo": {"hasNextPage": false}}}`
+	if diff := cmp.Diff(unmarshal(t, want), unmarshal(t, data)); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+
+	// Results can be restricted to kinds.
+	_, data, _ = query(t, `{ search(query: "ZEBRA", kinds: [SPEC, ARTIFACT]) { edges { node { ... on Spec { id } ... on Artifact { id } } } } }`)
+	want = `{"search": {"edges": [
+	  {"node": {"id": "projects/graphql-search/locations/global/apis/zebra/versions/v1/specs/openapi"}},
+	  {"node": {"id": "projects/graphql-search/locations/global/apis/zebra/artifacts/zebra-notes"}}
+	]}}`
+	if diff := cmp.Diff(unmarshal(t, want), unmarshal(t, data)); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+
+	// Pages continue across kinds.
+	var ids []string
+	after := ""
+	for page := 0; page < 4; page++ {
+		r, data, _ := query(t, fmt.Sprintf(`{ search(query: "zebra", first: 3, after: %q) {
+		  edges { node { ... on API { id } ... on Version { id } ... on Spec { id } ... on Artifact { id } } }
+		  pageInfo { hasNextPage endCursor }
+		} }`, after))
+		if len(r.Errors) > 0 {
+			t.Fatalf("query returned errors: %v", r.Errors)
+		}
+		var result struct {
+			Search struct {
+				Edges []struct {
+					Node struct{ ID string }
+				}
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   string
+				}
+			}
+		}
+		if err := json.Unmarshal([]byte(data), &result); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range result.Search.Edges {
+			ids = append(ids, e.Node.ID)
+		}
+		if !result.Search.PageInfo.HasNextPage {
+			break
+		}
+		after = result.Search.PageInfo.EndCursor
+	}
+	if len(ids) != 4 {
+		t.Errorf("paginated search returned %v, want 4 results", ids)
+	}
+
+	// Searches need queries.
+	r, _, _ = query(t, `{ search(query: " ") { edges { cursor } } }`)
+	if len(r.Errors) != 1 || r.Errors[0].Message != "query must not be empty" {
+		t.Errorf("search with an empty query returned errors %v", r.Errors)
+	}
+}
+
+// fakeSearch is a Search service with fixed results.
+type fakeSearch struct {
+	results []*experimental_rpc.QueryResponse_Result
+	queries []string
+}
+
+func (s *fakeSearch) Index(ctx context.Context, req *experimental_rpc.IndexRequest, opts ...grpc.CallOption) (*longrunning.Operation, error) {
+	return nil, nil
+}
+
+func (s *fakeSearch) Query(ctx context.Context, req *experimental_rpc.QueryRequest, opts ...grpc.CallOption) (*experimental_rpc.QueryResponse, error) {
+	s.queries = append(s.queries, req.Q)
+	return &experimental_rpc.QueryResponse{Results: s.results}, nil
+}
+
+func TestSearchService(t *testing.T) {
+	seedSearch(t)
+	spec := "projects/graphql-search/locations/global/apis/zebra/versions/v1/specs/openapi"
+	fake := &fakeSearch{results: []*experimental_rpc.QueryResponse_Result{
+		{Key: spec, Excerpt: "paths: /zebras"},
+		{Key: spec, Excerpt: "Zebra: type: object"},
+		{Key: "projects/graphql-search/locations/global/apis/zebra/versions/v1/specs/deleted", Excerpt: "zebras"},
+		{Key: "projects/graphql-search-private/locations/global/apis/zebra/versions/v1/specs/openapi", Excerpt: "zebras"},
+	}}
+	SearchService = fake
+	defer func() { SearchService = nil }()
+	denyProject(t, "projects/graphql-search-private")
+
+	r, data, _ := query(t, `{
+	  search(query: "zebra") {
+	    edges { node { ... on Spec { id filename } } highlights { field snippet } }
+	  }
+	}`)
+	if len(r.Errors) > 0 {
+		t.Fatalf("query returned errors: %v", r.Errors)
+	}
+	want := `{"search": {"edges": [
+	  {"node": {"id": "projects/graphql-search/locations/global/apis/zebra/versions/v1/specs/openapi", "filename": "zebra.yaml"},
+	   "highlights": [{"field": "contents", "snippet": "paths: /zebras"}, {"field": "contents", "snippet": "Zebra: type: object"}]}
+	]}}`
+	if diff := cmp.Diff(unmarshal(t, want), unmarshal(t, data)); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"zebra"}, fake.queries); diff != "" {
+		t.Errorf("unexpected queries (-want +got):\n%s", diff)
+	}
+
+	_, data, _ = query(t, `{ search(query: "zebra", kinds: [API]) { edges { cursor } } }`)
+	if diff := cmp.Diff(unmarshal(t, `{"search": {"edges": []}}`), unmarshal(t, data)); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+}
+
+// denyProject makes the registry client of requests without bearer tokens
+// fail requests for the resources of a project with PermissionDenied.
+func denyProject(t *testing.T, project string) {
+	t.Helper()
+	config, err := connection.ActiveConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied := func(name string) bool {
+		return strings.HasPrefix(name+"/", project+"/")
+	}
+	deny := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if r, ok := req.(interface{ GetName() string }); ok && denied(r.GetName()) {
+			return status.Errorf(codes.PermissionDenied, "permission denied for %s", r.GetName())
+		}
+		if r, ok := req.(interface{ GetParent() string }); ok && denied(r.GetParent()) {
+			return status.Errorf(codes.PermissionDenied, "permission denied for %s", r.GetParent())
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	client, err := gapic.NewRegistryClient(context.Background(),
+		option.WithEndpoint(config.Address),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithGRPCDialOption(grpc.WithUnaryInterceptor(deny)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients.Lock()
+	saved := clients.registry
+	clients.registry = client
+	clients.Unlock()
+	t.Cleanup(func() {
+		clients.Lock()
+		clients.registry = saved
+		clients.Unlock()
+		client.Close()
+	})
+}
+
+func unmarshal(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
@@ -15,9 +15,10 @@
 package graphql
 
 import (
+	"context"
 	"errors"
+	"strings"
 
-	"github.com/apigee/registry/pkg/connection"
 	"github.com/apigee/registry/rpc"
 	"github.com/graphql-go/graphql"
 )
@@ -32,6 +33,9 @@ var specType = graphql.NewObject(
 			"display_name": &graphql.Field{
 				Type: graphql.String,
 			},
+			"filename": &graphql.Field{
+				Type: graphql.String,
+			},
 			"description": &graphql.Field{
 				Type: graphql.String,
 			},
@@ -50,6 +54,26 @@ var specType = graphql.NewObject(
 			"revision_id": &graphql.Field{
 				Type: graphql.String,
 			},
+			"labels": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
+			"annotations": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
+			"contents": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Uncompressed contents, or null for zip archives and other binary contents.",
+				Resolve:     resolveContents(specContentsCollection, textContents),
+			},
+			"contents_base64": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: resolveContents(specContentsCollection, base64Contents),
+			},
+			"files": &graphql.Field{
+				Type:        graphql.NewList(fileType),
+				Description: "Files of zip archives.",
+				Resolve:     resolveContents(specContentsCollection, zipFiles),
+			},
 			"artifacts": &graphql.Field{
 				Type:    connectionType(artifactType),
 				Args:    argumentsForCollectionQuery,
@@ -58,6 +82,9 @@ var specType = graphql.NewObject(
 			"created": &graphql.Field{
 				Type: timestampType,
 			},
+			"revision_created": &graphql.Field{
+				Type: timestampType,
+			},
 			"updated": &graphql.Field{
 				Type: timestampType,
 			},
@@ -65,76 +92,110 @@ var specType = graphql.NewObject(
 	},
 )
 
+func init() {
+	// Revisions are specs, so this field can't be in the literal.
+	specType.AddFieldConfig("revisions", &graphql.Field{
+		Type:    connectionType(specType),
+		Args:    argumentsForCollectionQuery,
+		Resolve: resolveSpecRevisions,
+	})
+}
+
 func representationForSpec(spec *rpc.ApiSpec) map[string]interface{} {
 	result := map[string]interface{}{
-		"id":          spec.Name,
-		"filename":    spec.Filename,
-		"description": spec.Description,
-		"mime_type":   spec.MimeType,
-		"size_bytes":  spec.SizeBytes,
-		"hash":        spec.Hash,
-		"source_uri":  spec.SourceUri,
-		"revision_id": spec.RevisionId,
-		"created":     representationForTimestamp(spec.CreateTime),
-		"updated":     representationForTimestamp(spec.RevisionUpdateTime),
+		"id":               spec.Name,
+		"filename":         spec.Filename,
+		"description":      spec.Description,
+		"mime_type":        spec.MimeType,
+		"size_bytes":       spec.SizeBytes,
+		"hash":             spec.Hash,
+		"source_uri":       spec.SourceUri,
+		"revision_id":      spec.RevisionId,
+		"labels":           representationForMap(spec.Labels),
+		"annotations":      representationForMap(spec.Annotations),
+		"created":          representationForTimestamp(spec.CreateTime),
+		"revision_created": representationForTimestamp(spec.RevisionCreateTime),
+		"updated":          representationForTimestamp(spec.RevisionUpdateTime),
 	}
 	return result
 }
 
-func resolveSpecs(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewRegistryClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-	req := &rpc.ListApiSpecsRequest{
-		Parent: getParentFromParams(p),
-	}
-	filter, isFound := p.Args["filter"].(string)
-	if isFound {
-		req.Filter = filter
-	}
-	pageToken, isFound := p.Args["after"].(string)
-	if isFound {
-		req.PageToken = pageToken
-	}
-	pageSize, isFound := p.Args["first"].(int)
-	if isFound {
-		req.PageSize = int32(pageSize)
-	} else {
-		pageSize = 50
-	}
-	var response *rpc.ListApiSpecsResponse
-	edges := []map[string]interface{}{}
-	for len(edges) < pageSize {
-		response, _ = c.GrpcClient().ListApiSpecs(ctx, req)
-		for _, spec := range response.GetApiSpecs() {
-			edges = append(edges, representationForEdge(representationForSpec(spec)))
+var specCollection = &collection{
+	kind: "specs",
+	get: func(ctx context.Context, name string) (map[string]interface{}, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, err
 		}
-		req.PageToken = response.GetNextPageToken()
-		if req.PageToken == "" {
-			break
+		spec, err := c.GetApiSpec(ctx, &rpc.GetApiSpecRequest{
+			Name: name,
+		})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return connectionForEdgesAndEndCursor(edges, response.GetNextPageToken()), nil
+		return representationForSpec(spec), nil
+	},
+	list: func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := c.GrpcClient().ListApiSpecs(ctx, &rpc.ListApiSpecsRequest{
+			Parent:    req.Parent,
+			Filter:    req.Filter,
+			PageToken: req.PageToken,
+			PageSize:  req.PageSize,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		var items []map[string]interface{}
+		for _, spec := range response.GetApiSpecs() {
+			items = append(items, representationForSpec(spec))
+		}
+		return items, response.GetNextPageToken(), nil
+	},
+}
+
+func resolveSpecs(p graphql.ResolveParams) (interface{}, error) {
+	return loaderFor(p.Context).list(p.Context, specCollection, getParentFromParams(p), p.Args), nil
 }
 
 func resolveSpec(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewRegistryClient(ctx)
-	if err != nil {
-		return nil, err
-	}
 	name, isFound := p.Args["id"].(string)
 	if !isFound {
 		return nil, errors.New("missing id field")
 	}
-	req := &rpc.GetApiSpecRequest{
-		Name: name,
-	}
-	spec, err := c.GetApiSpec(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return representationForSpec(spec), err
+	return loaderFor(p.Context).get(p.Context, specCollection, name), nil
+}
+
+var specRevisionCollection = &collection{
+	kind:      "spec revisions",
+	unbatched: true,
+	list: func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := c.GrpcClient().ListApiSpecRevisions(ctx, &rpc.ListApiSpecRevisionsRequest{
+			Name:      req.Parent,
+			Filter:    req.Filter,
+			PageToken: req.PageToken,
+			PageSize:  req.PageSize,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		var items []map[string]interface{}
+		for _, spec := range response.GetApiSpecs() {
+			items = append(items, representationForSpec(spec))
+		}
+		return items, response.GetNextPageToken(), nil
+	},
+}
+
+func resolveSpecRevisions(p graphql.ResolveParams) (interface{}, error) {
+	name, _ := p.Source.(map[string]interface{})["id"].(string)
+	name, _, _ = strings.Cut(name, "@")
+	return loaderFor(p.Context).list(p.Context, specRevisionCollection, name, p.Args), nil
 }
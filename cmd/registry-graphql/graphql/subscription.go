@@ -0,0 +1,240 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apigee/registry/rpc"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var changeType = graphql.NewEnum(
+	graphql.EnumConfig{
+		Name: "Change",
+		Values: graphql.EnumValueConfigMap{
+			"CREATED": &graphql.EnumValueConfig{
+				Value: rpc.Notification_CREATED.String(),
+			},
+			"UPDATED": &graphql.EnumValueConfig{
+				Value: rpc.Notification_UPDATED.String(),
+			},
+			"DELETED": &graphql.EnumValueConfig{
+				Value: rpc.Notification_DELETED.String(),
+			},
+		},
+	},
+)
+
+var resourceChangeType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "ResourceChange",
+		Fields: graphql.Fields{
+			"change": &graphql.Field{
+				Type: changeType,
+			},
+			"resource": &graphql.Field{
+				Type: graphql.String,
+			},
+			"change_time": &graphql.Field{
+				Type: timestampType,
+			},
+		},
+	},
+)
+
+var subscriptionType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"resourceChanged": &graphql.Field{
+				Type: resourceChangeType,
+				Description: "Changes to resources with names that match a pattern, " +
+					"where IDs can be \"-\" to match any ID. Without a pattern, all changes are sent.",
+				Args: graphql.FieldConfigArgument{
+					"pattern": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+				},
+				Subscribe: subscribeResourceChanged,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	},
+)
+
+func representationForNotification(n *rpc.Notification) map[string]interface{} {
+	return map[string]interface{}{
+		"change":      n.Change.String(),
+		"resource":    n.Resource,
+		"change_time": representationForTimestamp(n.ChangeTime),
+	}
+}
+
+// subscribeResourceChanged returns a channel of the matching notifications
+// about resources that the caller can read, which is closed when the
+// subscription ends.
+func subscribeResourceChanged(p graphql.ResolveParams) (interface{}, error) {
+	if Notifications == nil {
+		return nil, status.Error(codes.Unimplemented, "subscriptions are not enabled")
+	}
+	pattern, _ := p.Args["pattern"].(string)
+	notifications, err := Notifications.Subscribe(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan interface{})
+	go func() {
+		defer close(events)
+		for n := range notifications {
+			if !matchesPattern(n.Resource, pattern) || !readable(p.Context, n) {
+				continue
+			}
+			select {
+			case events <- representationForNotification(n):
+			case <-p.Context.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// readabilityTTL is how long the readability of a resource is remembered
+// for a caller, so that a change is checked once for all of the caller's
+// subscriptions instead of once for each of them.
+const readabilityTTL = 5 * time.Second
+
+type readabilityCheck struct {
+	done     chan struct{}
+	readable bool
+	checked  time.Time
+}
+
+var readability = struct {
+	sync.Mutex
+	checks    map[string]*readabilityCheck
+	lastSweep time.Time
+}{checks: make(map[string]*readabilityCheck)}
+
+// readable reports whether the caller can read the resource of a
+// notification, so that subscribers only learn of changes that they could
+// query. Results are shared by concurrent and recent checks with the same
+// bearer token.
+func readable(ctx context.Context, n *rpc.Notification) bool {
+	name, _, _ := strings.Cut(n.Resource, "@")
+	token, _ := ctx.Value(bearerTokenKey{}).(string)
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:]) + " " + strconv.FormatBool(n.Change == rpc.Notification_DELETED) + " " + name
+	for {
+		readability.Lock()
+		now := time.Now()
+		if now.Sub(readability.lastSweep) > readabilityTTL {
+			readability.lastSweep = now
+			for k, c := range readability.checks {
+				if !c.checked.IsZero() && now.Sub(c.checked) > readabilityTTL {
+					delete(readability.checks, k)
+				}
+			}
+		}
+		c, ok := readability.checks[key]
+		if ok && (c.checked.IsZero() || now.Sub(c.checked) <= readabilityTTL) {
+			readability.Unlock()
+			cacheRequests.WithLabelValues("readability", "hit").Inc()
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				return false
+			}
+			if c.checked.IsZero() {
+				// The check was abandoned, so make another.
+				continue
+			}
+			return c.readable
+		}
+		c = &readabilityCheck{done: make(chan struct{})}
+		readability.checks[key] = c
+		readability.Unlock()
+		cacheRequests.WithLabelValues("readability", "miss").Inc()
+
+		result := checkReadable(ctx, name, n.Change)
+		readability.Lock()
+		if ctx.Err() != nil {
+			// A canceled check says nothing about the resource.
+			delete(readability.checks, key)
+		} else {
+			c.readable = result
+			c.checked = time.Now()
+		}
+		readability.Unlock()
+		close(c.done)
+		return result
+	}
+}
+
+// checkReadable asks the registry whether the caller can read a resource.
+// Deleted resources are checked by listing their collection in their
+// parent. Resources that can't be checked aren't readable.
+func checkReadable(ctx context.Context, name string, change rpc.Notification_Change) bool {
+	c := projectCollection
+	if kind := kindOf(name); kind != "projects" {
+		c = collectionForKind(kind)
+	}
+	if change != rpc.Notification_DELETED {
+		_, err := c.get(ctx, name)
+		return err == nil
+	}
+	parent := parentOf(name)
+	if parent == "" {
+		return false
+	}
+	_, _, err := c.list(ctx, listRequest{
+		Parent:   parent,
+		Filter:   "name == " + strconv.Quote(name),
+		PageSize: 1,
+	})
+	return err == nil
+}
+
+// matchesPattern reports whether a resource name matches a pattern, which
+// is a name with IDs that can be "-". Revisions match the names of their
+// resources.
+func matchesPattern(name, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	name, _, _ = strings.Cut(name, "@")
+	names := strings.Split(name, "/")
+	patterns := strings.Split(pattern, "/")
+	if len(names) != len(patterns) {
+		return false
+	}
+	for i := range names {
+		if names[i] != patterns[i] && (i%2 == 0 || patterns[i] != "-") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,250 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apigee/registry/pkg/connection/grpctest"
+	"github.com/apigee/registry/rpc"
+	"github.com/apigee/registry/server/registry/test/seeder"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMatchesPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    bool
+	}{
+		{"projects/p/locations/global/apis/a", "", true},
+		{"projects/p/locations/global/apis/a", "projects/p/locations/global/apis/a", true},
+		{"projects/p/locations/global/apis/a", "projects/p/locations/global/apis/-", true},
+		{"projects/p/locations/global/apis/a", "projects/-/locations/global/apis/-", true},
+		{"projects/p/locations/global/apis/a", "projects/p/locations/global/apis/b", false},
+		{"projects/p/locations/global/apis/a", "projects/p/locations/global/-/a", false},
+		{"projects/p/locations/global/apis/a/versions/v1", "projects/p/locations/global/apis/-", false},
+		{"projects/p/locations/global/apis/a/versions/v1/specs/s@123", "projects/p/locations/global/apis/a/versions/-/specs/-", true},
+	}
+	for _, test := range tests {
+		if got := matchesPattern(test.name, test.pattern); got != test.want {
+			t.Errorf("matchesPattern(%q, %q) = %t, want %t", test.name, test.pattern, got, test.want)
+		}
+	}
+}
+
+// wsClient is a client of the graphql-transport-ws protocol.
+type wsClient struct {
+	t  *testing.T
+	ws *websocket.Conn
+}
+
+func dialWebSocket(t *testing.T) *wsClient {
+	t.Helper()
+	s := httptest.NewServer(WebSocketHandler)
+	t.Cleanup(s.Close)
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(s.URL, "http"), s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Protocol = []string{webSocketProtocol}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	c := &wsClient{t: t, ws: ws}
+	c.send(`{"type": "connection_init"}`)
+	c.expect(`{"type": "connection_ack"}`)
+	return c
+}
+
+func TestWebSocketOrigins(t *testing.T) {
+	s := httptest.NewServer(WebSocketHandler)
+	defer s.Close()
+	dial := func(origin string) error {
+		config, err := websocket.NewConfig("ws"+strings.TrimPrefix(s.URL, "http"), origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Protocol = []string{webSocketProtocol}
+		ws, err := websocket.DialConfig(config)
+		if err == nil {
+			ws.Close()
+		}
+		return err
+	}
+	if err := dial(s.URL); err != nil {
+		t.Errorf("connection from the same origin failed: %s", err)
+	}
+	if err := dial("https://other.example.com"); err == nil {
+		t.Errorf("connection from another origin succeeded")
+	}
+	AllowedOrigins = []string{"https://other.example.com"}
+	defer func() { AllowedOrigins = nil }()
+	if err := dial("https://other.example.com"); err != nil {
+		t.Errorf("connection from an allowed origin failed: %s", err)
+	}
+}
+
+func (c *wsClient) send(message string) {
+	c.t.Helper()
+	if err := websocket.Message.Send(c.ws, message); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsClient) expect(message string) {
+	c.t.Helper()
+	_ = c.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var got, want interface{}
+	if err := websocket.JSON.Receive(c.ws, &got); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(message), &want); err != nil {
+		c.t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		c.t.Errorf("unexpected message (-want +got):\n%s", diff)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	grpctest.SetupRegistry(context.Background(), t, "graphql-subscriptions", []seeder.RegistryResource{
+		&rpc.Api{Name: "projects/graphql-subscriptions/locations/global/apis/a"},
+	})
+	source := NewMemorySource()
+	Notifications = source
+	defer func() { Notifications = nil }()
+
+	c := dialWebSocket(t)
+	c.send(`{"id": "1", "type": "subscribe", "payload": {
+	  "query": "subscription { resourceChanged(pattern: \"projects/graphql-subscriptions/locations/global/apis/-\") { change resource change_time { seconds } } }"
+	}}`)
+	// Wait for the subscription to start.
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		source.mu.Lock()
+		n := len(source.subscribers)
+		source.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("subscription was not started")
+		}
+	}
+	source.Publish(&rpc.Notification{
+		Change:   rpc.Notification_UPDATED,
+		Resource: "projects/graphql-subscriptions/locations/global/apis/a/versions/v1",
+	})
+	// Notifications about resources that the caller can't read aren't sent.
+	source.Publish(&rpc.Notification{
+		Change:   rpc.Notification_CREATED,
+		Resource: "projects/graphql-subscriptions/locations/global/apis/missing",
+	})
+	source.Publish(&rpc.Notification{
+		Change:     rpc.Notification_CREATED,
+		Resource:   "projects/graphql-subscriptions/locations/global/apis/a",
+		ChangeTime: &timestamppb.Timestamp{Seconds: 100},
+	})
+	c.expect(`{"id": "1", "type": "next", "payload": {"data": {"resourceChanged": {
+	  "change": "CREATED", "resource": "projects/graphql-subscriptions/locations/global/apis/a", "change_time": {"seconds": 100}
+	}}}}`)
+	// Deletions are sent if the caller can list the parent of the resource.
+	source.Publish(&rpc.Notification{
+		Change:     rpc.Notification_DELETED,
+		Resource:   "projects/graphql-subscriptions/locations/global/apis/b",
+		ChangeTime: &timestamppb.Timestamp{Seconds: 200},
+	})
+	source.Publish(&rpc.Notification{
+		Change:   rpc.Notification_DELETED,
+		Resource: "projects/graphql-unknown/locations/global/apis/b",
+	})
+	c.expect(`{"id": "1", "type": "next", "payload": {"data": {"resourceChanged": {
+	  "change": "DELETED", "resource": "projects/graphql-subscriptions/locations/global/apis/b", "change_time": {"seconds": 200}
+	}}}}`)
+
+	// Queries can also be sent over WebSocket connections.
+	c.send(`{"id": "2", "type": "subscribe", "payload": {"query": "{ __typename }"}}`)
+	c.expect(`{"id": "2", "type": "next", "payload": {"data": {"__typename": "Query"}}}`)
+	c.expect(`{"id": "2", "type": "complete"}`)
+
+	c.send(`{"type": "ping"}`)
+	c.expect(`{"type": "pong"}`)
+
+	// Completed subscriptions are ended.
+	c.send(`{"id": "1", "type": "complete"}`)
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		source.mu.Lock()
+		n := len(source.subscribers)
+		source.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("subscription was not ended")
+		}
+	}
+}
+
+func TestReadableIsShared(t *testing.T) {
+	ctx := context.Background()
+	grpctest.SetupRegistry(ctx, t, "graphql-readable", []seeder.RegistryResource{
+		&rpc.Api{Name: "projects/graphql-readable/locations/global/apis/a"},
+	})
+	n := &rpc.Notification{
+		Change:   rpc.Notification_UPDATED,
+		Resource: "projects/graphql-readable/locations/global/apis/a",
+	}
+	misses := cacheRequests.WithLabelValues("readability", "miss")
+	before := testutil.ToFloat64(misses)
+
+	// Subscribers with the same credentials share one check.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !readable(ctx, n) {
+				t.Error("readable() returned false for an existing resource")
+			}
+		}()
+	}
+	wg.Wait()
+	if got := testutil.ToFloat64(misses) - before; got != 1 {
+		t.Errorf("10 subscribers made %.0f checks, want 1", got)
+	}
+
+	// Other credentials are checked separately.
+	readable(WithBearerToken(ctx, "other"), n)
+	if got := testutil.ToFloat64(misses) - before; got != 2 {
+		t.Errorf("subscribers with two tokens made %.0f checks, want 2", got)
+	}
+}
+
+func TestSubscriptionErrors(t *testing.T) {
+	c := dialWebSocket(t)
+	c.send(`{"id": "1", "type": "subscribe", "payload": {"query": "subscription { resourceChanged { resource } }"}}`)
+	c.expect(`{"id": "1", "type": "error", "payload": [{"message": "subscriptions are not enabled", "locations": []}]}`)
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"sort"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Labels and annotations are represented as lists of key/value pairs.
+var keyValueType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "KeyValue",
+		Fields: graphql.Fields{
+			"key": &graphql.Field{
+				Type: graphql.String,
+			},
+			"value": &graphql.Field{
+				Type: graphql.String,
+			},
+		},
+	},
+)
+
+func representationForMap(m map[string]string) []map[string]interface{} {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]map[string]interface{}, len(keys))
+	for i, k := range keys {
+		pairs[i] = map[string]interface{}{
+			"key":   k,
+			"value": m[k],
+		}
+	}
+	return pairs
+}
+
+// jsonType is a scalar for values that are returned as JSON, such as
+// decoded artifact contents.
+var jsonType = graphql.NewScalar(
+	graphql.ScalarConfig{
+		Name:        "JSON",
+		Description: "A JSON value.",
+		Serialize: func(value interface{}) interface{} {
+			return value
+		},
+		ParseValue: func(value interface{}) interface{} {
+			return value
+		},
+		ParseLiteral: func(valueAST ast.Value) interface{} {
+			return valueAST.GetValue()
+		},
+	},
+)
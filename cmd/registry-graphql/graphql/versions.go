@@ -15,9 +15,9 @@
 package graphql
 
 import (
+	"context"
 	"errors"
 
-	"github.com/apigee/registry/pkg/connection"
 	"github.com/apigee/registry/rpc"
 	"github.com/graphql-go/graphql"
 )
@@ -35,6 +35,18 @@ var versionType = graphql.NewObject(
 			"description": &graphql.Field{
 				Type: graphql.String,
 			},
+			"state": &graphql.Field{
+				Type: graphql.String,
+			},
+			"primary_spec": &graphql.Field{
+				Type: graphql.String,
+			},
+			"labels": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
+			"annotations": &graphql.Field{
+				Type: graphql.NewList(keyValueType),
+			},
 			"specs": &graphql.Field{
 				Type:    connectionType(specType),
 				Args:    argumentsForCollectionQuery,
@@ -61,64 +73,59 @@ func representationForVersion(version *rpc.ApiVersion) map[string]interface{} {
 		"display_name": version.DisplayName,
 		"description":  version.Description,
 		"state":        version.State,
+		"primary_spec": version.PrimarySpec,
+		"labels":       representationForMap(version.Labels),
+		"annotations":  representationForMap(version.Annotations),
 		"created":      representationForTimestamp(version.CreateTime),
 		"updated":      representationForTimestamp(version.UpdateTime),
 	}
 }
-func resolveVersions(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewRegistryClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-	req := &rpc.ListApiVersionsRequest{
-		Parent: getParentFromParams(p),
-	}
-	filter, isFound := p.Args["filter"].(string)
-	if isFound {
-		req.Filter = filter
-	}
-	pageToken, isFound := p.Args["after"].(string)
-	if isFound {
-		req.PageToken = pageToken
-	}
-	pageSize, isFound := p.Args["first"].(int)
-	if isFound {
-		req.PageSize = int32(pageSize)
-	} else {
-		pageSize = 50
-	}
-	var response *rpc.ListApiVersionsResponse
-	edges := []map[string]interface{}{}
-	for len(edges) < pageSize {
-		response, _ = c.GrpcClient().ListApiVersions(ctx, req)
-		for _, version := range response.GetApiVersions() {
-			edges = append(edges, representationForEdge(representationForVersion(version)))
+
+var versionCollection = &collection{
+	kind: "versions",
+	get: func(ctx context.Context, name string) (map[string]interface{}, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, err
 		}
-		req.PageToken = response.GetNextPageToken()
-		if req.PageToken == "" {
-			break
+		version, err := c.GetApiVersion(ctx, &rpc.GetApiVersionRequest{
+			Name: name,
+		})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return connectionForEdgesAndEndCursor(edges, response.GetNextPageToken()), nil
+		return representationForVersion(version), nil
+	},
+	list: func(ctx context.Context, req listRequest) ([]map[string]interface{}, string, error) {
+		c, err := registryClient(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		response, err := c.GrpcClient().ListApiVersions(ctx, &rpc.ListApiVersionsRequest{
+			Parent:    req.Parent,
+			Filter:    req.Filter,
+			PageToken: req.PageToken,
+			PageSize:  req.PageSize,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		var items []map[string]interface{}
+		for _, version := range response.GetApiVersions() {
+			items = append(items, representationForVersion(version))
+		}
+		return items, response.GetNextPageToken(), nil
+	},
+}
+
+func resolveVersions(p graphql.ResolveParams) (interface{}, error) {
+	return loaderFor(p.Context).list(p.Context, versionCollection, getParentFromParams(p), p.Args), nil
 }
 
 func resolveVersion(p graphql.ResolveParams) (interface{}, error) {
-	ctx := p.Context
-	c, err := connection.NewRegistryClient(ctx)
-	if err != nil {
-		return nil, err
-	}
 	name, isFound := p.Args["id"].(string)
 	if !isFound {
 		return nil, errors.New("missing id field")
 	}
-	req := &rpc.GetApiVersionRequest{
-		Name: name,
-	}
-	version, err := c.GetApiVersion(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return representationForVersion(version), err
+	return loaderFor(p.Context).get(p.Context, versionCollection, name), nil
 }
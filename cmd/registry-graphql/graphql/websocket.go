@@ -0,0 +1,261 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"golang.org/x/net/websocket"
+)
+
+// webSocketProtocol is the subprotocol of the graphql-ws library:
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const webSocketProtocol = "graphql-transport-ws"
+
+// initTimeout is how long clients have to initialize their connections.
+const initTimeout = 10 * time.Second
+
+// WebSocketHandler serves GraphQL operations, including subscriptions, over
+// WebSocket connections. Bearer tokens can be sent in the Authorization
+// header of the upgrade request or in the "Authorization" field of the
+// payload of the connection_init message, and are used as WithAuthorization
+// describes. Connections can only be opened from the origins that
+// originAllowed accepts.
+var WebSocketHandler http.Handler = websocket.Server{
+	Handshake: func(config *websocket.Config, r *http.Request) error {
+		var err error
+		config.Origin, err = websocket.Origin(config, r)
+		if err != nil {
+			return err
+		}
+		if !originAllowed(config.Origin, r) {
+			return errors.New("origin not allowed")
+		}
+		for _, p := range config.Protocol {
+			if p == webSocketProtocol {
+				config.Protocol = []string{webSocketProtocol}
+				return nil
+			}
+		}
+		return errors.New("unsupported subprotocol")
+	},
+	Handler: serveWebSocket,
+}
+
+// AllowedOrigins are the origins of other sites whose pages can open
+// WebSocket connections. "*" allows all origins.
+var AllowedOrigins []string
+
+// originAllowed returns true if a WebSocket connection can be opened from an
+// origin. Browsers can open WebSocket connections to any site with the
+// cookies and credentials of that site, so pages of other origins are only
+// allowed if they are listed in AllowedOrigins. Pages of this server and
+// clients that aren't browsers, which don't send origins, are allowed.
+func originAllowed(origin *url.URL, r *http.Request) bool {
+	if origin == nil || origin.Host == r.Host {
+		return true
+	}
+	for _, o := range AllowedOrigins {
+		if o == "*" || o == origin.String() {
+			return true
+		}
+	}
+	return false
+}
+
+type operationMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type operationPayload struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// A webSocketConn sends messages from concurrent operations.
+type webSocketConn struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *webSocketConn) send(id, messageType string, payload interface{}) {
+	m := map[string]interface{}{"type": messageType}
+	if id != "" {
+		m["id"] = id
+	}
+	if payload != nil {
+		m["payload"] = payload
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = websocket.JSON.Send(c.ws, m)
+}
+
+// close closes the connection with a status code of the protocol.
+func (c *webSocketConn) close(code uint16, reason string) {
+	frame := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(frame, code)
+	frame = append(frame, reason...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ws.PayloadType = websocket.CloseFrame
+	_, _ = c.ws.Write(frame)
+}
+
+func serveWebSocket(ws *websocket.Conn) {
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+	c := &webSocketConn{ws: ws}
+	var wg sync.WaitGroup
+	operations := make(map[string]context.CancelFunc)
+	var mu sync.Mutex // guards operations
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	initialized := false
+	_ = ws.SetReadDeadline(time.Now().Add(initTimeout))
+	for {
+		var m operationMessage
+		if err := websocket.JSON.Receive(ws, &m); err != nil {
+			var netErr net.Error
+			if !initialized && errors.As(err, &netErr) && netErr.Timeout() {
+				c.close(4408, "Connection initialisation timeout")
+			}
+			return
+		}
+		switch m.Type {
+		case "connection_init":
+			if initialized {
+				c.close(4429, "Too many initialisation requests")
+				return
+			}
+			auth := ws.Request().Header.Get("Authorization")
+			var payload map[string]interface{}
+			_ = json.Unmarshal(m.Payload, &payload)
+			for k, v := range payload {
+				if s, ok := v.(string); ok && strings.EqualFold(k, "Authorization") {
+					auth = s
+				}
+			}
+			var err error
+			if ctx, err = WithAuthorization(ctx, auth); err != nil {
+				c.close(4403, "Forbidden")
+				return
+			}
+			initialized = true
+			_ = ws.SetReadDeadline(time.Time{})
+			c.send("", "connection_ack", nil)
+		case "ping":
+			c.send("", "pong", nil)
+		case "pong":
+		case "subscribe":
+			if !initialized {
+				c.close(4401, "Unauthorized")
+				return
+			}
+			var payload operationPayload
+			if err := json.Unmarshal(m.Payload, &payload); err != nil || m.ID == "" {
+				c.close(4400, "Invalid message")
+				return
+			}
+			mu.Lock()
+			if _, ok := operations[m.ID]; ok {
+				mu.Unlock()
+				c.close(4409, "Subscriber for "+m.ID+" already exists")
+				return
+			}
+			opCtx, opCancel := context.WithCancel(ctx)
+			operations[m.ID] = opCancel
+			mu.Unlock()
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				completed := runOperation(opCtx, c, id, payload)
+				mu.Lock()
+				_, active := operations[id]
+				delete(operations, id)
+				mu.Unlock()
+				opCancel()
+				if completed && active {
+					c.send(id, "complete", nil)
+				}
+			}(m.ID)
+		case "complete":
+			mu.Lock()
+			if opCancel, ok := operations[m.ID]; ok {
+				delete(operations, m.ID)
+				opCancel()
+			}
+			mu.Unlock()
+		default:
+			c.close(4400, "Invalid message type "+m.Type)
+			return
+		}
+	}
+}
+
+// runOperation sends the results of an operation and reports whether they
+// should be followed by a complete message. Operations that fail before
+// they are executed are reported with a single error message.
+func runOperation(ctx context.Context, c *webSocketConn, id string, payload operationPayload) bool {
+	params := graphql.Params{
+		Schema:         Schema,
+		RequestString:  payload.Query,
+		VariableValues: payload.Variables,
+		OperationName:  payload.OperationName,
+	}
+	if operationType(payload.Query, payload.OperationName) != ast.OperationTypeSubscription {
+		params.Context = WithLoader(ctx)
+		result := execute(params)
+		if result.Data == nil && len(result.Errors) > 0 {
+			c.send(id, "error", result.Errors)
+			return false
+		}
+		c.send(id, "next", result)
+		return true
+	}
+	params.Context = ctx
+	results := graphql.Subscribe(params)
+	first := true
+	for result := range results {
+		if first && result.Data == nil && len(result.Errors) > 0 {
+			c.send(id, "error", result.Errors)
+			// Drain the channel so that its sender can finish.
+			for range results {
+			}
+			return false
+		}
+		first = false
+		if ctx.Err() == nil {
+			c.send(id, "next", result)
+		}
+	}
+	return ctx.Err() == nil
+}
@@ -15,25 +15,55 @@
 package main
 
 import (
-	"flag"
-	"fmt"
+	"bufio"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/apigee/registry-experimental/cmd/registry-graphql/graphql"
-	"github.com/graphql-go/handler"
+	"github.com/apigee/registry-experimental/gapic"
+	"github.com/apigee/registry/pkg/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
-var corsAllowOriginFlag *string
+// static files for the Graphiql in-browser editor
+//
+//go:embed static
+var static embed.FS
 
+// corsProxy allows CORS requests from a list of origins.
 type corsProxy struct {
-	h http.Handler
+	h       http.Handler
+	origins []string
+}
+
+func (p *corsProxy) allowed(origin string) bool {
+	for _, o := range p.origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *corsProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if *corsAllowOriginFlag != "" {
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Add("Vary", "Origin")
+	if origin := r.Header.Get("Origin"); origin != "" && p.allowed(origin) {
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Origin", *corsAllowOriginFlag)
+		w.Header().Set("Access-Control-Allow-Origin", origin)
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte(""))
@@ -43,24 +73,209 @@ func (p *corsProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.h.ServeHTTP(w, r)
 }
 
-func main() {
-	// allow all CORS requests (not for production use)
-	corsAllowOriginFlag = flag.String("cors-allow-origin", "", "allow all CORS requests from the specified origin")
-	flag.Parse()
+// contextProxy passes the bearer token of each request to the registry, so
+// that registry authorization applies to the caller. WebSocket upgrade
+// requests are sent to the WebSocket handler, which checks their origins and
+// gets bearer tokens from the first message of each connection.
+type contextProxy struct {
+	h http.Handler
+}
+
+func (p *contextProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		graphql.WebSocketHandler.ServeHTTP(w, r)
+		return
+	}
+	ctx, err := graphql.WithAuthorization(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": []map[string]interface{}{{
+				"message":    "a bearer token is required",
+				"extensions": map[string]interface{}{"code": "UNAUTHENTICATED"},
+			}},
+		})
+		return
+	}
+	p.h.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// logProxy logs requests and the status codes of their responses.
+type logProxy struct {
+	h      http.Handler
+	logger log.Logger
+}
+
+func (p *logProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	p.h.ServeHTTP(rw, r)
+	p.logger.WithFields(map[string]interface{}{
+		"method":   r.Method,
+		"path":     r.URL.Path,
+		"remote":   r.RemoteAddr,
+		"status":   rw.status,
+		"duration": time.Since(start).String(),
+	}).Info("Handled request.")
+}
+
+// responseRecorder records the status code of a response. It can be
+// hijacked for WebSocket connections.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response can't be hijacked")
+	}
+	rw.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (rw *responseRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// newHandler returns the handler of all requests.
+func newHandler(config ServerConfig, logger log.Logger) http.Handler {
+	mux := http.NewServeMux()
 
 	// graphql handler
-	h := handler.New(&handler.Config{
-		Schema: &graphql.Schema,
-		Pretty: true,
-	})
-	http.Handle("/graphql", &corsProxy{h: h})
+	mux.Handle("/graphql", &corsProxy{h: &contextProxy{h: graphql.Handler}, origins: config.CORSAllowedOrigins})
 
 	// static file server for Graphiql in-browser editor
-	fs := http.FileServer(http.Dir("static"))
-	http.Handle("/", fs)
+	files, _ := fs.Sub(static, "static")
+	mux.Handle("/", http.FileServer(http.FS(files)))
+
+	if !config.Logging.Requests {
+		return mux
+	}
+	return &logProxy{h: mux, logger: logger}
+}
+
+func main() {
+	// Use a default logger configuration until we load the server config.
+	bootLogger := log.NewLogger()
+	config, err := loadConfig(os.Args[1:])
+	if err != nil {
+		bootLogger.WithError(err).Fatal("Invalid configuration")
+	}
+	logger := log.NewLogger(loggerOptions(config.Logging)...)
 
-	// run the server
-	port := "8088"
-	fmt.Println("Running server on port " + port)
-	_ = http.ListenAndServe(":"+port, nil)
+	graphql.MaxDepth = config.Limits.MaxDepth
+	graphql.MaxComplexity = config.Limits.MaxComplexity
+	graphql.ForwardCredentials = config.Auth.Forward
+	graphql.RequireCredentials = config.Auth.Require
+	graphql.AllowedOrigins = config.CORSAllowedOrigins
+	if c := config.Cache.PersistedQueries; c.Enabled {
+		graphql.PersistedQueries = graphql.NewQueryStore(c.MaxEntries, c.TTL)
+	}
+	if c := config.Cache.Responses; c.Enabled {
+		graphql.Cache = graphql.NewResponseCache(c.MaxEntries, c.TTL)
+		if config.Pubsub.Project == "" {
+			logger.Warnf("Cached responses won't include changes made by other clients until they expire after %s; set pubsub.project to invalidate them", c.TTL)
+		}
+	}
+
+	// Requests are canceled when the server stops, after a grace period.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	baseCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// registry notifications for subscriptions
+	if config.Pubsub.Project != "" {
+		source, err := graphql.NewPubSubSource(ctx, config.Pubsub.Project, config.Pubsub.Subscription)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to subscribe to notifications")
+		}
+		graphql.Notifications = source
+		go func() {
+			if err := source.Receive(ctx); err != nil {
+				logger.WithError(err).Error("Failed to receive notifications")
+			}
+		}()
+		// Changes invalidate cached results.
+		if graphql.Cache != nil {
+			go func() {
+				if err := graphql.Cache.Watch(ctx, source); err != nil {
+					logger.WithError(err).Error("Failed to watch notifications")
+				}
+			}()
+		}
+	}
+
+	// Prometheus metrics, including cache hits and misses
+	if config.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			logger.Infof("Serving metrics on %s", config.MetricsAddress)
+			server := &http.Server{Addr: config.MetricsAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+			if err := server.ListenAndServe(); err != nil {
+				logger.WithError(err).Error("Failed to serve metrics")
+			}
+		}()
+	}
+
+	// search service for search queries
+	if config.Search.Address != "" {
+		opts := []option.ClientOption{option.WithEndpoint(config.Search.Address)}
+		if config.Search.Insecure {
+			conn, err := grpc.Dial(config.Search.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				logger.WithError(err).Fatal("Failed to connect to search service")
+			}
+			opts = append(opts, option.WithGRPCConn(conn))
+		}
+		client, err := gapic.NewSearchClient(ctx, opts...)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to connect to search service")
+		}
+		graphql.SearchService = client.GrpcClient()
+	}
+
+	server := &http.Server{
+		Addr:              config.Address,
+		Handler:           newHandler(config, logger),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+	go func() {
+		var err error
+		if config.TLS.CertFile != "" {
+			logger.Infof("Listening on %s with TLS", config.Address)
+			err = server.ListenAndServeTLS(config.TLS.CertFile, config.TLS.KeyFile)
+		} else {
+			logger.Infof("Listening on %s", config.Address)
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Fatal("Failed to serve")
+		}
+	}()
+
+	// Wait for an interruption signal.
+	<-ctx.Done()
+	logger.Info("Shutting down.")
+	shutdownCtx, cancelShutdown := context.WithTimeout(baseCtx, config.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.WithError(err).Error("Failed to shut down gracefully")
+	}
+	// Hijacked WebSocket connections aren't tracked by the server, so they
+	// are closed by canceling their contexts.
+	cancel()
 }
@@ -0,0 +1,47 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package artifacttypes registers the message types of common artifacts
+// with the global proto registry.
+package artifacttypes
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	// These packages are imported to register the message types of
+	// common artifacts; other linked-in types are also resolved.
+	_ "github.com/apigee/registry-experimental/rpc"
+	_ "github.com/apigee/registry/pkg/application/apihub"
+	_ "github.com/apigee/registry/pkg/application/controller"
+	_ "github.com/apigee/registry/pkg/application/scoring"
+	_ "github.com/apigee/registry/pkg/application/style"
+	_ "github.com/google/gnostic/metrics"
+	_ "github.com/google/gnostic/openapiv2"
+	_ "github.com/google/gnostic/openapiv3"
+	_ "google.golang.org/protobuf/types/descriptorpb"
+)
+
+// NewMessage returns an empty message of the named type.
+// Types are resolved with the global proto registry.
+func NewMessage(messageType string) (proto.Message, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported message type: %s", messageType)
+	}
+	return mt.New().Interface(), nil
+}